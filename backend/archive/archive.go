@@ -0,0 +1,485 @@
+// Package archive implements a read-only backend which presents zip
+// and tar archives on another remote as directories.
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/hash"
+	libarchive "github.com/rclone/rclone/lib/archive"
+	libcache "github.com/rclone/rclone/lib/cache"
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "archive",
+		Description: "Read archives (zip, tar, tar.gz, tar.bz2) as directories",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name:     "remote",
+			Help:     "Remote containing the archives (e.g. myRemote:bucket).",
+			Required: true,
+		}},
+	})
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Remote string `config:"remote"`
+}
+
+// errorReadOnly is returned for all operations which modify the remote
+var errorReadOnly = errors.New("archive remote is read only")
+
+// Fs represents a remote containing archives
+type Fs struct {
+	name     string
+	root     string
+	opt      Options
+	base     fs.Fs           // the remote being wrapped
+	prefix   string          // path of the root in base
+	features *fs.Features    // optional features
+	indexes  *libcache.Cache // cache of archive indexes
+}
+
+// NewFs constructs an Fs from the path, container:path
+func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, error) {
+	var opt Options
+	err := configstruct.Set(m, &opt)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(opt.Remote, name+":") {
+		return nil, errors.New("can't point archive remote at itself - check the value of the remote setting")
+	}
+	// The base is created without root so that paths inside
+	// archives don't get passed to it
+	base, err := cache.Get(ctx, opt.Remote)
+	var prefix string
+	if err == fs.ErrorIsFile {
+		// remote points at an archive so the base is its parent
+		_, prefix, err = fspath.Split(opt.Remote)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to make remote %q to wrap: %w", opt.Remote, err)
+	}
+	root = strings.Trim(root, "/")
+	f := &Fs{
+		name:    name,
+		root:    root,
+		opt:     opt,
+		base:    base,
+		prefix:  prefix,
+		indexes: libcache.New(),
+	}
+	f.features = (&fs.Features{
+		CanHaveEmptyDirectories: true,
+	}).Fill(ctx, f)
+	cache.PinUntilFinalized(f.base, f)
+
+	// Check to see if the root points to a file
+	if f.root != "" {
+		_, err := f.NewObject(ctx, "")
+		if err == nil {
+			f.root = path.Dir(f.root)
+			if f.root == "." {
+				f.root = ""
+			}
+			return f, fs.ErrorIsFile
+		}
+	}
+	return f, nil
+}
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("Archive '%s:%s'", f.name, f.root)
+}
+
+// Precision of the ModTimes in this Fs
+func (f *Fs) Precision() time.Duration {
+	// tar archives store times to the second
+	precision := f.base.Precision()
+	if precision < time.Second {
+		precision = time.Second
+	}
+	return precision
+}
+
+// Hashes returns the supported hash types of the filesystem
+//
+// zip archives store a CRC-32 for each file and this is the only hash
+// which can be read for the files inside the archives.
+func (f *Fs) Hashes() hash.Set {
+	return hash.Set(hash.CRC32)
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// UnWrap returns the Fs that this Fs is wrapping
+func (f *Fs) UnWrap() fs.Fs {
+	return f.base
+}
+
+// fullPath returns the path of remote in the base
+func (f *Fs) fullPath(remote string) string {
+	return path.Join(f.prefix, f.root, remote)
+}
+
+// relPath returns the path in f of the base path p
+func (f *Fs) relPath(p string) string {
+	root := path.Join(f.prefix, f.root)
+	if root == "" {
+		return p
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+}
+
+// split finds the archive in the base path p returning it and the
+// path inside it. If there is no archive then o will be nil.
+func (f *Fs) split(ctx context.Context, p string) (o fs.Object, inner string, err error) {
+	if p == "" {
+		return nil, "", nil
+	}
+	elements := strings.Split(p, "/")
+	for i := range elements {
+		if libarchive.FormatOf(elements[i]) == libarchive.FormatNone {
+			continue
+		}
+		o, err = f.base.NewObject(ctx, path.Join(elements[:i+1]...))
+		switch err {
+		case nil:
+			return o, path.Join(elements[i+1:]...), nil
+		case fs.ErrorObjectNotFound, fs.ErrorIsDir, fs.ErrorNotAFile:
+			// not an archive so keep looking
+		default:
+			return nil, "", err
+		}
+	}
+	return nil, p, nil
+}
+
+// getIndex reads the index of the archive o using the cached copy if
+// the archive hasn't changed
+func (f *Fs) getIndex(ctx context.Context, o fs.Object) (*index, error) {
+	key := fmt.Sprintf("%s\x00%d\x00%d", o.Remote(), o.Size(), o.ModTime(ctx).UnixNano())
+	value, err := f.indexes.Get(key, func(key string) (interface{}, bool, error) {
+		fs.Debugf(f, "Reading index of %q", o.Remote())
+		idx, err := readIndex(ctx, o, libarchive.FormatOf(o.Remote()))
+		return idx, true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*index), nil
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	o, inner, err := f.split(ctx, f.fullPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return f.listBase(ctx, dir)
+	}
+	idx, err := f.getIndex(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	e := idx.entries[inner]
+	if e == nil || !e.isDir {
+		return nil, fs.ErrorDirNotFound
+	}
+	for _, child := range idx.children[inner] {
+		remote := path.Join(dir, path.Base(child.name))
+		if child.isDir {
+			entries = append(entries, fs.NewDir(remote, child.modTime))
+		} else {
+			entries = append(entries, f.newEntry(remote, idx, child))
+		}
+	}
+	return entries, nil
+}
+
+// listBase lists dir in the base remote showing any archives as
+// directories
+func (f *Fs) listBase(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	baseEntries, err := f.base.List(ctx, f.fullPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	for _, baseEntry := range baseEntries {
+		remote := f.relPath(baseEntry.Remote())
+		switch x := baseEntry.(type) {
+		case fs.Directory:
+			entries = append(entries, fs.NewDirCopy(ctx, x).SetRemote(remote))
+		case fs.Object:
+			if libarchive.FormatOf(remote) != libarchive.FormatNone {
+				entries = append(entries, fs.NewDir(remote, x.ModTime(ctx)))
+			} else {
+				entries = append(entries, f.newObject(x))
+			}
+		default:
+			return nil, fmt.Errorf("unknown object type %T", baseEntry)
+		}
+	}
+	return entries, nil
+}
+
+// NewObject finds the Object at remote.  If it can't be found
+// it returns the error ErrorObjectNotFound.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	o, inner, err := f.split(ctx, f.fullPath(remote))
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		o, err := f.base.NewObject(ctx, f.fullPath(remote))
+		if err != nil {
+			return nil, err
+		}
+		return f.newObject(o), nil
+	}
+	if inner == "" {
+		// archives are directories
+		return nil, fs.ErrorObjectNotFound
+	}
+	idx, err := f.getIndex(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	e := idx.entries[inner]
+	if e == nil || e.isDir {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return f.newEntry(remote, idx, e), nil
+}
+
+// Put in to the remote path with the modTime given of the given size
+//
+// The archive remote is read only so this always fails.
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return nil, errorReadOnly
+}
+
+// Mkdir makes the directory (container, bucket)
+//
+// The archive remote is read only so this always fails.
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	return errorReadOnly
+}
+
+// Rmdir removes the directory (container, bucket) if empty
+//
+// The archive remote is read only so this always fails.
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	return errorReadOnly
+}
+
+// Object is an object in the wrapped remote which isn't an archive
+type Object struct {
+	fs.Object
+	f *Fs
+}
+
+// newObject wraps o from the base remote
+func (f *Fs) newObject(o fs.Object) *Object {
+	return &Object{
+		Object: o,
+		f:      f,
+	}
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Object) Fs() fs.Info {
+	return o.f
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.Remote()
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.f.relPath(o.Object.Remote())
+}
+
+// Hash returns the selected checksum of the file
+//
+// If the wrapped remote doesn't support CRC-32 then it returns an
+// empty string.
+func (o *Object) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	if ht != hash.CRC32 {
+		return "", hash.ErrUnsupported
+	}
+	if !o.f.base.Hashes().Contains(ht) {
+		return "", nil
+	}
+	return o.Object.Hash(ctx, ht)
+}
+
+// SetModTime sets the modification time of the file
+func (o *Object) SetModTime(ctx context.Context, t time.Time) error {
+	return errorReadOnly
+}
+
+// Update in to the object with the modTime given of the given size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	return errorReadOnly
+}
+
+// Remove an object
+func (o *Object) Remove(ctx context.Context) error {
+	return errorReadOnly
+}
+
+// UnWrap returns the wrapped Object
+func (o *Object) UnWrap() fs.Object {
+	return o.Object
+}
+
+// Entry is a file inside an archive
+type Entry struct {
+	f      *Fs
+	remote string
+	idx    *index
+	e      *entry
+}
+
+// newEntry makes an Entry for e in the archive idx
+func (f *Fs) newEntry(remote string, idx *index, e *entry) *Entry {
+	return &Entry{
+		f:      f,
+		remote: remote,
+		idx:    idx,
+		e:      e,
+	}
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Entry) Fs() fs.Info {
+	return o.f
+}
+
+// Return a string version
+func (o *Entry) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Entry) Remote() string {
+	return o.remote
+}
+
+// ModTime returns the modification time of the file
+func (o *Entry) ModTime(ctx context.Context) time.Time {
+	return o.e.modTime
+}
+
+// Size returns the size of the file
+func (o *Entry) Size() int64 {
+	return o.e.size
+}
+
+// Hash returns the selected checksum of the file
+//
+// Only CRC-32 is supported and only for zip archives.
+func (o *Entry) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	if ht != hash.CRC32 {
+		return "", hash.ErrUnsupported
+	}
+	if !o.e.hasCRC {
+		return "", nil
+	}
+	return fmt.Sprintf("%08x", o.e.crc), nil
+}
+
+// Storable returns whether the object is storable
+func (o *Entry) Storable() bool {
+	return true
+}
+
+// SetModTime sets the modification time of the file
+func (o *Entry) SetModTime(ctx context.Context, t time.Time) error {
+	return errorReadOnly
+}
+
+// Open opens the file for read.  Call Close() on the returned io.ReadCloser
+func (o *Entry) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.Size())
+		default:
+			if option.Mandatory() {
+				fs.Logf(o, "Unsupported mandatory option: %v", option)
+			}
+		}
+	}
+	return o.idx.open(ctx, o.e, offset, limit)
+}
+
+// Update in to the object with the modTime given of the given size
+func (o *Entry) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	return errorReadOnly
+}
+
+// Remove an object
+func (o *Entry) Remove(ctx context.Context) error {
+	return errorReadOnly
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs              = (*Fs)(nil)
+	_ fs.UnWrapper       = (*Fs)(nil)
+	_ fs.Object          = (*Object)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+	_ fs.Object          = (*Entry)(nil)
+)
@@ -0,0 +1,266 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testTime  = time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	testNames = []string{"a.txt", "big.bin", "dir/sub/b.txt"}
+	testFiles = map[string]string{
+		"a.txt":         "hello",
+		"big.bin":       random.String(3 * 1024 * 1024),
+		"dir/sub/b.txt": "world!",
+	}
+)
+
+// makeZip makes a zip archive of the test files with the even ones
+// stored and the odd ones deflated
+func makeZip(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	_, err := zw.Create("empty/")
+	require.NoError(t, err)
+	for i, name := range testNames {
+		method := zip.Deflate
+		if i%2 == 0 {
+			method = zip.Store
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: testTime})
+		require.NoError(t, err)
+		_, err = io.WriteString(w, testFiles[name])
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// makeTar makes a tar archive of the test files, gzipped if required
+func makeTar(t *testing.T, gz bool) []byte {
+	var buf bytes.Buffer
+	var out io.Writer = &buf
+	var gw *gzip.Writer
+	if gz {
+		gw = gzip.NewWriter(&buf)
+		out = gw
+	}
+	tw := tar.NewWriter(out)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "empty/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: testTime}))
+	for _, name := range testNames {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(testFiles[name])),
+			ModTime:  testTime,
+		}))
+		_, err := io.WriteString(tw, testFiles[name])
+		require.NoError(t, err)
+	}
+	// links are ignored
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "a.txt", ModTime: testTime}))
+	require.NoError(t, tw.Close())
+	if gw != nil {
+		require.NoError(t, gw.Close())
+	}
+	return buf.Bytes()
+}
+
+// makeTestFs makes a local directory of archives and returns an
+// archive Fs wrapping it
+func makeTestFs(t *testing.T, root string) (fs.Fs, error) {
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"files/test.zip":    makeZip(t),
+		"files/test.tar":    makeTar(t, false),
+		"files/test.tar.gz": makeTar(t, true),
+		"files/plain.txt":   []byte("plain"),
+	} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0777))
+		require.NoError(t, os.WriteFile(name, data, 0666))
+	}
+	return NewFs(context.Background(), "TestArchive", root, configmap.Simple{"remote": dir})
+}
+
+func readObject(t *testing.T, o fs.Object, options ...fs.OpenOption) string {
+	in, err := o.Open(context.Background(), options...)
+	require.NoError(t, err)
+	data, err := io.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	return string(data)
+}
+
+func listNames(t *testing.T, f fs.Fs, dir string) (names []string) {
+	entries, err := f.List(context.Background(), dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		name := entry.Remote()
+		if _, isDir := entry.(fs.Directory); isDir {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestList(t *testing.T) {
+	f, err := makeTestFs(t, "files")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"plain.txt", "test.tar.gz/", "test.tar/", "test.zip/"}, listNames(t, f, ""))
+	for _, archive := range []string{"test.zip", "test.tar", "test.tar.gz"} {
+		t.Run(archive, func(t *testing.T) {
+			assert.Equal(t, []string{archive + "/a.txt", archive + "/big.bin", archive + "/dir/", archive + "/empty/"}, listNames(t, f, archive))
+			assert.Equal(t, []string{archive + "/dir/sub/"}, listNames(t, f, archive+"/dir"))
+			assert.Equal(t, []string{archive + "/dir/sub/b.txt"}, listNames(t, f, archive+"/dir/sub"))
+			assert.Empty(t, listNames(t, f, archive+"/empty"))
+
+			_, err := f.List(context.Background(), archive+"/notfound")
+			assert.Equal(t, fs.ErrorDirNotFound, err)
+			_, err = f.List(context.Background(), archive+"/a.txt")
+			assert.Equal(t, fs.ErrorDirNotFound, err)
+		})
+	}
+}
+
+func TestNewObject(t *testing.T) {
+	ctx := context.Background()
+	f, err := makeTestFs(t, "files")
+	require.NoError(t, err)
+
+	o, err := f.NewObject(ctx, "plain.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "plain.txt", o.Remote())
+	assert.Equal(t, "plain", readObject(t, o))
+
+	for _, archive := range []string{"test.zip", "test.tar", "test.tar.gz"} {
+		t.Run(archive, func(t *testing.T) {
+			for _, name := range testNames {
+				want := testFiles[name]
+				o, err := f.NewObject(ctx, archive+"/"+name)
+				require.NoError(t, err)
+				assert.Equal(t, archive+"/"+name, o.Remote())
+				assert.Equal(t, int64(len(want)), o.Size())
+				assert.True(t, testTime.Equal(o.ModTime(ctx)))
+				assert.Equal(t, want, readObject(t, o))
+				assert.Equal(t, want[1:3], readObject(t, o, &fs.RangeOption{Start: 1, End: 2}))
+				assert.Equal(t, want[2:], readObject(t, o, &fs.SeekOption{Offset: 2}))
+				assert.Equal(t, want[len(want)-2:], readObject(t, o, &fs.RangeOption{Start: -1, End: 2}))
+			}
+
+			// Directories and the archive itself aren't objects
+			for _, remote := range []string{"", "/dir", "/empty", "/notfound"} {
+				_, err = f.NewObject(ctx, archive+remote)
+				assert.Equal(t, fs.ErrorObjectNotFound, err, remote)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	ctx := context.Background()
+	f, err := makeTestFs(t, "files")
+	require.NoError(t, err)
+	assert.True(t, f.Hashes().Contains(hash.CRC32))
+
+	o, err := f.NewObject(ctx, "test.zip/a.txt")
+	require.NoError(t, err)
+	sum, err := o.Hash(ctx, hash.CRC32)
+	require.NoError(t, err)
+	assert.Equal(t, "3610a686", sum)
+	_, err = o.Hash(ctx, hash.MD5)
+	assert.Equal(t, hash.ErrUnsupported, err)
+
+	// tar archives don't have checksums
+	o, err = f.NewObject(ctx, "test.tar/a.txt")
+	require.NoError(t, err)
+	sum, err = o.Hash(ctx, hash.CRC32)
+	require.NoError(t, err)
+	assert.Equal(t, "", sum)
+}
+
+// TestCopy checks the files can be copied to a remote which supports
+// all the hashes, so the hashes are checked after the copy
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	f, err := makeTestFs(t, "files")
+	require.NoError(t, err)
+	fdst, err := fs.NewFs(ctx, t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, hash.Set(hash.CRC32), f.Hashes().Overlap(fdst.Hashes()))
+
+	remotes := []string{"plain.txt"}
+	for _, archive := range []string{"test.zip", "test.tar", "test.tar.gz"} {
+		for _, name := range testNames {
+			remotes = append(remotes, archive+"/"+name)
+		}
+	}
+	for _, remote := range remotes {
+		src, err := f.NewObject(ctx, remote)
+		require.NoError(t, err)
+		dst, err := operations.Copy(ctx, fdst, nil, remote, src)
+		require.NoError(t, err, remote)
+		assert.Equal(t, src.Size(), dst.Size(), remote)
+		assert.Equal(t, readObject(t, src), readObject(t, dst), remote)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	f, err := makeTestFs(t, "files")
+	require.NoError(t, err)
+
+	assert.Equal(t, errorReadOnly, f.Mkdir(ctx, "newdir"))
+	assert.Equal(t, errorReadOnly, f.Rmdir(ctx, "test.zip/empty"))
+	for _, remote := range []string{"plain.txt", "test.zip/a.txt"} {
+		o, err := f.NewObject(ctx, remote)
+		require.NoError(t, err)
+		assert.Equal(t, errorReadOnly, o.Remove(ctx))
+		assert.Equal(t, errorReadOnly, o.SetModTime(ctx, time.Now()))
+	}
+}
+
+func TestRoot(t *testing.T) {
+	ctx := context.Background()
+
+	// Root inside an archive
+	f, err := makeTestFs(t, "files/test.zip/dir")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sub/"}, listNames(t, f, ""))
+	o, err := f.NewObject(ctx, "sub/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "world!", readObject(t, o))
+
+	// Root pointing to a file inside an archive
+	f, err = makeTestFs(t, "files/test.tar.gz/dir/sub/b.txt")
+	assert.Equal(t, fs.ErrorIsFile, err)
+	assert.Equal(t, "files/test.tar.gz/dir/sub", f.Root())
+	assert.Equal(t, []string{"b.txt"}, listNames(t, f, ""))
+
+	// Root pointing to an archive is a directory
+	f, err = makeTestFs(t, "files/test.tar")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "big.bin", "dir/", "empty/"}, listNames(t, f, ""))
+}
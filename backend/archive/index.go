@@ -0,0 +1,422 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/chunkedreader"
+	libarchive "github.com/rclone/rclone/lib/archive"
+	"github.com/rclone/rclone/lib/readers"
+)
+
+// seekThreshold is the smallest skip in an uncompressed tar which will
+// be done with a new range request rather than by reading and
+// discarding the data
+const seekThreshold = 1024 * 1024
+
+// entry is a file or directory in an archive
+type entry struct {
+	name    string // path in the archive with no leading or trailing /
+	isDir   bool
+	size    int64
+	modTime time.Time
+	crc     uint32    // CRC-32 of the data if hasCRC is set
+	hasCRC  bool      // set if crc is valid
+	file    *zip.File // zip entry
+	offset  int64     // offset of the data in an uncompressed tar or -1 if unknown
+	seq     int       // number of the header in a tar
+}
+
+// index is the directory tree of an archive
+type index struct {
+	format   libarchive.Format
+	archive  fs.Object            // the archive the index was read from
+	modTime  time.Time            // modification time of the archive
+	entries  map[string]*entry    // all the entries by path
+	children map[string][]*entry  // the entries in each directory
+	ra       *libarchive.ReaderAt // for reading zip archives
+}
+
+// newIndex makes an empty index for the archive
+func newIndex(ctx context.Context, o fs.Object, fm libarchive.Format) *index {
+	idx := &index{
+		format:   fm,
+		archive:  o,
+		modTime:  o.ModTime(ctx),
+		entries:  make(map[string]*entry),
+		children: make(map[string][]*entry),
+	}
+	idx.entries[""] = &entry{isDir: true, modTime: idx.modTime}
+	return idx
+}
+
+// add an entry to the index, adding any missing parent directories.
+//
+// Directories which aren't in the archive get the modification time
+// of the archive.
+func (idx *index) add(e *entry) {
+	if e.modTime.IsZero() {
+		e.modTime = idx.modTime
+	}
+	if existing := idx.entries[e.name]; existing != nil {
+		// Later entries replace earlier ones
+		*existing = *e
+		return
+	}
+	idx.entries[e.name] = e
+	parent := path.Dir(e.name)
+	if parent == "." {
+		parent = ""
+	}
+	idx.children[parent] = append(idx.children[parent], e)
+	if idx.entries[parent] == nil {
+		idx.add(&entry{name: parent, isDir: true})
+	}
+}
+
+// sort the children of each directory by name
+func (idx *index) sort() {
+	for _, children := range idx.children {
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].name < children[j].name
+		})
+	}
+}
+
+// readIndex reads the directory tree of the archive o
+func readIndex(ctx context.Context, o fs.Object, fm libarchive.Format) (idx *index, err error) {
+	idx = newIndex(ctx, o, fm)
+	if fm == libarchive.FormatZip {
+		err = idx.readZip(ctx)
+	} else {
+		err = idx.readTar(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %q: %w", o.Remote(), err)
+	}
+	idx.sort()
+	return idx, nil
+}
+
+// readZip reads the central directory of a zip file using range
+// requests so only the end of the archive is read.
+func (idx *index) readZip(ctx context.Context) (err error) {
+	idx.ra = libarchive.NewReaderAt(idx.archive)
+	var zr *zip.Reader
+	err = idx.ra.Use(ctx, func() (err error) {
+		zr, err = zip.NewReader(idx.ra, idx.archive.Size())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, file := range zr.File {
+		name, ok := libarchive.CleanName(file.Name)
+		if !ok {
+			continue
+		}
+		isDir := strings.HasSuffix(file.Name, "/") || file.FileInfo().IsDir()
+		e := &entry{
+			name:    name,
+			isDir:   isDir,
+			modTime: file.Modified,
+			offset:  -1,
+		}
+		if !isDir {
+			e.file = file
+			e.size = int64(file.UncompressedSize64)
+			e.crc = file.CRC32
+			e.hasCRC = true
+		}
+		idx.add(e)
+	}
+	return nil
+}
+
+// isSparse returns true if hdr describes a sparse file
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// readTar reads all the headers in a tar file.
+//
+// If the tar is uncompressed then the file data is skipped with range
+// requests where possible, otherwise the whole archive has to be read.
+func (idx *index) readTar(ctx context.Context) (err error) {
+	cr := chunkedreader.New(ctx, idx.archive, libarchive.InitialChunkSize, libarchive.MaxChunkSize)
+	defer fs.CheckClose(cr, &err)
+	var (
+		in io.Reader
+		sr *seekReader
+	)
+	if idx.format == libarchive.FormatTar {
+		sr = newSeekReader(ctx, cr, idx.archive.Size())
+		in = sr
+	} else {
+		in, err = idx.format.Decompress(cr)
+		if err != nil {
+			return err
+		}
+	}
+	tr := tar.NewReader(in)
+	for seq := 1; ; seq++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		name, ok := libarchive.CleanName(hdr.Name)
+		if !ok {
+			continue
+		}
+		e := &entry{
+			name:    name,
+			modTime: hdr.ModTime,
+			offset:  -1,
+			seq:     seq,
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			e.isDir = true
+		case tar.TypeReg, tar.TypeGNUSparse:
+			e.size = hdr.Size
+			if sr != nil && !isSparse(hdr) {
+				e.offset = sr.pos
+			}
+		default:
+			// ignore links, devices etc
+			continue
+		}
+		idx.add(e)
+	}
+	return nil
+}
+
+// readCloser joins a reader and a closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// newEmptyReader returns a reader for zero length data
+func newEmptyReader() io.ReadCloser {
+	return io.NopCloser(strings.NewReader(""))
+}
+
+// openRange opens length bytes of the archive starting at offset
+func (idx *index) openRange(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	if length == 0 || offset >= idx.archive.Size() {
+		return newEmptyReader(), nil
+	}
+	cr := chunkedreader.New(ctx, idx.archive, libarchive.InitialChunkSize, libarchive.MaxChunkSize)
+	_, err := cr.RangeSeek(ctx, offset, io.SeekStart, length)
+	if err != nil {
+		_ = cr.Close()
+		return nil, err
+	}
+	return readers.NewLimitedReadCloser(cr, length), nil
+}
+
+// errCorrupted is returned if the CRC of an entry doesn't match
+var errCorrupted = errors.New("archive entry is corrupted: CRC-32 mismatch")
+
+// crcReader checks the CRC-32 of the data when it has all been read
+type crcReader struct {
+	io.ReadCloser
+	want uint32
+	crc  uint32
+	size int64 // expected size of the data
+	read int64 // bytes read so far
+}
+
+// Read data checking the CRC at the end
+func (r *crcReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.crc = crc32.Update(r.crc, crc32.IEEETable, p[:n])
+	r.read += int64(n)
+	if (err == io.EOF || r.read == r.size) && r.crc != r.want {
+		err = errCorrupted
+	}
+	return n, err
+}
+
+// open returns a reader for limit bytes of e starting at offset. If
+// limit is < 0 it reads to the end.
+func (idx *index) open(ctx context.Context, e *entry, offset, limit int64) (rc io.ReadCloser, err error) {
+	if offset >= e.size {
+		return newEmptyReader(), nil
+	}
+	if limit < 0 || offset+limit > e.size {
+		limit = e.size - offset
+	}
+	var seeked bool // set if rc starts at offset
+	switch {
+	case e.file != nil:
+		rc, seeked, err = idx.openZip(ctx, e, offset, limit)
+	case e.offset >= 0:
+		rc, err = idx.openRange(ctx, e.offset+offset, limit)
+		seeked = true
+	default:
+		rc, err = idx.openTarStream(ctx, e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.hasCRC && offset == 0 && limit == e.size {
+		rc = &crcReader{ReadCloser: rc, want: e.crc, size: e.size}
+	}
+	if !seeked && offset > 0 {
+		_, err = io.CopyN(io.Discard, rc, offset)
+		if err != nil {
+			_ = rc.Close()
+			return nil, err
+		}
+	}
+	return readers.NewLimitedReadCloser(rc, limit), nil
+}
+
+// openZip opens the zip entry e. If it is stored rather than
+// compressed it is opened at offset and seeked will be set.
+func (idx *index) openZip(ctx context.Context, e *entry, offset, limit int64) (rc io.ReadCloser, seeked bool, err error) {
+	if e.file.Flags&0x1 != 0 {
+		return nil, false, errors.New("encrypted zip entries aren't supported")
+	}
+	var dataOffset int64
+	err = idx.ra.Use(ctx, func() (err error) {
+		dataOffset, err = e.file.DataOffset()
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	switch e.file.Method {
+	case zip.Store:
+		rc, err = idx.openRange(ctx, dataOffset+offset, limit)
+		return rc, true, err
+	case zip.Deflate:
+		in, err := idx.openRange(ctx, dataOffset, int64(e.file.CompressedSize64))
+		if err != nil {
+			return nil, false, err
+		}
+		return readCloser{Reader: flate.NewReader(in), Closer: in}, false, nil
+	}
+	return nil, false, fmt.Errorf("unsupported zip compression method %d", e.file.Method)
+}
+
+// openTarStream opens the tar entry e by reading the archive from the
+// start until the entry is found.
+func (idx *index) openTarStream(ctx context.Context, e *entry) (rc io.ReadCloser, err error) {
+	cr := chunkedreader.New(ctx, idx.archive, libarchive.InitialChunkSize, libarchive.MaxChunkSize)
+	defer func() {
+		if err != nil {
+			_ = cr.Close()
+		}
+	}()
+	in, err := idx.format.Decompress(cr)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(in)
+	for seq := 1; seq <= e.seq; seq++ {
+		_, err = tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%q not found in archive", e.name)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return readCloser{Reader: tr, Closer: cr}, nil
+}
+
+// seekReader reads an object sequentially. It can Seek forwards
+// which archive/tar uses to skip file data.
+type seekReader struct {
+	ctx    context.Context
+	cr     *chunkedreader.ChunkedReader
+	size   int64
+	pos    int64 // position of the next read from cr
+	seekTo int64 // pending seek or -1 if none
+}
+
+// newSeekReader makes a seekReader reading from cr
+func newSeekReader(ctx context.Context, cr *chunkedreader.ChunkedReader, size int64) *seekReader {
+	return &seekReader{
+		ctx:    ctx,
+		cr:     cr,
+		size:   size,
+		seekTo: -1,
+	}
+}
+
+// Read reads data doing any pending seek first
+func (r *seekReader) Read(p []byte) (n int, err error) {
+	if r.seekTo >= 0 {
+		seekTo := r.seekTo
+		r.seekTo = -1
+		switch skip := seekTo - r.pos; {
+		case skip == 0:
+		case skip > 0 && skip < seekThreshold:
+			n, err := io.CopyN(io.Discard, r.cr, skip)
+			r.pos += n
+			if err != nil {
+				return 0, err
+			}
+		case seekTo >= r.size:
+			r.pos = seekTo
+			return 0, io.EOF
+		default:
+			_, err = r.cr.RangeSeek(r.ctx, seekTo, io.SeekStart, -1)
+			if err != nil {
+				return 0, err
+			}
+			r.pos = seekTo
+		}
+	}
+	n, err = r.cr.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+// Seek records the new position which is sought on the next Read
+func (r *seekReader) Seek(offset int64, whence int) (int64, error) {
+	current := r.pos
+	if r.seekTo >= 0 {
+		current = r.seekTo
+	}
+	var newPos int64
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = current + offset
+	case io.SeekEnd:
+		newPos = r.size + offset
+	}
+	if newPos < 0 {
+		return current, errors.New("seek before start of file")
+	}
+	if newPos != current {
+		r.seekTo = newPos
+	}
+	return newPos, nil
+}
@@ -9,6 +9,7 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -2063,6 +2064,135 @@ func (o *Object) uploadMultipart(ctx context.Context, in io.Reader, size int64,
 	return nil
 }
 
+// blockID returns the ID of the block for chunk number n
+//
+// This is the same as the IDs made by uploadMultipart
+func blockID(n int) string {
+	binaryBlockID := make([]byte, 8)
+	binary.LittleEndian.PutUint64(binaryBlockID, uint64(n)+1)
+	return base64.StdEncoding.EncodeToString(binaryBlockID)
+}
+
+// OpenChunkWriter returns the chunk size and a ChunkWriter
+//
+// Pass in the remote and the src object
+func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	// Temporary Object under construction
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	size := src.Size()
+	if size < 0 {
+		return info, nil, errors.New("can't use chunked upload with unknown size")
+	}
+	chunkSize := chunksize.Calculator(o, size, blockblob.MaxBlocks, f.opt.ChunkSize)
+	if chunkSize > fs.SizeSuffix(blockblob.MaxStageBlockBytes) {
+		return info, nil, fmt.Errorf("can't upload as it is too big %v - takes more than %d chunks of %v", fs.SizeSuffix(size), fs.SizeSuffix(blockblob.MaxBlocks), fs.SizeSuffix(blockblob.MaxStageBlockBytes))
+	}
+	blb, httpHeaders, err := o.prepareUpload(ctx, src, options)
+	if err != nil {
+		return info, nil, err
+	}
+	numChunks := int(fs.SizeSuffix(size) / chunkSize)
+	if fs.SizeSuffix(size)%chunkSize != 0 || numChunks == 0 {
+		numChunks++
+	}
+	fs.Debugf(o, "Multipart upload session started for %d parts of size %v", numChunks, chunkSize)
+	w := &azChunkWriter{
+		o:           o,
+		blb:         blb,
+		httpHeaders: httpHeaders,
+		blocks:      make([]string, numChunks),
+	}
+	info = fs.ChunkWriterInfo{
+		ChunkSize:   int64(chunkSize),
+		Concurrency: f.opt.UploadConcurrency,
+		// Uncommitted blocks can't be deleted, they are garbage
+		// collected by Azure after a week.
+		LeavePartsOnError: true,
+	}
+	return info, w, nil
+}
+
+// azChunkWriter uploads the chunks of a file as blocks which are
+// committed in Close
+type azChunkWriter struct {
+	o           *Object
+	blb         *blockblob.Client
+	httpHeaders *blob.HTTPHeaders
+	blocksMu    sync.Mutex // protects blocks
+	blocks      []string   // block IDs indexed by chunk number, "" if not written
+}
+
+// WriteChunk uploads chunk number chunkNumber reading the data from reader
+func (w *azChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	if chunkNumber < 0 || chunkNumber >= len(w.blocks) {
+		return -1, fmt.Errorf("invalid chunk number %d - want 0..%d", chunkNumber, len(w.blocks)-1)
+	}
+	md5er := md5.New()
+	n, err := io.Copy(md5er, reader)
+	if err != nil {
+		return -1, fmt.Errorf("multipart upload failed to read part: %w", err)
+	}
+	transactionalMD5 := md5er.Sum(nil)
+	id := blockID(chunkNumber)
+	fs.Debugf(w.o, "Uploading part %d/%d size %d", chunkNumber+1, len(w.blocks), n)
+	err = w.o.fs.pacer.Call(func() (bool, error) {
+		if _, err := reader.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		rs := readSeekCloser{reader, reader}
+		options := blockblob.StageBlockOptions{
+			// Specify the transactional md5 for the body, to be validated by the service.
+			TransactionalValidation: blob.TransferValidationTypeMD5(transactionalMD5),
+		}
+		_, err := w.blb.StageBlock(ctx, id, &rs, &options)
+		return w.o.fs.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return -1, fmt.Errorf("multipart upload failed to upload part: %w", err)
+	}
+	w.blocksMu.Lock()
+	w.blocks[chunkNumber] = id
+	w.blocksMu.Unlock()
+	return n, nil
+}
+
+// Close commits the blocks to make the blob
+func (w *azChunkWriter) Close(ctx context.Context) error {
+	w.blocksMu.Lock()
+	defer w.blocksMu.Unlock()
+	for i, id := range w.blocks {
+		if id == "" {
+			return fmt.Errorf("multipart upload failed to finalize: part %d not uploaded", i+1)
+		}
+	}
+	tier := blob.AccessTier(w.o.fs.opt.AccessTier)
+	options := blockblob.CommitBlockListOptions{
+		Metadata:    w.o.getMetadata(),
+		Tier:        &tier,
+		HTTPHeaders: w.httpHeaders,
+	}
+	err := w.o.fs.pacer.Call(func() (bool, error) {
+		_, err := w.blb.CommitBlockList(ctx, w.blocks, &options)
+		return w.o.fs.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return fmt.Errorf("multipart upload failed to finalize: %w", err)
+	}
+	return nil
+}
+
+// Abort the upload
+//
+// There isn't a way of deleting uncommitted blocks so this just
+// leaves them for Azure to remove.
+func (w *azChunkWriter) Abort(ctx context.Context) error {
+	fs.Debugf(w.o, "Cancelling multipart upload - uncommitted blocks will expire")
+	return nil
+}
+
 // uploadSinglepart uploads a short blob using a single part upload
 func (o *Object) uploadSinglepart(ctx context.Context, in io.Reader, size int64, blb *blockblob.Client, httpHeaders *blob.HTTPHeaders) (err error) {
 	// fs.Debugf(o, "Single part upload starting of object %d bytes", size)
@@ -2106,38 +2236,34 @@ func (o *Object) uploadSinglepart(ctx context.Context, in io.Reader, size int64,
 	})
 }
 
-// Update the object with the contents of the io.Reader, modTime and size
-//
-// The new object may have been created if an error is returned
-func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (err error) {
+// prepareUpload makes the container and works out the HTTP headers
+// for uploading src to o
+func (o *Object) prepareUpload(ctx context.Context, src fs.ObjectInfo, options []fs.OpenOption) (blb *blockblob.Client, httpHeaders *blob.HTTPHeaders, err error) {
 	if o.accessTier == blob.AccessTierArchive {
 		if o.fs.opt.ArchiveTierDelete {
 			fs.Debugf(o, "deleting archive tier blob before updating")
 			err = o.Remove(ctx)
 			if err != nil {
-				return fmt.Errorf("failed to delete archive blob before updating: %w", err)
+				return nil, nil, fmt.Errorf("failed to delete archive blob before updating: %w", err)
 			}
 		} else {
-			return errCantUpdateArchiveTierBlobs
+			return nil, nil, errCantUpdateArchiveTierBlobs
 		}
 	}
 	container, containerPath := o.split()
 	if container == "" || containerPath == "" {
-		return fmt.Errorf("can't upload to root - need a container")
+		return nil, nil, fmt.Errorf("can't upload to root - need a container")
 	}
 	err = o.fs.makeContainer(ctx, container)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// Update Mod time
 	o.updateMetadataWithModTime(src.ModTime(ctx))
-	if err != nil {
-		return err
-	}
 
 	// Create the HTTP headers for the upload
-	httpHeaders := blob.HTTPHeaders{
+	httpHeaders = &blob.HTTPHeaders{
 		BlobContentType: pString(fs.MimeType(ctx, src)),
 	}
 
@@ -2174,14 +2300,25 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		}
 	}
 
-	blb := o.fs.getBlockBlobSVC(container, containerPath)
+	blb = o.fs.getBlockBlobSVC(container, containerPath)
+	return blb, httpHeaders, nil
+}
+
+// Update the object with the contents of the io.Reader, modTime and size
+//
+// The new object may have been created if an error is returned
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (err error) {
+	blb, httpHeaders, err := o.prepareUpload(ctx, src, options)
+	if err != nil {
+		return err
+	}
 	size := src.Size()
 	multipartUpload := size < 0 || size > o.fs.poolSize
 
 	if multipartUpload {
-		err = o.uploadMultipart(ctx, in, size, blb, &httpHeaders)
+		err = o.uploadMultipart(ctx, in, size, blb, httpHeaders)
 	} else {
-		err = o.uploadSinglepart(ctx, in, size, blb, &httpHeaders)
+		err = o.uploadSinglepart(ctx, in, size, blb, httpHeaders)
 	}
 	if err != nil {
 		return err
@@ -2267,13 +2404,14 @@ func (o *Object) GetTier() string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Copier      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.Purger      = &Fs{}
-	_ fs.ListRer     = &Fs{}
-	_ fs.Object      = &Object{}
-	_ fs.MimeTyper   = &Object{}
-	_ fs.GetTierer   = &Object{}
-	_ fs.SetTierer   = &Object{}
+	_ fs.Fs              = &Fs{}
+	_ fs.Copier          = &Fs{}
+	_ fs.PutStreamer     = &Fs{}
+	_ fs.Purger          = &Fs{}
+	_ fs.ListRer         = &Fs{}
+	_ fs.OpenChunkWriter = &Fs{}
+	_ fs.Object          = &Object{}
+	_ fs.MimeTyper       = &Object{}
+	_ fs.GetTierer       = &Object{}
+	_ fs.SetTierer       = &Object{}
 )
@@ -1842,6 +1842,72 @@ func urlEncode(in string) string {
 	return out.String()
 }
 
+// OpenChunkWriter returns the chunk size and a ChunkWriter
+//
+// Pass in the remote and the src object
+func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	if f.opt.Versions {
+		return info, nil, errNotWithVersions
+	}
+	if f.opt.VersionAt.IsSet() {
+		return info, nil, errNotWithVersionAt
+	}
+	size := src.Size()
+	// Large files need at least two parts
+	if size < 2*int64(minChunkSize) {
+		return info, nil, fmt.Errorf("file of size %v is too small for a large file upload", fs.SizeSuffix(size))
+	}
+	chunkSize := f.opt.ChunkSize
+	if half := fs.SizeSuffix((size + 1) / 2); chunkSize > half {
+		chunkSize = half
+	}
+
+	// Temporary Object under construction
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	bucket, _ := o.split()
+	err = f.makeBucket(ctx, bucket)
+	if err != nil {
+		return info, nil, err
+	}
+	up, err := f.newLargeUpload(ctx, o, nil, src, chunkSize, false, nil)
+	if err != nil {
+		return info, nil, err
+	}
+	info = fs.ChunkWriterInfo{
+		ChunkSize:   up.chunkSize,
+		Concurrency: fs.GetConfig(ctx).Transfers,
+	}
+	return info, up, nil
+}
+
+// ResumeChunkWriter carries on with the large file upload described
+// by state from a ChunkWriter returned by OpenChunkWriter
+func (f *Fs) ResumeChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, state []byte, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	if f.opt.Versions {
+		return info, nil, errNotWithVersions
+	}
+	if f.opt.VersionAt.IsSet() {
+		return info, nil, errNotWithVersionAt
+	}
+	// Temporary Object under construction
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	up, err := f.resumeLargeUpload(ctx, o, state)
+	if err != nil {
+		return info, nil, err
+	}
+	info = fs.ChunkWriterInfo{
+		ChunkSize:   up.chunkSize,
+		Concurrency: fs.GetConfig(ctx).Transfers,
+	}
+	return info, up, nil
+}
+
 // Update the object with the contents of the io.Reader, modTime and size
 //
 // The new object may have been created if an error is returned
@@ -2028,14 +2094,17 @@ func (o *Object) ID() string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs           = &Fs{}
-	_ fs.Purger       = &Fs{}
-	_ fs.Copier       = &Fs{}
-	_ fs.PutStreamer  = &Fs{}
-	_ fs.CleanUpper   = &Fs{}
-	_ fs.ListRer      = &Fs{}
-	_ fs.PublicLinker = &Fs{}
-	_ fs.Object       = &Object{}
-	_ fs.MimeTyper    = &Object{}
-	_ fs.IDer         = &Object{}
+	_ fs.Fs                 = &Fs{}
+	_ fs.Purger             = &Fs{}
+	_ fs.Copier             = &Fs{}
+	_ fs.PutStreamer        = &Fs{}
+	_ fs.CleanUpper         = &Fs{}
+	_ fs.ListRer            = &Fs{}
+	_ fs.PublicLinker       = &Fs{}
+	_ fs.OpenChunkWriter    = &Fs{}
+	_ fs.ResumeChunkWriter  = &Fs{}
+	_ fs.ChunkWriterResumer = &largeUpload{}
+	_ fs.Object             = &Object{}
+	_ fs.MimeTyper          = &Object{}
+	_ fs.IDer               = &Object{}
 )
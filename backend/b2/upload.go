@@ -9,6 +9,8 @@ import (
 	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	gohash "hash"
 	"io"
@@ -81,6 +83,7 @@ type largeUpload struct {
 	id        string                          // ID of the file being uploaded
 	size      int64                           // total size
 	parts     int64                           // calculated number of parts, if known
+	sha1sMu   sync.Mutex                      // lock for sha1s
 	sha1s     []string                        // slice of SHA1s for each part
 	uploadMu  sync.Mutex                      // lock for upload variable
 	uploads   []*api.GetUploadPartURLResponse // result of get upload URL calls
@@ -203,6 +206,13 @@ func (up *largeUpload) returnUploadURL(upload *api.GetUploadPartURLResponse) {
 	up.uploadMu.Unlock()
 }
 
+// setSHA1 records the SHA1 of part which counts from 1
+func (up *largeUpload) setSHA1(part int64, sha1 string) {
+	up.sha1sMu.Lock()
+	up.sha1s[part-1] = sha1
+	up.sha1sMu.Unlock()
+}
+
 // Transfer a chunk
 func (up *largeUpload) transferChunk(ctx context.Context, part int64, body []byte) error {
 	err := up.f.pacer.Call(func() (bool, error) {
@@ -264,7 +274,7 @@ func (up *largeUpload) transferChunk(ctx context.Context, part int64, body []byt
 			upload = nil
 		}
 		up.returnUploadURL(upload)
-		up.sha1s[part-1] = in.HexSum()
+		up.setSHA1(part, in.HexSum())
 		return retry, err
 	})
 	if err != nil {
@@ -296,7 +306,7 @@ func (up *largeUpload) copyChunk(ctx context.Context, part int64, partSize int64
 		if err != nil {
 			fs.Debugf(up.o, "Error copying chunk %d (retry=%v): %v: %#v", part, retry, err, err)
 		}
-		up.sha1s[part-1] = response.SHA1
+		up.setSHA1(part, response.SHA1)
 		return retry, err
 	})
 	if err != nil {
@@ -509,3 +519,87 @@ func (up *largeUpload) Upload(ctx context.Context) (err error) {
 	}
 	return up.finish(ctx)
 }
+
+// WriteChunk uploads chunk number chunkNumber, counting from 0, to
+// satisfy the fs.ChunkWriter interface
+func (up *largeUpload) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (size int64, err error) {
+	if chunkNumber < 0 || int64(chunkNumber) >= up.parts {
+		return -1, fmt.Errorf("invalid chunk number %d for upload of %d parts", chunkNumber, up.parts)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return -1, fmt.Errorf("failed to read chunk %d: %w", chunkNumber, err)
+	}
+	err = up.transferChunk(ctx, int64(chunkNumber)+1, body)
+	if err != nil {
+		return -1, err
+	}
+	return int64(len(body)), nil
+}
+
+// Close finishes the upload to satisfy the fs.ChunkWriter interface
+func (up *largeUpload) Close(ctx context.Context) error {
+	return up.finish(ctx)
+}
+
+// Abort cancels the upload to satisfy the fs.ChunkWriter interface
+func (up *largeUpload) Abort(ctx context.Context) error {
+	return up.cancel(ctx)
+}
+
+// largeUploadState is the state of a large file upload saved so it
+// can be resumed
+type largeUploadState struct {
+	ID        string   `json:"id"`
+	Size      int64    `json:"size"`
+	ChunkSize int64    `json:"chunkSize"`
+	SHA1s     []string `json:"sha1s"`
+}
+
+// ResumeState returns the state of the upload to satisfy the
+// fs.ChunkWriterResumer interface
+func (up *largeUpload) ResumeState() ([]byte, error) {
+	up.sha1sMu.Lock()
+	defer up.sha1sMu.Unlock()
+	return json.Marshal(&largeUploadState{
+		ID:        up.id,
+		Size:      up.size,
+		ChunkSize: up.chunkSize,
+		SHA1s:     up.sha1s,
+	})
+}
+
+// resumeLargeUpload carries on with the upload of object o described
+// by state from ResumeState
+func (f *Fs) resumeLargeUpload(ctx context.Context, o *Object, state []byte) (up *largeUpload, err error) {
+	var s largeUploadState
+	err = json.Unmarshal(state, &s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode large file upload state: %w", err)
+	}
+	if s.ID == "" || s.ChunkSize <= 0 || s.Size <= 0 {
+		return nil, errors.New("invalid large file upload state")
+	}
+	parts := (s.Size + s.ChunkSize - 1) / s.ChunkSize
+	if int64(len(s.SHA1s)) != parts {
+		return nil, fmt.Errorf("invalid large file upload state: %d SHA1s for %d parts", len(s.SHA1s), parts)
+	}
+	up = &largeUpload{
+		f:         f,
+		o:         o,
+		what:      "upload",
+		id:        s.ID,
+		size:      s.Size,
+		parts:     parts,
+		sha1s:     s.SHA1s,
+		chunkSize: s.ChunkSize,
+	}
+	up.in, up.wrap = accounting.UnWrap(nil)
+	// Check the large file is still there by getting an upload URL
+	upload, err := up.getUploadURL(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume large file %q: %w", s.ID, err)
+	}
+	up.returnUploadURL(upload)
+	return up, nil
+}
@@ -43,14 +43,20 @@ const (
 	minCompressionRatio = 1.1
 
 	gzFileExt           = ".gz"
+	zstdFileExt         = ".zst"
 	metaFileExt         = ".json"
 	uncompressedFileExt = ".bin"
+
+	// metadataVersion is the version of the metadata format written.
+	// Metadata written before versioning was introduced has version 0.
+	metadataVersion = 1
 )
 
 // Compression modes
 const (
 	Uncompressed = 0
 	Gzip         = 2
+	Zstd         = 3
 )
 
 var nameRegexp = regexp.MustCompile(`^(.+?)\.([A-Za-z0-9-_]{11})$`)
@@ -62,6 +68,9 @@ func init() {
 		{ // Default compression mode options {
 			Value: "gzip",
 			Help:  "Standard gzip compression with fastest parameters.",
+		}, {
+			Value: "zstd",
+			Help:  "Zstandard compression - faster and smaller than gzip.",
 		},
 	}
 
@@ -84,15 +93,18 @@ func init() {
 			Examples: compressionModeOptions,
 		}, {
 			Name: "level",
-			Help: `GZIP compression level (-2 to 9).
+			Help: `Compression level.
 
-Generally -1 (default, equivalent to 5) is recommended.
-Levels 1 to 9 increase compression at the cost of speed. Going past 6 
-generally offers very little return.
+-1 (default) uses the default level for the compression mode which is
+recommended.
 
+For gzip the level is -2 to 9. Levels 1 to 9 increase compression at
+the cost of speed. Going past 6 generally offers very little return.
 Level -2 uses Huffman encoding only. Only use if you know what you
-are doing.
-Level 0 turns off compression.`,
+are doing. Level 0 turns off compression.
+
+For zstd the level is 1 to 22 with the same meaning as for the zstd
+command line tool. The default is 3.`,
 			Default:  sgzip.DefaultCompression,
 			Advanced: true,
 		}, {
@@ -201,6 +213,8 @@ func compressionModeFromName(name string) int {
 	switch name {
 	case "gzip":
 		return Gzip
+	case "zstd":
+		return Zstd
 	default:
 		return Uncompressed
 	}
@@ -243,7 +257,7 @@ func processFileName(compressedFileName string) (origFileName string, extension
 	if err != nil {
 		return "", "", 0, errors.New("could not decode size")
 	}
-	return match[1], gzFileExt, size, nil
+	return match[1], extension, size, nil
 }
 
 // Generates the file name for a metadata file
@@ -258,10 +272,13 @@ func isMetadataFile(filename string) bool {
 
 // makeDataName generates the file name for a data file with specified compression mode
 func makeDataName(remote string, size int64, mode int) (newRemote string) {
-	if mode != Uncompressed {
-		newRemote = remote + "." + int64ToBase64(size) + gzFileExt
-	} else {
+	switch mode {
+	case Uncompressed:
 		newRemote = remote + uncompressedFileExt
+	case Zstd:
+		newRemote = remote + "." + int64ToBase64(size) + zstdFileExt
+	default:
+		newRemote = remote + "." + int64ToBase64(size) + gzFileExt
 	}
 	return newRemote
 }
@@ -439,8 +456,10 @@ func (f *Fs) verifyObjectHash(ctx context.Context, o fs.Object, hasher *hash.Mul
 type putFn func(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error)
 
 type compressionResult struct {
-	err  error
-	meta sgzip.GzipMetadata
+	err           error
+	size          int64              // uncompressed size
+	meta          sgzip.GzipMetadata // only set for gzip
+	seekTableSize int64              // only set for zstd
 }
 
 // replicating some of operations.Rcat functionality because we want to support remotes without streaming
@@ -503,17 +522,30 @@ func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, o
 	pipeReader, pipeWriter := io.Pipe()
 	results := make(chan compressionResult)
 	go func() {
-		gz, err := sgzip.NewWriterLevel(pipeWriter, f.opt.CompressionLevel)
+		var (
+			w   io.WriteCloser
+			gz  *sgzip.Writer
+			zw  *seekableWriter
+			err error
+		)
+		if f.mode == Zstd {
+			zw, err = newSeekableWriter(pipeWriter, f.opt.CompressionLevel)
+			w = zw
+		} else {
+			gz, err = sgzip.NewWriterLevel(pipeWriter, f.opt.CompressionLevel)
+			w = gz
+		}
 		if err != nil {
+			_ = pipeWriter.CloseWithError(err)
 			results <- compressionResult{err: err, meta: sgzip.GzipMetadata{}}
 			return
 		}
-		_, err = io.Copy(gz, in)
-		gzErr := gz.Close()
-		if gzErr != nil {
-			fs.Errorf(nil, "Failed to close compress: %v", gzErr)
+		_, err = io.Copy(w, in)
+		compressErr := w.Close()
+		if compressErr != nil {
+			fs.Errorf(nil, "Failed to close compress: %v", compressErr)
 			if err == nil {
-				err = gzErr
+				err = compressErr
 			}
 		}
 		closeErr := pipeWriter.Close()
@@ -523,7 +555,12 @@ func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, o
 				err = closeErr
 			}
 		}
-		results <- compressionResult{err: err, meta: gz.MetaData()}
+		if zw != nil {
+			results <- compressionResult{err: err, size: zw.Size(), seekTableSize: zw.SeekTableSize()}
+		} else {
+			meta := gz.MetaData()
+			results <- compressionResult{err: err, size: meta.Size, meta: meta}
+		}
 	}()
 	wrappedIn := wrap(bufio.NewReaderSize(pipeReader, bufferSize)) // Probably no longer needed as sgzip has it's own buffering
 
@@ -570,7 +607,8 @@ func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, o
 	}
 
 	// Generate metadata
-	meta := newMetadata(result.meta.Size, f.mode, result.meta, hex.EncodeToString(metaHasher.Sum(nil)), mimeType)
+	meta := newMetadata(result.size, f.mode, result.meta, hex.EncodeToString(metaHasher.Sum(nil)), mimeType)
+	meta.SeekTableSize = result.seekTableSize
 
 	// Check the hashes of the compressed data if we were comparing them
 	if ht != hash.None && hasher != nil {
@@ -1013,12 +1051,18 @@ func (f *Fs) PublicLink(ctx context.Context, remote string, duration fs.Duration
 /*** OBJECT FUNCTIONS ***/
 
 // ObjectMetadata describes the metadata for an Object.
+//
+// CompressionMetadata is only used for gzip files as zstd files contain
+// their own seek table. SeekTableSize records the size of that so it
+// can be read without first reading its footer.
 type ObjectMetadata struct {
+	Version             int    // Version of the metadata format.
 	Mode                int    // Compression mode of the file.
 	Size                int64  // Size of the object.
 	MD5                 string // MD5 hash of the file.
 	MimeType            string // Mime type of the file
 	CompressionMetadata sgzip.GzipMetadata
+	SeekTableSize       int64 `json:",omitempty"` // Size of the zstd seek table frame, 0 if unknown
 }
 
 // Object with external metadata
@@ -1034,6 +1078,7 @@ type Object struct {
 // This function generates a metadata object
 func newMetadata(size int64, mode int, cmeta sgzip.GzipMetadata, md5 string, mimeType string) *ObjectMetadata {
 	meta := new(ObjectMetadata)
+	meta.Version = metadataVersion
 	meta.Size = size
 	meta.Mode = mode
 	meta.CompressionMetadata = cmeta
@@ -1055,6 +1100,9 @@ func readMetadata(ctx context.Context, mo fs.Object) (meta *ObjectMetadata, err
 	if err = jr.Decode(meta); err != nil {
 		return nil, err
 	}
+	if meta.Version > metadataVersion {
+		return nil, fmt.Errorf("metadata version %d is newer than the supported version %d - upgrade rclone", meta.Version, metadataVersion)
+	}
 	return meta, nil
 }
 
@@ -1310,9 +1358,17 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.Read
 	chunkedReader := chunkedreader.New(ctx, o.Object, initialChunkSize, maxChunkSize)
 	// Get file handle
 	var file io.Reader
-	if offset != 0 {
+	var closer io.Closer = chunkedReader
+	switch {
+	case o.meta.Mode == Zstd:
+		var zr *zstdReader
+		zr, err = newZstdReader(ctx, o.Object, chunkedReader, offset, o.meta.SeekTableSize)
+		file, closer = zr, zr
+	case o.meta.Mode != Gzip:
+		err = fmt.Errorf("unknown compression mode %d", o.meta.Mode)
+	case offset != 0:
 		file, err = sgzip.NewReaderAt(chunkedReader, &o.meta.CompressionMetadata, offset)
-	} else {
+	default:
 		file, err = sgzip.NewReader(chunkedReader)
 	}
 	if err != nil {
@@ -1326,7 +1382,7 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.Read
 		fileReader = file
 	}
 	// Return a ReadCloser
-	return ReadCloserWrapper{Reader: fileReader, Closer: chunkedReader}, nil
+	return ReadCloserWrapper{Reader: fileReader, Closer: closer}, nil
 }
 
 // ObjectInfo describes a wrapped fs.ObjectInfo for being the source
@@ -0,0 +1,284 @@
+package compress
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/chunkedreader"
+)
+
+// The zstd data is written in the seekable format described in
+// https://github.com/facebook/zstd/blob/dev/contrib/seekable_format/zstd_seekable_compression_format.md
+//
+// The data is compressed as a series of independent frames followed by
+// a seek table in a skippable frame which records the compressed and
+// decompressed size of each frame. Normal zstd decompressors ignore the
+// seek table and read the data as usual.
+const (
+	zstdFrameSize          = 1024 * 1024 // uncompressed size of each frame
+	zstdSkippableMagic     = 0x184D2A5E
+	zstdSeekableMagic      = 0x8F92EAB1
+	zstdSeekFooterSize     = 9
+	zstdSeekChecksumFlag   = 0x80
+	zstdMaxSeekTableFrames = 1 << 27 // sanity check on the size of the seek table
+)
+
+// zstdEncoderLevel converts the level option into a zstd encoder level
+func zstdEncoderLevel(level int) zstd.EncoderLevel {
+	if level < 0 {
+		return zstd.SpeedDefault
+	}
+	return zstd.EncoderLevelFromZstd(level)
+}
+
+// seekableWriter compresses data into the zstd seekable format
+type seekableWriter struct {
+	out    io.Writer
+	enc    *zstd.Encoder
+	buf    []byte   // uncompressed data for the current frame
+	frame  []byte   // compressed data for the current frame
+	frames []uint32 // compressed and decompressed sizes of each frame
+	size   int64    // total uncompressed size
+	table  int64    // size of the seek table frame written by Close
+}
+
+// newSeekableWriter makes a seekableWriter writing to out at the level given
+func newSeekableWriter(out io.Writer, level int) (*seekableWriter, error) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstdEncoderLevel(level)), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &seekableWriter{
+		out: out,
+		enc: enc,
+		buf: make([]byte, 0, zstdFrameSize),
+	}, nil
+}
+
+// flush writes the buffered data as a frame
+func (w *seekableWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	w.frame = w.enc.EncodeAll(w.buf, w.frame[:0])
+	_, err := w.out.Write(w.frame)
+	if err != nil {
+		return err
+	}
+	w.frames = append(w.frames, uint32(len(w.frame)), uint32(len(w.buf)))
+	w.size += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Write compresses p
+func (w *seekableWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := zstdFrameSize - len(w.buf)
+		if chunk > len(p) {
+			chunk = len(p)
+		}
+		w.buf = append(w.buf, p[:chunk]...)
+		p = p[chunk:]
+		n += chunk
+		if len(w.buf) >= zstdFrameSize {
+			err = w.flush()
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close writes the final frame and the seek table
+func (w *seekableWriter) Close() error {
+	err := w.flush()
+	if err != nil {
+		return err
+	}
+	numFrames := len(w.frames) / 2
+	table := make([]byte, 8+len(w.frames)*4+zstdSeekFooterSize)
+	binary.LittleEndian.PutUint32(table[0:], zstdSkippableMagic)
+	binary.LittleEndian.PutUint32(table[4:], uint32(len(table)-8))
+	for i, v := range w.frames {
+		binary.LittleEndian.PutUint32(table[8+i*4:], v)
+	}
+	footer := table[len(table)-zstdSeekFooterSize:]
+	binary.LittleEndian.PutUint32(footer[0:], uint32(numFrames))
+	footer[4] = 0 // no checksums
+	binary.LittleEndian.PutUint32(footer[5:], zstdSeekableMagic)
+	_, err = w.out.Write(table)
+	w.table = int64(len(table))
+	return err
+}
+
+// Size returns the uncompressed size of the data written
+func (w *seekableWriter) Size() int64 {
+	return w.size
+}
+
+// SeekTableSize returns the size of the seek table frame written at
+// the end of the data
+func (w *seekableWriter) SeekTableSize() int64 {
+	return w.table
+}
+
+// seekTable is the start of each frame in the compressed and
+// decompressed data with a final entry for the end of the data
+type seekTable struct {
+	compressed   []int64
+	decompressed []int64
+}
+
+// readRange reads length bytes at offset from o
+func readRange(ctx context.Context, o fs.Object, offset, length int64) (buf []byte, err error) {
+	rc, err := o.Open(ctx, &fs.RangeOption{Start: offset, End: offset + length - 1})
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(rc, &err)
+	buf = make([]byte, length)
+	_, err = io.ReadFull(rc, buf)
+	return buf, err
+}
+
+// readSeekTable reads the seek table from the end of the zstd object o.
+//
+// If frameSize, the size of the skippable frame holding the seek
+// table, is known the table is read with a single request, otherwise
+// the footer is read first to find it.
+func readSeekTable(ctx context.Context, o fs.Object, frameSize int64) (*seekTable, error) {
+	size := o.Size()
+	if size < zstdSeekFooterSize {
+		return nil, errors.New("zstd seek table not found: file too short")
+	}
+	var (
+		table []byte
+		err   error
+	)
+	if frameSize > 0 {
+		if frameSize < 8+zstdSeekFooterSize || frameSize > size {
+			return nil, errors.New("zstd seek table is corrupted")
+		}
+		table, err = readRange(ctx, o, size-frameSize, frameSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd seek table: %w", err)
+		}
+		if binary.LittleEndian.Uint32(table[0:]) != zstdSkippableMagic {
+			return nil, errors.New("zstd seek table not found: bad skippable frame magic")
+		}
+	} else {
+		table, err = readRange(ctx, o, size-zstdSeekFooterSize, zstdSeekFooterSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd seek table footer: %w", err)
+		}
+	}
+	footer := table[len(table)-zstdSeekFooterSize:]
+	if binary.LittleEndian.Uint32(footer[5:]) != zstdSeekableMagic {
+		return nil, errors.New("zstd seek table not found: bad magic")
+	}
+	numFrames := int64(binary.LittleEndian.Uint32(footer[0:]))
+	entrySize := int64(8)
+	if footer[4]&zstdSeekChecksumFlag != 0 {
+		entrySize = 12
+	}
+	tableSize := numFrames * entrySize
+	if numFrames > zstdMaxSeekTableFrames || tableSize+8+zstdSeekFooterSize > size {
+		return nil, errors.New("zstd seek table is corrupted")
+	}
+	var entries []byte
+	if frameSize > 0 {
+		if tableSize+8+zstdSeekFooterSize != frameSize {
+			return nil, errors.New("zstd seek table is corrupted")
+		}
+		entries = table[8:]
+	} else {
+		entries, err = readRange(ctx, o, size-zstdSeekFooterSize-tableSize, tableSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd seek table: %w", err)
+		}
+	}
+	t := &seekTable{
+		compressed:   make([]int64, numFrames+1),
+		decompressed: make([]int64, numFrames+1),
+	}
+	for i := int64(0); i < numFrames; i++ {
+		entry := entries[i*entrySize:]
+		t.compressed[i+1] = t.compressed[i] + int64(binary.LittleEndian.Uint32(entry[0:]))
+		t.decompressed[i+1] = t.decompressed[i] + int64(binary.LittleEndian.Uint32(entry[4:]))
+	}
+	return t, nil
+}
+
+// find returns the compressed and decompressed offsets of the start of
+// the frame containing the decompressed offset given
+func (t *seekTable) find(offset int64) (compressedOffset, frameOffset int64) {
+	// index of the first frame starting after offset
+	i := sort.Search(len(t.decompressed), func(i int) bool {
+		return t.decompressed[i] > offset
+	})
+	if i == 0 {
+		return 0, 0
+	}
+	return t.compressed[i-1], t.decompressed[i-1]
+}
+
+// zstdReader decompresses a zstd object
+type zstdReader struct {
+	io.Reader
+	dec *zstd.Decoder
+	cr  *chunkedreader.ChunkedReader
+}
+
+// newZstdReader returns a reader for the data in the zstd object o
+// starting at offset. It reads the data using cr.
+//
+// seekTableSize is the size of the seek table frame if known or 0.
+func newZstdReader(ctx context.Context, o fs.Object, cr *chunkedreader.ChunkedReader, offset int64, seekTableSize int64) (*zstdReader, error) {
+	var skip int64
+	if offset > 0 {
+		t, err := readSeekTable(ctx, o, seekTableSize)
+		if err != nil {
+			return nil, err
+		}
+		compressedOffset, frameOffset := t.find(offset)
+		_, err = cr.RangeSeek(ctx, compressedOffset, io.SeekStart, -1)
+		if err != nil {
+			return nil, err
+		}
+		skip = offset - frameOffset
+	}
+	dec, err := zstd.NewReader(cr, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	if skip > 0 {
+		_, err = io.CopyN(io.Discard, dec, skip)
+		if err == io.EOF {
+			err = nil
+		}
+		if err != nil {
+			dec.Close()
+			return nil, err
+		}
+	}
+	return &zstdReader{
+		Reader: dec,
+		dec:    dec,
+		cr:     cr,
+	}, nil
+}
+
+// Close the decoder and the underlying reader
+func (r *zstdReader) Close() error {
+	r.dec.Close()
+	return r.cr.Close()
+}
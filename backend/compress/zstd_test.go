@@ -0,0 +1,109 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rclone/rclone/fs/chunkedreader"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeSeekable compresses data in the zstd seekable format returning
+// it and the size of the seek table frame
+func makeSeekable(t *testing.T, data []byte) ([]byte, int64) {
+	var buf bytes.Buffer
+	w, err := newSeekableWriter(&buf, -1)
+	require.NoError(t, err)
+	// write in odd sized pieces to check the frames are filled
+	for in := data; len(in) > 0; {
+		n := 12345
+		if n > len(in) {
+			n = len(in)
+		}
+		_, err = w.Write(in[:n])
+		require.NoError(t, err)
+		in = in[n:]
+	}
+	require.NoError(t, w.Close())
+	assert.Equal(t, int64(len(data)), w.Size())
+	return buf.Bytes(), w.SeekTableSize()
+}
+
+func TestZstdSeekable(t *testing.T) {
+	ctx := context.Background()
+	data := []byte(random.String(3*zstdFrameSize + 1000))
+	compressed, seekTableSize := makeSeekable(t, data)
+	o := object.NewMemoryObject("file.zst", time.Now(), compressed)
+
+	// Normal zstd decoders can read the data
+	dec, err := zstd.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	got, err := io.ReadAll(dec)
+	dec.Close()
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	// Check the seek table with and without its size
+	table, err := readSeekTable(ctx, o, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, zstdFrameSize, 2 * zstdFrameSize, 3 * zstdFrameSize, int64(len(data))}, table.decompressed)
+	tableSized, err := readSeekTable(ctx, o, seekTableSize)
+	require.NoError(t, err)
+	assert.Equal(t, table, tableSized)
+	_, err = readSeekTable(ctx, o, seekTableSize+1)
+	assert.Error(t, err)
+
+	// Check reading from offsets
+	for _, offset := range []int64{0, 1, zstdFrameSize - 1, zstdFrameSize, 2*zstdFrameSize + 17, int64(len(data)) - 1, int64(len(data)), int64(len(data)) + 10} {
+		cr := chunkedreader.New(ctx, o, initialChunkSize, maxChunkSize)
+		zr, err := newZstdReader(ctx, o, cr, offset, seekTableSize)
+		require.NoError(t, err, offset)
+		got, err := io.ReadAll(zr)
+		require.NoError(t, err, offset)
+		require.NoError(t, zr.Close())
+		want := []byte{}
+		if offset < int64(len(data)) {
+			want = data[offset:]
+		}
+		assert.Equal(t, want, got, offset)
+	}
+}
+
+func TestZstdSeekableEmpty(t *testing.T) {
+	ctx := context.Background()
+	compressed, seekTableSize := makeSeekable(t, nil)
+	o := object.NewMemoryObject("file.zst", time.Now(), compressed)
+
+	table, err := readSeekTable(ctx, o, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0}, table.decompressed)
+	table, err = readSeekTable(ctx, o, seekTableSize)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0}, table.decompressed)
+
+	cr := chunkedreader.New(ctx, o, initialChunkSize, maxChunkSize)
+	zr, err := newZstdReader(ctx, o, cr, 0, seekTableSize)
+	require.NoError(t, err)
+	got, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.NoError(t, zr.Close())
+	assert.Empty(t, got)
+}
+
+func TestZstdSeekTableCorrupt(t *testing.T) {
+	ctx := context.Background()
+	compressed, seekTableSize := makeSeekable(t, []byte("hello"))
+	compressed[len(compressed)-1] ^= 0xFF
+	o := object.NewMemoryObject("file.zst", time.Now(), compressed)
+	_, err := readSeekTable(ctx, o, 0)
+	assert.Error(t, err)
+	_, err = readSeekTable(ctx, o, seekTableSize)
+	assert.Error(t, err)
+}
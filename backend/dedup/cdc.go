@@ -0,0 +1,131 @@
+package dedup
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// Content defined chunking
+//
+// The data is split where a rolling "gear" hash of the last 64 bytes
+// matches a mask, so the chunk boundaries depend on the content rather
+// than the offset. Inserting or removing data only changes the chunks
+// around the edit and the rest of the chunks are the same as before.
+//
+// This uses the normalized chunking of FastCDC: a harder mask is used
+// before the average chunk size and an easier one after it, which
+// keeps the chunk sizes close to the average.
+
+// gear maps each byte to a random 64 bit value. It is made from a
+// fixed seed as the chunk boundaries must never change.
+var gear [256]uint64
+
+func init() {
+	seed := uint64(0x6465647570636463) // "dedupcdc"
+	for i := range gear {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+// chunker splits a stream into content defined chunks
+type chunker struct {
+	in    io.Reader
+	min   int    // minimum chunk size
+	avg   int    // average chunk size
+	max   int    // maximum chunk size
+	maskS uint64 // mask used before the average chunk size
+	maskL uint64 // mask used after the average chunk size
+	buf   []byte // buffered data, max bytes long
+	start int    // start of the unread data in buf
+	end   int    // end of the data in buf
+	eof   bool   // set when in has returned EOF
+}
+
+// topBits returns a mask of the n most significant bits
+//
+// The most significant bits of the gear hash depend on the most
+// input so they are used for matching.
+func topBits(n int) uint64 {
+	return ^uint64(0) << (64 - n)
+}
+
+// newChunker returns a chunker reading from in making chunks of avg
+// bytes on average. avg must be a power of two.
+func newChunker(in io.Reader, avg int) (*chunker, error) {
+	if avg < minChunkSize || avg&(avg-1) != 0 {
+		return nil, errors.New("chunk size must be a power of two and at least 1 KiB")
+	}
+	n := bits.TrailingZeros(uint(avg))
+	return &chunker{
+		in:    in,
+		min:   avg / 4,
+		avg:   avg,
+		max:   avg * 4,
+		maskS: topBits(n + 1),
+		maskL: topBits(n - 1),
+		buf:   make([]byte, avg*4),
+	}, nil
+}
+
+// cut returns the length of the first chunk in data
+func (c *chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.min {
+		return n
+	}
+	if n > c.max {
+		n = c.max
+	}
+	normal := c.avg
+	if normal > n {
+		normal = n
+	}
+	var fp uint64
+	i := c.min
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// Next returns the next chunk or io.EOF when there are no more.
+//
+// The chunk returned is only valid until the next call to Next.
+func (c *chunker) Next() ([]byte, error) {
+	// Move the unread data to the start of the buffer and top it up
+	if c.start > 0 {
+		c.end = copy(c.buf, c.buf[c.start:c.end])
+		c.start = 0
+	}
+	for !c.eof && c.end < len(c.buf) {
+		n, err := c.in.Read(c.buf[c.end:])
+		c.end += n
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+	n := c.cut(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
@@ -0,0 +1,577 @@
+// Package dedup implements a deduplicating overlay backend which
+// stores files as content defined chunks
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/walk"
+	"golang.org/x/sync/errgroup"
+)
+
+// Layout of the store on the underlying remote
+const (
+	filesDir     = "files"  // manifests, one for each file
+	chunksDir    = "chunks" // chunk data named by SHA-256
+	minChunkSize = 1024
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "dedup",
+		Description: "Deduplicate files stored on another remote",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name:     "remote",
+			Required: true,
+			Help: `Remote to store the chunks and manifests in.
+
+Normally should contain a ':' and a path, e.g. "myremote:path/to/dir",
+"myremote:bucket" or maybe "myremote:" (not recommended).`,
+		}, {
+			Name:     "chunk_size",
+			Advanced: true,
+			Default:  fs.SizeSuffix(1024 * 1024),
+			Help: `Average size of the chunks files are split into.
+
+Must be a power of two. Chunks vary in size between a quarter of this
+and four times this depending on the content.
+
+Smaller chunks find more duplicate data but need more requests and
+longer manifests. Changing this means new files share no chunks with
+the files already stored.`,
+		}, {
+			Name:     "cleanup_min_age",
+			Advanced: true,
+			Default:  fs.Duration(time.Hour),
+			Help: `Minimum age of unused chunks removed by cleanup.
+
+Chunks are uploaded before the manifest which refers to them, so
+chunks newer than this are left alone in case an upload is in
+progress. Existing chunks reused by an upload have their modification
+time updated so they are protected in the same way.
+
+This should be longer than the longest upload.`,
+		}},
+	})
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Remote        string        `config:"remote"`
+	ChunkSize     fs.SizeSuffix `config:"chunk_size"`
+	CleanupMinAge fs.Duration   `config:"cleanup_min_age"`
+}
+
+// Fs represents a wrapped fs.Fs
+//
+// The embedded Fs is the directory of manifests for the root.
+type Fs struct {
+	fs.Fs
+	name     string
+	root     string
+	opt      Options
+	features *fs.Features
+	wrapper  fs.Fs
+	files    fs.Fs    // root of all the manifests in the store
+	chunks   fs.Fs    // chunk data
+	known    sync.Map // chunk name to the time.Time it was known to exist from
+}
+
+// NewFs constructs an Fs from the remote:path string
+func NewFs(ctx context.Context, name, rpath string, m configmap.Mapper) (fs.Fs, error) {
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(opt.Remote, name+":") {
+		return nil, errors.New("can't point dedup remote at itself - check the value of the remote setting")
+	}
+	if _, err = newChunker(nil, int(opt.ChunkSize)); err != nil {
+		return nil, err
+	}
+	rpath = strings.Trim(rpath, "/")
+
+	f := &Fs{
+		name: name,
+		root: rpath,
+		opt:  *opt,
+	}
+	f.files, err = cache.Get(ctx, fspath.JoinRootPath(opt.Remote, filesDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make remote for the manifests: %w", err)
+	}
+	f.chunks, err = cache.Get(ctx, fspath.JoinRootPath(opt.Remote, chunksDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make remote for the chunks: %w", err)
+	}
+	baseFs, err := cache.Get(ctx, fspath.JoinRootPath(opt.Remote, path.Join(filesDir, rpath)))
+	if err != nil && err != fs.ErrorIsFile {
+		return nil, fmt.Errorf("failed to make remote %q to wrap: %w", opt.Remote, err)
+	}
+	if err == fs.ErrorIsFile {
+		f.root = path.Dir(rpath)
+		if f.root == "." {
+			f.root = ""
+		}
+	}
+	f.Fs = baseFs
+	// Only one finalizer can be set on f so pin all the remotes
+	// here rather than with cache.PinUntilFinalized
+	for _, pin := range []fs.Fs{f.Fs, f.files, f.chunks} {
+		cache.Pin(pin)
+	}
+	runtime.SetFinalizer(f, func(f *Fs) {
+		for _, pin := range []fs.Fs{f.Fs, f.files, f.chunks} {
+			cache.Unpin(pin)
+		}
+	})
+
+	f.features = (&fs.Features{
+		CaseInsensitive:         baseFs.Features().CaseInsensitive,
+		DuplicateFiles:          false,
+		ReadMimeType:            false,
+		WriteMimeType:           false,
+		CanHaveEmptyDirectories: true,
+	}).Fill(ctx, f).Mask(ctx, baseFs).WrapsFs(f, baseFs)
+	// These only write manifests so don't need the base remote to
+	// support them
+	f.features.Copy = f.Copy
+	f.features.Move = f.Move
+	f.features.PutStream = f.PutStream
+	f.features.CleanUp = f.CleanUp
+	return f, err
+}
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// String returns a description of the FS
+func (f *Fs) String() string {
+	return fmt.Sprintf("Dedup '%s:%s'", f.name, f.root)
+}
+
+// Precision of the modification times stored in the manifests
+func (f *Fs) Precision() time.Duration {
+	return time.Nanosecond
+}
+
+// Hashes returns the supported hash sets.
+func (f *Fs) Hashes() hash.Set {
+	return hash.NewHashSet(hash.MD5, hash.SHA1)
+}
+
+// UnWrap returns the Fs that this Fs is wrapping
+func (f *Fs) UnWrap() fs.Fs {
+	return f.Fs
+}
+
+// WrapFs returns the Fs that is wrapping this Fs
+func (f *Fs) WrapFs() fs.Fs {
+	return f.wrapper
+}
+
+// SetWrapper sets the Fs that is wrapping this Fs
+func (f *Fs) SetWrapper(wrapper fs.Fs) {
+	f.wrapper = wrapper
+}
+
+// wrapEntries reads the manifests of the objects in entries
+func (f *Fs) wrapEntries(ctx context.Context, entries fs.DirEntries) (fs.DirEntries, error) {
+	out := make(fs.DirEntries, len(entries))
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(fs.GetConfig(ctx).Checkers)
+	for i, entry := range entries {
+		i, entry := i, entry
+		switch x := entry.(type) {
+		case fs.Object:
+			g.Go(func() error {
+				o, err := f.newObject(gCtx, x)
+				if err != nil {
+					fs.Errorf(x, "Skipping file with bad manifest: %v", err)
+					return nil
+				}
+				out[i] = o
+				return nil
+			})
+		case fs.Directory:
+			out[i] = f.newDir(x)
+		default:
+			return nil, fmt.Errorf("unknown object type %T", entry)
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	// Remove the skipped entries
+	entries = out[:0]
+	for _, entry := range out {
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// newDir returns a directory with the size and count cleared as these
+// are for the manifests
+func (f *Fs) newDir(dir fs.Directory) fs.Directory {
+	return fs.NewDirCopy(context.Background(), dir).SetSize(-1).SetItems(-1)
+}
+
+// List the objects and directories in dir into entries.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	entries, err = f.Fs.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return f.wrapEntries(ctx, entries)
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (err error) {
+	return f.Fs.Features().ListR(ctx, dir, func(entries fs.DirEntries) error {
+		newEntries, err := f.wrapEntries(ctx, entries)
+		if err != nil {
+			return err
+		}
+		return callback(newEntries)
+	})
+}
+
+// NewObject finds the Object at remote.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	mo, err := f.Fs.NewObject(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	return f.newObject(ctx, mo)
+}
+
+// chunkName returns the name of the chunk with the SHA-256 given
+func chunkName(sum string) string {
+	return sum[:2] + "/" + sum
+}
+
+// knownMaxAge returns how long a chunk known to exist can be reused
+// without checking it again.
+//
+// Cleanup, perhaps by another rclone, only removes unused chunks
+// older than cleanup_min_age, so chunks which are reused have their
+// modification time set to now and are only trusted for half that.
+func (f *Fs) knownMaxAge() time.Duration {
+	return time.Duration(f.opt.CleanupMinAge) / 2
+}
+
+// touchChunk sets the modification time of the existing chunk o to
+// now so cleanup doesn't remove it while the manifest using it is
+// being uploaded. If o can't have its modification time set it is
+// uploaded again from data.
+func (f *Fs) touchChunk(ctx context.Context, o fs.Object, data []byte) error {
+	now := time.Now()
+	err := o.SetModTime(ctx, now)
+	if err == fs.ErrorCantSetModTime || err == fs.ErrorCantSetModTimeWithoutDelete {
+		src := object.NewStaticObjectInfo(o.Remote(), now, int64(len(data)), true, nil, f.chunks)
+		err = o.Update(ctx, bytes.NewReader(data), src)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update modification time of chunk %s: %w", o.Remote(), err)
+	}
+	return nil
+}
+
+// putChunk uploads data as a chunk unless it is stored already
+func (f *Fs) putChunk(ctx context.Context, sum string, data []byte) error {
+	name := chunkName(sum)
+	if t, ok := f.known.Load(name); ok && time.Since(t.(time.Time)) < f.knownMaxAge() {
+		return nil
+	}
+	o, err := f.chunks.NewObject(ctx, name)
+	if err == nil && o.Size() == int64(len(data)) {
+		modTime := o.ModTime(ctx)
+		if time.Since(modTime) >= f.knownMaxAge() {
+			err = f.touchChunk(ctx, o, data)
+			if err != nil {
+				return err
+			}
+			modTime = time.Now()
+		}
+		f.known.Store(name, modTime)
+		return nil
+	}
+	if err != nil && err != fs.ErrorObjectNotFound {
+		return err
+	}
+	now := time.Now()
+	src := object.NewStaticObjectInfo(name, now, int64(len(data)), true, nil, f.chunks)
+	if o != nil {
+		// Wrong size so replace it
+		fs.Logf(f, "Replacing damaged chunk %s", name)
+		err = o.Update(ctx, bytes.NewReader(data), src)
+	} else {
+		_, err = f.chunks.Put(ctx, bytes.NewReader(data), src)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload chunk %s: %w", name, err)
+	}
+	f.known.Store(name, now)
+	return nil
+}
+
+// putChunks splits in into chunks, uploads the ones which aren't
+// stored already and returns the manifest for the data
+func (f *Fs) putChunks(ctx context.Context, in io.Reader, src fs.ObjectInfo) (*manifest, error) {
+	c, err := newChunker(in, int(f.opt.ChunkSize))
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := hash.NewMultiHasherTypes(f.Hashes())
+	if err != nil {
+		return nil, err
+	}
+	m := &manifest{
+		Version: manifestVersion,
+		ModTime: src.ModTime(ctx),
+		Chunks:  []chunkRef{},
+	}
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(fs.GetConfig(ctx).Transfers)
+	for {
+		data, err := c.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			_ = g.Wait()
+			return nil, err
+		}
+		_, _ = hasher.Write(data)
+		sumBytes := sha256.Sum256(data)
+		sum := hex.EncodeToString(sumBytes[:])
+		m.Chunks = append(m.Chunks, chunkRef{Hash: sum, Size: int64(len(data))})
+		m.Size += int64(len(data))
+		// take a copy as data is reused by the chunker
+		data = append([]byte(nil), data...)
+		g.Go(func() error {
+			return f.putChunk(gCtx, sum, data)
+		})
+		if gCtx.Err() != nil {
+			break
+		}
+	}
+	if err = g.Wait(); err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if size := src.Size(); size >= 0 && size != m.Size {
+		return nil, fmt.Errorf("upload size mismatch: expected %d bytes but got %d", size, m.Size)
+	}
+	sums := hasher.Sums()
+	m.MD5 = sums[hash.MD5]
+	m.SHA1 = sums[hash.SHA1]
+	return m, nil
+}
+
+// Put in to the remote path with the modTime given of the given size
+//
+// The data is split into chunks and only the chunks which aren't
+// stored already are uploaded, then the manifest is written.
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	m, err := f.putChunks(ctx, in, src)
+	if err != nil {
+		return nil, err
+	}
+	return f.putManifest(ctx, src.Remote(), m, nil)
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return f.Put(ctx, in, src, options...)
+}
+
+// Copy src to this remote by copying its manifest
+//
+// This only works if src is stored in the same chunk store.
+func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok || fs.ConfigString(srcObj.f.chunks) != fs.ConfigString(f.chunks) {
+		fs.Debugf(src, "Can't copy - not in the same chunk store")
+		return nil, fs.ErrorCantCopy
+	}
+	m := *srcObj.m
+	var existing fs.Object
+	if o, err := f.Fs.NewObject(ctx, remote); err == nil {
+		existing = o
+	}
+	return f.putManifest(ctx, remote, &m, existing)
+}
+
+// Move src to this remote by copying its manifest then removing the
+// original
+func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	dst, err := f.Copy(ctx, src, remote)
+	if err == fs.ErrorCantCopy {
+		return nil, fs.ErrorCantMove
+	} else if err != nil {
+		return nil, err
+	}
+	err = src.Remove(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote using
+// server-side move operations on the manifests.
+func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string) error {
+	do := f.Fs.Features().DirMove
+	if do == nil {
+		return fs.ErrorCantDirMove
+	}
+	srcFs, ok := src.(*Fs)
+	if !ok || fs.ConfigString(srcFs.chunks) != fs.ConfigString(f.chunks) {
+		fs.Debugf(srcFs, "Can't move directory - not in the same chunk store")
+		return fs.ErrorCantDirMove
+	}
+	return do(ctx, srcFs.Fs, srcRemote, dstRemote)
+}
+
+// Purge all files in the directory specified
+//
+// This removes the manifests. The chunks are removed by cleanup.
+func (f *Fs) Purge(ctx context.Context, dir string) error {
+	do := f.Fs.Features().Purge
+	if do == nil {
+		return fs.ErrorCantPurge
+	}
+	return do(ctx, dir)
+}
+
+// About gets quota information from the Fs
+func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
+	do := f.Fs.Features().About
+	if do == nil {
+		return nil, errors.New("About not supported")
+	}
+	return do(ctx)
+}
+
+// CleanUp removes the chunks which aren't used by any file
+//
+// All the manifests in the store are read first, so if any of them
+// can't be read nothing is removed.
+func (f *Fs) CleanUp(ctx context.Context) error {
+	// Filters must not hide any manifests or chunks
+	fi, err := filter.NewFilter(nil)
+	if err != nil {
+		return err
+	}
+	ctx = filter.ReplaceConfig(ctx, fi)
+	used := map[string]struct{}{}
+	var mu sync.Mutex
+	err = walk.ListR(ctx, f.files, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		g, gCtx := errgroup.WithContext(ctx)
+		g.SetLimit(fs.GetConfig(ctx).Checkers)
+		entries.ForObject(func(mo fs.Object) {
+			g.Go(func() error {
+				m, err := readManifest(gCtx, mo)
+				if err != nil {
+					return fmt.Errorf("cleanup aborted: %s: %w", mo.Remote(), err)
+				}
+				mu.Lock()
+				for _, chunk := range m.Chunks {
+					used[chunkName(chunk.Hash)] = struct{}{}
+				}
+				mu.Unlock()
+				return nil
+			})
+		})
+		return g.Wait()
+	})
+	if err != nil && err != fs.ErrorDirNotFound {
+		return err
+	}
+	cutoff := time.Now().Add(-time.Duration(f.opt.CleanupMinAge))
+	var deleted, kept int
+	err = walk.ListR(ctx, f.chunks, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		var err error
+		entries.ForObject(func(o fs.Object) {
+			if err != nil {
+				return
+			}
+			if _, ok := used[o.Remote()]; ok {
+				return
+			}
+			if o.ModTime(ctx).After(cutoff) {
+				kept++
+				return
+			}
+			f.known.Delete(o.Remote())
+			if err = o.Remove(ctx); err == nil {
+				deleted++
+			}
+		})
+		return err
+	})
+	if err != nil && err != fs.ErrorDirNotFound {
+		return err
+	}
+	fs.Infof(f, "Cleanup removed %d unused chunks, kept %d unused chunks newer than %v", deleted, kept, f.opt.CleanupMinAge)
+	if do := f.chunks.Features().CleanUp; do != nil {
+		return do(ctx)
+	}
+	return nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs              = (*Fs)(nil)
+	_ fs.Purger          = (*Fs)(nil)
+	_ fs.Copier          = (*Fs)(nil)
+	_ fs.Mover           = (*Fs)(nil)
+	_ fs.DirMover        = (*Fs)(nil)
+	_ fs.PutStreamer     = (*Fs)(nil)
+	_ fs.CleanUpper      = (*Fs)(nil)
+	_ fs.UnWrapper       = (*Fs)(nil)
+	_ fs.ListRer         = (*Fs)(nil)
+	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.Wrapper         = (*Fs)(nil)
+	_ fs.Object          = (*Object)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+)
@@ -0,0 +1,236 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/memory"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testChunkSize = 4096
+
+// randomData returns n bytes of reproducible random data
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	_, _ = rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// split data into chunks, reading it in small pieces
+func split(t *testing.T, data []byte) (chunks []string) {
+	c, err := newChunker(io.LimitReader(bytes.NewReader(data), int64(len(data))), testChunkSize)
+	require.NoError(t, err)
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		require.NoError(t, err)
+		chunks = append(chunks, string(chunk))
+	}
+}
+
+func TestNewChunker(t *testing.T) {
+	for _, avg := range []int{0, 1000, 1023, 3 * 1024} {
+		_, err := newChunker(nil, avg)
+		assert.Error(t, err, avg)
+	}
+	c, err := newChunker(nil, testChunkSize)
+	require.NoError(t, err)
+	assert.Equal(t, testChunkSize/4, c.min)
+	assert.Equal(t, testChunkSize*4, c.max)
+}
+
+func TestChunkerSizes(t *testing.T) {
+	data := randomData(1, 1024*1024)
+	chunks := split(t, data)
+	var total int
+	for i, chunk := range chunks {
+		if i < len(chunks)-1 {
+			assert.GreaterOrEqual(t, len(chunk), testChunkSize/4)
+		}
+		assert.LessOrEqual(t, len(chunk), testChunkSize*4)
+		total += len(chunk)
+	}
+	assert.Equal(t, len(data), total)
+	assert.Equal(t, data, []byte(joinChunks(chunks)))
+	// on average the chunks should be about the right size
+	avg := len(data) / len(chunks)
+	assert.Greater(t, avg, testChunkSize/2)
+	assert.Less(t, avg, testChunkSize*2)
+
+	// splitting again gives the same chunks
+	assert.Equal(t, chunks, split(t, data))
+
+	assert.Empty(t, split(t, nil))
+	assert.Equal(t, []string{"hello"}, split(t, []byte("hello")))
+}
+
+// joinChunks joins the chunks back together
+func joinChunks(chunks []string) string {
+	var buf bytes.Buffer
+	for _, chunk := range chunks {
+		buf.WriteString(chunk)
+	}
+	return buf.String()
+}
+
+// Inserting data should only change the chunks near the insertion
+func TestChunkerResync(t *testing.T) {
+	data := randomData(2, 256*1024)
+	edited := append(append(append([]byte{}, data[:100000]...), []byte("inserted data")...), data[100000:]...)
+	before := split(t, data)
+	after := split(t, edited)
+	seen := map[string]bool{}
+	for _, chunk := range before {
+		seen[chunk] = true
+	}
+	changed := 0
+	for _, chunk := range after {
+		if !seen[chunk] {
+			changed++
+		}
+	}
+	assert.LessOrEqual(t, changed, 3)
+	assert.Greater(t, len(after), 20)
+}
+
+// newTestFs makes a dedup Fs storing into memory
+func newTestFs(t *testing.T) *Fs {
+	m := configmap.Simple{
+		"remote":          ":memory:" + t.Name(),
+		"chunk_size":      "4Ki",
+		"cleanup_min_age": "0s",
+	}
+	f, err := NewFs(context.Background(), "TestDedupInternal", "", m)
+	require.NoError(t, err)
+	return f.(*Fs)
+}
+
+// countChunks returns the number of chunks stored
+func countChunks(t *testing.T, f *Fs) (n int) {
+	err := walk.ListR(context.Background(), f.chunks, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		n += len(entries)
+		return nil
+	})
+	if err == fs.ErrorDirNotFound {
+		return 0
+	}
+	require.NoError(t, err)
+	return n
+}
+
+func TestDedupStore(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFs(t)
+	data := randomData(3, 200*1024)
+	put := func(remote string, data []byte) fs.Object {
+		src := object.NewStaticObjectInfo(remote, time.Now(), int64(len(data)), true, nil, nil)
+		o, err := f.Put(ctx, bytes.NewReader(data), src)
+		require.NoError(t, err)
+		return o
+	}
+	o1 := put("file1", data)
+	n := countChunks(t, f)
+	assert.Greater(t, n, 10)
+
+	// The same data with a prefix stores only a few new chunks
+	o2 := put("dir/file2", append([]byte("prefix"), data...))
+	assert.LessOrEqual(t, countChunks(t, f)-n, 2)
+
+	// Check ranged reads
+	for _, opt := range []fs.OpenOption{
+		nil,
+		&fs.SeekOption{Offset: 5000},
+		&fs.RangeOption{Start: 0, End: 0},
+		&fs.RangeOption{Start: 4000, End: 150000},
+		&fs.RangeOption{Start: -1, End: 1000},
+		&fs.SeekOption{Offset: int64(len(data))},
+	} {
+		var options []fs.OpenOption
+		want := data
+		if opt != nil {
+			options = append(options, opt)
+			offset, limit := int64(0), int64(-1)
+			switch x := opt.(type) {
+			case *fs.SeekOption:
+				offset = x.Offset
+			case *fs.RangeOption:
+				offset, limit = x.Decode(int64(len(data)))
+			}
+			want = data[offset:]
+			if limit >= 0 {
+				want = want[:limit]
+			}
+		}
+		in, err := o1.Open(ctx, options...)
+		require.NoError(t, err)
+		got, err := io.ReadAll(in)
+		require.NoError(t, err)
+		require.NoError(t, in.Close())
+		assert.Equal(t, want, got, opt)
+	}
+
+	// Removing a file leaves the chunks used by the other one
+	require.NoError(t, o2.Remove(ctx))
+	require.NoError(t, f.CleanUp(ctx))
+	assert.LessOrEqual(t, countChunks(t, f), n)
+	in, err := o1.Open(ctx)
+	require.NoError(t, err)
+	got, err := io.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, data, got)
+
+	// Removing the last file removes all the chunks
+	require.NoError(t, o1.Remove(ctx))
+	require.NoError(t, f.CleanUp(ctx))
+	assert.Equal(t, 0, countChunks(t, f))
+}
+
+func TestDedupReusedChunksKept(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFs(t)
+	f.opt.CleanupMinAge = fs.Duration(time.Hour)
+	data := randomData(4, 100*1024)
+	src := object.NewStaticObjectInfo("file", time.Now(), int64(len(data)), true, nil, nil)
+	o, err := f.Put(ctx, bytes.NewReader(data), src)
+	require.NoError(t, err)
+	n := countChunks(t, f)
+	require.NoError(t, o.Remove(ctx))
+
+	// Make the chunks, and what this Fs knows about them, look
+	// like they were uploaded a day ago
+	old := time.Now().Add(-24 * time.Hour)
+	err = walk.ListR(ctx, f.chunks, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		var err error
+		entries.ForObject(func(o fs.Object) {
+			if err == nil {
+				err = o.SetModTime(ctx, old)
+			}
+		})
+		return err
+	})
+	require.NoError(t, err)
+	f.known.Range(func(key, value any) bool {
+		f.known.Store(key, old)
+		return true
+	})
+
+	// An upload in progress reusing the chunks stops cleanup
+	// removing them before its manifest is written
+	_, err = f.putChunks(ctx, bytes.NewReader(data), src)
+	require.NoError(t, err)
+	require.NoError(t, f.CleanUp(ctx))
+	assert.Equal(t, n, countChunks(t, f))
+}
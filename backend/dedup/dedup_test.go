@@ -0,0 +1,54 @@
+// Test Dedup filesystem interface
+package dedup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/backend/dedup"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/fstest/fstests"
+
+	_ "github.com/rclone/rclone/backend/all" // for integration tests
+)
+
+// TestIntegration runs integration tests against the remote
+func TestIntegration(t *testing.T) {
+	opt := fstests.Opt{
+		RemoteName: *fstest.RemoteName,
+		NilObject:  (*dedup.Object)(nil),
+		UnimplementableFsMethods: []string{
+			"ChangeNotify",
+			"DirCacheFlush",
+			"PublicLink",
+			"PutUnchecked",
+			"MergeDirs",
+			"OpenWriterAt",
+			"OpenChunkWriter",
+			"ResumeChunkWriter",
+			"UserInfo",
+			"Disconnect",
+			"Command",
+			"Shutdown",
+		},
+		UnimplementableObjectMethods: []string{
+			"MimeType",
+			"ID",
+			"GetTier",
+			"SetTier",
+			"Metadata",
+		},
+	}
+	if *fstest.RemoteName == "" {
+		tempDir := filepath.Join(os.TempDir(), "rclone-dedup-test")
+		opt.ExtraConfig = []fstests.ExtraConfigItem{
+			{Name: "TestDedup", Key: "type", Value: "dedup"},
+			{Name: "TestDedup", Key: "remote", Value: tempDir},
+			{Name: "TestDedup", Key: "chunk_size", Value: "4Ki"},
+		}
+		opt.RemoteName = "TestDedup:"
+		opt.QuickTestOK = true
+	}
+	fstests.Run(t, &opt)
+}
@@ -0,0 +1,342 @@
+package dedup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	fshash "github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/object"
+)
+
+const (
+	manifestVersion = 1
+	maxManifestSize = 64 * 1024 * 1024 // refuse to read manifests bigger than this
+)
+
+// chunkRef is a chunk of a file in the manifest
+type chunkRef struct {
+	Hash string `json:"hash"` // SHA-256 of the chunk in hex
+	Size int64  `json:"size"`
+}
+
+// manifest describes a file as the list of its chunks
+type manifest struct {
+	Version int        `json:"version"`
+	Size    int64      `json:"size"`
+	ModTime time.Time  `json:"modtime"`
+	MD5     string     `json:"md5,omitempty"`
+	SHA1    string     `json:"sha1,omitempty"`
+	Chunks  []chunkRef `json:"chunks"`
+}
+
+// check the manifest is consistent
+func (m *manifest) check() error {
+	if m.Version != manifestVersion {
+		return fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	var size int64
+	for _, chunk := range m.Chunks {
+		if len(chunk.Hash) != 2*sha256.Size || chunk.Size <= 0 {
+			return fmt.Errorf("bad chunk %q in manifest", chunk.Hash)
+		}
+		size += chunk.Size
+	}
+	if size != m.Size {
+		return fmt.Errorf("manifest size %d doesn't match chunks size %d", m.Size, size)
+	}
+	return nil
+}
+
+// readManifest reads and checks the manifest in mo
+func readManifest(ctx context.Context, mo fs.Object) (*manifest, error) {
+	if mo.Size() > maxManifestSize {
+		return nil, fmt.Errorf("manifest too big: %d bytes", mo.Size())
+	}
+	in, err := mo.Open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(in, maxManifestSize+1))
+	closeErr := in.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	m := new(manifest)
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if err = m.check(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// newObject makes an Object from the manifest in mo
+func (f *Fs) newObject(ctx context.Context, mo fs.Object) (*Object, error) {
+	m, err := readManifest(ctx, mo)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{
+		Object: mo,
+		f:      f,
+		m:      m,
+	}, nil
+}
+
+// putManifest writes m as the manifest for remote, updating existing
+// if set
+func (f *Fs) putManifest(ctx context.Context, remote string, m *manifest, existing fs.Object) (fs.Object, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	src := object.NewStaticObjectInfo(remote, m.ModTime, int64(len(data)), true, nil, f.Fs)
+	var mo fs.Object
+	if existing != nil {
+		err = existing.Update(ctx, bytes.NewReader(data), src)
+		mo = existing
+	} else {
+		mo, err = f.Fs.Put(ctx, bytes.NewReader(data), src)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return &Object{
+		Object: mo,
+		f:      f,
+		m:      m,
+	}, nil
+}
+
+// Object represents a file stored as chunks
+//
+// The embedded Object is the manifest.
+type Object struct {
+	fs.Object
+	f *Fs
+	m *manifest
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Object) Fs() fs.Info {
+	return o.f
+}
+
+// String returns a description of the Object
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.Remote()
+}
+
+// Size returns the size of the file
+func (o *Object) Size() int64 {
+	return o.m.Size
+}
+
+// ModTime returns the modification time of the file
+func (o *Object) ModTime(ctx context.Context) time.Time {
+	return o.m.ModTime
+}
+
+// UnWrap returns the manifest object
+func (o *Object) UnWrap() fs.Object {
+	return o.Object
+}
+
+// Hash returns the selected checksum of the file or "" if unavailable.
+func (o *Object) Hash(ctx context.Context, hashType fshash.Type) (string, error) {
+	switch hashType {
+	case fshash.MD5:
+		return o.m.MD5, nil
+	case fshash.SHA1:
+		return o.m.SHA1, nil
+	}
+	return "", fshash.ErrUnsupported
+}
+
+// SetModTime sets the modification time of the file by rewriting the
+// manifest
+func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
+	m := *o.m
+	m.ModTime = modTime
+	newO, err := o.f.putManifest(ctx, o.Remote(), &m, o.Object)
+	if err != nil {
+		return err
+	}
+	*o = *newO.(*Object)
+	return nil
+}
+
+// Update the file with the contents of in
+//
+// Chunks no longer used are left for cleanup to remove.
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	m, err := o.f.putChunks(ctx, in, src)
+	if err != nil {
+		return err
+	}
+	newO, err := o.f.putManifest(ctx, o.Remote(), m, o.Object)
+	if err != nil {
+		return err
+	}
+	*o = *newO.(*Object)
+	return nil
+}
+
+// Open an object for read
+//
+// Only the chunks overlapping the range requested are read.
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset, limit = x.Offset, -1
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.Size())
+		default:
+			if option.Mandatory() {
+				fs.Logf(o, "Unsupported mandatory option: %v", option)
+			}
+		}
+	}
+	r := &chunkReader{
+		ctx:       ctx,
+		f:         o.f,
+		chunks:    o.m.Chunks,
+		remaining: limit,
+	}
+	// Skip the chunks before offset
+	for r.idx < len(r.chunks) && offset >= r.chunks[r.idx].Size {
+		offset -= r.chunks[r.idx].Size
+		r.idx++
+	}
+	r.skip = offset
+	return r, nil
+}
+
+// chunkReader reads a range of a file from its chunks
+type chunkReader struct {
+	ctx       context.Context
+	f         *Fs
+	chunks    []chunkRef
+	idx       int           // index of the current chunk
+	skip      int64         // offset to start reading the current chunk at
+	remaining int64         // bytes left to read or -1 for all
+	in        io.ReadCloser // current chunk or nil
+	want      int64         // bytes expected from in
+	read      int64         // bytes read from in
+	hasher    hash.Hash     // set if reading the whole chunk to check it
+}
+
+// open the current chunk
+func (r *chunkReader) open() error {
+	chunk := r.chunks[r.idx]
+	o, err := r.f.chunks.NewObject(r.ctx, chunkName(chunk.Hash))
+	if err != nil {
+		return fmt.Errorf("failed to find chunk %s: %w", chunk.Hash, err)
+	}
+	end := chunk.Size
+	if r.remaining >= 0 && r.skip+r.remaining < end {
+		end = r.skip + r.remaining
+	}
+	var options []fs.OpenOption
+	r.hasher = nil
+	if r.skip == 0 && end == chunk.Size {
+		r.hasher = sha256.New()
+	} else {
+		options = append(options, &fs.RangeOption{Start: r.skip, End: end - 1})
+	}
+	r.in, err = o.Open(r.ctx, options...)
+	if err != nil {
+		return fmt.Errorf("failed to open chunk %s: %w", chunk.Hash, err)
+	}
+	r.want = end - r.skip
+	r.read = 0
+	return nil
+}
+
+// finish checks the current chunk was read correctly and closes it
+func (r *chunkReader) finish() error {
+	chunk := r.chunks[r.idx]
+	err := r.in.Close()
+	r.in = nil
+	r.idx++
+	r.skip = 0
+	if err != nil {
+		return err
+	}
+	if r.read != r.want {
+		return fmt.Errorf("chunk %s truncated: read %d bytes but expected %d: %w", chunk.Hash, r.read, r.want, io.ErrUnexpectedEOF)
+	}
+	if r.hasher != nil && hex.EncodeToString(r.hasher.Sum(nil)) != chunk.Hash {
+		return fmt.Errorf("chunk %s corrupted: SHA-256 mismatch", chunk.Hash)
+	}
+	return nil
+}
+
+// Read bytes from the chunks
+func (r *chunkReader) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		if r.in == nil {
+			if r.remaining == 0 || r.idx >= len(r.chunks) {
+				return 0, io.EOF
+			}
+			if err = r.open(); err != nil {
+				return 0, err
+			}
+		}
+		buf := p
+		if left := r.want - r.read; int64(len(buf)) > left {
+			buf = buf[:left]
+		}
+		if len(buf) > 0 {
+			n, err = r.in.Read(buf)
+		} else {
+			err = io.EOF
+		}
+		r.read += int64(n)
+		if r.remaining > 0 {
+			r.remaining -= int64(n)
+		}
+		if r.hasher != nil {
+			_, _ = r.hasher.Write(buf[:n])
+		}
+		if err == io.EOF {
+			if err = r.finish(); err != nil {
+				return n, err
+			}
+			if n == 0 {
+				continue
+			}
+		}
+		return n, err
+	}
+}
+
+// Close the reader
+func (r *chunkReader) Close() error {
+	if r.in == nil {
+		return nil
+	}
+	err := r.in.Close()
+	r.in = nil
+	return err
+}
@@ -1306,6 +1306,11 @@ func NewFs(ctx context.Context, name, path string, m configmap.Mapper) (fs.Fs, e
 	if err != nil {
 		return nil, err
 	}
+	if f.importMimeTypes != nil && !f.opt.SkipGdocs {
+		// Multipart uploads can't convert files into documents
+		f.features.OpenChunkWriter = nil
+		f.features.ResumeChunkWriter = nil
+	}
 
 	// Find the current root
 	err = f.dirCache.FindRoot(ctx, false)
@@ -4008,31 +4013,33 @@ Type=Link
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs              = (*Fs)(nil)
-	_ fs.Purger          = (*Fs)(nil)
-	_ fs.CleanUpper      = (*Fs)(nil)
-	_ fs.PutStreamer     = (*Fs)(nil)
-	_ fs.Copier          = (*Fs)(nil)
-	_ fs.Mover           = (*Fs)(nil)
-	_ fs.DirMover        = (*Fs)(nil)
-	_ fs.Commander       = (*Fs)(nil)
-	_ fs.DirCacheFlusher = (*Fs)(nil)
-	_ fs.ChangeNotifier  = (*Fs)(nil)
-	_ fs.PutUncheckeder  = (*Fs)(nil)
-	_ fs.PublicLinker    = (*Fs)(nil)
-	_ fs.ListRer         = (*Fs)(nil)
-	_ fs.MergeDirser     = (*Fs)(nil)
-	_ fs.Abouter         = (*Fs)(nil)
-	_ fs.Object          = (*Object)(nil)
-	_ fs.MimeTyper       = (*Object)(nil)
-	_ fs.IDer            = (*Object)(nil)
-	_ fs.ParentIDer      = (*Object)(nil)
-	_ fs.Object          = (*documentObject)(nil)
-	_ fs.MimeTyper       = (*documentObject)(nil)
-	_ fs.IDer            = (*documentObject)(nil)
-	_ fs.ParentIDer      = (*documentObject)(nil)
-	_ fs.Object          = (*linkObject)(nil)
-	_ fs.MimeTyper       = (*linkObject)(nil)
-	_ fs.IDer            = (*linkObject)(nil)
-	_ fs.ParentIDer      = (*linkObject)(nil)
+	_ fs.Fs                = (*Fs)(nil)
+	_ fs.Purger            = (*Fs)(nil)
+	_ fs.CleanUpper        = (*Fs)(nil)
+	_ fs.PutStreamer       = (*Fs)(nil)
+	_ fs.Copier            = (*Fs)(nil)
+	_ fs.Mover             = (*Fs)(nil)
+	_ fs.DirMover          = (*Fs)(nil)
+	_ fs.Commander         = (*Fs)(nil)
+	_ fs.DirCacheFlusher   = (*Fs)(nil)
+	_ fs.ChangeNotifier    = (*Fs)(nil)
+	_ fs.PutUncheckeder    = (*Fs)(nil)
+	_ fs.PublicLinker      = (*Fs)(nil)
+	_ fs.ListRer           = (*Fs)(nil)
+	_ fs.MergeDirser       = (*Fs)(nil)
+	_ fs.Abouter           = (*Fs)(nil)
+	_ fs.OpenChunkWriter   = (*Fs)(nil)
+	_ fs.ResumeChunkWriter = (*Fs)(nil)
+	_ fs.Object            = (*Object)(nil)
+	_ fs.MimeTyper         = (*Object)(nil)
+	_ fs.IDer              = (*Object)(nil)
+	_ fs.ParentIDer        = (*Object)(nil)
+	_ fs.Object            = (*documentObject)(nil)
+	_ fs.MimeTyper         = (*documentObject)(nil)
+	_ fs.IDer              = (*documentObject)(nil)
+	_ fs.ParentIDer        = (*documentObject)(nil)
+	_ fs.Object            = (*linkObject)(nil)
+	_ fs.MimeTyper         = (*linkObject)(nil)
+	_ fs.IDer              = (*linkObject)(nil)
+	_ fs.ParentIDer        = (*linkObject)(nil)
 )
@@ -14,11 +14,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/fserrors"
@@ -51,6 +54,17 @@ type resumableUpload struct {
 
 // Upload the io.Reader in of size bytes with contentType and info
 func (f *Fs) Upload(ctx context.Context, in io.Reader, size int64, contentType, fileID, remote string, info *drive.File) (*drive.File, error) {
+	rx, err := f.startUpload(ctx, size, contentType, fileID, remote, info)
+	if err != nil {
+		return nil, err
+	}
+	rx.Media = in
+	return rx.Upload(ctx)
+}
+
+// startUpload starts a resumable upload session for an object of
+// size bytes with contentType and info, updating fileID if set.
+func (f *Fs) startUpload(ctx context.Context, size int64, contentType, fileID, remote string, info *drive.File) (*resumableUpload, error) {
 	params := url.Values{
 		"alt":        {"json"},
 		"uploadType": {"resumable"},
@@ -99,16 +113,13 @@ func (f *Fs) Upload(ctx context.Context, in io.Reader, size int64, contentType,
 	if err != nil {
 		return nil, err
 	}
-	loc := res.Header.Get("Location")
-	rx := &resumableUpload{
+	return &resumableUpload{
 		f:             f,
 		remote:        remote,
-		URI:           loc,
-		Media:         in,
+		URI:           res.Header.Get("Location"),
 		MediaType:     contentType,
 		ContentLength: size,
-	}
-	return rx.Upload(ctx)
+	}, nil
 }
 
 // Make an http.Request for the range passed in
@@ -240,3 +251,292 @@ func (rx *resumableUpload) Upload(ctx context.Context) (*drive.File, error) {
 	}
 	return rx.ret, nil
 }
+
+// OpenChunkWriter returns the chunk size and a ChunkWriter
+//
+// Pass in the remote and the src object
+// You can also use options to hint at the desired chunk size
+//
+// Drive resumable uploads must be sent in order, so the chunks are
+// uploaded one at a time in order whatever the concurrency.
+func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	size := src.Size()
+	if size < 0 {
+		return info, nil, errors.New("multipart upload needs the size of the object")
+	}
+	srcMimeType := fs.MimeType(ctx, src)
+	var (
+		fileID     string
+		uploadInfo *drive.File
+	)
+	existingObj, err := f.NewObject(ctx, remote)
+	switch err {
+	case nil:
+		o, ok := existingObj.(*Object)
+		if !ok {
+			return info, nil, fmt.Errorf("can't use multipart upload to replace %T", existingObj)
+		}
+		if isShortcutID(o.id) {
+			// Delete the shortcut and upload a new file
+			err = f.delete(ctx, shortcutID(o.id), f.opt.UseTrash)
+			if err != nil {
+				return info, nil, err
+			}
+		} else {
+			fileID = o.id
+			uploadInfo = &drive.File{
+				MimeType:     srcMimeType,
+				ModifiedTime: src.ModTime(ctx).Format(timeFormatOut),
+			}
+		}
+	case fs.ErrorObjectNotFound:
+	default:
+		return info, nil, err
+	}
+	if uploadInfo == nil {
+		uploadInfo, err = f.createFileInfo(ctx, remote, src.ModTime(ctx))
+		if err != nil {
+			return info, nil, err
+		}
+		uploadInfo.MimeType = fs.MimeTypeFromName(remote)
+	}
+	rx, err := f.startUpload(ctx, size, srcMimeType, fileID, remote, uploadInfo)
+	if err != nil {
+		return info, nil, fmt.Errorf("failed to start resumable upload: %w", err)
+	}
+	return f.newChunkWriter(rx, 0)
+}
+
+// driveResumeState is the state of a resumable upload saved so it
+// can be carried on by ResumeChunkWriter
+type driveResumeState struct {
+	URI       string `json:"uri"`       // the resumable upload session
+	MediaType string `json:"mediaType"` // content type of the upload
+	Size      int64  `json:"size"`      // size of the object
+	ChunkSize int64  `json:"chunkSize"` // size of the chunks
+	Offset    int64  `json:"offset"`    // bytes known to be uploaded
+}
+
+// ResumeChunkWriter carries on with the resumable upload session
+// described by state, which was returned by ResumeState.
+//
+// Upload sessions expire after about a week, in which case this
+// returns an error.
+func (f *Fs) ResumeChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, state []byte, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	var rs driveResumeState
+	err = json.Unmarshal(state, &rs)
+	if err != nil {
+		return info, nil, fmt.Errorf("failed to decode resumable upload state: %w", err)
+	}
+	if rs.URI == "" || rs.ChunkSize != int64(f.opt.ChunkSize) || rs.Size != src.Size() {
+		return info, nil, errors.New("invalid resumable upload state")
+	}
+	rx := &resumableUpload{
+		f:             f,
+		remote:        remote,
+		URI:           rs.URI,
+		MediaType:     rs.MediaType,
+		ContentLength: rs.Size,
+	}
+	offset, err := rx.status(ctx)
+	if err != nil {
+		return info, nil, fmt.Errorf("failed to find resumable upload: %w", err)
+	}
+	if offset < rs.Offset {
+		return info, nil, fmt.Errorf("resumable upload only has %d of the %d bytes uploaded", offset, rs.Offset)
+	}
+	return f.newChunkWriter(rx, offset)
+}
+
+// newChunkWriter makes a ChunkWriter for rx which has had offset
+// bytes uploaded
+func (f *Fs) newChunkWriter(rx *resumableUpload, offset int64) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	w := &driveChunkWriter{
+		rx:        rx,
+		chunkSize: int64(f.opt.ChunkSize),
+		offset:    offset,
+	}
+	w.cond = sync.NewCond(&w.mu)
+	info = fs.ChunkWriterInfo{
+		ChunkSize:   w.chunkSize,
+		Concurrency: 1,
+	}
+	fs.Debugf(rx.remote, "open chunk writer: started resumable upload at offset %d", offset)
+	return info, w, nil
+}
+
+// status reads how many bytes the server has received for the upload.
+//
+// If the upload has finished it reads the uploaded file into rx.ret.
+func (rx *resumableUpload) status(ctx context.Context) (offset int64, err error) {
+	var res *http.Response
+	err = rx.f.pacer.Call(func() (bool, error) {
+		req := rx.makeRequest(ctx, 0, nil, 0)
+		res, err = rx.f.client.Do(req)
+		if err == nil && res.StatusCode != statusResumeIncomplete {
+			err = googleapi.CheckResponse(res)
+			if err != nil {
+				googleapi.CloseBody(res)
+			}
+		}
+		return rx.f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer googleapi.CloseBody(res)
+	if res.StatusCode != statusResumeIncomplete {
+		// The upload is complete
+		if err = json.NewDecoder(res.Body).Decode(&rx.ret); err != nil {
+			return 0, err
+		}
+		return rx.ContentLength, nil
+	}
+	// The Range header is "bytes=0-N" or missing if nothing has been received
+	received := res.Header.Get("Range")
+	if received == "" {
+		return 0, nil
+	}
+	dash := strings.LastIndexByte(received, '-')
+	if !strings.HasPrefix(received, "bytes=0-") || dash < 0 {
+		return 0, fmt.Errorf("bad Range header %q", received)
+	}
+	end, err := strconv.ParseInt(received[dash+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad Range header %q: %w", received, err)
+	}
+	return end + 1, nil
+}
+
+// driveChunkWriter uploads a file in chunks with a resumable upload
+// session
+type driveChunkWriter struct {
+	rx        *resumableUpload
+	chunkSize int64
+	mu        sync.Mutex
+	cond      *sync.Cond // signalled when offset or err change
+	offset    int64      // bytes uploaded so far
+	err       error      // set if a chunk failed
+}
+
+// WriteChunk will write chunk number with reader bytes, where chunk
+// number >= 0
+//
+// The chunks must be uploaded in order so this waits for the
+// previous chunks to be written first.
+func (w *driveChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (bytesWritten int64, err error) {
+	if chunkNumber < 0 {
+		return -1, fmt.Errorf("invalid chunk number provided: %v", chunkNumber)
+	}
+	// The reader may be streamed so work out the size rather than
+	// seeking to the end
+	start := int64(chunkNumber) * w.chunkSize
+	size := w.rx.ContentLength - start
+	if size > w.chunkSize {
+		size = w.chunkSize
+	}
+	if size <= 0 {
+		return -1, fmt.Errorf("invalid chunk number %d for size %d", chunkNumber, w.rx.ContentLength)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.offset < start && w.err == nil {
+		w.cond.Wait()
+	}
+	if w.err != nil {
+		return -1, fmt.Errorf("not uploading chunk %d as a previous chunk failed: %w", chunkNumber, w.err)
+	}
+	defer w.cond.Broadcast()
+
+	// Skip any of the chunk uploaded before the upload was resumed
+	skip := w.offset - start
+	if skip >= size {
+		return size, nil
+	}
+	var chunk io.ReadSeeker = reader
+	if skip > 0 {
+		_, err = io.CopyN(io.Discard, reader, skip)
+		if err == nil {
+			var data []byte
+			data, err = io.ReadAll(reader)
+			chunk = bytes.NewReader(data)
+		}
+		if err != nil {
+			w.err = err
+			return -1, err
+		}
+	}
+	reqSize := size - skip
+
+	var statusCode int
+	err = w.rx.f.pacer.Call(func() (bool, error) {
+		fs.Debugf(w.rx.remote, "Sending chunk %d offset %d length %d", chunkNumber, w.offset, reqSize)
+		statusCode, err = w.rx.transferChunk(ctx, w.offset, chunk, reqSize)
+		again, err := w.rx.f.shouldRetry(ctx, err)
+		if statusCode == statusResumeIncomplete || statusCode == http.StatusCreated || statusCode == http.StatusOK {
+			again = false
+			err = nil
+		}
+		return again, err
+	})
+	if err != nil {
+		w.err = err
+		return -1, fmt.Errorf("failed to upload chunk %d: %w", chunkNumber, err)
+	}
+	w.offset += reqSize
+	return size, nil
+}
+
+// Close completes the upload
+func (w *driveChunkWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.rx.ret == nil {
+		return fmt.Errorf("resumable upload incomplete after %d of %d bytes", w.offset, w.rx.ContentLength)
+	}
+	if w.rx.ret.Size != w.rx.ContentLength {
+		return fmt.Errorf("uploaded file has size %d but expected %d", w.rx.ret.Size, w.rx.ContentLength)
+	}
+	fs.Debugf(w.rx.remote, "multipart upload finished")
+	return nil
+}
+
+// Abort cancels the upload session
+func (w *driveChunkWriter) Abort(ctx context.Context) error {
+	err := w.rx.f.pacer.Call(func() (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, "DELETE", w.rx.URI, nil)
+		if err != nil {
+			return false, err
+		}
+		res, err := w.rx.f.client.Do(req)
+		if err != nil {
+			return w.rx.f.shouldRetry(ctx, err)
+		}
+		defer googleapi.CloseBody(res)
+		// A cancelled upload returns 499 and an unknown one 404
+		if res.StatusCode == 499 || res.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return w.rx.f.shouldRetry(ctx, googleapi.CheckResponse(res))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel resumable upload: %w", err)
+	}
+	fs.Debugf(w.rx.remote, "multipart upload cancelled")
+	return nil
+}
+
+// ResumeState returns the state of the upload which can be passed to
+// ResumeChunkWriter
+func (w *driveChunkWriter) ResumeState() ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return json.Marshal(driveResumeState{
+		URI:       w.rx.URI,
+		MediaType: w.rx.MediaType,
+		Size:      w.rx.ContentLength,
+		ChunkSize: w.chunkSize,
+		Offset:    w.offset,
+	})
+}
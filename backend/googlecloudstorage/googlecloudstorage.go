@@ -13,13 +13,17 @@ FIXME Patch/Delete/Get isn't working with files with spaces in - giving 404 erro
 */
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
@@ -28,6 +32,7 @@ import (
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/chunksize"
 	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/configstruct"
@@ -41,6 +46,7 @@ import (
 	"github.com/rclone/rclone/lib/env"
 	"github.com/rclone/rclone/lib/oauthutil"
 	"github.com/rclone/rclone/lib/pacer"
+	"github.com/rclone/rclone/lib/rest"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/googleapi"
@@ -57,6 +63,8 @@ const (
 	metaMtime                   = "mtime"                    // key to store mtime in metadata
 	metaMtimeGsutil             = "goog-reserved-file-mtime" // key used by GSUtil to store mtime in metadata
 	listChunks                  = 1000                       // chunk size to read directory listings
+	maxUploadParts              = 10000                      // maximum allowed number of parts in a multipart upload
+	defaultChunkSize            = 16 * fs.Mebi               // part size for multipart uploads
 	minSleep                    = 10 * time.Millisecond
 )
 
@@ -1308,6 +1316,238 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	return nil
 }
 
+// xmlACLs maps the predefined ACL names used by the JSON API onto
+// the canned ACL names used by the XML API
+var xmlACLs = map[string]string{
+	"authenticatedRead":      "authenticated-read",
+	"bucketOwnerFullControl": "bucket-owner-full-control",
+	"bucketOwnerRead":        "bucket-owner-read",
+	"private":                "private",
+	"projectPrivate":         "project-private",
+	"publicRead":             "public-read",
+	"publicReadWrite":        "public-read-write",
+}
+
+// initiateMultipartUploadResult is returned when a multipart upload is started
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// completeMultipartUpload is sent to finish a multipart upload
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// completedPart describes a part of a multipart upload
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// xmlURL returns the XML API URL for bucketPath in bucket with the
+// raw query given
+//
+// The multipart upload is only available in the XML API so this
+// uses the host of the endpoint if one is set.
+func (f *Fs) xmlURL(bucket, bucketPath, rawQuery string) string {
+	root := "https://storage.googleapis.com"
+	if f.opt.Endpoint != "" {
+		if u, err := url.Parse(f.opt.Endpoint); err == nil && u.Host != "" {
+			root = u.Scheme + "://" + u.Host
+		}
+	}
+	return root + rest.URLPathEscape("/"+bucket+"/"+bucketPath) + "?" + rawQuery
+}
+
+// xmlCall makes an XML API call to rawURL sending body if set and
+// decoding the reply into result if set
+//
+// body is seeked to the start before each try.
+func (f *Fs) xmlCall(ctx context.Context, method, rawURL string, header http.Header, body io.ReadSeeker, result interface{}) (resHeader http.Header, err error) {
+	err = f.pacer.Call(func() (bool, error) {
+		var in io.Reader
+		var size int64
+		if body != nil {
+			size, err = body.Seek(0, io.SeekEnd)
+			if err != nil {
+				return false, err
+			}
+			if _, err = body.Seek(0, io.SeekStart); err != nil {
+				return false, err
+			}
+			in = io.NopCloser(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, rawURL, in)
+		if err != nil {
+			return false, err
+		}
+		req.ContentLength = size
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if f.opt.UserProject != "" {
+			req.Header.Set("x-goog-user-project", f.opt.UserProject)
+		}
+		res, err := f.client.Do(req)
+		if err == nil {
+			err = googleapi.CheckResponse(res)
+			if err == nil && result != nil {
+				err = xml.NewDecoder(res.Body).Decode(result)
+			}
+			_ = res.Body.Close() // ignore error
+		}
+		if err == nil {
+			resHeader = res.Header
+		}
+		return shouldRetry(ctx, err)
+	})
+	return resHeader, err
+}
+
+// OpenChunkWriter returns the chunk size and a ChunkWriter
+//
+// Pass in the remote and the src object
+func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	// Temporary Object under construction
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	size := src.Size()
+	if size < 0 {
+		return info, nil, errors.New("can't use chunked upload with unknown size")
+	}
+	bucket, bucketPath := o.split()
+	err = f.checkBucket(ctx, bucket)
+	if err != nil {
+		return info, nil, err
+	}
+	chunkSize := chunksize.Calculator(o, size, maxUploadParts, defaultChunkSize)
+	numChunks := int(fs.SizeSuffix(size) / chunkSize)
+	if fs.SizeSuffix(size)%chunkSize != 0 || numChunks == 0 {
+		numChunks++
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", fs.MimeType(ctx, src))
+	for k, v := range metadataFromModTime(src.ModTime(ctx)) {
+		header.Set("x-goog-meta-"+k, v)
+	}
+	if !f.opt.BucketPolicyOnly {
+		if acl, ok := xmlACLs[f.opt.ObjectACL]; ok {
+			header.Set("x-goog-acl", acl)
+		} else {
+			header.Set("x-goog-acl", f.opt.ObjectACL)
+		}
+	}
+	fs.OpenOptionAddHTTPHeaders(header, options)
+
+	var result initiateMultipartUploadResult
+	_, err = f.xmlCall(ctx, "POST", f.xmlURL(bucket, bucketPath, "uploads"), header, nil, &result)
+	if err != nil {
+		return info, nil, fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	if result.UploadID == "" {
+		return info, nil, errors.New("failed to start multipart upload: no upload ID returned")
+	}
+	fs.Debugf(o, "Multipart upload session started for %d parts of size %v", numChunks, chunkSize)
+	w := &gcsChunkWriter{
+		o:          o,
+		bucket:     bucket,
+		bucketPath: bucketPath,
+		uploadID:   result.UploadID,
+		parts:      make([]completedPart, numChunks),
+	}
+	info = fs.ChunkWriterInfo{
+		ChunkSize:   int64(chunkSize),
+		Concurrency: fs.GetConfig(ctx).Transfers,
+	}
+	return info, w, nil
+}
+
+// gcsChunkWriter uploads an object with an XML API multipart upload
+type gcsChunkWriter struct {
+	o          *Object
+	bucket     string
+	bucketPath string
+	uploadID   string
+	partsMu    sync.Mutex      // protects parts
+	parts      []completedPart // indexed by chunk number, ETag "" if not written
+}
+
+// uploadURL returns the URL for the upload with the extra query given
+func (w *gcsChunkWriter) uploadURL(query string) string {
+	rawQuery := "uploadId=" + url.QueryEscape(w.uploadID)
+	if query != "" {
+		rawQuery = query + "&" + rawQuery
+	}
+	return w.o.fs.xmlURL(w.bucket, w.bucketPath, rawQuery)
+}
+
+// WriteChunk uploads chunk number chunkNumber reading the data from reader
+func (w *gcsChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	if chunkNumber < 0 || chunkNumber >= len(w.parts) {
+		return -1, fmt.Errorf("invalid chunk number %d - want 0..%d", chunkNumber, len(w.parts)-1)
+	}
+	md5er := md5.New()
+	n, err := io.Copy(md5er, reader)
+	if err != nil {
+		return -1, fmt.Errorf("multipart upload failed to read part: %w", err)
+	}
+	header := make(http.Header)
+	header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5er.Sum(nil)))
+	partNumber := chunkNumber + 1
+	fs.Debugf(w.o, "Uploading part %d/%d size %d", partNumber, len(w.parts), n)
+	resHeader, err := w.o.fs.xmlCall(ctx, "PUT", w.uploadURL("partNumber="+strconv.Itoa(partNumber)), header, reader, nil)
+	if err != nil {
+		return -1, fmt.Errorf("multipart upload failed to upload part: %w", err)
+	}
+	eTag := resHeader.Get("ETag")
+	if eTag == "" {
+		return -1, fmt.Errorf("multipart upload failed to upload part %d: no ETag returned", partNumber)
+	}
+	w.partsMu.Lock()
+	w.parts[chunkNumber] = completedPart{
+		PartNumber: partNumber,
+		ETag:       eTag,
+	}
+	w.partsMu.Unlock()
+	return n, nil
+}
+
+// Close completes the multipart upload
+func (w *gcsChunkWriter) Close(ctx context.Context) error {
+	w.partsMu.Lock()
+	defer w.partsMu.Unlock()
+	for i, part := range w.parts {
+		if part.ETag == "" {
+			return fmt.Errorf("multipart upload failed to finalize: part %d not uploaded", i+1)
+		}
+	}
+	body, err := xml.Marshal(completeMultipartUpload{Parts: w.parts})
+	if err != nil {
+		return fmt.Errorf("multipart upload failed to finalize: %w", err)
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/xml")
+	_, err = w.o.fs.xmlCall(ctx, "POST", w.uploadURL(""), header, bytes.NewReader(body), nil)
+	if err != nil {
+		return fmt.Errorf("multipart upload failed to finalize: %w", err)
+	}
+	return nil
+}
+
+// Abort the multipart upload removing any parts uploaded
+func (w *gcsChunkWriter) Abort(ctx context.Context) error {
+	_, err := w.o.fs.xmlCall(ctx, "DELETE", w.uploadURL(""), nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload %q: %w", w.uploadID, err)
+	}
+	fs.Debugf(w.o, "multipart upload %q aborted", w.uploadID)
+	return nil
+}
+
 // Remove an object
 func (o *Object) Remove(ctx context.Context) (err error) {
 	bucket, bucketPath := o.split()
@@ -1329,10 +1569,11 @@ func (o *Object) MimeType(ctx context.Context) string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Copier      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.ListRer     = &Fs{}
-	_ fs.Object      = &Object{}
-	_ fs.MimeTyper   = &Object{}
+	_ fs.Fs              = &Fs{}
+	_ fs.Copier          = &Fs{}
+	_ fs.PutStreamer     = &Fs{}
+	_ fs.ListRer         = &Fs{}
+	_ fs.OpenChunkWriter = &Fs{}
+	_ fs.Object          = &Object{}
+	_ fs.MimeTyper       = &Object{}
 )
@@ -23,6 +23,8 @@ func TestIntegration(t *testing.T) {
 		NilObject:  (*hasher.Object)(nil),
 		UnimplementableFsMethods: []string{
 			"OpenWriterAt",
+			"OpenChunkWriter",
+			"ResumeChunkWriter",
 		},
 		UnimplementableObjectMethods: []string{},
 	}
@@ -5074,16 +5074,134 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.Read
 
 var warnStreamUpload sync.Once
 
-func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, size int64, in io.Reader) (wantETag, gotETag string, versionID *string, err error) {
-	f := o.fs
+// OpenChunkWriter returns the chunk size and a ChunkWriter
+//
+// Pass in the remote and the src object
+func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	if f.opt.VersionAt.IsSet() {
+		return info, nil, errNotWithVersionAt
+	}
+	// Temporary Object under construction
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	bucket, _ := o.split()
+	err = f.makeBucket(ctx, bucket)
+	if err != nil {
+		return info, nil, err
+	}
+	req, _, err := o.buildS3Req(ctx, src, options, true)
+	if err != nil {
+		return info, nil, err
+	}
+	chunkWriter, err := f.newChunkWriter(ctx, o, req, src.Size())
+	if err != nil {
+		return info, nil, err
+	}
+	info = fs.ChunkWriterInfo{
+		ChunkSize:         chunkWriter.chunkSize,
+		Concurrency:       chunkWriter.concurrency,
+		LeavePartsOnError: f.opt.LeavePartsOnError,
+	}
+	fs.Debugf(o, "open chunk writer: started multipart upload: %v", *chunkWriter.uploadID)
+	return info, chunkWriter, nil
+}
 
-	// make concurrency machinery
-	concurrency := f.opt.UploadConcurrency
-	if concurrency < 1 {
-		concurrency = 1
+// ResumeChunkWriter carries on with the multipart upload described by
+// state from a ChunkWriter returned by OpenChunkWriter
+func (f *Fs) ResumeChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, state []byte, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	if f.opt.VersionAt.IsSet() {
+		return info, nil, errNotWithVersionAt
+	}
+	var rs s3ResumeState
+	err = json.Unmarshal(state, &rs)
+	if err != nil {
+		return info, nil, fmt.Errorf("failed to decode multipart upload state: %w", err)
+	}
+	if rs.UploadID == "" || rs.ChunkSize <= 0 {
+		return info, nil, errors.New("invalid multipart upload state")
+	}
+	// Temporary Object under construction
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	req, _, err := o.buildS3Req(ctx, src, options, true)
+	if err != nil {
+		return info, nil, err
+	}
+	// Check the upload still exists
+	err = f.pacer.Call(func() (bool, error) {
+		_, err := f.c.ListPartsWithContext(ctx, &s3.ListPartsInput{
+			Bucket:       req.Bucket,
+			Key:          req.Key,
+			UploadId:     &rs.UploadID,
+			MaxParts:     aws.Int64(1),
+			RequestPayer: req.RequestPayer,
+		})
+		return f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return info, nil, fmt.Errorf("failed to find multipart upload %q: %w", rs.UploadID, err)
+	}
+	chunkWriter := &s3ChunkWriter{
+		f:           f,
+		o:           o,
+		req:         req,
+		uploadID:    aws.String(rs.UploadID),
+		chunkSize:   rs.ChunkSize,
+		concurrency: f.uploadConcurrency(),
+		md5s:        rs.MD5s,
+	}
+	for _, part := range rs.Parts {
+		chunkWriter.parts = append(chunkWriter.parts, &s3.CompletedPart{
+			PartNumber: aws.Int64(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		})
 	}
-	tokens := pacer.NewTokenDispenser(concurrency)
+	info = fs.ChunkWriterInfo{
+		ChunkSize:         chunkWriter.chunkSize,
+		Concurrency:       chunkWriter.concurrency,
+		LeavePartsOnError: f.opt.LeavePartsOnError,
+	}
+	fs.Debugf(o, "resume chunk writer: resumed multipart upload %v with %d parts", rs.UploadID, len(rs.Parts))
+	return info, chunkWriter, nil
+}
+
+// s3ResumeState is the state of a multipart upload saved so it can
+// be resumed
+type s3ResumeState struct {
+	UploadID  string          `json:"uploadId"`
+	ChunkSize int64           `json:"chunkSize"`
+	Parts     []s3ResumedPart `json:"parts"`
+	MD5s      []byte          `json:"md5s"`
+}
+
+// s3ResumedPart is a part uploaded before the upload was resumed
+type s3ResumedPart struct {
+	PartNumber int64  `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
 
+// s3ChunkWriter uploads an object with a multipart upload
+type s3ChunkWriter struct {
+	f           *Fs
+	o           *Object
+	req         *s3.PutObjectInput
+	uploadID    *string
+	chunkSize   int64
+	concurrency int
+	partsMu     sync.Mutex // to protect parts and md5s
+	parts       []*s3.CompletedPart
+	md5s        []byte
+	eTag        string  // ETag from the completed upload
+	versionID   *string // versionID from the completed upload
+}
+
+// newChunkWriter starts a multipart upload of size bytes (or -1 if
+// unknown) described by req
+func (f *Fs) newChunkWriter(ctx context.Context, o *Object, req *s3.PutObjectInput, size int64) (*s3ChunkWriter, error) {
 	uploadParts := f.opt.MaxUploadParts
 	if uploadParts < 1 {
 		uploadParts = 1
@@ -5106,21 +5224,213 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 		partSize = chunksize.Calculator(o, size, uploadParts, f.opt.ChunkSize)
 	}
 
-	memPool := f.getMemoryPool(int64(partSize))
-
 	var mReq s3.CreateMultipartUploadInput
 	//structs.SetFrom(&mReq, req)
 	setFrom_s3CreateMultipartUploadInput_s3PutObjectInput(&mReq, req)
 	var cout *s3.CreateMultipartUploadOutput
-	err = f.pacer.Call(func() (bool, error) {
+	err := f.pacer.Call(func() (bool, error) {
 		var err error
 		cout, err = f.c.CreateMultipartUploadWithContext(ctx, &mReq)
 		return f.shouldRetry(ctx, err)
 	})
 	if err != nil {
-		return wantETag, gotETag, nil, fmt.Errorf("multipart upload failed to initialise: %w", err)
+		return nil, fmt.Errorf("multipart upload failed to initialise: %w", err)
 	}
-	uid := cout.UploadId
+
+	return &s3ChunkWriter{
+		f:           f,
+		o:           o,
+		req:         req,
+		uploadID:    cout.UploadId,
+		chunkSize:   int64(partSize),
+		concurrency: f.uploadConcurrency(),
+	}, nil
+}
+
+// uploadConcurrency returns the number of parts to upload at once
+func (f *Fs) uploadConcurrency() int {
+	if f.opt.UploadConcurrency < 1 {
+		return 1
+	}
+	return f.opt.UploadConcurrency
+}
+
+// addMd5 records the MD5 of chunk number chunkNumber
+func (w *s3ChunkWriter) addMd5(md5binary *[md5.Size]byte, chunkNumber int64) {
+	w.partsMu.Lock()
+	defer w.partsMu.Unlock()
+	start := chunkNumber * md5.Size
+	end := start + md5.Size
+	if extend := end - int64(len(w.md5s)); extend > 0 {
+		w.md5s = append(w.md5s, make([]byte, extend)...)
+	}
+	copy(w.md5s[start:end], (*md5binary)[:])
+}
+
+// addPart records a completed part, replacing the part with the same
+// number if it was uploaded already by a resumed upload
+func (w *s3ChunkWriter) addPart(part *s3.CompletedPart) {
+	w.partsMu.Lock()
+	defer w.partsMu.Unlock()
+	for i := range w.parts {
+		if *w.parts[i].PartNumber == *part.PartNumber {
+			w.parts[i] = part
+			return
+		}
+	}
+	w.parts = append(w.parts, part)
+}
+
+// WriteChunk uploads chunk number chunkNumber, counting from 0
+func (w *s3ChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	if chunkNumber < 0 {
+		return -1, fmt.Errorf("invalid chunk number provided: %v", chunkNumber)
+	}
+	f := w.f
+	// S3 part numbers count from 1
+	partNum := int64(chunkNumber) + 1
+
+	// create checksum of the chunk for integrity checking
+	hasher := md5.New()
+	partLength, err := io.Copy(hasher, reader)
+	if err != nil {
+		return -1, fmt.Errorf("multipart upload failed to read chunk: %w", err)
+	}
+	var md5sumBinary [md5.Size]byte
+	copy(md5sumBinary[:], hasher.Sum(nil))
+	w.addMd5(&md5sumBinary, partNum-1)
+	md5sum := base64.StdEncoding.EncodeToString(md5sumBinary[:])
+
+	var uout *s3.UploadPartOutput
+	err = f.pacer.Call(func() (bool, error) {
+		// rewind the reader on retry and after hashing
+		if _, err := reader.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		uploadPartReq := &s3.UploadPartInput{
+			Body:                 reader,
+			Bucket:               w.req.Bucket,
+			Key:                  w.req.Key,
+			PartNumber:           &partNum,
+			UploadId:             w.uploadID,
+			ContentMD5:           &md5sum,
+			ContentLength:        &partLength,
+			RequestPayer:         w.req.RequestPayer,
+			SSECustomerAlgorithm: w.req.SSECustomerAlgorithm,
+			SSECustomerKey:       w.req.SSECustomerKey,
+			SSECustomerKeyMD5:    w.req.SSECustomerKeyMD5,
+		}
+		var err error
+		uout, err = f.c.UploadPartWithContext(ctx, uploadPartReq)
+		if err != nil {
+			if partNum <= int64(w.concurrency) {
+				return f.shouldRetry(ctx, err)
+			}
+			// retry all chunks once have done the first batch
+			return true, err
+		}
+		return false, nil
+	})
+	if err != nil {
+		return -1, fmt.Errorf("multipart upload failed to upload part: %w", err)
+	}
+	w.addPart(&s3.CompletedPart{
+		PartNumber: &partNum,
+		ETag:       uout.ETag,
+	})
+
+	fs.Debugf(w.o, "multipart upload wrote chunk %d with %v bytes and etag %v", partNum, partLength, aws.StringValue(uout.ETag))
+	return partLength, nil
+}
+
+// Abort the multipart upload
+func (w *s3ChunkWriter) Abort(ctx context.Context) error {
+	f := w.f
+	err := f.pacer.Call(func() (bool, error) {
+		_, err := f.c.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:       w.req.Bucket,
+			Key:          w.req.Key,
+			UploadId:     w.uploadID,
+			RequestPayer: w.req.RequestPayer,
+		})
+		return f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload %q: %w", aws.StringValue(w.uploadID), err)
+	}
+	fs.Debugf(w.o, "multipart upload %q aborted", aws.StringValue(w.uploadID))
+	return nil
+}
+
+// ResumeState returns the state of the upload which can be passed to
+// ResumeChunkWriter
+func (w *s3ChunkWriter) ResumeState() ([]byte, error) {
+	w.partsMu.Lock()
+	defer w.partsMu.Unlock()
+	rs := s3ResumeState{
+		UploadID:  aws.StringValue(w.uploadID),
+		ChunkSize: w.chunkSize,
+		MD5s:      w.md5s,
+	}
+	for _, part := range w.parts {
+		rs.Parts = append(rs.Parts, s3ResumedPart{
+			PartNumber: aws.Int64Value(part.PartNumber),
+			ETag:       aws.StringValue(part.ETag),
+		})
+	}
+	return json.Marshal(&rs)
+}
+
+// Close and finalise the multipart upload
+func (w *s3ChunkWriter) Close(ctx context.Context) error {
+	f := w.f
+	// sort the completed parts by part number
+	sort.Slice(w.parts, func(i, j int) bool {
+		return *w.parts[i].PartNumber < *w.parts[j].PartNumber
+	})
+	var resp *s3.CompleteMultipartUploadOutput
+	err := f.pacer.Call(func() (bool, error) {
+		var err error
+		resp, err = f.c.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket: w.req.Bucket,
+			Key:    w.req.Key,
+			MultipartUpload: &s3.CompletedMultipartUpload{
+				Parts: w.parts,
+			},
+			RequestPayer: w.req.RequestPayer,
+			UploadId:     w.uploadID,
+		})
+		return f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return fmt.Errorf("multipart upload failed to finalise: %w", err)
+	}
+	if resp != nil {
+		if resp.ETag != nil {
+			w.eTag = *resp.ETag
+		}
+		w.versionID = resp.VersionId
+	}
+	return nil
+}
+
+// wantETag returns the ETag the completed multipart upload should have
+func (w *s3ChunkWriter) wantETag() string {
+	hashOfHashes := md5.Sum(w.md5s)
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(hashOfHashes[:]), len(w.parts))
+}
+
+func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, size int64, in io.Reader) (wantETag, gotETag string, versionID *string, err error) {
+	f := o.fs
+
+	chunkWriter, err := f.newChunkWriter(ctx, o, req, size)
+	if err != nil {
+		return wantETag, gotETag, nil, err
+	}
+
+	// make concurrency machinery
+	tokens := pacer.NewTokenDispenser(chunkWriter.concurrency)
+	memPool := f.getMemoryPool(chunkWriter.chunkSize)
 
 	uploadCtx, cancel := context.WithCancel(ctx)
 	defer atexit.OnError(&err, func() {
@@ -5129,15 +5439,7 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 			return
 		}
 		fs.Debugf(o, "Cancelling multipart upload")
-		errCancel := f.pacer.Call(func() (bool, error) {
-			_, err := f.c.AbortMultipartUploadWithContext(context.Background(), &s3.AbortMultipartUploadInput{
-				Bucket:       req.Bucket,
-				Key:          req.Key,
-				UploadId:     uid,
-				RequestPayer: req.RequestPayer,
-			})
-			return f.shouldRetry(ctx, err)
-		})
+		errCancel := chunkWriter.Abort(context.Background())
 		if errCancel != nil {
 			fs.Debugf(o, "Failed to cancel multipart upload: %v", errCancel)
 		}
@@ -5146,24 +5448,9 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 	var (
 		g, gCtx  = errgroup.WithContext(uploadCtx)
 		finished = false
-		partsMu  sync.Mutex // to protect parts
-		parts    []*s3.CompletedPart
 		off      int64
-		md5sMu   sync.Mutex
-		md5s     []byte
 	)
 
-	addMd5 := func(md5binary *[md5.Size]byte, partNum int64) {
-		md5sMu.Lock()
-		defer md5sMu.Unlock()
-		start := partNum * md5.Size
-		end := start + md5.Size
-		if extend := end - int64(len(md5s)); extend > 0 {
-			md5s = append(md5s, make([]byte, extend)...)
-		}
-		copy(md5s[start:end], (*md5binary)[:])
-	}
-
 	for partNum := int64(1); !finished; partNum++ {
 		// Get a block of memory from the pool and token which limits concurrency.
 		tokens.Get()
@@ -5202,48 +5489,8 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 		off += int64(n)
 		g.Go(func() (err error) {
 			defer free()
-			partLength := int64(len(buf))
-
-			// create checksum of buffer for integrity checking
-			md5sumBinary := md5.Sum(buf)
-			addMd5(&md5sumBinary, partNum-1)
-			md5sum := base64.StdEncoding.EncodeToString(md5sumBinary[:])
-
-			err = f.pacer.Call(func() (bool, error) {
-				uploadPartReq := &s3.UploadPartInput{
-					Body:                 bytes.NewReader(buf),
-					Bucket:               req.Bucket,
-					Key:                  req.Key,
-					PartNumber:           &partNum,
-					UploadId:             uid,
-					ContentMD5:           &md5sum,
-					ContentLength:        &partLength,
-					RequestPayer:         req.RequestPayer,
-					SSECustomerAlgorithm: req.SSECustomerAlgorithm,
-					SSECustomerKey:       req.SSECustomerKey,
-					SSECustomerKeyMD5:    req.SSECustomerKeyMD5,
-				}
-				uout, err := f.c.UploadPartWithContext(gCtx, uploadPartReq)
-				if err != nil {
-					if partNum <= int64(concurrency) {
-						return f.shouldRetry(gCtx, err)
-					}
-					// retry all chunks once have done the first batch
-					return true, err
-				}
-				partsMu.Lock()
-				parts = append(parts, &s3.CompletedPart{
-					PartNumber: &partNum,
-					ETag:       uout.ETag,
-				})
-				partsMu.Unlock()
-
-				return false, nil
-			})
-			if err != nil {
-				return fmt.Errorf("multipart upload failed to upload part: %w", err)
-			}
-			return nil
+			_, err = chunkWriter.WriteChunk(gCtx, int(partNum-1), bytes.NewReader(buf))
+			return err
 		})
 	}
 	err = g.Wait()
@@ -5251,36 +5498,11 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 		return wantETag, gotETag, nil, err
 	}
 
-	// sort the completed parts by part number
-	sort.Slice(parts, func(i, j int) bool {
-		return *parts[i].PartNumber < *parts[j].PartNumber
-	})
-
-	var resp *s3.CompleteMultipartUploadOutput
-	err = f.pacer.Call(func() (bool, error) {
-		resp, err = f.c.CompleteMultipartUploadWithContext(uploadCtx, &s3.CompleteMultipartUploadInput{
-			Bucket: req.Bucket,
-			Key:    req.Key,
-			MultipartUpload: &s3.CompletedMultipartUpload{
-				Parts: parts,
-			},
-			RequestPayer: req.RequestPayer,
-			UploadId:     uid,
-		})
-		return f.shouldRetry(uploadCtx, err)
-	})
+	err = chunkWriter.Close(uploadCtx)
 	if err != nil {
-		return wantETag, gotETag, nil, fmt.Errorf("multipart upload failed to finalise: %w", err)
-	}
-	hashOfHashes := md5.Sum(md5s)
-	wantETag = fmt.Sprintf("%s-%d", hex.EncodeToString(hashOfHashes[:]), len(parts))
-	if resp != nil {
-		if resp.ETag != nil {
-			gotETag = *resp.ETag
-		}
-		versionID = resp.VersionId
+		return wantETag, gotETag, nil, err
 	}
-	return wantETag, gotETag, versionID, nil
+	return chunkWriter.wantETag(), chunkWriter.eTag, chunkWriter.versionID, nil
 }
 
 // unWrapAwsError unwraps AWS errors, looking for a non AWS error
@@ -5410,22 +5632,15 @@ func (o *Object) uploadSinglepartPresignedRequest(ctx context.Context, req *s3.P
 	return etag, lastModified, versionID, nil
 }
 
-// Update the Object from in with modTime and size
-func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
-	if o.fs.opt.VersionAt.IsSet() {
-		return errNotWithVersionAt
-	}
+// buildS3Req makes the PutObjectInput to upload src to o
+//
+// It also returns the MD5 of src as hex if it was read.
+func (o *Object) buildS3Req(ctx context.Context, src fs.ObjectInfo, options []fs.OpenOption, multipart bool) (req *s3.PutObjectInput, md5sumHex string, err error) {
 	bucket, bucketPath := o.split()
-	err := o.fs.makeBucket(ctx, bucket)
-	if err != nil {
-		return err
-	}
 	modTime := src.ModTime(ctx)
 	size := src.Size()
 
-	multipart := size < 0 || size >= int64(o.fs.opt.UploadCutoff)
-
-	req := s3.PutObjectInput{
+	req = &s3.PutObjectInput{
 		Bucket: &bucket,
 		ACL:    stringPointerOrNil(o.fs.opt.ACL),
 		Key:    &bucketPath,
@@ -5434,7 +5649,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	// Fetch metadata if --metadata is in use
 	meta, err := fs.GetMetadataOptions(ctx, src, options)
 	if err != nil {
-		return fmt.Errorf("failed to read metadata from source object: %w", err)
+		return nil, "", fmt.Errorf("failed to read metadata from source object: %w", err)
 	}
 	req.Metadata = make(map[string]*string, len(meta)+2)
 	// merge metadata into request and user metadata
@@ -5486,7 +5701,6 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	// - for multipart provided checksums aren't disabled
 	//    - so we can add the md5sum in the metadata as metaMD5Hash
 	var md5sumBase64 string
-	var md5sumHex string
 	if !multipart || !o.fs.opt.DisableChecksum {
 		md5sumHex, err = src.Hash(ctx, hash.MD5)
 		if err == nil && matchMd5.MatchString(md5sumHex) {
@@ -5579,17 +5793,38 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		}
 	}
 
+	return req, md5sumHex, nil
+}
+
+// Update the Object from in with modTime and size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	if o.fs.opt.VersionAt.IsSet() {
+		return errNotWithVersionAt
+	}
+	bucket, _ := o.split()
+	err := o.fs.makeBucket(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	size := src.Size()
+	multipart := size < 0 || size >= int64(o.fs.opt.UploadCutoff)
+
+	req, md5sumHex, err := o.buildS3Req(ctx, src, options, multipart)
+	if err != nil {
+		return err
+	}
+
 	var wantETag string        // Multipart upload Etag to check
 	var gotETag string         // Etag we got from the upload
 	var lastModified time.Time // Time we got from the upload
 	var versionID *string      // versionID we got from the upload
 	if multipart {
-		wantETag, gotETag, versionID, err = o.uploadMultipart(ctx, &req, size, in)
+		wantETag, gotETag, versionID, err = o.uploadMultipart(ctx, req, size, in)
 	} else {
 		if o.fs.opt.UsePresignedRequest {
-			gotETag, lastModified, versionID, err = o.uploadSinglepartPresignedRequest(ctx, &req, size, in)
+			gotETag, lastModified, versionID, err = o.uploadSinglepartPresignedRequest(ctx, req, size, in)
 		} else {
-			gotETag, lastModified, versionID, err = o.uploadSinglepartPutObject(ctx, &req, size, in)
+			gotETag, lastModified, versionID, err = o.uploadSinglepartPutObject(ctx, req, size, in)
 		}
 	}
 	if err != nil {
@@ -5609,7 +5844,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	if o.fs.opt.NoHead && size >= 0 {
 		head = new(s3.HeadObjectOutput)
 		//structs.SetFrom(head, &req)
-		setFrom_s3HeadObjectOutput_s3PutObjectInput(head, &req)
+		setFrom_s3HeadObjectOutput_s3PutObjectInput(head, req)
 		head.ETag = &md5sumHex // doesn't matter quotes are missing
 		head.ContentLength = &size
 		// We get etag back from single and multipart upload so fill it in here
@@ -5748,16 +5983,19 @@ func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error)
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Purger      = &Fs{}
-	_ fs.Copier      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.ListRer     = &Fs{}
-	_ fs.Commander   = &Fs{}
-	_ fs.CleanUpper  = &Fs{}
-	_ fs.Object      = &Object{}
-	_ fs.MimeTyper   = &Object{}
-	_ fs.GetTierer   = &Object{}
-	_ fs.SetTierer   = &Object{}
-	_ fs.Metadataer  = &Object{}
+	_ fs.Fs                 = &Fs{}
+	_ fs.Purger             = &Fs{}
+	_ fs.Copier             = &Fs{}
+	_ fs.PutStreamer        = &Fs{}
+	_ fs.ListRer            = &Fs{}
+	_ fs.Commander          = &Fs{}
+	_ fs.CleanUpper         = &Fs{}
+	_ fs.OpenChunkWriter    = &Fs{}
+	_ fs.ResumeChunkWriter  = &Fs{}
+	_ fs.ChunkWriterResumer = &s3ChunkWriter{}
+	_ fs.Object             = &Object{}
+	_ fs.MimeTyper          = &Object{}
+	_ fs.GetTierer          = &Object{}
+	_ fs.SetTierer          = &Object{}
+	_ fs.Metadataer         = &Object{}
 )
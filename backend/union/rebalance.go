@@ -0,0 +1,318 @@
+// Rebalancing files between the upstreams
+
+package union
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rclone/rclone/backend/union/upstream"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+var commandHelp = []fs.CommandHelp{{
+	Name:  "rebalance",
+	Short: "Copy and move files between the upstreams.",
+	Long: `This makes the missing copies of files which are on fewer upstreams
+than the replicas option, for example after an upstream has been
+replaced with an empty one. The upstreams for the copies are chosen
+with the create policy in the same way as for new files.
+
+It then moves files from the upstreams with the least free space to
+those with the most until the free space on each is within the
+threshold of the others. Only the upstreams which report their free
+space with "rclone about" are used for this, and they should be on
+separate storage for it to make sense.
+
+Usage Examples:
+
+    rclone backend rebalance union:
+    rclone backend rebalance union:path -o mode=replicas
+    rclone backend rebalance union: -o mode=space -o threshold=10G
+
+The filter flags can be used to choose which files are rebalanced and
+--dry-run to see what would be done. The result shows the number of
+files copied and moved and the number which still have too few copies.
+`,
+	Opts: map[string]string{
+		"mode":      "\"replicas\" to only copy files, \"space\" to only move files, default both",
+		"threshold": "Difference in free space to stop moving files at, default 1Gi",
+	},
+}}
+
+// Command the backend to run a named command
+//
+// The command run is name
+// args may be used to read arguments from
+// opts may be used to read optional arguments from
+//
+// The result should be capable of being JSON encoded
+// If it is a string or a []string it will be shown to the user
+// otherwise it will be JSON encoded and shown to the user like that
+func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[string]string) (out interface{}, err error) {
+	switch name {
+	case "rebalance":
+		return f.rebalance(ctx, opt)
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
+// rebalanceFile is a file and the copies of it on each upstream
+type rebalanceFile struct {
+	remote string
+	size   int64
+	objs   map[int]*upstream.Object // copies indexed by upstream, nil if not made in a dry run
+	failed bool                     // set if a move failed so it isn't tried again
+}
+
+// rebalanceUpstream is an upstream while balancing free space
+type rebalanceUpstream struct {
+	free      int64 // estimated free space
+	canRemove bool  // set if files can be moved off it
+	canCreate bool  // set if files can be moved on to it
+}
+
+// rebalanceResult is the output of the rebalance command
+type rebalanceResult struct {
+	Files           int `json:"files"`           // number of files found
+	Copied          int `json:"copied"`          // copies made to restore the replicas
+	Moved           int `json:"moved"`           // files moved to balance the free space
+	UnderReplicated int `json:"underReplicated"` // files which still have too few copies
+	Errors          int `json:"errors"`          // number of copies and moves which failed
+}
+
+// rebalance the files between the upstreams
+func (f *Fs) rebalance(ctx context.Context, opt map[string]string) (*rebalanceResult, error) {
+	copyFiles, moveFiles := true, true
+	if mode, ok := opt["mode"]; ok {
+		switch mode {
+		case "replicas":
+			moveFiles = false
+		case "space":
+			copyFiles = false
+		default:
+			return nil, fmt.Errorf("unknown mode %q - expecting \"replicas\" or \"space\"", mode)
+		}
+	}
+	threshold := fs.SizeSuffix(fs.Gibi)
+	if value, ok := opt["threshold"]; ok {
+		err := threshold.Set(value)
+		if err != nil {
+			return nil, fmt.Errorf("bad threshold: %w", err)
+		}
+	}
+
+	files, err := f.rebalanceList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &rebalanceResult{
+		Files: len(files),
+	}
+	if copyFiles {
+		f.restoreReplicas(ctx, files, result)
+	}
+	if moveFiles {
+		f.balanceFreeSpace(ctx, files, int64(threshold), result)
+	}
+	if result.Errors > 0 {
+		return result, fmt.Errorf("failed to rebalance %d files", result.Errors)
+	}
+	return result, nil
+}
+
+// rebalanceList lists the files on all the upstreams
+func (f *Fs) rebalanceList(ctx context.Context) (files []*rebalanceFile, err error) {
+	fileMap := map[string]*rebalanceFile{}
+	for i, u := range f.upstreams {
+		err := walk.ListR(ctx, u, "", false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+			for _, entry := range entries {
+				o, ok := entry.(fs.Object)
+				if !ok {
+					continue
+				}
+				remote := o.Remote()
+				if f.Features().CaseInsensitive {
+					remote = strings.ToLower(remote)
+				}
+				file := fileMap[remote]
+				if file == nil {
+					file = &rebalanceFile{
+						remote: o.Remote(),
+						size:   o.Size(),
+						objs:   map[int]*upstream.Object{},
+					}
+					fileMap[remote] = file
+					files = append(files, file)
+				}
+				file.objs[i] = u.WrapObject(o)
+			}
+			return nil
+		})
+		if errors.Is(err, fs.ErrorDirNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", u.Name(), err)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].remote < files[j].remote
+	})
+	return files, nil
+}
+
+// upstreamIndex returns the index of u in f.upstreams or -1
+func (f *Fs) upstreamIndex(u *upstream.Fs) int {
+	for i, v := range f.upstreams {
+		if v == u {
+			return i
+		}
+	}
+	return -1
+}
+
+// restoreReplicas copies the files which have fewer copies than the
+// replicas option to the upstreams chosen by the create policy
+func (f *Fs) restoreReplicas(ctx context.Context, files []*rebalanceFile, result *rebalanceResult) {
+	for _, file := range files {
+		if len(file.objs) >= f.opt.Replicas {
+			continue
+		}
+		// Copy from the first upstream the file is on
+		var have []*upstream.Fs
+		var src *upstream.Object
+		for i, u := range f.upstreams {
+			if o, ok := file.objs[i]; ok {
+				have = append(have, u)
+				if src == nil {
+					src = o
+				}
+			}
+		}
+		copies := len(have)
+		for _, u := range f.replicate(ctx, have, file.remote)[len(have):] {
+			dst, err := operations.Copy(ctx, u, nil, file.remote, src.UnWrap())
+			if err != nil {
+				fs.Errorf(src, "Failed to copy to %s: %v", u.Name(), err)
+				result.Errors++
+				continue
+			}
+			fs.Debugf(src, "Copied to %s", u.Name())
+			file.objs[f.upstreamIndex(u)] = u.WrapObject(dst)
+			result.Copied++
+			copies++
+		}
+		if copies < f.opt.Replicas {
+			fs.Logf(src, "Only %d of %d replicas", copies, f.opt.Replicas)
+			result.UnderReplicated++
+		}
+	}
+}
+
+// balanceFreeSpace moves files from the upstreams with the least free
+// space to those with the most
+func (f *Fs) balanceFreeSpace(ctx context.Context, files []*rebalanceFile, threshold int64, result *rebalanceResult) {
+	ups := make([]*rebalanceUpstream, len(f.upstreams))
+	usable := 0
+	for i, u := range f.upstreams {
+		ups[i] = &rebalanceUpstream{}
+		do := u.RootFs.Features().About
+		if do == nil {
+			fs.Logf(f, "Not balancing free space on %s as it doesn't support about", u.Name())
+			continue
+		}
+		usage, err := do(ctx)
+		if err == nil && usage.Free == nil {
+			err = errors.New("free space not reported")
+		}
+		if err != nil {
+			fs.Logf(f, "Not balancing free space on %s: %v", u.Name(), err)
+			continue
+		}
+		ups[i].free = *usage.Free
+		ups[i].canRemove = u.IsWritable()
+		ups[i].canCreate = u.IsCreatable()
+		usable++
+	}
+	if usable < 2 {
+		fs.Logf(f, "Not balancing free space as fewer than 2 upstreams report it")
+		return
+	}
+	balance(ups, files, threshold, func(file *rebalanceFile, from, to int) (*upstream.Object, error) {
+		src, u := file.objs[from], f.upstreams[to]
+		if src == nil {
+			// Copy was only made in a dry run
+			return nil, nil
+		}
+		dst, err := operations.Move(ctx, u, nil, file.remote, src.UnWrap())
+		if err != nil {
+			fs.Errorf(src, "Failed to move to %s: %v", u.Name(), err)
+			result.Errors++
+			return nil, err
+		}
+		fs.Debugf(src, "Moved to %s", u.Name())
+		result.Moved++
+		return u.WrapObject(dst), nil
+	})
+}
+
+// balance moves files with move from the upstream with the least free
+// space to the one with the most until the difference is no more than
+// threshold or there are no suitable files left.
+//
+// Only files which aren't on the destination and which are no bigger
+// than half the difference are moved so that each move makes the free
+// space more even.
+func balance(ups []*rebalanceUpstream, files []*rebalanceFile, threshold int64, move func(file *rebalanceFile, from, to int) (*upstream.Object, error)) {
+	// Try the biggest files first
+	bySize := append([]*rebalanceFile(nil), files...)
+	sort.SliceStable(bySize, func(i, j int) bool {
+		return bySize[i].size > bySize[j].size
+	})
+	for {
+		from, to := -1, -1
+		for i, u := range ups {
+			if u.canRemove && (from < 0 || u.free < ups[from].free) {
+				from = i
+			}
+			if u.canCreate && (to < 0 || u.free > ups[to].free) {
+				to = i
+			}
+		}
+		if from < 0 || to < 0 || from == to {
+			return
+		}
+		gap := ups[to].free - ups[from].free
+		if gap <= threshold {
+			return
+		}
+		var file *rebalanceFile
+		for _, candidate := range bySize {
+			_, onFrom := candidate.objs[from]
+			_, onTo := candidate.objs[to]
+			if onFrom && !onTo && !candidate.failed && candidate.size > 0 && candidate.size <= gap/2 {
+				file = candidate
+				break
+			}
+		}
+		if file == nil {
+			return
+		}
+		o, err := move(file, from, to)
+		if err != nil {
+			file.failed = true
+			continue
+		}
+		delete(file.objs, from)
+		file.objs[to] = o
+		ups[from].free += file.size
+		ups[to].free -= file.size
+	}
+}
@@ -30,6 +30,7 @@ func init() {
 		Name:        "union",
 		Description: "Union merges the contents of several upstream fs",
 		NewFs:       NewFs,
+		CommandHelp: commandHelp,
 		MetadataInfo: &fs.MetadataInfo{
 			Help: `Any metadata supported by the underlying remote is read and written.`,
 		},
@@ -61,6 +62,20 @@ If a remote has less than this much free space then it won't be
 considered for use in lfs or eplfs policies.`,
 			Advanced: true,
 			Default:  fs.Gibi,
+		}, {
+			Name: "replicas",
+			Help: `Number of upstreams to create each new file on.
+
+The create policy is used to choose the upstreams. If it chooses fewer
+than this many then it is used again to choose from the remaining
+upstreams until enough have been chosen or there are none left. The
+path preserving policies choose the extra upstreams as the non path
+preserving version of the policy if the path doesn't exist on them.
+
+This only applies to new files. Use the rebalance backend command to
+make the missing copies of existing files.`,
+			Advanced: true,
+			Default:  1,
 		}},
 	}
 	fs.Register(fsi)
@@ -68,15 +83,16 @@ considered for use in lfs or eplfs policies.`,
 
 // Fs represents a union of upstreams
 type Fs struct {
-	name         string         // name of this remote
-	features     *fs.Features   // optional features
-	opt          common.Options // options for this Fs
-	root         string         // the path we are working on
-	upstreams    []*upstream.Fs // slice of upstreams
-	hashSet      hash.Set       // intersection of hash types
-	actionPolicy policy.Policy  // policy for ACTION
-	createPolicy policy.Policy  // policy for CREATE
-	searchPolicy policy.Policy  // policy for SEARCH
+	name          string         // name of this remote
+	features      *fs.Features   // optional features
+	opt           common.Options // options for this Fs
+	root          string         // the path we are working on
+	upstreams     []*upstream.Fs // slice of upstreams
+	hashSet       hash.Set       // intersection of hash types
+	actionPolicy  policy.Policy  // policy for ACTION
+	createPolicy  policy.Policy  // policy for CREATE
+	searchPolicy  policy.Policy  // policy for SEARCH
+	replicaPolicy policy.Policy  // policy for CREATE of replicas where the path doesn't exist
 }
 
 // Wrap candidate objects in to a union Object
@@ -226,6 +242,10 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 		fs.Debugf(src, "Can't copy - not same remote type")
 		return nil, fs.ErrorCantCopy
 	}
+	if f.opt.Replicas > 1 {
+		fs.Debugf(src, "Can't copy - replicas are needed")
+		return nil, fs.ErrorCantCopy
+	}
 	o := srcObj.UnWrapUpstream()
 	su := o.UpstreamFs()
 	if su.Features().Copy == nil {
@@ -753,7 +773,56 @@ func (f *Fs) actionEntries(entries ...upstream.Entry) ([]upstream.Entry, error)
 }
 
 func (f *Fs) create(ctx context.Context, path string) ([]*upstream.Fs, error) {
-	return f.createPolicy.Create(ctx, f.upstreams, path)
+	upstreams, err := f.createPolicy.Create(ctx, f.upstreams, path)
+	if err != nil {
+		return nil, err
+	}
+	return f.replicate(ctx, upstreams, path), nil
+}
+
+// replicate adds upstreams chosen by the create policy to upstreams
+// until there are as many as the replicas option or there are no
+// more which can be created on.
+func (f *Fs) replicate(ctx context.Context, upstreams []*upstream.Fs, path string) []*upstream.Fs {
+	for len(upstreams) < f.opt.Replicas {
+		var candidates []*upstream.Fs
+		for _, u := range f.upstreams {
+			if u.IsCreatable() && !containsUpstream(upstreams, u) {
+				candidates = append(candidates, u)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		chosen, err := f.createPolicy.Create(ctx, candidates, path)
+		if err == fs.ErrorObjectNotFound && f.replicaPolicy != nil {
+			chosen, err = f.replicaPolicy.Create(ctx, candidates, path)
+		}
+		if err != nil {
+			fs.Debugf(f, "Couldn't choose upstream for replica %d of %q: %v", len(upstreams)+1, path, err)
+			break
+		}
+		n := len(upstreams)
+		for _, u := range chosen {
+			if u != nil && !containsUpstream(upstreams, u) {
+				upstreams = append(upstreams, u)
+			}
+		}
+		if len(upstreams) == n {
+			break
+		}
+	}
+	return upstreams
+}
+
+// containsUpstream returns true if u is in upstreams
+func containsUpstream(upstreams []*upstream.Fs, u *upstream.Fs) bool {
+	for _, v := range upstreams {
+		if v == u {
+			return true
+		}
+	}
+	return false
 }
 
 func (f *Fs) searchEntries(entries ...upstream.Entry) (upstream.Entry, error) {
@@ -871,6 +940,29 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 	if err != nil {
 		return nil, err
 	}
+	if opt.Replicas < 1 {
+		return nil, errors.New("union replicas must be at least 1")
+	}
+	if opt.Replicas > 1 {
+		// The path preserving policies fall back to the non path
+		// preserving version for the extra replicas
+		createPolicy := strings.ToLower(opt.CreatePolicy)
+		if strings.HasPrefix(createPolicy, "ep") {
+			f.replicaPolicy, err = policy.Get(strings.TrimPrefix(createPolicy, "ep"))
+			if err != nil {
+				return nil, err
+			}
+		}
+		creatable := 0
+		for _, u := range f.upstreams {
+			if u.IsCreatable() {
+				creatable++
+			}
+		}
+		if creatable < opt.Replicas {
+			fs.Logf(f, "Only %d upstreams can be created on so files will have fewer than %d replicas", creatable, opt.Replicas)
+		}
+	}
 	fs.Debugf(f, "actionPolicy = %T, createPolicy = %T, searchPolicy = %T", f.actionPolicy, f.createPolicy, f.searchPolicy)
 	var features = (&fs.Features{
 		CaseInsensitive:         true,
@@ -960,4 +1052,5 @@ var (
 	_ fs.Abouter         = (*Fs)(nil)
 	_ fs.ListRer         = (*Fs)(nil)
 	_ fs.Shutdowner      = (*Fs)(nil)
+	_ fs.Commander       = (*Fs)(nil)
 )
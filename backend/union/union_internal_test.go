@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/rclone/rclone/backend/union/upstream"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/object"
 	"github.com/rclone/rclone/fs/operations"
@@ -156,3 +157,100 @@ func TestMoveCopy(t *testing.T) {
 		})
 	})
 }
+
+// Test files are created on the number of upstreams set by replicas
+// and rebalance makes the missing copies
+func TestReplicas(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	ctx := context.Background()
+	dirs := MakeTestDirs(t, 3)
+	fsString := fmt.Sprintf(":union,upstreams='%s %s %s',replicas=2:", dirs[0], dirs[1], dirs[2])
+	f, err := fs.NewFs(ctx, fsString)
+	require.NoError(t, err)
+	unionFs := f.(*Fs)
+
+	// count the upstreams the file is on
+	replicas := func(remote string) (n int) {
+		for _, u := range unionFs.upstreams {
+			_, err := u.NewObject(ctx, remote)
+			if err == nil {
+				n++
+			} else {
+				require.Equal(t, fs.ErrorObjectNotFound, err)
+			}
+		}
+		return n
+	}
+
+	contents := random.String(50)
+	file1 := fstest.NewItem("dir/file.txt", contents, time.Now())
+	_ = fstests.PutTestContents(ctx, t, f, &file1, contents, true)
+	assert.Equal(t, 2, replicas(file1.Path))
+
+	// Remove a copy from an upstream
+	for _, u := range unionFs.upstreams {
+		o, err := u.NewObject(ctx, file1.Path)
+		if err == nil {
+			require.NoError(t, o.Remove(ctx))
+			break
+		}
+	}
+	assert.Equal(t, 1, replicas(file1.Path))
+
+	out, err := unionFs.Command(ctx, "rebalance", nil, map[string]string{"mode": "replicas"})
+	require.NoError(t, err)
+	assert.Equal(t, &rebalanceResult{Files: 1, Copied: 1}, out)
+	assert.Equal(t, 2, replicas(file1.Path))
+
+	// Nothing to do now
+	out, err = unionFs.Command(ctx, "rebalance", nil, map[string]string{"mode": "replicas"})
+	require.NoError(t, err)
+	assert.Equal(t, &rebalanceResult{Files: 1}, out)
+
+	_, err = unionFs.Command(ctx, "rebalance", nil, map[string]string{"mode": "potato"})
+	assert.EqualError(t, err, `unknown mode "potato" - expecting "replicas" or "space"`)
+}
+
+func TestBalance(t *testing.T) {
+	newFile := func(remote string, size int64, on ...int) *rebalanceFile {
+		file := &rebalanceFile{
+			remote: remote,
+			size:   size,
+			objs:   map[int]*upstream.Object{},
+		}
+		for _, i := range on {
+			file.objs[i] = nil
+		}
+		return file
+	}
+	ups := []*rebalanceUpstream{
+		{free: 100, canRemove: true, canCreate: true},
+		{free: 1000, canRemove: true, canCreate: true},
+		{free: 10000, canRemove: false, canCreate: false}, // read only
+	}
+	files := []*rebalanceFile{
+		newFile("big", 600, 0),
+		newFile("medium", 300, 0),
+		newFile("small1", 100, 0),
+		newFile("small2", 100, 0),
+		newFile("both", 200, 0, 1),
+		newFile("empty", 0, 0),
+	}
+	var moves []string
+	balance(ups, files, 50, func(file *rebalanceFile, from, to int) (*upstream.Object, error) {
+		moves = append(moves, fmt.Sprintf("%s %d->%d", file.remote, from, to))
+		if file.remote == "small1" {
+			return nil, assert.AnError
+		}
+		return nil, nil
+	})
+	assert.Equal(t, []string{"medium 0->1", "small1 0->1", "small2 0->1"}, moves)
+	assert.Equal(t, int64(500), ups[0].free)
+	assert.Equal(t, int64(600), ups[1].free)
+	assert.Equal(t, int64(10000), ups[2].free)
+	assert.True(t, files[2].failed)
+	_, onTo := files[1].objs[1]
+	assert.True(t, onTo)
+}
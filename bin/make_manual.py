@@ -31,6 +31,7 @@ docs = [
     "alias.md",
     "amazonclouddrive.md",
     "s3.md",
+    "archive.md",
     "b2.md",
     "box.md",
     "cache.md",
@@ -39,6 +40,7 @@ docs = [
     "crypt.md",
     "compress.md",
     "combine.md",
+    "dedup.md",
     "dropbox.md",
     "filefabric.md",
     "ftp.md",
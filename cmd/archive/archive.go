@@ -0,0 +1,28 @@
+// Package archive provides the archive command.
+package archive
+
+import (
+	"github.com/rclone/rclone/cmd"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(archiveCommand)
+	archiveCommand.AddCommand(createCommand)
+	archiveCommand.AddCommand(extractCommand)
+}
+
+var archiveCommand = &cobra.Command{
+	Use:   "archive <action> [opts] <source> <destination>",
+	Short: `Create and extract archives on remotes.`,
+	Long: `Create and extract zip and tar archives streaming between remotes.
+
+Archives are streamed directly from the source to the destination so
+no local disk space is needed.
+
+Each subcommand has its own options which you can see in their help.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.64",
+	},
+}
@@ -0,0 +1,116 @@
+package archive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fstest"
+	libarchive "github.com/rclone/rclone/lib/archive"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	t1 = fstest.Time("2017-02-03T04:05:06Z")
+	t2 = fstest.Time("2020-10-11T12:13:14Z")
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+// newLocalFs makes an empty local Fs
+func newLocalFs(t *testing.T) fs.Fs {
+	f, err := fs.NewFs(context.Background(), t.TempDir())
+	require.NoError(t, err)
+	return f
+}
+
+func TestCreateExtract(t *testing.T) {
+	ctx := context.Background()
+	for _, fm := range []libarchive.Format{libarchive.FormatZip, libarchive.FormatTar, libarchive.FormatTarGz} {
+		t.Run(fm.String(), func(t *testing.T) {
+			r := fstest.NewRun(t)
+			file1 := r.WriteFile("file1.txt", "hello", t1)
+			file2 := r.WriteFile("dir/sub/file2.bin", random.String(100*1024), t2)
+			archive := "archive." + fm.String()
+
+			require.NoError(t, create(ctx, r.Flocal, r.Fremote, archive, fm))
+			o, err := r.Fremote.NewObject(ctx, archive)
+			require.NoError(t, err)
+			assert.Greater(t, o.Size(), int64(0))
+
+			fdst := newLocalFs(t)
+			require.NoError(t, extract(ctx, r.Fremote, archive, fdst, fm))
+			fstest.CheckListingWithPrecision(t, fdst, []fstest.Item{file1, file2}, []string{"dir", "dir/sub"}, time.Second)
+		})
+	}
+}
+
+func TestCreateInSource(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("file1.txt", "hello", t1)
+
+	// The archive shouldn't be added to itself
+	require.NoError(t, create(ctx, r.Flocal, r.Flocal, "archive.tar", libarchive.FormatTar))
+	fdst := newLocalFs(t)
+	require.NoError(t, extract(ctx, r.Flocal, "archive.tar", fdst, libarchive.FormatTar))
+	fstest.CheckListingWithPrecision(t, fdst, []fstest.Item{file1}, nil, time.Second)
+}
+
+func TestExtractFilter(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("file1.txt", "hello", t1)
+	r.WriteFile("file2.bin", "world", t2)
+	require.NoError(t, create(ctx, r.Flocal, r.Fremote, "archive.zip", libarchive.FormatZip))
+
+	// Extract only the .txt files
+	fi, err := filter.NewFilter(nil)
+	require.NoError(t, err)
+	require.NoError(t, fi.AddRule("+ *.txt"))
+	require.NoError(t, fi.AddRule("- *"))
+	filterCtx := filter.ReplaceConfig(ctx, fi)
+
+	fdst := newLocalFs(t)
+	require.NoError(t, extract(filterCtx, r.Fremote, "archive.zip", fdst, libarchive.FormatZip))
+	fstest.CheckListingWithPrecision(t, fdst, []fstest.Item{file1}, nil, time.Second)
+}
+
+func TestMetadata(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes not supported on Windows")
+	}
+	ctx, ci := fs.AddConfig(context.Background())
+	ci.Metadata = true
+	for _, fm := range []libarchive.Format{libarchive.FormatZip, libarchive.FormatTar} {
+		t.Run(fm.String(), func(t *testing.T) {
+			r := fstest.NewRun(t)
+			modTime := fstest.Time("2017-02-03T04:05:06.123456789Z")
+			r.WriteFile("file1.txt", "hello", modTime)
+			require.NoError(t, os.Chmod(filepath.Join(r.LocalName, "file1.txt"), 0640))
+
+			archive := "archive." + fm.String()
+			require.NoError(t, create(ctx, r.Flocal, r.Fremote, archive, fm))
+			dir := t.TempDir()
+			fdst, err := fs.NewFs(ctx, dir)
+			require.NoError(t, err)
+			require.NoError(t, extract(ctx, r.Fremote, archive, fdst, fm))
+
+			fi, err := os.Stat(filepath.Join(dir, "file1.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+			fstest.AssertTimeEqualWithPrecision(t, "file1.txt", modTime, fi.ModTime(), fs.GetModifyWindow(ctx, fdst))
+		})
+	}
+}
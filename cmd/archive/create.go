@@ -0,0 +1,269 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	libarchive "github.com/rclone/rclone/lib/archive"
+	"github.com/spf13/cobra"
+)
+
+var createFormat string
+
+func init() {
+	cmdFlags := createCommand.Flags()
+	flags.StringVarP(cmdFlags, &createFormat, "format", "", createFormat, "Archive format: zip, tar or tar.gz (default: from the file name)")
+}
+
+var createCommand = &cobra.Command{
+	Use:   "create source:path dest:path/archive",
+	Short: `Create an archive from the files in source:path.`,
+	Long: strings.ReplaceAll(`
+Walks source:path and streams the files found into a zip or tar
+archive which is uploaded to dest:path/archive as it is made.
+
+    rclone archive create remote:project s3:bucket/project.tar.gz
+
+The format of the archive is worked out from its file name (.zip, .tar,
+.tar.gz or .tgz) unless the |--format| flag is used.
+
+Filters can be used to choose which files go in the archive.
+
+Modification times are stored in the archive. If |--metadata| is in
+use then the metadata of each file is stored in the archive too.
+
+Files with an unknown size, such as Google Docs, can't be added to tar
+archives and will be skipped with an error.
+
+Note that the upload can't be retried as the archive is streamed -
+see [rclone rcat](/commands/rclone_rcat/) for more info.
+`, "|", "`"),
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.64",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc := cmd.NewFsSrc(args[:1])
+		fdst, dstFileName := cmd.NewFsDstFile(args[1:2])
+		cmd.Run(false, true, command, func() error {
+			fm, err := libarchive.ParseFormat(createFormat, dstFileName)
+			if err != nil {
+				return err
+			}
+			if fm == libarchive.FormatTarBz2 {
+				return errors.New("can't create tar.bz2 archives")
+			}
+			return create(context.Background(), fsrc, fdst, dstFileName, fm)
+		})
+	},
+}
+
+// archiveWriter writes the entries of an archive
+type archiveWriter interface {
+	// addDir adds the directory dir
+	addDir(ctx context.Context, dir fs.Directory) error
+	// addFile adds the contents of o
+	addFile(ctx context.Context, o fs.Object) error
+	// Close finishes the archive
+	Close() error
+}
+
+// newArchiveWriter makes an archiveWriter writing archives of format
+// fm to out
+func newArchiveWriter(out io.Writer, fm libarchive.Format) archiveWriter {
+	switch fm {
+	case libarchive.FormatZip:
+		return &zipWriter{zw: zip.NewWriter(out)}
+	case libarchive.FormatTarGz:
+		gz := gzip.NewWriter(out)
+		return &tarWriter{tw: tar.NewWriter(gz), closer: gz}
+	}
+	return &tarWriter{tw: tar.NewWriter(out)}
+}
+
+// create streams the files in fsrc into an archive which is uploaded
+// to dstFileName on fdst
+func create(ctx context.Context, fsrc fs.Fs, fdst fs.Fs, dstFileName string, fm libarchive.Format) error {
+	pr, pw := io.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeArchive(ctx, fsrc, fdst, dstFileName, pw, fm)
+		_ = pw.CloseWithError(err)
+		writeErr <- err
+	}()
+	_, err := operations.Rcat(ctx, fdst, dstFileName, pr, time.Now(), nil)
+	// unblock the writer if the upload failed
+	_ = pr.CloseWithError(err)
+	if werr := <-writeErr; werr != nil {
+		return fmt.Errorf("failed to make archive: %w", werr)
+	}
+	return err
+}
+
+// writeArchive walks fsrc writing the files found to out
+func writeArchive(ctx context.Context, fsrc fs.Fs, fdst fs.Fs, dstFileName string, out io.Writer, fm libarchive.Format) (err error) {
+	aw := newArchiveWriter(out, fm)
+	defer fs.CheckClose(aw, &err)
+	// Don't add the archive to itself if it is being written into
+	// the source
+	archivePath := ""
+	if operations.SameConfig(fsrc, fdst) {
+		archivePath = path.Join(fdst.Root(), dstFileName)
+	}
+	return walk.Walk(ctx, fsrc, "", false, -1, func(dirPath string, entries fs.DirEntries, err error) error {
+		if err != nil {
+			return err
+		}
+		sort.Sort(entries)
+		for _, entry := range entries {
+			switch x := entry.(type) {
+			case fs.Directory:
+				err = aw.addDir(ctx, x)
+			case fs.Object:
+				if path.Join(fsrc.Root(), x.Remote()) == archivePath {
+					fs.Debugf(x, "Not adding archive to itself")
+					continue
+				}
+				err = aw.addFile(ctx, x)
+				if err == errUnknownSize {
+					err = fs.CountError(err)
+					fs.Errorf(x, "Skipping: %v", err)
+					continue
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("failed to add %q: %w", entry.Remote(), err)
+			}
+			fs.Debugf(entry, "Added to archive")
+		}
+		return nil
+	})
+}
+
+// copyObject copies the contents of o to out checking the size
+func copyObject(ctx context.Context, out io.Writer, o fs.Object) (err error) {
+	in, err := o.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(in, &err)
+	n, err := io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+	if size := o.Size(); size >= 0 && n != size {
+		return fmt.Errorf("size changed while reading: expecting %d bytes but read %d", size, n)
+	}
+	return nil
+}
+
+// errUnknownSize is returned when adding files of unknown size to a tar
+var errUnknownSize = errors.New("can't add file of unknown size to a tar archive")
+
+// tarWriter writes tar archives
+type tarWriter struct {
+	tw     *tar.Writer
+	closer io.Closer // compressor to close after tw if set
+}
+
+// addDir adds the directory dir
+func (w *tarWriter) addDir(ctx context.Context, dir fs.Directory) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir.Remote() + "/",
+		Mode:     0755,
+		ModTime:  dir.ModTime(ctx),
+		Format:   tar.FormatPAX,
+	})
+}
+
+// addFile adds the contents of o
+func (w *tarWriter) addFile(ctx context.Context, o fs.Object) error {
+	if o.Size() < 0 {
+		return errUnknownSize
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     o.Remote(),
+		Size:     o.Size(),
+		Mode:     0644,
+		ModTime:  o.ModTime(ctx),
+		Format:   tar.FormatPAX, // for sub-second modification times
+	}
+	meta, err := fs.GetMetadataOptions(ctx, o, nil)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+	libarchive.SetTarMetadata(hdr, meta)
+	err = w.tw.WriteHeader(hdr)
+	if err != nil {
+		return err
+	}
+	return copyObject(ctx, w.tw, o)
+}
+
+// Close finishes the archive
+func (w *tarWriter) Close() error {
+	err := w.tw.Close()
+	if w.closer != nil {
+		if cerr := w.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// zipWriter writes zip archives
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+// addDir adds the directory dir
+func (w *zipWriter) addDir(ctx context.Context, dir fs.Directory) error {
+	fh := &zip.FileHeader{
+		Name:     dir.Remote() + "/",
+		Method:   zip.Store,
+		Modified: dir.ModTime(ctx),
+	}
+	libarchive.SetZipMetadata(fh, nil)
+	_, err := w.zw.CreateHeader(fh)
+	return err
+}
+
+// addFile adds the contents of o
+func (w *zipWriter) addFile(ctx context.Context, o fs.Object) error {
+	fh := &zip.FileHeader{
+		Name:     o.Remote(),
+		Method:   zip.Deflate,
+		Modified: o.ModTime(ctx),
+	}
+	meta, err := fs.GetMetadataOptions(ctx, o, nil)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+	libarchive.SetZipMetadata(fh, meta)
+	out, err := w.zw.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	return copyObject(ctx, out, o)
+}
+
+// Close finishes the archive
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
@@ -0,0 +1,200 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+	libarchive "github.com/rclone/rclone/lib/archive"
+	"github.com/spf13/cobra"
+)
+
+var extractFormat string
+
+func init() {
+	cmdFlags := extractCommand.Flags()
+	flags.StringVarP(cmdFlags, &extractFormat, "format", "", extractFormat, "Archive format: zip, tar, tar.gz or tar.bz2 (default: from the file name)")
+}
+
+var extractCommand = &cobra.Command{
+	Use:   "extract source:path/archive dest:path",
+	Short: `Extract the files in an archive to dest:path.`,
+	Long: strings.ReplaceAll(`
+Reads the zip or tar archive at source:path/archive and uploads each
+file in it to dest:path as it is read.
+
+    rclone archive extract s3:bucket/project.zip remote:project
+
+The format of the archive is worked out from its file name (.zip, .tar,
+.tar.gz, .tgz, .tar.bz2 or .tbz2) unless the |--format| flag is used.
+
+Filters can be used to choose which files in the archive are extracted.
+Files which already exist in the destination are overwritten.
+
+Modification times are restored from the archive. If |--metadata| is
+in use then the metadata stored in the archive is restored too.
+
+The central directory of zip archives is read with range requests and
+the files are then read in order. Tar archives are read in one pass.
+`, "|", "`"),
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.64",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc, srcFileName, fdst := cmd.NewFsSrcFileDst(args)
+		cmd.Run(false, true, command, func() error {
+			if srcFileName == "" {
+				return fmt.Errorf("%q is not an archive file", args[0])
+			}
+			fm, err := libarchive.ParseFormat(extractFormat, srcFileName)
+			if err != nil {
+				return err
+			}
+			return extract(context.Background(), fsrc, srcFileName, fdst, fm)
+		})
+	},
+}
+
+// extractor writes the entries of an archive to fdst
+type extractor struct {
+	ctx     context.Context
+	fdst    fs.Fs
+	fi      *filter.Filter
+	archive fs.Object
+	errors  int // number of files which failed
+}
+
+// extract the archive srcFileName in fsrc to fdst
+func extract(ctx context.Context, fsrc fs.Fs, srcFileName string, fdst fs.Fs, fm libarchive.Format) error {
+	o, err := fsrc.NewObject(ctx, srcFileName)
+	if err != nil {
+		return fmt.Errorf("failed to find archive: %w", err)
+	}
+	x := &extractor{
+		ctx:     ctx,
+		fdst:    fdst,
+		fi:      filter.GetConfig(ctx),
+		archive: o,
+	}
+	if fm == libarchive.FormatZip {
+		err = x.extractZip()
+	} else {
+		err = x.extractTar(fm)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	if x.errors > 0 {
+		return fmt.Errorf("failed to extract %d files", x.errors)
+	}
+	return nil
+}
+
+// dir makes the directory name
+func (x *extractor) dir(name string) {
+	if !x.fi.IncludeRemote(name + "/") {
+		return
+	}
+	if !x.fdst.Features().CanHaveEmptyDirectories {
+		return
+	}
+	err := operations.Mkdir(x.ctx, x.fdst, name)
+	if err != nil {
+		x.errors++
+		fs.Errorf(name, "Failed to make directory: %v", err)
+	}
+}
+
+// file uploads the contents of in to name
+func (x *extractor) file(name string, in io.Reader, size int64, modTime time.Time, meta fs.Metadata) {
+	if !x.fi.IncludeRemote(name) {
+		fs.Debugf(name, "Excluded from extract")
+		return
+	}
+	if modTime.IsZero() {
+		modTime = x.archive.ModTime(x.ctx)
+	}
+	_, err := operations.RcatSize(x.ctx, x.fdst, name, io.NopCloser(in), size, modTime, meta)
+	if err != nil {
+		x.errors++
+		fs.Errorf(name, "Failed to extract: %v", err)
+		return
+	}
+	fs.Debugf(name, "Extracted")
+}
+
+// extractTar reads the tar archive in a single stream
+func (x *extractor) extractTar(fm libarchive.Format) (err error) {
+	rc, err := x.archive.Open(x.ctx)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(rc, &err)
+	in, err := fm.Decompress(rc)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		name, ok := libarchive.CleanName(hdr.Name)
+		if !ok {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			x.dir(name)
+		case tar.TypeReg, tar.TypeGNUSparse:
+			x.file(name, tr, hdr.Size, hdr.ModTime, libarchive.GetTarMetadata(hdr))
+		default:
+			fs.Logf(name, "Skipping unsupported tar entry type %q", hdr.Typeflag)
+		}
+	}
+}
+
+// extractZip reads the central directory of the zip archive then
+// reads the files from it in order
+func (x *extractor) extractZip() (err error) {
+	ra := libarchive.NewReaderAt(x.archive)
+	return ra.Use(x.ctx, func() error {
+		zr, err := zip.NewReader(ra, x.archive.Size())
+		if err != nil {
+			return err
+		}
+		for _, file := range zr.File {
+			name, ok := libarchive.CleanName(file.Name)
+			if !ok {
+				continue
+			}
+			if strings.HasSuffix(file.Name, "/") || file.FileInfo().IsDir() {
+				x.dir(name)
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				x.errors++
+				fs.Errorf(name, "Failed to extract: %v", err)
+				continue
+			}
+			meta := libarchive.GetZipMetadata(file)
+			x.file(name, rc, int64(file.UncompressedSize64), libarchive.ModTime(meta, file.Modified), meta)
+			_ = rc.Close()
+		}
+		return nil
+	})
+}
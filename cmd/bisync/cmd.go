@@ -39,12 +39,20 @@ type Options struct {
 	DryRun          bool
 	NoCleanup       bool
 	SaveQueues      bool // save extra debugging files (test only flag)
+	ConflictResolve ConflictResolveMode
+	ConflictLoser   ConflictLoserAction
+	ConflictSuffix  string
+	BackupDir1      string // --conflict-backup-dir1 for --conflict-loser backup-dir
+	BackupDir2      string // --conflict-backup-dir2 for --conflict-loser backup-dir
+	Compare         CompareOpt
+	MaxLock         time.Duration
 }
 
 // Default values
 const (
-	DefaultMaxDelete     int    = 50
-	DefaultCheckFilename string = "RCLONE_TEST"
+	DefaultMaxDelete      int    = 50
+	DefaultCheckFilename  string = "RCLONE_TEST"
+	DefaultConflictSuffix string = "path"
 )
 
 // DefaultWorkdir is default working directory
@@ -92,6 +100,166 @@ func (x *CheckSyncMode) Type() string {
 	return "string"
 }
 
+// CompareOpt selects which properties of a file are compared with the
+// prior listing to find out whether it has changed
+type CompareOpt struct {
+	Size     bool
+	Modtime  bool
+	Checksum bool
+}
+
+// DefaultCompare is used when no --compare properties are set
+var DefaultCompare = CompareOpt{Size: true, Modtime: true}
+
+// IsZero returns true if no properties are selected
+func (x CompareOpt) IsZero() bool {
+	return !x.Size && !x.Modtime && !x.Checksum
+}
+
+func (x CompareOpt) String() string {
+	var out []string
+	if x.Size {
+		out = append(out, "size")
+	}
+	if x.Modtime {
+		out = append(out, "modtime")
+	}
+	if x.Checksum {
+		out = append(out, "checksum")
+	}
+	return strings.Join(out, ",")
+}
+
+// Set the Compare properties from a comma separated string
+func (x *CompareOpt) Set(s string) error {
+	var opt CompareOpt
+	for _, part := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "size":
+			opt.Size = true
+		case "modtime":
+			opt.Modtime = true
+		case "checksum":
+			opt.Checksum = true
+		default:
+			return fmt.Errorf("unknown compare property for bisync: %q", part)
+		}
+	}
+	*x = opt
+	return nil
+}
+
+// Type of the Compare value
+func (x *CompareOpt) Type() string {
+	return "string"
+}
+
+// ConflictResolveMode controls which version wins when a file has
+// changed on both paths
+type ConflictResolveMode int
+
+// ConflictResolve modes
+const (
+	ConflictResolveNone    ConflictResolveMode = iota // Keep both versions (default)
+	ConflictResolveNewer                              // Keep the version with the newest modification time
+	ConflictResolveOlder                              // Keep the version with the oldest modification time
+	ConflictResolveLarger                             // Keep the larger version
+	ConflictResolveSmaller                            // Keep the smaller version
+	ConflictResolvePath1                              // Keep the Path1 version
+	ConflictResolvePath2                              // Keep the Path2 version
+)
+
+func (x ConflictResolveMode) String() string {
+	switch x {
+	case ConflictResolveNone:
+		return "none"
+	case ConflictResolveNewer:
+		return "newer"
+	case ConflictResolveOlder:
+		return "older"
+	case ConflictResolveLarger:
+		return "larger"
+	case ConflictResolveSmaller:
+		return "smaller"
+	case ConflictResolvePath1:
+		return "path1"
+	case ConflictResolvePath2:
+		return "path2"
+	}
+	return "unknown"
+}
+
+// Set a ConflictResolve mode from a string
+func (x *ConflictResolveMode) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "none", "":
+		*x = ConflictResolveNone
+	case "newer":
+		*x = ConflictResolveNewer
+	case "older":
+		*x = ConflictResolveOlder
+	case "larger":
+		*x = ConflictResolveLarger
+	case "smaller":
+		*x = ConflictResolveSmaller
+	case "path1":
+		*x = ConflictResolvePath1
+	case "path2":
+		*x = ConflictResolvePath2
+	default:
+		return fmt.Errorf("unknown conflict-resolve mode for bisync: %q", s)
+	}
+	return nil
+}
+
+// Type of the ConflictResolve value
+func (x *ConflictResolveMode) Type() string {
+	return "string"
+}
+
+// ConflictLoserAction controls what happens to the losing version of a
+// conflict resolved with --conflict-resolve
+type ConflictLoserAction int
+
+// ConflictLoser actions
+const (
+	ConflictLoserRename    ConflictLoserAction = iota // Rename the loser with the conflict suffix (default)
+	ConflictLoserDelete                               // Overwrite the loser with the winner
+	ConflictLoserBackupDir                            // Move the loser to --conflict-backup-dir1 or 2
+)
+
+func (x ConflictLoserAction) String() string {
+	switch x {
+	case ConflictLoserRename:
+		return "rename"
+	case ConflictLoserDelete:
+		return "delete"
+	case ConflictLoserBackupDir:
+		return "backup-dir"
+	}
+	return "unknown"
+}
+
+// Set a ConflictLoser action from a string
+func (x *ConflictLoserAction) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "rename", "":
+		*x = ConflictLoserRename
+	case "delete":
+		*x = ConflictLoserDelete
+	case "backup-dir":
+		*x = ConflictLoserBackupDir
+	default:
+		return fmt.Errorf("unknown conflict-loser action for bisync: %q", s)
+	}
+	return nil
+}
+
+// Type of the ConflictLoser value
+func (x *ConflictLoserAction) Type() string {
+	return "string"
+}
+
 // Opt keeps command line options
 var Opt Options
 
@@ -108,6 +276,13 @@ func init() {
 	flags.StringVarP(cmdFlags, &Opt.Workdir, "workdir", "", Opt.Workdir, makeHelp("Use custom working dir - useful for testing. (default: {WORKDIR})"))
 	flags.BoolVarP(cmdFlags, &tzLocal, "localtime", "", tzLocal, "Use local time in listings (default: UTC)")
 	flags.BoolVarP(cmdFlags, &Opt.NoCleanup, "no-cleanup", "", Opt.NoCleanup, "Retain working files (useful for troubleshooting and testing).")
+	flags.DurationVarP(cmdFlags, &Opt.MaxLock, "max-lock", "", Opt.MaxLock, "Consider lock files older than this to be expired and renew the lock while running (default: 0 = never expire, minimum: 2m)")
+	flags.FVarP(cmdFlags, &Opt.Compare, "compare", "", "Comma separated list of properties to detect changes by: size,modtime,checksum (default: size,modtime)")
+	flags.FVarP(cmdFlags, &Opt.ConflictResolve, "conflict-resolve", "", "Automatically resolve files changed on both paths: none|newer|older|larger|smaller|path1|path2 (default: none)")
+	flags.FVarP(cmdFlags, &Opt.ConflictLoser, "conflict-loser", "", "Action to take on the losing version of a resolved conflict: rename|delete|backup-dir (default: rename)")
+	flags.StringVarP(cmdFlags, &Opt.BackupDir1, "conflict-backup-dir1", "", Opt.BackupDir1, "Directory to move losing Path1 versions to with --conflict-loser backup-dir")
+	flags.StringVarP(cmdFlags, &Opt.BackupDir2, "conflict-backup-dir2", "", Opt.BackupDir2, "Directory to move losing Path2 versions to with --conflict-loser backup-dir")
+	flags.StringVarP(cmdFlags, &Opt.ConflictSuffix, "conflict-suffix", "", Opt.ConflictSuffix, makeHelp("Suffix for renamed conflict copies, or two suffixes separated by a comma (default: {CONFLICTSUFFIX})"))
 }
 
 // bisync command definition
@@ -7,9 +7,12 @@ import (
 	"fmt"
 	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/rclone/rclone/cmd/bisync/bilib"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/operations"
 )
 
@@ -28,7 +31,7 @@ const (
 
 const (
 	deltaModified delta = deltaNewer | deltaOlder | deltaSize | deltaHash | deltaDeleted
-	deltaOther    delta = deltaNew | deltaNewer | deltaOlder
+	deltaOther    delta = deltaNew | deltaNewer | deltaOlder | deltaSize | deltaHash
 )
 
 func (d delta) is(cond delta) bool {
@@ -38,6 +41,10 @@ func (d delta) is(cond delta) bool {
 // deltaSet
 type deltaSet struct {
 	deltas     map[string]delta
+	info       map[string]*fileInfo // current size and modtime of new and changed files
+	hash       hash.Type            // hash type of the current listing
+	old        *fileList            // prior listing, updated as changes are applied
+	listing    string               // file name of the prior listing
 	opt        *Options
 	fs         fs.Fs  // base filesystem
 	msg        string // filesystem name for logging
@@ -72,6 +79,8 @@ func (ds *deltaSet) printStats() {
 	nNewer := 0
 	nOlder := 0
 	nDeleted := 0
+	nSize := 0
+	nHash := 0
 	for _, d := range ds.deltas {
 		if d.is(deltaNew) {
 			nNew++
@@ -85,9 +94,18 @@ func (ds *deltaSet) printStats() {
 		if d.is(deltaDeleted) {
 			nDeleted++
 		}
+		if d.is(deltaSize) {
+			nSize++
+		}
+		if d.is(deltaHash) {
+			nHash++
+		}
 	}
-	fs.Infof(nil, "%s: %4d changes: %4d new, %4d newer, %4d older, %4d deleted",
-		ds.msg, nAll, nNew, nNewer, nOlder, nDeleted)
+	stats := fmt.Sprintf("%4d new, %4d newer, %4d older, %4d deleted", nNew, nNewer, nOlder, nDeleted)
+	if nSize > 0 || nHash > 0 {
+		stats += fmt.Sprintf(", %4d size, %4d checksum", nSize, nHash)
+	}
+	fs.Infof(nil, "%s: %4d changes: %s", ds.msg, nAll, stats)
 }
 
 // findDeltas
@@ -104,7 +122,7 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 		return
 	}
 
-	now, err = b.makeListing(fctx, f, newListing)
+	now, err = b.makeListing(fctx, f, newListing, old)
 	if err == nil {
 		err = b.checkListing(now, newListing, "current "+msg)
 	}
@@ -114,6 +132,10 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 
 	ds = &deltaSet{
 		deltas:     map[string]delta{},
+		info:       map[string]*fileInfo{},
+		hash:       now.hash,
+		old:        old,
+		listing:    oldListing,
 		fs:         f,
 		msg:        msg,
 		oldCount:   len(old.list),
@@ -121,6 +143,7 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 		checkFiles: bilib.Names{},
 	}
 
+	window := fs.GetModifyWindow(fctx, f)
 	for _, file := range old.list {
 		d := deltaZero
 		if !now.has(file) {
@@ -128,7 +151,8 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 			ds.deleted++
 			d |= deltaDeleted
 		} else {
-			if old.getTime(file) != now.getTime(file) {
+			compare := b.opt.Compare
+			if compare.Modtime && !timeEqual(old.getTime(file), now.getTime(file), window) {
 				if old.beforeOther(now, file) {
 					b.indent(msg, file, "File is newer")
 					d |= deltaNewer
@@ -136,12 +160,20 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 					b.indent(msg, file, "File is OLDER")
 					d |= deltaOlder
 				}
+			} else if compare.Size && old.getSize(file) != now.getSize(file) {
+				b.indent(msg, file, "File size changed")
+				d |= deltaSize
+			} else if compare.Checksum && old.hashChanged(now, file) {
+				b.indent(msg, file, "File checksum changed")
+				d |= deltaHash
 			}
-			// TODO Compare sizes and hashes
 		}
 
 		if d.is(deltaModified) {
 			ds.deltas[file] = d
+			if !d.is(deltaDeleted) {
+				ds.info[file] = now.get(file)
+			}
 		} else {
 			// Once we've found at least one unchanged file,
 			// we know that not everything has changed,
@@ -154,6 +186,7 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 		if !old.has(file) {
 			b.indent(msg, file, "File is new")
 			ds.deltas[file] = deltaNew
+			ds.info[file] = now.get(file)
 		}
 	}
 
@@ -198,23 +231,15 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 				copy1to2.Add(file)
 				handled.Add(file)
 			} else if d2.is(deltaOther) {
-				b.indent("!WARNING", file, "New or changed in both paths")
-				b.indent("!Path1", p1+"..path1", "Renaming Path1 copy")
-				if err = operations.MoveFile(ctxMove, b.fs1, b.fs1, file+"..path1", file); err != nil {
-					err = fmt.Errorf("path1 rename failed for %s: %w", p1, err)
-					b.critical = true
-					return
+				if sameFile(ds1, ds2, file, fs.GetModifyWindow(ctx, b.fs1, b.fs2)) {
+					// Most likely copied by an interrupted run
+					b.indent("INFO", file, "Changed in both paths but identical")
+					handled.Add(file)
+					continue
 				}
-				b.indent("!Path1", p2+"..path1", "Queue copy to Path2")
-				copy1to2.Add(file + "..path1")
-
-				b.indent("!Path2", p2+"..path2", "Renaming Path2 copy")
-				if err = operations.MoveFile(ctxMove, b.fs2, b.fs2, file+"..path2", file); err != nil {
-					err = fmt.Errorf("path2 rename failed for %s: %w", file, err)
+				if err = b.handleConflict(ctxMove, file, ds1, ds2, copy1to2, copy2to1); err != nil {
 					return
 				}
-				b.indent("!Path2", p1+"..path2", "Queue copy to Path1")
-				copy2to1.Add(file + "..path2")
 				handled.Add(file)
 			}
 		} else {
@@ -254,7 +279,9 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 	if copy2to1.NotEmpty() {
 		changes1 = true
 		b.indent("Path2", "Path1", "Do queued copies to")
-		err = b.fastCopy(ctx, b.fs2, b.fs1, copy2to1, "copy2to1")
+		err = b.fastCopy(ctx, b.fs2, b.fs1, copy2to1, "copy2to1", func(done bilib.Names) error {
+			return b.checkpoint(ctx, ds2, ds1, done, nil)
+		})
 		if err != nil {
 			return
 		}
@@ -263,7 +290,9 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 	if copy1to2.NotEmpty() {
 		changes2 = true
 		b.indent("Path1", "Path2", "Do queued copies to")
-		err = b.fastCopy(ctx, b.fs1, b.fs2, copy1to2, "copy1to2")
+		err = b.fastCopy(ctx, b.fs1, b.fs2, copy1to2, "copy1to2", func(done bilib.Names) error {
+			return b.checkpoint(ctx, ds1, ds2, done, nil)
+		})
 		if err != nil {
 			return
 		}
@@ -272,7 +301,9 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 	if delete1.NotEmpty() {
 		changes1 = true
 		b.indent("", "Path1", "Do queued deletes on")
-		err = b.fastDelete(ctx, b.fs1, delete1, "delete1")
+		err = b.fastDelete(ctx, b.fs1, delete1, "delete1", func(done bilib.Names) error {
+			return b.checkpoint(ctx, ds2, ds1, nil, done)
+		})
 		if err != nil {
 			return
 		}
@@ -281,7 +312,9 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 	if delete2.NotEmpty() {
 		changes2 = true
 		b.indent("", "Path2", "Do queued deletes on")
-		err = b.fastDelete(ctx, b.fs2, delete2, "delete2")
+		err = b.fastDelete(ctx, b.fs2, delete2, "delete2", func(done bilib.Names) error {
+			return b.checkpoint(ctx, ds1, ds2, nil, done)
+		})
 		if err != nil {
 			return
 		}
@@ -290,6 +323,190 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 	return
 }
 
+// checkpoint updates the prior listings with the files which have
+// been copied from src to dst or deleted from dst and saves them. If
+// the run is interrupted the next run sees these changes as done
+// rather than as changes on both paths.
+func (b *bisyncRun) checkpoint(ctx context.Context, src, dst *deltaSet, copied, deleted bilib.Names) error {
+	if b.opt.DryRun {
+		return nil
+	}
+	for file := range copied {
+		fi := src.info[file]
+		if fi == nil {
+			// renamed conflict copies aren't in the listings yet
+			continue
+		}
+		srcHash, dstHash := fi.hash, fi.hash
+		if src.old.hash != src.hash {
+			srcHash = ""
+		}
+		if dst.old.hash != src.hash {
+			dstHash = ""
+		}
+		src.old.put(file, fi.size, fi.time, srcHash, fi.id)
+		dst.old.put(file, fi.size, fi.time, dstHash, fi.id)
+	}
+	src.old.remove(deleted)
+	dst.old.remove(deleted)
+	if err := src.old.save(ctx, src.listing); err != nil {
+		return fmt.Errorf("failed to checkpoint %s listing: %w", src.msg, err)
+	}
+	if err := dst.old.save(ctx, dst.listing); err != nil {
+		return fmt.Errorf("failed to checkpoint %s listing: %w", dst.msg, err)
+	}
+	fs.Debugf(nil, "Checkpointed listings after %d copies and %d deletes", len(copied), len(deleted))
+	return nil
+}
+
+// sameFile returns true if the current versions of file on both paths
+// look the same. Hashes are used if both paths have the same hash
+// type, otherwise the size and modification time must match.
+func sameFile(ds1, ds2 *deltaSet, file string, window time.Duration) bool {
+	info1, info2 := ds1.info[file], ds2.info[file]
+	if info1 == nil || info2 == nil || info1.size != info2.size {
+		return false
+	}
+	if ds1.hash != hash.None && ds1.hash == ds2.hash && info1.hash != "" && info2.hash != "" {
+		return info1.hash == info2.hash
+	}
+	if info1.time.IsZero() || info2.time.IsZero() {
+		return false
+	}
+	return timeEqual(info1.time, info2.time, window)
+}
+
+// timeEqual returns true if t1 and t2 are within window of each other
+func timeEqual(t1, t2 time.Time, window time.Duration) bool {
+	dt := t1.Sub(t2)
+	if dt < 0 {
+		dt = -dt
+	}
+	return dt <= window
+}
+
+// handleConflict deals with a file which is new or changed on both
+// paths. Unless --conflict-resolve picks a winner both versions are
+// renamed and copied to the other path.
+func (b *bisyncRun) handleConflict(ctxMove context.Context, file string, ds1, ds2 *deltaSet, copy1to2, copy2to1 bilib.Names) (err error) {
+	path1 := bilib.FsPath(b.fs1)
+	path2 := bilib.FsPath(b.fs2)
+	p1 := path1 + file
+	p2 := path2 + file
+	name1, name2 := b.opt.conflictNames(file)
+
+	b.indent("!WARNING", file, "New or changed in both paths")
+
+	window := fs.GetModifyWindow(ctxMove, b.fs1, b.fs2)
+	winner := conflictWinner(b.opt.ConflictResolve, ds1.info[file], ds2.info[file], window)
+	if winner == 0 {
+		if b.opt.ConflictResolve != ConflictResolveNone {
+			b.indentf("!WARNING", file, "No %s version, keeping both", b.opt.ConflictResolve)
+		}
+		b.indent("!Path1", path1+name1, "Renaming Path1 copy")
+		if err = operations.MoveFile(ctxMove, b.fs1, b.fs1, name1, file); err != nil {
+			err = fmt.Errorf("path1 rename failed for %s: %w", p1, err)
+			b.critical = true
+			return
+		}
+		b.indent("!Path1", path2+name1, "Queue copy to Path2")
+		copy1to2.Add(name1)
+
+		b.indent("!Path2", path2+name2, "Renaming Path2 copy")
+		if err = operations.MoveFile(ctxMove, b.fs2, b.fs2, name2, file); err != nil {
+			err = fmt.Errorf("path2 rename failed for %s: %w", p2, err)
+			return
+		}
+		b.indent("!Path2", path1+name2, "Queue copy to Path1")
+		copy2to1.Add(name2)
+		return nil
+	}
+
+	// Set up the winner and loser of the conflict
+	winTag, loseTag := "Path1", "Path2"
+	winPath, losePath := path1, path2
+	loseFs, loseBackup := b.fs2, b.backup2
+	loseName := name2
+	copyWin, copyLose := copy1to2, copy2to1
+	if winner == 2 {
+		winTag, loseTag = loseTag, winTag
+		winPath, losePath = losePath, winPath
+		loseFs, loseBackup = b.fs1, b.backup1
+		loseName = name1
+		copyWin, copyLose = copyLose, copyWin
+	}
+	b.indentf("!"+winTag, file, "Conflict resolved (%s wins)", b.opt.ConflictResolve)
+
+	switch b.opt.ConflictLoser {
+	case ConflictLoserRename:
+		b.indent("!"+loseTag, losePath+loseName, "Renaming "+loseTag+" copy")
+		if err = operations.MoveFile(ctxMove, loseFs, loseFs, loseName, file); err != nil {
+			return fmt.Errorf("%s rename failed for %s: %w", strings.ToLower(loseTag), losePath+file, err)
+		}
+		b.indent("!"+loseTag, winPath+loseName, "Queue copy to "+winTag)
+		copyLose.Add(loseName)
+	case ConflictLoserBackupDir:
+		b.indent("!"+loseTag, losePath+file, "Moving loser to backup dir")
+		var obj fs.Object
+		obj, err = loseFs.NewObject(ctxMove, file)
+		if err == nil {
+			err = operations.MoveBackupDir(ctxMove, loseBackup, obj)
+		}
+		if err != nil {
+			return fmt.Errorf("%s backup failed for %s: %w", strings.ToLower(loseTag), losePath+file, err)
+		}
+	case ConflictLoserDelete:
+		b.indent("!"+loseTag, losePath+file, "Loser will be overwritten")
+	}
+	b.indent("!"+winTag, losePath+file, "Queue copy to "+loseTag)
+	copyWin.Add(file)
+	return nil
+}
+
+// conflictWinner decides which version of a file changed on both
+// paths should be kept according to mode. It returns 1 or 2 for the
+// winning path or 0 if there isn't a winner. Modification times
+// within window of each other are treated as equal.
+func conflictWinner(mode ConflictResolveMode, info1, info2 *fileInfo, window time.Duration) int {
+	if info1 == nil || info2 == nil {
+		return 0
+	}
+	pick := func(first bool) int {
+		if first {
+			return 1
+		}
+		return 2
+	}
+	switch mode {
+	case ConflictResolvePath1:
+		return 1
+	case ConflictResolvePath2:
+		return 2
+	case ConflictResolveNewer, ConflictResolveOlder:
+		if timeEqual(info1.time, info2.time, window) {
+			return 0
+		}
+		return pick(info1.time.After(info2.time) == (mode == ConflictResolveNewer))
+	case ConflictResolveLarger, ConflictResolveSmaller:
+		if info1.size < 0 || info2.size < 0 || info1.size == info2.size {
+			return 0
+		}
+		return pick((info1.size > info2.size) == (mode == ConflictResolveLarger))
+	}
+	return 0
+}
+
+// conflictNames returns the names for the renamed Path1 and Path2
+// versions of a conflicting file
+func (opt *Options) conflictNames(file string) (name1, name2 string) {
+	suffix1, suffix2, found := strings.Cut(opt.ConflictSuffix, ",")
+	if !found {
+		suffix1 = opt.ConflictSuffix + "1"
+		suffix2 = opt.ConflictSuffix + "2"
+	}
+	return file + ".." + suffix1, file + ".." + suffix2
+}
+
 // excessDeletes checks whether number of deletes is within allowed range
 func (ds *deltaSet) excessDeletes() bool {
 	maxDelete := ds.opt.MaxDelete
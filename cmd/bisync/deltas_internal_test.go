@@ -0,0 +1,248 @@
+package bisync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/cmd/bisync/bilib"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConflictWinner(t *testing.T) {
+	t1 := time.Date(2001, 1, 2, 3, 4, 5, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	small := &fileInfo{size: 10, time: t2}
+	large := &fileInfo{size: 20, time: t1}
+	for _, test := range []struct {
+		mode  ConflictResolveMode
+		info1 *fileInfo
+		info2 *fileInfo
+		want  int
+	}{
+		{ConflictResolveNone, small, large, 0},
+		{ConflictResolvePath1, small, large, 1},
+		{ConflictResolvePath2, small, large, 2},
+		{ConflictResolveNewer, small, large, 1},
+		{ConflictResolveNewer, large, small, 2},
+		{ConflictResolveOlder, small, large, 2},
+		{ConflictResolveOlder, large, small, 1},
+		{ConflictResolveLarger, small, large, 2},
+		{ConflictResolveLarger, large, small, 1},
+		{ConflictResolveSmaller, small, large, 1},
+		{ConflictResolveSmaller, large, small, 2},
+		{ConflictResolveNewer, small, &fileInfo{size: 20, time: t2.Add(time.Millisecond)}, 0},
+		{ConflictResolveLarger, small, &fileInfo{size: 10, time: t1}, 0},
+		{ConflictResolveLarger, small, &fileInfo{size: -1, time: t1}, 0},
+		{ConflictResolvePath1, nil, large, 0},
+	} {
+		got := conflictWinner(test.mode, test.info1, test.info2, time.Second)
+		assert.Equal(t, test.want, got, "%v %+v %+v", test.mode, test.info1, test.info2)
+	}
+}
+
+func TestConflictNames(t *testing.T) {
+	opt := &Options{ConflictSuffix: DefaultConflictSuffix}
+	name1, name2 := opt.conflictNames("dir/file.txt")
+	assert.Equal(t, "dir/file.txt..path1", name1)
+	assert.Equal(t, "dir/file.txt..path2", name2)
+
+	opt.ConflictSuffix = "laptop,server"
+	name1, name2 = opt.conflictNames("file.txt")
+	assert.Equal(t, "file.txt..laptop", name1)
+	assert.Equal(t, "file.txt..server", name2)
+}
+
+func TestConflictModes(t *testing.T) {
+	var mode ConflictResolveMode
+	for _, name := range []string{"none", "newer", "older", "larger", "smaller", "path1", "path2"} {
+		assert.NoError(t, mode.Set(name))
+		assert.Equal(t, name, mode.String())
+	}
+	assert.Error(t, mode.Set("biggest"))
+
+	var action ConflictLoserAction
+	for _, name := range []string{"rename", "delete", "backup-dir"} {
+		assert.NoError(t, action.Set(name))
+		assert.Equal(t, name, action.String())
+	}
+	assert.Error(t, action.Set("keep"))
+}
+
+func TestCompareOpt(t *testing.T) {
+	var compare CompareOpt
+	assert.True(t, compare.IsZero())
+	assert.NoError(t, compare.Set("size, checksum"))
+	assert.Equal(t, CompareOpt{Size: true, Checksum: true}, compare)
+	assert.Equal(t, "size,checksum", compare.String())
+	assert.NoError(t, compare.Set("modtime"))
+	assert.Equal(t, CompareOpt{Modtime: true}, compare)
+	assert.Error(t, compare.Set("size,inode"))
+	assert.Error(t, compare.Set(""))
+	assert.Equal(t, "size,modtime", DefaultCompare.String())
+}
+
+func TestHashChanged(t *testing.T) {
+	now := time.Now()
+	old := newFileList()
+	old.hash = hash.MD5
+	old.put("same", 1, now, "aaaa", "")
+	old.put("changed", 1, now, "aaaa", "")
+	old.put("nohash", 1, now, "", "")
+	cur := newFileList()
+	cur.hash = hash.MD5
+	cur.put("same", 1, now, "aaaa", "")
+	cur.put("changed", 1, now, "bbbb", "")
+	cur.put("nohash", 1, now, "cccc", "")
+	assert.False(t, old.hashChanged(cur, "same"))
+	assert.True(t, old.hashChanged(cur, "changed"))
+	assert.False(t, old.hashChanged(cur, "nohash"))
+
+	// Can't compare different hash types
+	cur.hash = hash.SHA1
+	assert.False(t, old.hashChanged(cur, "changed"))
+}
+
+func TestSameFile(t *testing.T) {
+	t1 := time.Date(2001, 1, 2, 3, 4, 5, 0, time.UTC)
+	ds1 := &deltaSet{hash: hash.MD5, info: map[string]*fileInfo{
+		"same":     {size: 1, time: t1},
+		"time":     {size: 1, time: t1},
+		"size":     {size: 1, time: t1},
+		"hash":     {size: 1, time: t1, hash: "aaaa"},
+		"samehash": {size: 1, time: t1, hash: "aaaa"},
+		"notime":   {size: 1},
+	}}
+	ds2 := &deltaSet{hash: hash.MD5, info: map[string]*fileInfo{
+		"same":     {size: 1, time: t1.Add(500 * time.Millisecond)},
+		"time":     {size: 1, time: t1.Add(time.Hour)},
+		"size":     {size: 2, time: t1},
+		"hash":     {size: 1, time: t1, hash: "bbbb"},
+		"samehash": {size: 1, time: t1.Add(time.Hour), hash: "aaaa"},
+		"notime":   {size: 1},
+	}}
+	assert.True(t, sameFile(ds1, ds2, "same", time.Second))
+	assert.False(t, sameFile(ds1, ds2, "time", time.Second))
+	assert.False(t, sameFile(ds1, ds2, "size", time.Second))
+	assert.False(t, sameFile(ds1, ds2, "hash", time.Second))
+	assert.True(t, sameFile(ds1, ds2, "samehash", time.Second))
+	assert.False(t, sameFile(ds1, ds2, "notime", time.Second))
+	assert.False(t, sameFile(ds1, ds2, "missing", time.Second))
+
+	// Different hash types fall back to size and modtime
+	ds2.hash = hash.SHA1
+	assert.True(t, sameFile(ds1, ds2, "hash", time.Second))
+	assert.False(t, sameFile(ds1, ds2, "samehash", time.Second))
+}
+
+func TestFileListRemove(t *testing.T) {
+	ls := newFileList()
+	for _, file := range []string{"a", "b", "c", "d"} {
+		ls.put(file, 1, time.Now(), "", "")
+	}
+	ls.remove(bilib.ToNames([]string{"b", "d", "e"}))
+	assert.Equal(t, []string{"a", "c"}, ls.list)
+	assert.True(t, ls.has("a"))
+	assert.False(t, ls.has("b"))
+	assert.False(t, ls.has("d"))
+}
+
+func TestConflictLoserBackupDir(t *testing.T) {
+	ctx := context.Background()
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	backupDir1, backupDir2 := t.TempDir(), t.TempDir()
+	write := func(dir, contents string, modTime time.Time) {
+		fileName := filepath.Join(dir, "file.txt")
+		require.NoError(t, os.WriteFile(fileName, []byte(contents), 0600))
+		require.NoError(t, os.Chtimes(fileName, modTime, modTime))
+	}
+	read := func(dir string) string {
+		data, err := os.ReadFile(filepath.Join(dir, "file.txt"))
+		require.NoError(t, err)
+		return string(data)
+	}
+	t0 := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write(dir1, "original", t0)
+	write(dir2, "original", t0)
+	// An unchanged file to pass the safety check on the number
+	// of changes
+	for _, dir := range []string{dir1, dir2} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "other.txt"), []byte("other"), 0600))
+	}
+	fs1, err := fs.NewFs(ctx, dir1)
+	require.NoError(t, err)
+	fs2, err := fs.NewFs(ctx, dir2)
+	require.NoError(t, err)
+
+	opt := &Options{
+		Workdir: t.TempDir(),
+		Resync:  true,
+	}
+	require.NoError(t, Bisync(ctx, fs1, fs2, opt))
+
+	// Change the file on both sides with Path1 the newer
+	write(dir1, "path1 version", t0.Add(30*time.Minute))
+	write(dir2, "path2 version", t0.Add(10*time.Minute))
+	opt.Resync = false
+	opt.ConflictResolve = ConflictResolveNewer
+	opt.ConflictLoser = ConflictLoserBackupDir
+
+	// Both backup dirs are needed
+	opt.BackupDir1 = backupDir1
+	err = Bisync(ctx, fs1, fs2, opt)
+	assert.ErrorContains(t, err, "--conflict-backup-dir2")
+
+	// The Path2 loser is moved to its backup dir
+	opt.BackupDir2 = backupDir2
+	require.NoError(t, Bisync(ctx, fs1, fs2, opt))
+	assert.Equal(t, "path1 version", read(dir1))
+	assert.Equal(t, "path1 version", read(dir2))
+	assert.Equal(t, "path2 version", read(backupDir2))
+	entries, err := os.ReadDir(backupDir1)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestFastCopyDeleteCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	oldCheckpointFiles := checkpointFiles
+	checkpointFiles = 2
+	defer func() {
+		checkpointFiles = oldCheckpointFiles
+	}()
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	files := []string{"a", "b", "c", "d", "e"}
+	for _, file := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir1, file), []byte(file), 0600))
+	}
+	fs1, err := fs.NewFs(ctx, dir1)
+	require.NoError(t, err)
+	fs2, err := fs.NewFs(ctx, dir2)
+	require.NoError(t, err)
+	b := &bisyncRun{fs1: fs1, fs2: fs2, opt: &Options{}}
+
+	var groups [][]string
+	done := func(group bilib.Names) error {
+		groups = append(groups, group.ToList())
+		return nil
+	}
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+
+	require.NoError(t, b.fastCopy(ctx, fs1, fs2, bilib.ToNames(files), "copy1to2", done))
+	assert.Equal(t, want, groups)
+	for _, file := range files {
+		assert.FileExists(t, filepath.Join(dir2, file))
+	}
+
+	groups = nil
+	require.NoError(t, b.fastDelete(ctx, fs2, bilib.ToNames(files), "delete2", done))
+	assert.Equal(t, want, groups)
+	for _, file := range files {
+		assert.NoFileExists(t, filepath.Join(dir2, file))
+	}
+}
@@ -11,6 +11,7 @@ func makeHelp(help string) string {
 		"{MAXDELETE}", strconv.Itoa(DefaultMaxDelete),
 		"{CHECKFILE}", DefaultCheckFilename,
 		"{WORKDIR}", DefaultWorkdir,
+		"{CONFLICTSUFFIX}", DefaultConflictSuffix,
 	)
 	return replacer.Replace(help)
 }
@@ -34,6 +35,16 @@ var rcHelp = makeHelp(`This takes the following parameters
 - filtersFile - read filtering patterns from a file
 - workdir - server directory for history files (default: {WORKDIR})
 - noCleanup - retain working files
+- maxLock - consider lock files older than this to be expired (default: never)
+- compare - comma separated list of properties to detect changes by:
+            |size|, |modtime| and |checksum| (default: |size,modtime|)
+- conflictResolve - automatically resolve files changed on both paths:
+                    |none|, |newer|, |older|, |larger|, |smaller|, |path1| or |path2|
+- conflictLoser - what to do with the losing version of a resolved conflict:
+                  |rename|, |delete| or |backup-dir| (default: |rename|)
+- conflictSuffix - suffix for renamed conflict copies (default: {CONFLICTSUFFIX})
+- conflictBackupDir1 - directory for losing Path1 versions with |backup-dir|
+- conflictBackupDir2 - directory for losing Path2 versions with |backup-dir|
 
 See [bisync command help](https://rclone.org/commands/rclone_bisync/)
 and [full bisync description](https://rclone.org/bisync/)
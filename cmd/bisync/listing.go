@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/rclone/rclone/cmd/bisync/bilib"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/walk"
@@ -81,6 +82,8 @@ func (ls *fileList) put(file string, size int64, time time.Time, hash, id string
 	if fi != nil {
 		fi.size = size
 		fi.time = time
+		fi.hash = hash
+		fi.id = id
 	} else {
 		fi = &fileInfo{
 			size: size,
@@ -93,6 +96,22 @@ func (ls *fileList) put(file string, size int64, time time.Time, hash, id string
 	}
 }
 
+// remove deletes files from the listing
+func (ls *fileList) remove(files bilib.Names) {
+	if len(files) == 0 {
+		return
+	}
+	list := ls.list[:0]
+	for _, file := range ls.list {
+		if files.Has(file) {
+			delete(ls.info, file)
+		} else {
+			list = append(list, file)
+		}
+	}
+	ls.list = list
+}
+
 func (ls *fileList) getTime(file string) time.Time {
 	fi := ls.get(file)
 	if fi == nil {
@@ -101,6 +120,36 @@ func (ls *fileList) getTime(file string) time.Time {
 	return fi.time
 }
 
+func (ls *fileList) getSize(file string) int64 {
+	fi := ls.get(file)
+	if fi == nil {
+		return -1
+	}
+	return fi.size
+}
+
+func (ls *fileList) getHash(file string) string {
+	fi := ls.get(file)
+	if fi == nil {
+		return ""
+	}
+	return fi.hash
+}
+
+// hashChanged returns true if file has a different hash in other.
+// Files without a hash in either listing are assumed unchanged.
+func (ls *fileList) hashChanged(other *fileList, file string) bool {
+	if ls.hash == hash.None || ls.hash != other.hash {
+		return false
+	}
+	thisHash := ls.getHash(file)
+	thatHash := other.getHash(file)
+	if thisHash == "" || thatHash == "" {
+		return false
+	}
+	return thisHash != thatHash
+}
+
 func (ls *fileList) beforeOther(other *fileList, file string) bool {
 	thisTime := ls.getTime(file)
 	thatTime := other.getTime(file)
@@ -119,8 +168,12 @@ func (ls *fileList) afterTime(file string, time time.Time) bool {
 }
 
 // save will save listing to a file.
+//
+// The listing is written to a temporary file which then replaces the
+// listing so an interrupted save never leaves a partial listing.
 func (ls *fileList) save(ctx context.Context, listing string) error {
-	file, err := os.Create(listing)
+	tmpListing := listing + ".tmp"
+	file, err := os.Create(tmpListing)
 	if err != nil {
 		return err
 	}
@@ -133,7 +186,7 @@ func (ls *fileList) save(ctx context.Context, listing string) error {
 	_, err = fmt.Fprintf(file, "%s %s\n", ListingHeader, time.Now().In(TZ).Format(timeFormat))
 	if err != nil {
 		_ = file.Close()
-		_ = os.Remove(listing)
+		_ = os.Remove(tmpListing)
 		return err
 	}
 
@@ -156,12 +209,16 @@ func (ls *fileList) save(ctx context.Context, listing string) error {
 		_, err = fmt.Fprintf(file, lineFormat, flags, fi.size, hash, id, time, remote)
 		if err != nil {
 			_ = file.Close()
-			_ = os.Remove(listing)
+			_ = os.Remove(tmpListing)
 			return err
 		}
 	}
 
-	return file.Close()
+	if err = file.Close(); err != nil {
+		_ = os.Remove(tmpListing)
+		return err
+	}
+	return os.Rename(tmpListing, listing)
 }
 
 // loadListing will load listing from a file.
@@ -249,15 +306,27 @@ func parseHash(str string) (string, string, error) {
 }
 
 // makeListing will produce listing from directory tree and write it to a file
-func (b *bisyncRun) makeListing(ctx context.Context, f fs.Fs, listing string) (ls *fileList, err error) {
+//
+// If hashing on f is slow then the hashes of files whose size and
+// modification time match those in cached are reused rather than
+// read again. cached may be nil.
+func (b *bisyncRun) makeListing(ctx context.Context, f fs.Fs, listing string, cached *fileList) (ls *fileList, err error) {
 	ci := fs.GetConfig(ctx)
 	depth := ci.MaxDepth
+	compare := b.opt.Compare
+	features := f.Features()
 	hashType := hash.None
-	if !ci.IgnoreChecksum {
-		// Currently bisync just honors --ignore-checksum
-		// TODO add full support for checksums and related flags
+	if !ci.IgnoreChecksum && (compare.Checksum || !features.SlowHash) {
 		hashType = f.Hashes().GetOne()
+		if hashType == hash.None && compare.Checksum {
+			fs.Logf(f, "No hashes available, can't compare checksums on this path")
+		}
 	}
+	if cached != nil && (!features.SlowHash || cached.hash != hashType) {
+		cached = nil
+	}
+	// Don't read slow modification times unless they are needed
+	readModTime := compare.Modtime || !features.SlowModTime
 	ls = newFileList()
 	ls.hash = hashType
 	var lock sync.Mutex
@@ -268,17 +337,28 @@ func (b *bisyncRun) makeListing(ctx context.Context, f fs.Fs, listing string) (l
 			var (
 				hashVal string
 				hashErr error
+				modTime time.Time
+				prior   *fileInfo
 			)
+			if readModTime {
+				modTime = o.ModTime(ctx).In(TZ)
+			}
+			if cached != nil {
+				prior = cached.get(o.Remote())
+			}
 			if hashType != hash.None {
-				hashVal, hashErr = o.Hash(ctx, hashType)
+				if readModTime && prior != nil && prior.hash != "" && prior.size == o.Size() && prior.time.Equal(modTime) {
+					hashVal = prior.hash
+				} else {
+					hashVal, hashErr = o.Hash(ctx, hashType)
+				}
 				if firstErr == nil {
 					firstErr = hashErr
 				}
 			}
-			time := o.ModTime(ctx).In(TZ)
 			id := "" // TODO
 			lock.Lock()
-			ls.put(o.Remote(), o.Size(), time, hashVal, id)
+			ls.put(o.Remote(), o.Size(), modTime, hashVal, id)
 			lock.Unlock()
 			//tr.Done(ctx, nil) // TODO
 		})
@@ -293,6 +373,21 @@ func (b *bisyncRun) makeListing(ctx context.Context, f fs.Fs, listing string) (l
 	return
 }
 
+// hashCache loads the listing to reuse hashes from when remaking the
+// listing of f. It returns nil if hashing on f is quick or the listing
+// can't be read.
+func (b *bisyncRun) hashCache(f fs.Fs, listing string) *fileList {
+	if !f.Features().SlowHash {
+		return nil
+	}
+	ls, err := b.loadListing(listing)
+	if err != nil {
+		fs.Debugf(nil, "Not reusing hashes from %s: %v", listing, err)
+		return nil
+	}
+	return ls
+}
+
 // checkListing verifies that listing is not empty (unless resynching)
 func (b *bisyncRun) checkListing(ls *fileList, listing, msg string) error {
 	if b.opt.Resync || !ls.empty() {
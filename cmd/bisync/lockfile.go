@@ -0,0 +1,161 @@
+package bisync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rclone/rclone/cmd/bisync/bilib"
+	"github.com/rclone/rclone/fs"
+)
+
+// MinMaxLock is the smallest --max-lock allowed, so the lock can be
+// renewed well before it expires
+const MinMaxLock = 2 * time.Minute
+
+// lockFileInfo is stored in the lock file
+type lockFileInfo struct {
+	Session     string
+	PID         string
+	TimeRenewed time.Time
+	TimeExpires time.Time // zero if the lock never expires
+}
+
+// readLockFile reads the lock file. Lock files from older versions
+// only contain the PID and never expire.
+func readLockFile(lockFile string) (info lockFileInfo, err error) {
+	data, err := os.ReadFile(lockFile)
+	if err != nil {
+		return info, err
+	}
+	if err = json.Unmarshal(data, &info); err != nil {
+		info = lockFileInfo{PID: string(data)}
+	}
+	return info, nil
+}
+
+// expired returns true if the lock has expired
+func (info *lockFileInfo) expired() bool {
+	return !info.TimeExpires.IsZero() && time.Now().After(info.TimeExpires)
+}
+
+// removeExpiredLock removes lockFile if it has expired.
+//
+// The removal is guarded by a second lock file, so another bisync
+// can't take the lock between it being checked and removed here.
+func removeExpiredLock(lockFile string) error {
+	breakFile := lockFile + ".break"
+	file, err := os.OpenFile(breakFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, bilib.PermSecure)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("prior lock file found: %s: another run is removing it (delete %s if not)", lockFile, breakFile)
+	} else if err != nil {
+		return fmt.Errorf("cannot remove expired lock file: %s: %w", lockFile, err)
+	}
+	_ = file.Close()
+	defer func() {
+		_ = os.Remove(breakFile)
+	}()
+
+	// Check again now no other run can remove it
+	info, err := readLockFile(lockFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("cannot read prior lock file: %s: %w", lockFile, err)
+	}
+	if !info.expired() {
+		return fmt.Errorf("prior lock file found: %s", lockFile)
+	}
+	fs.Logf(nil, "Removing prior lock file which expired at %v: %s", info.TimeExpires.Format(time.RFC3339), lockFile)
+	if err = os.Remove(lockFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove expired lock file: %s: %w", lockFile, err)
+	}
+	return nil
+}
+
+// writeLockFile writes the lock file, failing if create is set and
+// the lock file exists already
+func (b *bisyncRun) writeLockFile(lockFile string, create bool) error {
+	info := lockFileInfo{
+		Session:     bilib.SessionName(b.fs1, b.fs2),
+		PID:         strconv.Itoa(os.Getpid()),
+		TimeRenewed: time.Now(),
+	}
+	if b.opt.MaxLock > 0 {
+		info.TimeExpires = info.TimeRenewed.Add(b.opt.MaxLock)
+	}
+	data, err := json.MarshalIndent(info, "", "\t")
+	if err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_TRUNC
+	if create {
+		flags |= os.O_CREATE | os.O_EXCL
+	}
+	file, err := os.OpenFile(lockFile, flags, bilib.PermSecure)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// lock takes the lock file for this pair of paths, removing a prior
+// lock file if it has expired. While the lock is held it is renewed
+// if --max-lock is set. Call the returned function to stop renewing.
+func (b *bisyncRun) lock(lockFile string) (stopRenew func(), err error) {
+	if bilib.FileExists(lockFile) {
+		info, err := readLockFile(lockFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read prior lock file: %s: %w", lockFile, err)
+		}
+		if !info.expired() {
+			return nil, fmt.Errorf("prior lock file found: %s", lockFile)
+		}
+		if err = removeExpiredLock(lockFile); err != nil {
+			return nil, err
+		}
+	}
+	if err = b.writeLockFile(lockFile, true); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("prior lock file found: %s", lockFile)
+		}
+		return nil, fmt.Errorf("cannot create lock file: %s: %w", lockFile, err)
+	}
+	fs.Debugf(nil, "Lock file created: %s", lockFile)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	stopRenew = func() {
+		close(stop)
+		<-done
+	}
+	if b.opt.MaxLock <= 0 {
+		close(done)
+		return stopRenew, nil
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(b.opt.MaxLock / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := b.writeLockFile(lockFile, false); err != nil {
+					fs.Errorf(nil, "Failed to renew lock file: %s: %v", lockFile, err)
+				} else {
+					fs.Debugf(nil, "Lock file renewed: %s", lockFile)
+				}
+			}
+		}
+	}()
+	return stopRenew, nil
+}
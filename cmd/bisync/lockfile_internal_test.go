@@ -0,0 +1,92 @@
+package bisync
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLockTestRun(t *testing.T, maxLock time.Duration) *bisyncRun {
+	ctx := context.Background()
+	fs1, err := fs.NewFs(ctx, t.TempDir())
+	require.NoError(t, err)
+	fs2, err := fs.NewFs(ctx, t.TempDir())
+	require.NoError(t, err)
+	return &bisyncRun{
+		fs1: fs1,
+		fs2: fs2,
+		opt: &Options{MaxLock: maxLock},
+	}
+}
+
+func TestLock(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "test.lck")
+	b := newLockTestRun(t, 0)
+
+	stopRenew, err := b.lock(lockFile)
+	require.NoError(t, err)
+	info, err := readLockFile(lockFile)
+	require.NoError(t, err)
+	assert.NotEmpty(t, info.PID)
+	assert.True(t, info.TimeExpires.IsZero())
+
+	// A lock without an expiry blocks other runs
+	_, err = b.lock(lockFile)
+	assert.ErrorContains(t, err, "prior lock file found")
+	stopRenew()
+	require.NoError(t, os.Remove(lockFile))
+
+	// As does a lock file from an older version
+	require.NoError(t, os.WriteFile(lockFile, []byte("1234"), 0600))
+	info, err = readLockFile(lockFile)
+	require.NoError(t, err)
+	assert.Equal(t, "1234", info.PID)
+	_, err = b.lock(lockFile)
+	assert.ErrorContains(t, err, "prior lock file found")
+	require.NoError(t, os.Remove(lockFile))
+}
+
+func TestLockExpired(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), "test.lck")
+	b := newLockTestRun(t, MinMaxLock)
+
+	stopRenew, err := b.lock(lockFile)
+	require.NoError(t, err)
+	stopRenew()
+	info, err := readLockFile(lockFile)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(MinMaxLock), info.TimeExpires, time.Minute)
+
+	// An unexpired lock blocks other runs
+	_, err = b.lock(lockFile)
+	assert.ErrorContains(t, err, "prior lock file found")
+
+	// An expired lock should be taken over
+	info.TimeExpires = time.Now().Add(-time.Minute)
+	data, err := json.Marshal(info)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(lockFile, data, 0600))
+
+	// Not while another run is removing it
+	breakFile := lockFile + ".break"
+	require.NoError(t, os.WriteFile(breakFile, nil, 0600))
+	_, err = b.lock(lockFile)
+	assert.ErrorContains(t, err, "another run is removing it")
+	require.NoError(t, os.Remove(breakFile))
+
+	stopRenew, err = b.lock(lockFile)
+	require.NoError(t, err)
+	stopRenew()
+	info, err = readLockFile(lockFile)
+	require.NoError(t, err)
+	assert.True(t, info.TimeExpires.After(time.Now()))
+	assert.NoFileExists(t, breakFile)
+}
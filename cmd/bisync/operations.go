@@ -9,11 +9,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
 	gosync "sync"
 
 	"github.com/rclone/rclone/cmd/bisync/bilib"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/sync"
@@ -32,6 +32,8 @@ type bisyncRun struct {
 	basePath string
 	workDir  string
 	opt      *Options
+	backup1  fs.Fs // --conflict-backup-dir1 for --conflict-loser backup-dir
+	backup2  fs.Fs // --conflict-backup-dir2 for --conflict-loser backup-dir
 }
 
 // Bisync handles lock file, performs bisync run and checks exit status
@@ -49,8 +51,36 @@ func Bisync(ctx context.Context, fs1, fs2 fs.Fs, optArg *Options) (err error) {
 	if opt.Workdir == "" {
 		opt.Workdir = DefaultWorkdir
 	}
+	if opt.ConflictSuffix == "" {
+		opt.ConflictSuffix = DefaultConflictSuffix
+	}
+	if opt.ConflictLoser == ConflictLoserBackupDir && opt.ConflictResolve != ConflictResolveNone {
+		if opt.BackupDir1 == "" || opt.BackupDir2 == "" {
+			return errors.New("--conflict-loser backup-dir requires --conflict-backup-dir1 and --conflict-backup-dir2")
+		}
+		if b.backup1, err = cache.Get(ctx, opt.BackupDir1); err != nil {
+			return fmt.Errorf("failed to make fs for --conflict-backup-dir1 %q: %w", opt.BackupDir1, err)
+		}
+		if b.backup2, err = cache.Get(ctx, opt.BackupDir2); err != nil {
+			return fmt.Errorf("failed to make fs for --conflict-backup-dir2 %q: %w", opt.BackupDir2, err)
+		}
+	}
+
+	if opt.Compare.IsZero() {
+		opt.Compare = DefaultCompare
+	}
+	if !opt.Compare.Modtime {
+		// Make the copies use the same comparison as the listings
+		var ci *fs.ConfigInfo
+		ctx, ci = fs.AddConfig(ctx)
+		if opt.Compare.Checksum {
+			ci.CheckSum = true
+		} else {
+			ci.SizeOnly = true
+		}
+	}
 
-	if !opt.DryRun && !opt.Force {
+	if !opt.DryRun && !opt.Force && opt.Compare.Modtime {
 		if fs1.Precision() == fs.ModTimeNotSupported {
 			return errors.New("modification time support is missing on path1")
 		}
@@ -73,34 +103,27 @@ func Bisync(ctx context.Context, fs1, fs2 fs.Fs, optArg *Options) (err error) {
 
 	// Handle lock file
 	lockFile := ""
+	stopRenew := func() {}
 	if !opt.DryRun {
 		lockFile = b.basePath + ".lck"
-		if bilib.FileExists(lockFile) {
-			return fmt.Errorf("prior lock file found: %s", lockFile)
+		if opt.MaxLock > 0 && opt.MaxLock < MinMaxLock {
+			fs.Logf(nil, "--max-lock %v is too short, using %v", opt.MaxLock, MinMaxLock)
+			opt.MaxLock = MinMaxLock
 		}
-
-		pidStr := []byte(strconv.Itoa(os.Getpid()))
-		if err = os.WriteFile(lockFile, pidStr, bilib.PermSecure); err != nil {
-			return fmt.Errorf("cannot create lock file: %s: %w", lockFile, err)
+		if stopRenew, err = b.lock(lockFile); err != nil {
+			return err
 		}
-		fs.Debugf(nil, "Lock file created: %s", lockFile)
 	}
 
 	// Handle SIGINT
+	//
+	// The prior listings are checkpointed as changes are applied so
+	// the next run can carry on from where this one stopped.
 	var finaliseOnce gosync.Once
-	markFailed := func(file string) {
-		failFile := file + "-err"
-		if bilib.FileExists(file) {
-			_ = os.Remove(failFile)
-			_ = os.Rename(file, failFile)
-		}
-	}
 	finalise := func() {
 		finaliseOnce.Do(func() {
 			if atexit.Signalled() {
-				fs.Logf(nil, "Bisync interrupted. Must run --resync to recover.")
-				markFailed(listing1)
-				markFailed(listing2)
+				fs.Logf(nil, "Bisync interrupted. Run bisync again to recover.")
 				_ = os.Remove(lockFile)
 			}
 		})
@@ -110,6 +133,7 @@ func Bisync(ctx context.Context, fs1, fs2 fs.Fs, optArg *Options) (err error) {
 
 	// run bisync
 	err = b.runLocked(ctx, listing1, listing2)
+	stopRenew()
 
 	if lockFile != "" {
 		errUnlock := os.Remove(lockFile)
@@ -267,12 +291,12 @@ func (b *bisyncRun) runLocked(octx context.Context, listing1, listing2 string) (
 		err2 = bilib.CopyFileIfExists(newListing2, listing2)
 	} else {
 		if changes1 {
-			_, err1 = b.makeListing(fctx, b.fs1, listing1)
+			_, err1 = b.makeListing(fctx, b.fs1, listing1, b.hashCache(b.fs1, newListing1))
 		} else {
 			err1 = bilib.CopyFileIfExists(newListing1, listing1)
 		}
 		if changes2 {
-			_, err2 = b.makeListing(fctx, b.fs2, listing2)
+			_, err2 = b.makeListing(fctx, b.fs2, listing2, b.hashCache(b.fs2, newListing2))
 		} else {
 			err2 = bilib.CopyFileIfExists(newListing2, listing2)
 		}
@@ -324,7 +348,7 @@ func (b *bisyncRun) resync(octx, fctx context.Context, listing1, listing2 string
 	fs.Infof(nil, "Copying unique Path2 files to Path1")
 
 	newListing1 := listing1 + "-new"
-	filesNow1, err := b.makeListing(fctx, b.fs1, newListing1)
+	filesNow1, err := b.makeListing(fctx, b.fs1, newListing1, nil)
 	if err == nil {
 		err = b.checkListing(filesNow1, newListing1, "current Path1")
 	}
@@ -333,7 +357,7 @@ func (b *bisyncRun) resync(octx, fctx context.Context, listing1, listing2 string
 	}
 
 	newListing2 := listing2 + "-new"
-	filesNow2, err := b.makeListing(fctx, b.fs2, newListing2)
+	filesNow2, err := b.makeListing(fctx, b.fs2, newListing2, nil)
 	if err == nil {
 		err = b.checkListing(filesNow2, newListing2, "current Path2")
 	}
@@ -352,7 +376,7 @@ func (b *bisyncRun) resync(octx, fctx context.Context, listing1, listing2 string
 	if len(copy2to1) > 0 {
 		b.indent("Path2", "Path1", "Resync is doing queued copies to")
 		// octx does not have extra filters!
-		err = b.fastCopy(octx, b.fs2, b.fs1, bilib.ToNames(copy2to1), "resync-copy2to1")
+		err = b.fastCopy(octx, b.fs2, b.fs1, bilib.ToNames(copy2to1), "resync-copy2to1", nil)
 		if err != nil {
 			b.critical = true
 			return err
@@ -373,12 +397,12 @@ func (b *bisyncRun) resync(octx, fctx context.Context, listing1, listing2 string
 	}
 
 	fs.Infof(nil, "Resync updating listings")
-	if _, err = b.makeListing(fctx, b.fs1, listing1); err != nil {
+	if _, err = b.makeListing(fctx, b.fs1, listing1, filesNow1); err != nil {
 		b.critical = true
 		return err
 	}
 
-	if _, err = b.makeListing(fctx, b.fs2, listing2); err != nil {
+	if _, err = b.makeListing(fctx, b.fs2, listing2, filesNow2); err != nil {
 		b.critical = true
 		return err
 	}
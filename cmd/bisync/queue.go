@@ -3,6 +3,7 @@ package bisync
 import (
 	"context"
 	"fmt"
+	gosync "sync"
 
 	"github.com/rclone/rclone/cmd/bisync/bilib"
 	"github.com/rclone/rclone/fs"
@@ -11,22 +12,59 @@ import (
 	"github.com/rclone/rclone/fs/sync"
 )
 
-func (b *bisyncRun) fastCopy(ctx context.Context, fsrc, fdst fs.Fs, files bilib.Names, queueName string) error {
+// checkpointFiles is the number of files copied or deleted between
+// checkpoints of the listings
+var checkpointFiles = 1000
+
+// checkpointGroups returns the files sorted and split into the groups
+// to checkpoint after, or in a single group if done is nil.
+func checkpointGroups(files bilib.Names, done func(bilib.Names) error) [][]string {
+	list := files.ToList()
+	size := len(list)
+	if done != nil && checkpointFiles > 0 {
+		size = checkpointFiles
+	}
+	var groups [][]string
+	for len(list) > 0 {
+		n := size
+		if n > len(list) {
+			n = len(list)
+		}
+		groups = append(groups, list[:n])
+		list = list[n:]
+	}
+	return groups
+}
+
+// fastCopy copies files from fsrc to fdst calling done, if set, with
+// each group of files copied so progress can be checkpointed.
+func (b *bisyncRun) fastCopy(ctx context.Context, fsrc, fdst fs.Fs, files bilib.Names, queueName string, done func(bilib.Names) error) error {
 	if err := b.saveQueue(files, queueName); err != nil {
 		return err
 	}
 
-	ctxCopy, filterCopy := filter.AddConfig(b.opt.setDryRun(ctx))
-	for _, file := range files.ToList() {
-		if err := filterCopy.AddFile(file); err != nil {
+	for _, group := range checkpointGroups(files, done) {
+		ctxCopy, filterCopy := filter.AddConfig(b.opt.setDryRun(ctx))
+		for _, file := range group {
+			if err := filterCopy.AddFile(file); err != nil {
+				return err
+			}
+		}
+		if err := sync.CopyDir(ctxCopy, fdst, fsrc, false); err != nil {
 			return err
 		}
+		if done != nil {
+			if err := done(bilib.ToNames(group)); err != nil {
+				return err
+			}
+		}
 	}
-
-	return sync.CopyDir(ctxCopy, fdst, fsrc, false)
+	return nil
 }
 
-func (b *bisyncRun) fastDelete(ctx context.Context, f fs.Fs, files bilib.Names, queueName string) error {
+// fastDelete deletes files from f calling done, if set, with each
+// group of files deleted so progress can be checkpointed.
+func (b *bisyncRun) fastDelete(ctx context.Context, f fs.Fs, files bilib.Names, queueName string, done func(bilib.Names) error) error {
 	if err := b.saveQueue(files, queueName); err != nil {
 		return err
 	}
@@ -34,23 +72,42 @@ func (b *bisyncRun) fastDelete(ctx context.Context, f fs.Fs, files bilib.Names,
 	transfers := fs.GetConfig(ctx).Transfers
 	ctxRun := b.opt.setDryRun(ctx)
 
-	objChan := make(fs.ObjectsChan, transfers)
-	errChan := make(chan error, 1)
-	go func() {
-		errChan <- operations.DeleteFiles(ctxRun, objChan)
-	}()
+	var mu gosync.Mutex
+	objs := make(map[string]fs.Object, len(files))
 	err := operations.ListFn(ctxRun, f, func(obj fs.Object) {
 		remote := obj.Remote()
 		if files.Has(remote) {
-			objChan <- obj
+			mu.Lock()
+			objs[remote] = obj
+			mu.Unlock()
 		}
 	})
-	close(objChan)
-	opErr := <-errChan
-	if err == nil {
-		err = opErr
+	if err != nil {
+		return err
+	}
+
+	for _, group := range checkpointGroups(files, done) {
+		objChan := make(fs.ObjectsChan, transfers)
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- operations.DeleteFiles(ctxRun, objChan)
+		}()
+		for _, file := range group {
+			if obj := objs[file]; obj != nil {
+				objChan <- obj
+			}
+		}
+		close(objChan)
+		if err := <-errChan; err != nil {
+			return err
+		}
+		if done != nil {
+			if err := done(bilib.ToNames(group)); err != nil {
+				return err
+			}
+		}
 	}
-	return err
+	return nil
 }
 
 func (b *bisyncRun) saveQueue(files bilib.Names, jobName string) error {
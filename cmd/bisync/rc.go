@@ -64,6 +64,43 @@ func rcBisync(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	if opt.Workdir, err = in.GetString("workdir"); rc.NotErrParamNotFound(err) {
 		return
 	}
+	if opt.ConflictSuffix, err = in.GetString("conflictSuffix"); rc.NotErrParamNotFound(err) {
+		return
+	}
+	if opt.BackupDir1, err = in.GetString("conflictBackupDir1"); rc.NotErrParamNotFound(err) {
+		return
+	}
+	if opt.BackupDir2, err = in.GetString("conflictBackupDir2"); rc.NotErrParamNotFound(err) {
+		return
+	}
+
+	if opt.MaxLock, err = in.GetDuration("maxLock"); rc.NotErrParamNotFound(err) {
+		return
+	}
+
+	if compare, err := in.GetString("compare"); err == nil {
+		if err := opt.Compare.Set(compare); err != nil {
+			return nil, rc.NewErrParamInvalid(err)
+		}
+	} else if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+
+	conflictResolve, err := in.GetString("conflictResolve")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if err := opt.ConflictResolve.Set(conflictResolve); err != nil {
+		return nil, err
+	}
+
+	conflictLoser, err := in.GetString("conflictLoser")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if err := opt.ConflictLoser.Set(conflictLoser); err != nil {
+		return nil, err
+	}
 
 	checkSync, err := in.GetString("checkSync")
 	if rc.NotErrParamNotFound(err) {
@@ -101,19 +101,42 @@ var configPathsCommand = &cobra.Command{
 	},
 }
 
+var showResolved bool
+
+func init() {
+	flags.BoolVarP(configShowCommand.Flags(), &showResolved, "resolved", "", false, "Show the effective config with inherited options filled in")
+}
+
 var configShowCommand = &cobra.Command{
 	Use:   "show [<remote>]",
 	Short: `Print (decrypted) config file, or the config for a single remote.`,
+	Long: strings.ReplaceAll(`
+Print the (decrypted) config file, or the config for a single remote.
+
+A remote may inherit the options it doesn't set from another section
+of the config file with an |inherit| key, e.g. |inherit = s3-base|.
+Use |--resolved| to show the effective options of the remotes with
+the inherited options filled in. In this mode sections without a
+|type| are only used to inherit from so are not shown.
+`, "|", "`"),
 	Annotations: map[string]string{
 		"versionIntroduced": "v1.38",
 	},
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(0, 1, command, args)
 		if len(args) == 0 {
-			config.ShowConfig()
+			if showResolved {
+				config.ShowConfigResolved()
+			} else {
+				config.ShowConfig()
+			}
 		} else {
 			name := strings.TrimRight(args[0], ":")
-			config.ShowRemote(name)
+			if showResolved {
+				config.ShowRemoteResolved(name)
+			} else {
+				config.ShowRemote(name)
+			}
 		}
 	},
 }
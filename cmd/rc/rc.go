@@ -178,6 +178,10 @@ func doCall(ctx context.Context, path string, in rc.Params) (out rc.Params, err
 		if call == nil {
 			return nil, fmt.Errorf("method %q not found", path)
 		}
+		if in == nil {
+			in = make(rc.Params)
+		}
+		in["_path"] = path
 		_, out, err := jobs.NewJob(ctx, call.Fn, in)
 		if err != nil {
 			return nil, fmt.Errorf("loopback call failed: %w", err)
@@ -4,10 +4,14 @@ package rcd
 import (
 	"context"
 	"log"
+	"path/filepath"
 	"sync"
 
 	sysdnotify "github.com/iguanesolutions/go-systemd/v5/notify"
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/rc/jobs"
 	"github.com/rclone/rclone/fs/rc/rcflags"
 	"github.com/rclone/rclone/fs/rc/rcserver"
 	"github.com/rclone/rclone/lib/atexit"
@@ -56,6 +60,16 @@ See the [rc documentation](/rc/) for more info on the rc flags.
 			log.Fatal("rc server not configured")
 		}
 
+		// Start the scheduler - this is only run by rcd
+		scheduleFile := rcflags.Opt.ScheduleFile
+		if scheduleFile == "" {
+			scheduleFile = filepath.Join(config.GetCacheDir(), "rc", "schedules.json")
+		}
+		err = jobs.StartScheduler(context.Background(), scheduleFile)
+		if err != nil {
+			fs.Errorf(nil, "Scheduler not started: %v", err)
+		}
+
 		// Notify stopping on exit
 		var finaliseOnce sync.Once
 		finalise := func() {
@@ -12,7 +12,6 @@ import (
 	"path"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rclone/rclone/cmd"
@@ -20,6 +19,7 @@ import (
 	"github.com/rclone/rclone/cmd/serve/proxy/proxyflags"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config/flags"
 	libhttp "github.com/rclone/rclone/lib/http"
 	"github.com/rclone/rclone/lib/http/serve"
 	"github.com/rclone/rclone/vfs"
@@ -32,6 +32,7 @@ type Options struct {
 	Auth     libhttp.AuthConfig
 	HTTP     libhttp.Config
 	Template libhttp.TemplateConfig
+	Writable bool // allow uploads, deletes, renames and mkdir
 }
 
 // DefaultOpt is the default values used for Options
@@ -53,6 +54,7 @@ func init() {
 	libhttp.AddAuthFlagsPrefix(flagSet, flagPrefix, &Opt.Auth)
 	libhttp.AddHTTPFlagsPrefix(flagSet, flagPrefix, &Opt.HTTP)
 	libhttp.AddTemplateFlagsPrefix(flagSet, flagPrefix, &Opt.Template)
+	flags.BoolVarP(flagSet, &Opt.Writable, flagPrefix+"writable", "", Opt.Writable, "Allow uploads, deletes, renames and making directories")
 	vfsflags.AddFlags(flagSet)
 	proxyflags.AddFlags(flagSet)
 }
@@ -72,7 +74,7 @@ The server will log errors.  Use ` + "`-v`" + ` to see access logs.
 
 ` + "`--bwlimit`" + ` will be respected for file transfers.  Use ` + "`--stats`" + ` to
 control the stats printing.
-` + libhttp.Help(flagPrefix) + libhttp.TemplateHelp(flagPrefix) + libhttp.AuthHelp(flagPrefix) + vfs.Help + proxy.Help,
+` + listingHelp + writableHelp + libhttp.Help(flagPrefix) + libhttp.TemplateHelp(flagPrefix) + libhttp.AuthHelp(flagPrefix) + vfs.Help + proxy.Help,
 	Annotations: map[string]string{
 		"versionIntroduced": "v1.39",
 	},
@@ -116,20 +118,28 @@ func (s *HTTP) getVFS(ctx context.Context) (VFS *vfs.VFS, err error) {
 	if value == nil {
 		return nil, errors.New("no VFS found in context")
 	}
-	VFS, ok := value.(*vfs.VFS)
+	u, ok := value.(*user)
 	if !ok {
-		return nil, fmt.Errorf("context value is not VFS: %#v", value)
+		return nil, fmt.Errorf("context value is not user: %#v", value)
 	}
-	return VFS, nil
+	return u.vfs, nil
 }
 
 // auth does proxy authorization
-func (s *HTTP) auth(user, pass string) (value interface{}, err error) {
-	VFS, _, err := s.proxy.Call(user, pass, false)
+func (s *HTTP) auth(userName, pass string) (value interface{}, err error) {
+	VFS, permsString, err := s.proxy.CallPermissions(userName, pass, false)
 	if err != nil {
 		return nil, err
 	}
-	return VFS, err
+	perms, err := parsePermissions(permsString)
+	if err != nil {
+		fs.Errorf(nil, "Bad _permissions from auth proxy for %q - not allowing writes: %v", userName, err)
+		perms = permNone
+	}
+	return &user{
+		vfs:   VFS,
+		perms: perms,
+	}, nil
 }
 
 func run(ctx context.Context, f fs.Fs, opt Options) (s *HTTP, err error) {
@@ -163,6 +173,11 @@ func run(ctx context.Context, f fs.Fs, opt Options) (s *HTTP, err error) {
 	)
 	router.Get("/*", s.handler)
 	router.Head("/*", s.handler)
+	if s.opt.Writable {
+		router.Post("/*", s.handler)
+		router.Put("/*", s.handler)
+		router.Delete("/*", s.handler)
+	}
 
 	s.server.Serve()
 
@@ -173,7 +188,9 @@ func run(ctx context.Context, f fs.Fs, opt Options) (s *HTTP, err error) {
 func (s *HTTP) handler(w http.ResponseWriter, r *http.Request) {
 	isDir := strings.HasSuffix(r.URL.Path, "/")
 	remote := strings.Trim(r.URL.Path, "/")
-	if isDir {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		s.serveWrite(w, r, remote)
+	} else if isDir {
 		s.serveDir(w, r, remote)
 	} else {
 		s.serveFile(w, r, remote)
@@ -202,25 +219,52 @@ func (s *HTTP) serveDir(w http.ResponseWriter, r *http.Request, dirRemote string
 		return
 	}
 	dir := node.(*vfs.Dir)
-	dirEntries, err := dir.ReadDirAll()
-	if err != nil {
-		serve.Error(dirRemote, w, "Failed to list directory", err)
-		return
-	}
+	query := r.URL.Query()
 
 	// Make the entries for display
 	directory := serve.NewDirectory(dirRemote, s.server.HTMLTemplate())
-	for _, node := range dirEntries {
-		if vfsflags.Opt.NoModTime {
-			directory.AddHTMLEntry(node.Path(), node.IsDir(), node.Size(), time.Time{})
-		} else {
-			directory.AddHTMLEntry(node.Path(), node.IsDir(), node.Size(), node.ModTime().UTC())
+	if pattern := query.Get("search"); pattern != "" {
+		fi, err := searchFilter(pattern)
+		if err != nil {
+			http.Error(w, "Bad search pattern: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		directory.Search = pattern
+		directory.Title = fmt.Sprintf("Search results for %q in /%s", pattern, dirRemote)
+		truncated, err := search(r.Context(), dir, fi, directory)
+		if err != nil {
+			serve.Error(dirRemote, w, "Failed to search directory", err)
+			return
+		}
+		if truncated {
+			directory.Title += fmt.Sprintf(" (first %d)", searchMaxResults)
+			fs.Infof(dirRemote, "%s: Search for %q stopped after %d entries", r.RemoteAddr, pattern, searchMaxResults)
+		}
+	} else {
+		dirEntries, err := dir.ReadDirAll()
+		if err != nil {
+			serve.Error(dirRemote, w, "Failed to list directory", err)
+			return
+		}
+		for _, node := range dirEntries {
+			directory.AddHTMLEntry(node.Path(), node.IsDir(), node.Size(), nodeModTime(node))
 		}
 	}
 
-	sortParm := r.URL.Query().Get("sort")
-	orderParm := r.URL.Query().Get("order")
+	perms := s.getPermissions(r.Context())
+	directory.CanUpload = perms&permUpload != 0
+	directory.CanMkdir = perms&permMkdir != 0
+	directory.CanDelete = perms&permDelete != 0
+	directory.CanRename = perms&permRename != 0
+
+	sortParm := query.Get("sort")
+	orderParm := query.Get("order")
 	directory.ProcessQueryParams(sortParm, orderParm)
+	err = directory.ProcessPageParams(query.Get("offset"), query.Get("limit"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Set the Last-Modified header to the timestamp
 	w.Header().Set("Last-Modified", dir.ModTime().UTC().Format(http.TimeFormat))
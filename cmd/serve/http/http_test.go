@@ -1,11 +1,17 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -16,6 +22,8 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/filter"
 	libhttp "github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/lib/http/serve"
+	"github.com/rclone/rclone/vfs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -31,12 +39,13 @@ const (
 	testTemplate    = "testdata/golden/testindex.html"
 )
 
-func start(ctx context.Context, t *testing.T, f fs.Fs) (s *HTTP, testURL string) {
+func start(ctx context.Context, t *testing.T, f fs.Fs, writable bool) (s *HTTP, testURL string) {
 	opts := Options{
 		HTTP: libhttp.DefaultCfg(),
 		Template: libhttp.TemplateConfig{
 			Path: testTemplate,
 		},
+		Writable: writable,
 	}
 	opts.HTTP.ListenAddr = []string{testBindAddress}
 	if proxyflags.Opt.AuthProxy == "" {
@@ -128,7 +137,7 @@ func testGET(t *testing.T, useProxy bool) {
 		require.NoError(t, obj.SetModTime(context.Background(), expectedTime))
 	}
 
-	s, testURL := start(ctx, t, f)
+	s, testURL := start(ctx, t, f, false)
 	defer func() {
 		assert.NoError(t, s.server.Shutdown())
 	}()
@@ -267,3 +276,354 @@ func TestGET(t *testing.T) {
 func TestAuthProxy(t *testing.T) {
 	testGET(t, true)
 }
+
+func TestParsePermissions(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    permissions
+		wantErr bool
+	}{
+		{"", permNone, false},
+		{"read", permNone, false},
+		{"upload", permUpload, false},
+		{"upload, MKDIR", permUpload | permMkdir, false},
+		{"delete,rename", permDelete | permRename, false},
+		{"write", permAll, false},
+		{"read,all", permAll, false},
+		{"potato", permNone, true},
+	} {
+		got, err := parsePermissions(test.in)
+		assert.Equal(t, test.wantErr, err != nil, test.in)
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+// do makes an HTTP request returning the status and body
+func do(t *testing.T, method, URL, contentType string, body io.Reader, userName string) (status int, location, respBody string) {
+	req, err := http.NewRequest(method, URL, body)
+	require.NoError(t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.SetBasicAuth(userName, testPass)
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, resp.Header.Get("Location"), string(b)
+}
+
+func TestWritable(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+
+	s, testURL := start(ctx, t, f, true)
+	defer func() {
+		assert.NoError(t, s.server.Shutdown())
+	}()
+	form := "application/x-www-form-urlencoded"
+
+	// PUT a file
+	status, _, _ := do(t, "PUT", testURL+"file.txt", "", strings.NewReader("potato"), testUser)
+	assert.Equal(t, http.StatusCreated, status)
+	status, _, body := do(t, "GET", testURL+"file.txt", "", nil, testUser)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "potato", body)
+
+	// Make a directory
+	status, location, _ := do(t, "POST", testURL, form, strings.NewReader("action=mkdir&name=sub"), testUser)
+	assert.Equal(t, http.StatusSeeOther, status)
+	assert.Equal(t, "./", location)
+	fi, err := os.Stat(filepath.Join(dir, "sub"))
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+	status, _, _ = do(t, "POST", testURL, form, strings.NewReader("action=mkdir&name=.."), testUser)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Upload files with a multipart form
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		part, err := mw.CreateFormFile("file", name)
+		require.NoError(t, err)
+		_, err = part.Write([]byte("contents of " + name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+	status, location, _ = do(t, "POST", testURL+"sub/", mw.FormDataContentType(), &buf, testUser)
+	assert.Equal(t, http.StatusSeeOther, status)
+	assert.Equal(t, "./", location)
+	data, err := os.ReadFile(filepath.Join(dir, "sub", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "contents of b.txt", string(data))
+
+	// Rename a file
+	status, location, _ = do(t, "POST", testURL+"sub/a.txt", form, strings.NewReader("action=rename&name=c.txt"), testUser)
+	assert.Equal(t, http.StatusSeeOther, status)
+	assert.Equal(t, "./", location)
+	_, err = os.Stat(filepath.Join(dir, "sub", "c.txt"))
+	assert.NoError(t, err)
+	status, _, _ = do(t, "POST", testURL+"sub/c.txt", form, strings.NewReader("action=rename&name=../c.txt"), testUser)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Delete files and directories
+	status, _, _ = do(t, "DELETE", testURL+"file.txt", "", nil, testUser)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _, _ = do(t, "DELETE", testURL+"file.txt", "", nil, testUser)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _, _ = do(t, "POST", testURL+"sub/", form, strings.NewReader("action=delete"), testUser)
+	assert.Equal(t, http.StatusConflict, status)
+	for _, name := range []string{"b.txt", "c.txt"} {
+		status, _, _ = do(t, "POST", testURL+"sub/"+name, form, strings.NewReader("action=delete"), testUser)
+		assert.Equal(t, http.StatusSeeOther, status)
+	}
+	status, location, _ = do(t, "POST", testURL+"sub/", form, strings.NewReader("action=delete"), testUser)
+	assert.Equal(t, http.StatusSeeOther, status)
+	assert.Equal(t, "../", location)
+	_, err = os.Stat(filepath.Join(dir, "sub"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Requests from other sites are refused
+	req, err := http.NewRequest("PUT", testURL+"evil.txt", strings.NewReader("evil"))
+	require.NoError(t, err)
+	req.SetBasicAuth(testUser, testPass)
+	req.Header.Set("Origin", "https://evil.example.com")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_, err = os.Stat(filepath.Join(dir, "evil.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// errorReader returns some data then an error
+type errorReader struct {
+	data string
+}
+
+func (r *errorReader) Read(p []byte) (n int, err error) {
+	if r.data == "" {
+		return 0, errors.New("upload interrupted")
+	}
+	n = copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestUpload(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(fileName, []byte("potato"), 0666))
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+	VFS := vfs.New(f, nil)
+	defer VFS.Shutdown()
+
+	// listDir returns the names of the files in dir
+	listDir := func() (names []string) {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	// A failed upload leaves the existing file and no partial file
+	err = upload(VFS, "file.txt", &errorReader{data: "half a"})
+	assert.EqualError(t, err, "upload interrupted")
+	data, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, "potato", string(data))
+	assert.Equal(t, []string{"file.txt"}, listDir())
+
+	// A successful upload replaces the file
+	require.NoError(t, upload(VFS, "file.txt", strings.NewReader("carrot")))
+	data, err = os.ReadFile(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, "carrot", string(data))
+	assert.Equal(t, []string{"file.txt"}, listDir())
+}
+
+func TestWritableProxyPermissions(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// the backend config will be made by the proxy
+	prog, err := filepath.Abs("../servetest/proxy_code.go")
+	require.NoError(t, err)
+	proxyflags.Opt.AuthProxy = "go run " + prog + " " + dir
+	defer func() {
+		proxyflags.Opt.AuthProxy = ""
+	}()
+
+	s, testURL := start(ctx, t, nil, true)
+	defer func() {
+		assert.NoError(t, s.server.Shutdown())
+	}()
+
+	// The test proxy returns _permissions = read for users called
+	// readonly* and nothing for users called noperms* who are
+	// read only too
+	for _, userName := range []string{"readonly", "noperms"} {
+		status, _, _ := do(t, "PUT", testURL+"file.txt", "", strings.NewReader("potato"), userName)
+		assert.Equal(t, http.StatusForbidden, status, userName)
+		_, err = os.Stat(filepath.Join(dir, "file.txt"))
+		assert.True(t, os.IsNotExist(err), userName)
+	}
+
+	// Other users get _permissions = write so may do everything
+	status, _, _ := do(t, "PUT", testURL+"file.txt", "", strings.NewReader("potato"), testUser)
+	assert.Equal(t, http.StatusCreated, status)
+	data, err := os.ReadFile(filepath.Join(dir, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "potato", string(data))
+}
+
+func TestListJSON(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "Holiday.jpg", "sub/b.txt", "sub/holiday2.JPG", "sub/deep/c.txt"} {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(fileName), 0777))
+		require.NoError(t, os.WriteFile(fileName, []byte(name), 0666))
+	}
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+
+	s, testURL := start(ctx, t, f, false)
+	defer func() {
+		assert.NoError(t, s.server.Shutdown())
+	}()
+
+	// list returns the paths in the JSON listing of URL
+	list := func(URL string, accept string) (status int, paths []string) {
+		req, err := http.NewRequest("GET", URL, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth(testUser, testPass)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var items []struct {
+			Path  string
+			Name  string
+			IsDir bool
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&items))
+		paths = []string{}
+		for _, item := range items {
+			assert.Equal(t, path.Base(item.Path), item.Name)
+			if item.IsDir {
+				item.Path += "/"
+			}
+			paths = append(paths, item.Path)
+		}
+		return resp.StatusCode, paths
+	}
+
+	for _, test := range []struct {
+		url    string
+		accept string
+		status int
+		want   []string
+	}{
+		{"", "application/json", http.StatusOK, []string{"sub/", "a.txt", "Holiday.jpg"}},
+		{"?format=json", "", http.StatusOK, []string{"sub/", "a.txt", "Holiday.jpg"}},
+		{"sub/?format=json", "", http.StatusOK, []string{"deep/", "b.txt", "holiday2.JPG"}},
+		{"?format=json&sort=size&order=desc", "", http.StatusOK, []string{"Holiday.jpg", "a.txt", "sub/"}},
+		{"?format=json&offset=1&limit=1", "", http.StatusOK, []string{"a.txt"}},
+		{"?format=json&limit=potato", "", http.StatusBadRequest, nil},
+		{"?format=json&search=holiday", "", http.StatusOK, []string{"Holiday.jpg", "sub/holiday2.JPG"}},
+		{"?format=json&search=*.txt", "", http.StatusOK, []string{"a.txt", "sub/b.txt", "sub/deep/c.txt"}},
+		{"?format=json&search=de", "", http.StatusOK, []string{"sub/deep/"}},
+		{"sub/?format=json&search=*.txt&sort=name&order=desc", "", http.StatusOK, []string{"deep/c.txt", "b.txt"}},
+		{"?format=json&search=*.txt&limit=2", "", http.StatusOK, []string{"a.txt", "sub/b.txt"}},
+		{"?format=json&search=%5B", "", http.StatusBadRequest, nil},
+	} {
+		status, paths := list(testURL+test.url, test.accept)
+		assert.Equal(t, test.status, status, test.url)
+		assert.Equal(t, test.want, paths, test.url)
+	}
+
+	// The HTML listing is used by default
+	status, _, body := do(t, "GET", testURL+"?search=holiday", "", nil, testUser)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `Search results for &#34;holiday&#34; in /`)
+	assert.Contains(t, body, `<a href="sub/holiday2.JPG">sub/holiday2.JPG</a>`)
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "big.txt", "sub/b.txt", "private/c.txt"} {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(fileName), 0777))
+		contents := name
+		if name == "big.txt" {
+			contents = strings.Repeat("x", 1000)
+		}
+		require.NoError(t, os.WriteFile(fileName, []byte(contents), 0666))
+	}
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+	VFS := vfs.New(f, nil)
+	defer VFS.Shutdown()
+	root, err := VFS.Root()
+	require.NoError(t, err)
+
+	// searchPaths returns the paths found searching for pattern
+	searchPaths := func(ctx context.Context, pattern string) (paths []string, truncated bool) {
+		fi, err := searchFilter(pattern)
+		require.NoError(t, err)
+		directory := serve.NewDirectory("", nil)
+		truncated, err = search(ctx, root, fi, directory)
+		require.NoError(t, err)
+		for _, entry := range directory.Entries {
+			paths = append(paths, entry.Leaf)
+		}
+		return paths, truncated
+	}
+
+	paths, truncated := searchPaths(ctx, "*.txt")
+	assert.Equal(t, []string{"a.txt", "big.txt", "private/c.txt", "sub/b.txt"}, paths)
+	assert.False(t, truncated)
+
+	// The search is limited by the filter in the context
+	ctx, fi := filter.AddConfig(ctx)
+	require.NoError(t, fi.AddRule("- private/**"))
+	fi.Opt.MaxSize = 100
+	paths, truncated = searchPaths(ctx, "*.txt")
+	assert.Equal(t, []string{"a.txt", "sub/b.txt"}, paths)
+	assert.False(t, truncated)
+
+	// The search stops after searchMaxResults entries
+	for i := 0; i < searchMaxResults; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", fmt.Sprintf("file%04d.txt", i)), nil, 0666))
+	}
+	subDir, err := VFS.Stat("sub")
+	require.NoError(t, err)
+	subDir.(*vfs.Dir).ForgetAll()
+	paths, truncated = searchPaths(ctx, "*.txt")
+	assert.Equal(t, searchMaxResults, len(paths))
+	assert.True(t, truncated)
+}
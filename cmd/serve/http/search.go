@@ -0,0 +1,133 @@
+// JSON listings and searching
+
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/lib/http/serve"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+)
+
+// listingHelp describes the query parameters for directory listings
+var listingHelp = fmt.Sprintf(strings.ReplaceAll(`
+### Directory listings
+
+Directory listings are HTML unless the request has an |Accept:
+application/json| header or a |?format=json| query parameter. The
+JSON listing is a list of objects with the same fields as
+[rclone lsjson](/commands/rclone_lsjson/) with the |Path| relative to
+the directory listed.
+
+Listings take these query parameters
+
+- |sort=| one of |namedirfirst| (the default), |name|, |size| or |time|
+- |order=| either |asc| (the default) or |desc|
+- |offset=N| to skip the first N entries
+- |limit=N| to return at most N entries
+- |search=PATTERN| to search below the directory
+
+|search| lists the files and directories below the directory whose
+names match PATTERN, which uses the same syntax as the |--include|
+flag, ignoring case. If PATTERN has no wildcards then it matches names
+containing it, so |search=holiday| is the same as |search=*holiday*|.
+Only entries which are also included by the filter flags are found.
+The search reads every directory below the one searched, honouring
+|--max-depth|, so may be slow on large remotes. It stops after finding
+%d entries.
+
+`, "|", "`"), searchMaxResults)
+
+// searchMaxResults is the maximum number of entries a search returns
+const searchMaxResults = 1000
+
+// errSearchFull is returned by the search walk when it has found
+// searchMaxResults entries
+var errSearchFull = errors.New("search found too many entries")
+
+// searchFilter makes a filter which includes the paths matching the
+// search pattern
+func searchFilter(pattern string) (*filter.Filter, error) {
+	if !strings.ContainsAny(pattern, "*?[{") {
+		pattern = "*" + pattern + "*"
+	}
+	opt := filter.DefaultOpt
+	opt.IncludeRule = []string{pattern}
+	opt.IgnoreCase = true
+	return filter.NewFilter(&opt)
+}
+
+// nodeModTime returns the modification time of node for listings
+func nodeModTime(node vfs.Node) time.Time {
+	if vfsflags.Opt.NoModTime {
+		return time.Time{}
+	}
+	return node.ModTime().UTC()
+}
+
+// search walks the directory tree below dir adding the nodes with
+// paths relative to dir included by fi to directory
+//
+// Only nodes which are also included by the filter in ctx are
+// found. It returns truncated set if the search stopped after finding
+// searchMaxResults entries.
+func search(ctx context.Context, dir *vfs.Dir, fi *filter.Filter, directory *serve.Directory) (truncated bool, err error) {
+	prefix := ""
+	if dir.Path() != "" {
+		prefix = dir.Path() + "/"
+	}
+	maxDepth := fs.GetConfig(ctx).MaxDepth
+	ctxFi := filter.GetConfig(ctx)
+	includeDirectory := ctxFi.IncludeDirectory(ctx, dir.Fs())
+	found := 0
+	var walk func(dir *vfs.Dir, depth int) error
+	walk = func(dir *vfs.Dir, depth int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		nodes, err := dir.ReadDirAll()
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			subDir, isDir := node.(*vfs.Dir)
+			if isDir {
+				include, err := includeDirectory(node.Path())
+				if err != nil {
+					return err
+				}
+				if !include {
+					continue
+				}
+			} else if !ctxFi.Include(node.Path(), node.Size(), node.ModTime(), nil) {
+				continue
+			}
+			if fi.IncludeRemote(strings.TrimPrefix(node.Path(), prefix)) {
+				if found >= searchMaxResults {
+					return errSearchFull
+				}
+				directory.AddSearchEntry(node.Path(), node.IsDir(), node.Size(), nodeModTime(node))
+				found++
+			}
+			if isDir && (maxDepth < 0 || depth < maxDepth) {
+				err = walk(subDir, depth+1)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	err = walk(dir, 1)
+	if err == errSearchFull {
+		return true, nil
+	}
+	return false, err
+}
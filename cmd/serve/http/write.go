@@ -0,0 +1,368 @@
+// Uploads, deletes, renames and mkdir for the writable mode
+
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+	libhttp "github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/lib/http/serve"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/rclone/rclone/vfs"
+)
+
+// writableHelp describes the writable mode
+var writableHelp = strings.ReplaceAll(`
+### Writable mode
+
+By default the server is read only. Use |--writable| to allow files
+to be uploaded, deleted and renamed and directories to be made. The
+directory listing then has an upload form and files can be dropped
+onto the page to upload them. The |--read-only| flag still applies
+and the changes are made through the VFS so the |--vfs-cache-mode|
+flags apply too. Uploads are written to a temporary file next to the
+destination which is renamed into place once complete, so an
+interrupted upload doesn't replace an existing file.
+
+Without a browser these operations can be done with
+
+- |PUT /path/to/file| with the contents of the file in the body
+- |DELETE /path/to/file| or |DELETE /path/to/dir/| for empty directories
+- |POST /path/to/dir/| with a |multipart/form-data| body with the files to upload
+- |POST /path/to/dir/| with |action=mkdir| and |name=NAME| form values
+- |POST /path/to/file| with |action=delete|
+- |POST /path/to/file| with |action=rename| and |name=NEWNAME| form values
+
+Requests from pages on other sites are refused, so other sites can't
+use the credentials a browser has saved for this server.
+
+When using |--auth-proxy| the proxy can limit what each user may do
+by returning |_permissions| with a comma separated list of |upload|,
+|mkdir|, |delete| and |rename|, or |write| for all of them or |read|
+for none. If the proxy doesn't return |_permissions| the user is read
+only.
+
+`, "|", "`")
+
+// permissions are the write operations a user may do
+type permissions uint8
+
+// The write operations
+const (
+	permUpload permissions = 1 << iota
+	permMkdir
+	permDelete
+	permRename
+	permNone permissions = 0
+	permAll              = permUpload | permMkdir | permDelete | permRename
+)
+
+// parsePermissions parses a comma separated list of permissions as
+// returned by the auth proxy in _permissions
+func parsePermissions(s string) (perms permissions, err error) {
+	for _, name := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "", "read":
+		case "upload":
+			perms |= permUpload
+		case "mkdir":
+			perms |= permMkdir
+		case "delete":
+			perms |= permDelete
+		case "rename":
+			perms |= permRename
+		case "write", "all":
+			perms |= permAll
+		default:
+			return permNone, fmt.Errorf("unknown permission %q", name)
+		}
+	}
+	return perms, nil
+}
+
+// user is the value stored in the request context by the auth proxy
+type user struct {
+	vfs   *vfs.VFS
+	perms permissions // permissions returned by the proxy
+}
+
+// getPermissions returns the write operations allowed for this request
+//
+// Users from the auth proxy may only do what it allows, other users
+// may do everything if the server is writable.
+func (s *HTTP) getPermissions(ctx context.Context) permissions {
+	if !s.opt.Writable {
+		return permNone
+	}
+	if u, ok := libhttp.CtxGetAuth(ctx).(*user); ok {
+		return u.perms
+	}
+	if s.proxy != nil {
+		return permNone
+	}
+	return permAll
+}
+
+// checkOrigin returns an error if the request was made by a page
+// from another site.
+//
+// Browsers send the credentials for this server with cross site form
+// posts so these must be rejected to stop other sites changing files.
+func checkOrigin(r *http.Request) error {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return errors.New("cross site request")
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host != r.Host {
+		return fmt.Errorf("request from origin %q", origin)
+	}
+	return nil
+}
+
+// writeError writes an HTTP error for err from the VFS
+func writeError(remote string, w http.ResponseWriter, text string, err error) {
+	switch {
+	case errors.Is(err, vfs.ENOENT):
+		http.Error(w, text+": not found", http.StatusNotFound)
+	case errors.Is(err, vfs.EEXIST), errors.Is(err, vfs.ENOTEMPTY):
+		http.Error(w, text+": "+err.Error(), http.StatusConflict)
+	case errors.Is(err, vfs.EROFS), errors.Is(err, vfs.EPERM):
+		http.Error(w, text+": "+err.Error(), http.StatusForbidden)
+	default:
+		serve.Error(remote, w, text, err)
+	}
+}
+
+// checkLeaf returns an error if leaf isn't a valid file name
+func checkLeaf(leaf string) error {
+	if leaf == "" || leaf == "." || leaf == ".." || strings.ContainsAny(leaf, `/\`) {
+		return fmt.Errorf("invalid name %q", leaf)
+	}
+	return nil
+}
+
+// redirect sends the browser back to the directory listing after a
+// form post, which is the parent of the URL posted to if parent is
+// set.
+//
+// The Location is relative so it works behind a --baseurl.
+func redirect(w http.ResponseWriter, r *http.Request, parent bool) {
+	location := "./"
+	if parent && strings.HasSuffix(r.URL.Path, "/") {
+		location = "../"
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+// upload the contents of in to the file at remote
+//
+// The contents are written to a temporary file in the same directory
+// which is renamed to remote once complete, so a failed upload leaves
+// any existing file untouched.
+func upload(VFS *vfs.VFS, remote string, in io.Reader) (err error) {
+	tmpRemote := path.Join(path.Dir(remote), "."+path.Base(remote)+"."+random.String(8)+".partial")
+	out, err := VFS.OpenFile(tmpRemote, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if removeErr := VFS.Remove(tmpRemote); removeErr != nil && !errors.Is(removeErr, vfs.ENOENT) {
+				fs.Errorf(tmpRemote, "Failed to remove partial upload: %v", removeErr)
+			}
+		}
+	}()
+	_, err = io.Copy(out, in)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return VFS.Rename(tmpRemote, remote)
+}
+
+// serveWrite checks the write request is allowed and dispatches it
+func (s *HTTP) serveWrite(w http.ResponseWriter, r *http.Request, remote string) {
+	if err := checkOrigin(r); err != nil {
+		fs.Infof(remote, "%s: Refusing write: %v", r.RemoteAddr, err)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	VFS, err := s.getVFS(r.Context())
+	if err != nil {
+		http.Error(w, "Root directory not found", http.StatusNotFound)
+		fs.Errorf(nil, "Failed to serve write: %v", err)
+		return
+	}
+	perms := s.getPermissions(r.Context())
+	allowed := func(perm permissions) bool {
+		if perms&perm == 0 {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+
+	switch r.Method {
+	case "PUT":
+		if allowed(permUpload) {
+			s.servePut(w, r, VFS, remote)
+		}
+	case "DELETE":
+		if allowed(permDelete) {
+			s.serveDelete(w, r, VFS, remote)
+		}
+	case "POST":
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "multipart/form-data" {
+			if allowed(permUpload) {
+				s.serveUpload(w, r, VFS, remote)
+			}
+			return
+		}
+		switch action := r.PostFormValue("action"); action {
+		case "mkdir":
+			if allowed(permMkdir) {
+				s.serveMkdir(w, r, VFS, remote)
+			}
+		case "delete":
+			if allowed(permDelete) {
+				s.serveDelete(w, r, VFS, remote)
+			}
+		case "rename":
+			if allowed(permRename) {
+				s.serveRename(w, r, VFS, remote)
+			}
+		default:
+			http.Error(w, fmt.Sprintf("Unknown action %q", action), http.StatusBadRequest)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// servePut uploads the body of the request to the file at remote
+func (s *HTTP) servePut(w http.ResponseWriter, r *http.Request, VFS *vfs.VFS, remote string) {
+	if strings.HasSuffix(r.URL.Path, "/") || remote == "" {
+		http.Error(w, "Can't upload to a directory", http.StatusBadRequest)
+		return
+	}
+	err := upload(VFS, remote, r.Body)
+	if err != nil {
+		writeError(remote, w, "Failed to upload file", err)
+		return
+	}
+	fs.Infof(remote, "%s: Uploaded file", r.RemoteAddr)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// serveUpload uploads the files in the multipart form to the
+// directory dirRemote
+func (s *HTTP) serveUpload(w http.ResponseWriter, r *http.Request, VFS *vfs.VFS, dirRemote string) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Bad multipart form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			http.Error(w, "Bad multipart form: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Skip form fields which aren't files
+		if part.FileName() == "" {
+			continue
+		}
+		leaf := path.Base(strings.ReplaceAll(part.FileName(), `\`, "/"))
+		if err := checkLeaf(leaf); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		remote := path.Join(dirRemote, leaf)
+		err = upload(VFS, remote, part)
+		if err != nil {
+			writeError(remote, w, "Failed to upload file", err)
+			return
+		}
+		fs.Infof(remote, "%s: Uploaded file", r.RemoteAddr)
+	}
+	redirect(w, r, false)
+}
+
+// serveMkdir makes the directory in the name form value in dirRemote
+func (s *HTTP) serveMkdir(w http.ResponseWriter, r *http.Request, VFS *vfs.VFS, dirRemote string) {
+	leaf := r.PostFormValue("name")
+	if err := checkLeaf(leaf); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	remote := path.Join(dirRemote, leaf)
+	err := VFS.Mkdir(remote, 0777)
+	if err != nil {
+		writeError(remote, w, "Failed to make directory", err)
+		return
+	}
+	fs.Infof(remote, "%s: Made directory", r.RemoteAddr)
+	redirect(w, r, false)
+}
+
+// serveDelete deletes the file or empty directory at remote
+func (s *HTTP) serveDelete(w http.ResponseWriter, r *http.Request, VFS *vfs.VFS, remote string) {
+	if remote == "" {
+		http.Error(w, "Can't delete the root", http.StatusBadRequest)
+		return
+	}
+	err := VFS.Remove(remote)
+	if err != nil {
+		writeError(remote, w, "Failed to delete", err)
+		return
+	}
+	fs.Infof(remote, "%s: Deleted", r.RemoteAddr)
+	if r.Method == "DELETE" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	redirect(w, r, true)
+}
+
+// serveRename renames remote to the name form value in the same
+// directory
+func (s *HTTP) serveRename(w http.ResponseWriter, r *http.Request, VFS *vfs.VFS, remote string) {
+	if remote == "" {
+		http.Error(w, "Can't rename the root", http.StatusBadRequest)
+		return
+	}
+	leaf := r.PostFormValue("name")
+	if err := checkLeaf(leaf); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	newRemote := path.Join(path.Dir(remote), leaf)
+	err := VFS.Rename(remote, newRemote)
+	if err != nil {
+		writeError(remote, w, "Failed to rename", err)
+		return
+	}
+	fs.Infof(remote, "%s: Renamed to %q", r.RemoteAddr, newRemote)
+	redirect(w, r, true)
+}
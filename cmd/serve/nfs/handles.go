@@ -0,0 +1,360 @@
+package nfs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// handleSize is the size of the file handles we generate. NFSv3
+// allows up to 64 bytes.
+const handleSize = sha256.Size
+
+// renamedSuffix is added to the name of the file persisting which
+// handle a renamed path has
+const renamedSuffix = ".renamed"
+
+// errStale is returned when a file handle can't be resolved
+var errStale = errors.New("stale file handle")
+
+// handleCache converts between NFS file handles and paths in the VFS.
+//
+// Handles are derived from a hash of the remote and the path so the
+// same path always gets the same handle. Clients only send handles
+// back to us so we need to remember which path each handle was made
+// from. If dir is set these are persisted to disk so that handles
+// given out survive a restart of the server.
+//
+// When a file or directory is renamed its handle, and those of
+// anything inside it, are re-keyed to the new path so the handles
+// clients hold stay valid.
+type handleCache struct {
+	mu      sync.Mutex
+	salt    string            // makes handles unique to the remote
+	saltID  string            // identifies the salt in the handles persisted
+	dir     string            // directory to persist handles in, if set
+	limit   int               // max number of handles to keep in memory
+	paths   map[string]string // handle to path
+	renamed map[string]string // path to handle for renamed paths
+}
+
+// newHandleCache makes a new handle cache for the remote described
+// by salt
+func newHandleCache(salt string, dir string, limit int) (*handleCache, error) {
+	if dir != "" {
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make handle cache directory: %w", err)
+		}
+	}
+	saltHash := sha256.Sum256([]byte(salt))
+	return &handleCache{
+		salt:    salt,
+		saltID:  hex.EncodeToString(saltHash[:8]),
+		dir:     dir,
+		limit:   limit,
+		paths:   make(map[string]string),
+		renamed: make(map[string]string),
+	}, nil
+}
+
+// makeHandle returns the handle for path without storing it
+func (c *handleCache) makeHandle(path string) []byte {
+	h := sha256.New()
+	_, _ = h.Write([]byte(c.salt))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(path))
+	return h.Sum(nil)
+}
+
+// diskPath returns the file the handle is persisted in
+func (c *handleCache) diskPath(handle []byte) string {
+	name := hex.EncodeToString(handle)
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+// loadDisk reads the file at diskPath written by writeDisk returning
+// its value, or "" if it couldn't be read or was made by another
+// remote.
+func (c *handleCache) loadDisk(diskPath string) string {
+	data, err := os.ReadFile(diskPath)
+	if err != nil {
+		return ""
+	}
+	saltID, value, ok := strings.Cut(string(data), "\n")
+	if !ok || saltID != c.saltID {
+		return ""
+	}
+	return value
+}
+
+// readDisk does loadDisk and updates the modification time of the
+// file so prune knows it is in use.
+func (c *handleCache) readDisk(diskPath string) string {
+	value := c.loadDisk(diskPath)
+	if value != "" {
+		now := time.Now()
+		_ = os.Chtimes(diskPath, now, now)
+	}
+	return value
+}
+
+// writeDisk persists value in the file at diskPath
+func (c *handleCache) writeDisk(diskPath, value string) {
+	err := os.MkdirAll(filepath.Dir(diskPath), 0700)
+	if err == nil {
+		err = os.WriteFile(diskPath, []byte(c.saltID+"\n"+value), 0600)
+	}
+	if err != nil {
+		fs.Errorf(nil, "NFS failed to persist file handle for %q: %v", value, err)
+	}
+}
+
+// removeDisk removes the persisted file at diskPath
+func (c *handleCache) removeDisk(diskPath string) {
+	err := os.Remove(diskPath)
+	if err != nil && !os.IsNotExist(err) {
+		fs.Errorf(nil, "NFS failed to remove persisted file handle: %v", err)
+	}
+}
+
+// findHandle returns the handle for path if it was renamed there.
+//
+// Call with the lock held.
+func (c *handleCache) findHandle(path string) []byte {
+	if handle, found := c.renamed[path]; found {
+		return []byte(handle)
+	}
+	if c.dir == "" {
+		return nil
+	}
+	handle, err := hex.DecodeString(c.readDisk(c.diskPath(c.makeHandle(path)) + renamedSuffix))
+	if err != nil || len(handle) != handleSize {
+		return nil
+	}
+	c.renamed[path] = string(handle)
+	return handle
+}
+
+// toHandle returns the handle for path, remembering it so it can be
+// converted back with fromHandle.
+func (c *handleCache) toHandle(path string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.toHandleLocked(path)
+}
+
+// toHandleLocked does toHandle with the lock held
+func (c *handleCache) toHandleLocked(path string) []byte {
+	handle := c.findHandle(path)
+	if handle == nil {
+		handle = c.makeHandle(path)
+	}
+	if _, found := c.paths[string(handle)]; found {
+		return handle
+	}
+	c.evict()
+	c.paths[string(handle)] = path
+	if c.dir != "" {
+		diskPath := c.diskPath(handle)
+		if c.readDisk(diskPath) != path {
+			c.writeDisk(diskPath, path)
+		}
+	}
+	return handle
+}
+
+// fromHandle returns the path that handle was made from
+func (c *handleCache) fromHandle(handle []byte) (string, error) {
+	if len(handle) != handleSize {
+		return "", errStale
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if path, found := c.paths[string(handle)]; found {
+		return path, nil
+	}
+	if c.dir == "" {
+		return "", errStale
+	}
+	path := c.readDisk(c.diskPath(handle))
+	if path == "" {
+		return "", errStale
+	}
+	// Check the handle is still the one for the path
+	if string(c.makeHandle(path)) != string(handle) && string(c.findHandle(path)) != string(handle) {
+		return "", errStale
+	}
+	c.evict()
+	c.paths[string(handle)] = path
+	return path, nil
+}
+
+// isBelow returns true if path is dir or is inside it
+func isBelow(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// rename re-keys the handles of from, and of anything inside it, to
+// the path they have been renamed to so the handles stay valid.
+//
+// Handles for to and anything inside it become stale as they have
+// been overwritten.
+func (c *handleCache) rename(from, to string) {
+	if from == to {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Make sure the handle for from is in memory
+	c.toHandleLocked(from)
+	moved := make(map[string]string)
+	for handle, path := range c.paths {
+		if isBelow(path, from) {
+			moved[handle] = path
+		} else if isBelow(path, to) {
+			c.forget(handle, path)
+		}
+	}
+	if toHandle := c.makeHandle(to); c.dir != "" && moved[string(toHandle)] == "" {
+		c.removeDisk(c.diskPath(toHandle))
+	}
+	for handle, path := range moved {
+		newPath := to + path[len(from):]
+		c.forget(handle, path)
+		c.paths[handle] = newPath
+		if string(c.makeHandle(newPath)) == handle {
+			continue
+		}
+		c.renamed[newPath] = handle
+		if c.dir != "" {
+			c.writeDisk(c.diskPath(c.makeHandle(newPath))+renamedSuffix, hex.EncodeToString([]byte(handle)))
+		}
+	}
+	if c.dir != "" {
+		for handle, path := range moved {
+			c.writeDisk(c.diskPath([]byte(handle)), to+path[len(from):])
+		}
+	}
+}
+
+// forget removes the handle for path from memory and disk.
+//
+// Call with the lock held.
+func (c *handleCache) forget(handle, path string) {
+	delete(c.paths, handle)
+	renamedHandle, isRenamed := c.renamed[path]
+	if isRenamed && renamedHandle == handle {
+		delete(c.renamed, path)
+	}
+	if c.dir != "" {
+		c.removeDisk(c.diskPath([]byte(handle)))
+		if isRenamed {
+			c.removeDisk(c.diskPath(c.makeHandle(path)) + renamedSuffix)
+		}
+	}
+}
+
+// evict removes handles from memory if there are too many.
+//
+// Call with the lock held.
+func (c *handleCache) evict() {
+	if c.limit <= 0 || len(c.paths) < c.limit {
+		return
+	}
+	// Remove about 10% of the entries - which ones doesn't matter
+	// much as in memory mode the client will look them up again
+	// and in disk mode they can be read back from disk.
+	toRemove := c.limit/10 + 1
+	for handle, path := range c.paths {
+		delete(c.paths, handle)
+		if c.renamed[path] == handle {
+			delete(c.renamed, path)
+		}
+		toRemove--
+		if toRemove <= 0 {
+			break
+		}
+	}
+}
+
+// renamedInUse returns true if the rename persisted at diskPath
+// points to a handle which is still in memory or on disk.
+//
+// Call with the lock held.
+func (c *handleCache) renamedInUse(diskPath string) bool {
+	handle, err := hex.DecodeString(c.loadDisk(diskPath))
+	if err != nil || len(handle) != handleSize {
+		return false
+	}
+	if _, found := c.paths[string(handle)]; found {
+		return true
+	}
+	_, err = os.Stat(c.diskPath(handle))
+	return err == nil
+}
+
+// prune removes the handles persisted on disk which haven't been
+// used for maxAge.
+//
+// Handles in memory are still in use, but aren't read from disk, so
+// their modification times are updated instead. Renames are kept for
+// as long as the handle they point to is kept.
+func (c *handleCache) prune(maxAge time.Duration) {
+	if c.dir == "" || maxAge <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-maxAge)
+	pruned := 0
+	err := filepath.Walk(c.dir, func(diskPath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !info.ModTime().Before(cutoff) {
+			return err
+		}
+		name := info.Name()
+		handle, err := hex.DecodeString(strings.TrimSuffix(name, renamedSuffix))
+		if err != nil || len(handle) != handleSize {
+			return nil
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		inUse := false
+		if strings.HasSuffix(name, renamedSuffix) {
+			inUse = c.renamedInUse(diskPath)
+		} else {
+			_, inUse = c.paths[string(handle)]
+		}
+		if inUse {
+			now := time.Now()
+			_ = os.Chtimes(diskPath, now, now)
+			return nil
+		}
+		c.removeDisk(diskPath)
+		pruned++
+		return nil
+	})
+	if err != nil {
+		fs.Errorf(nil, "NFS failed to prune file handles: %v", err)
+	}
+	if pruned > 0 {
+		fs.Debugf(nil, "NFS pruned %d file handles unused for %v", pruned, maxAge)
+	}
+}
+
+// fileID returns a stable inode number for the path with the given
+// handle
+func fileID(handle []byte) uint64 {
+	id := binary.BigEndian.Uint64(handle)
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
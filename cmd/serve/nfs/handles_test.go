@@ -0,0 +1,191 @@
+package nfs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCacheMemory(t *testing.T) {
+	c, err := newHandleCache("remote:", "", 0)
+	require.NoError(t, err)
+
+	handle := c.toHandle("dir/file.txt")
+	assert.Len(t, handle, handleSize)
+	assert.Equal(t, handle, c.toHandle("dir/file.txt"))
+	assert.NotEqual(t, handle, c.toHandle("dir"))
+
+	p, err := c.fromHandle(handle)
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file.txt", p)
+
+	// A new cache doesn't know the handle
+	c2, err := newHandleCache("remote:", "", 0)
+	require.NoError(t, err)
+	_, err = c2.fromHandle(handle)
+	assert.Equal(t, errStale, err)
+
+	// Bad handles are stale
+	_, err = c.fromHandle([]byte("short"))
+	assert.Equal(t, errStale, err)
+}
+
+func TestHandleCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newHandleCache("remote:", dir, 0)
+	require.NoError(t, err)
+	handle := c.toHandle("dir/file.txt")
+
+	// A new cache using the same directory can resolve the
+	// handle, as if the server was restarted
+	c2, err := newHandleCache("remote:", dir, 0)
+	require.NoError(t, err)
+	p, err := c2.fromHandle(handle)
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file.txt", p)
+
+	// But not if it is serving a different remote
+	c3, err := newHandleCache("other:", dir, 0)
+	require.NoError(t, err)
+	_, err = c3.fromHandle(handle)
+	assert.Equal(t, errStale, err)
+}
+
+func TestHandleCacheLimit(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newHandleCache("remote:", dir, 10)
+	require.NoError(t, err)
+	var handles [][]byte
+	for _, p := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+		handles = append(handles, c.toHandle(p))
+	}
+	assert.LessOrEqual(t, len(c.paths), 10)
+
+	// Evicted handles are read back from disk
+	p, err := c.fromHandle(handles[0])
+	require.NoError(t, err)
+	assert.Equal(t, "a", p)
+}
+
+func TestHandleCacheRename(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newHandleCache("remote:", dir, 0)
+	require.NoError(t, err)
+	dirHandle := c.toHandle("dir")
+	fileHandle := c.toHandle("dir/file.txt")
+	otherHandle := c.toHandle("other.txt")
+
+	// Renaming a directory moves the handles inside it
+	c.rename("dir", "newdir")
+	p, err := c.fromHandle(dirHandle)
+	require.NoError(t, err)
+	assert.Equal(t, "newdir", p)
+	p, err = c.fromHandle(fileHandle)
+	require.NoError(t, err)
+	assert.Equal(t, "newdir/file.txt", p)
+	assert.Equal(t, fileHandle, c.toHandle("newdir/file.txt"))
+
+	// The handles are still right after a restart
+	c2, err := newHandleCache("remote:", dir, 0)
+	require.NoError(t, err)
+	p, err = c2.fromHandle(fileHandle)
+	require.NoError(t, err)
+	assert.Equal(t, "newdir/file.txt", p)
+	assert.Equal(t, fileHandle, c2.toHandle("newdir/file.txt"))
+
+	// Renaming over a file makes its handle stale
+	c.rename("newdir/file.txt", "other.txt")
+	_, err = c.fromHandle(otherHandle)
+	assert.Equal(t, errStale, err)
+	p, err = c.fromHandle(fileHandle)
+	require.NoError(t, err)
+	assert.Equal(t, "other.txt", p)
+
+	// Renaming back gives the original handle
+	c.rename("other.txt", "dir/file.txt")
+	assert.Equal(t, fileHandle, c.toHandle("dir/file.txt"))
+	assert.NotContains(t, c.renamed, "dir/file.txt")
+}
+
+func TestHandleCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newHandleCache("remote:", dir, 0)
+	require.NoError(t, err)
+	oldHandle := c.toHandle("old.txt")
+	inUseHandle := c.toHandle("inuse.txt")
+	newHandle := c.toHandle("new.txt")
+
+	// Make old.txt and inuse.txt look unused for a day
+	// and forget old.txt from memory
+	old := time.Now().Add(-24 * time.Hour)
+	for _, handle := range [][]byte{oldHandle, inUseHandle} {
+		require.NoError(t, os.Chtimes(c.diskPath(handle), old, old))
+	}
+	delete(c.paths, string(oldHandle))
+
+	c.prune(time.Hour)
+	_, err = os.Stat(c.diskPath(oldHandle))
+	assert.True(t, os.IsNotExist(err))
+	_, err = c.fromHandle(oldHandle)
+	assert.Equal(t, errStale, err)
+
+	// Handles in memory and recently used handles are kept
+	for _, handle := range [][]byte{inUseHandle, newHandle} {
+		_, err = os.Stat(c.diskPath(handle))
+		assert.NoError(t, err)
+	}
+}
+
+func TestHandleCachePruneRenamed(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newHandleCache("remote:", dir, 0)
+	require.NoError(t, err)
+	fileHandle := c.toHandle("file.txt")
+	c.rename("file.txt", "renamed.txt")
+	renamedPath := c.diskPath(c.makeHandle("renamed.txt")) + renamedSuffix
+
+	// Make everything look unused for a day while the handle is
+	// still in memory
+	old := time.Now().Add(-24 * time.Hour)
+	for _, diskPath := range []string{c.diskPath(fileHandle), renamedPath} {
+		require.NoError(t, os.Chtimes(diskPath, old, old))
+	}
+	c.prune(time.Hour)
+
+	// The handle and the rename are kept and marked as used so
+	// they survive a restart
+	for _, diskPath := range []string{c.diskPath(fileHandle), renamedPath} {
+		info, err := os.Stat(diskPath)
+		require.NoError(t, err)
+		assert.True(t, info.ModTime().After(old))
+	}
+	c2, err := newHandleCache("remote:", dir, 0)
+	require.NoError(t, err)
+	assert.Equal(t, fileHandle, c2.toHandle("renamed.txt"))
+
+	// Once the handle has gone the rename is removed too
+	require.NoError(t, os.Remove(c.diskPath(fileHandle)))
+	require.NoError(t, os.Chtimes(renamedPath, old, old))
+	c3, err := newHandleCache("remote:", dir, 0)
+	require.NoError(t, err)
+	c3.prune(time.Hour)
+	_, err = os.Stat(renamedPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHandleCacheEvictKeepsRenames(t *testing.T) {
+	c, err := newHandleCache("remote:", "", 1)
+	require.NoError(t, err)
+	fileHandle := c.toHandle("file.txt")
+	c.rename("file.txt", "renamed.txt")
+
+	// Evicting another handle for the same path doesn't forget
+	// the rename
+	c.paths = map[string]string{"other handle": "renamed.txt"}
+	c.evict()
+	assert.Empty(t, c.paths)
+	assert.Equal(t, fileHandle, c.toHandle("renamed.txt"))
+}
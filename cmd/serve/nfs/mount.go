@@ -0,0 +1,92 @@
+package nfs
+
+import (
+	"path"
+	"strings"
+
+	"github.com/rclone/rclone/vfs"
+)
+
+// MOUNT v3 (RFC 1813 Appendix I) constants
+const (
+	mountProgram = 100005
+	mountVersion = 3
+
+	mnt3OK         = 0
+	mnt3ErrNoEnt   = 2
+	mnt3ErrIO      = 5
+	mnt3ErrNotDir  = 20
+	mnt3ErrNameLen = 63
+)
+
+// mountProgramInfo describes the MOUNT program
+var mountProgramInfo = &program{
+	name: "MOUNT",
+	vers: mountVersion,
+	procs: map[uint32]proc{
+		0: {"NULL", (*server).null},
+		1: {"MNT", (*server).mnt},
+		2: {"DUMP", (*server).dump},
+		3: {"UMNT", (*server).umnt},
+		4: {"UMNTALL", (*server).null},
+		5: {"EXPORT", (*server).export},
+	},
+}
+
+// toVFSPath converts a path given to MNT into a VFS path
+func toVFSPath(dirPath string) string {
+	p := path.Clean("/" + dirPath)
+	return strings.TrimPrefix(p, "/")
+}
+
+// mnt returns the file handle for the directory being mounted
+func (s *server) mnt(args *xdrReader, w *xdrWriter) error {
+	dirPath := args.string(maxPathLen)
+	if args.err != nil {
+		return args.err
+	}
+	if len(dirPath) > 1024 {
+		w.uint32(mnt3ErrNameLen)
+		return nil
+	}
+	p := toVFSPath(dirPath)
+	node, err := s.vfs.Stat(p)
+	switch {
+	case err == vfs.ENOENT:
+		w.uint32(mnt3ErrNoEnt)
+	case err != nil:
+		w.uint32(mnt3ErrIO)
+	case !node.IsDir():
+		w.uint32(mnt3ErrNotDir)
+	default:
+		w.uint32(mnt3OK)
+		w.opaque(s.handles.toHandle(p))
+		// auth flavors
+		w.uint32(2)
+		w.uint32(authSys)
+		w.uint32(authNone)
+	}
+	return nil
+}
+
+// dump returns an empty list of mounts as they aren't tracked
+func (s *server) dump(args *xdrReader, w *xdrWriter) error {
+	w.bool(false)
+	return nil
+}
+
+// umnt does nothing as mounts aren't tracked
+func (s *server) umnt(args *xdrReader, w *xdrWriter) error {
+	_ = args.string(maxPathLen)
+	return args.err
+}
+
+// export returns the list of exported directories which is just the
+// root available to everyone
+func (s *server) export(args *xdrReader, w *xdrWriter) error {
+	w.bool(true)
+	w.string("/")
+	w.bool(false) // no groups
+	w.bool(false)
+	return nil
+}
@@ -0,0 +1,145 @@
+// Package nfs implements an NFSv3 server to serve an rclone VFS
+package nfs
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Options contains options for the NFS Server
+type Options struct {
+	ListenAddr       string        // Port to listen on
+	HandleCacheType  string        // "memory" or "disk"
+	HandleCacheDir   string        // where to store the handle cache if "disk"
+	HandleCacheLimit int           // max number of handles to keep in memory
+	HandleCacheAge   time.Duration // remove handles from the disk cache unused for this long
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	ListenAddr:       "localhost:2049",
+	HandleCacheType:  "disk",
+	HandleCacheLimit: 1000000,
+	HandleCacheAge:   30 * 24 * time.Hour,
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+// AddFlags adds flags for the nfs server
+func AddFlags(flagSet *pflag.FlagSet, Opt *Options) {
+	rc.AddOption("nfs", &Opt)
+	flags.StringVarP(flagSet, &Opt.ListenAddr, "addr", "", Opt.ListenAddr, "IPaddress:Port or :Port to bind server to")
+	flags.StringVarP(flagSet, &Opt.HandleCacheType, "nfs-cache-type", "", Opt.HandleCacheType, "Where to store the file handle cache: memory or disk")
+	flags.StringVarP(flagSet, &Opt.HandleCacheDir, "nfs-cache-dir", "", Opt.HandleCacheDir, "Directory to store the file handle cache in if --nfs-cache-type disk (default is in the rclone cache directory)")
+	flags.IntVarP(flagSet, &Opt.HandleCacheLimit, "nfs-cache-handle-limit", "", Opt.HandleCacheLimit, "Maximum number of file handles to keep in memory")
+	flags.DurationVarP(flagSet, &Opt.HandleCacheAge, "nfs-cache-handle-max-age", "", Opt.HandleCacheAge, "Remove file handles from the disk cache if unused for this long (0 to keep forever)")
+}
+
+func init() {
+	vfsflags.AddFlags(Command.Flags())
+	AddFlags(Command.Flags(), &Opt)
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "nfs remote:path",
+	Short: `Serve the remote as an NFS mount`,
+	Long: strings.ReplaceAll(`Run an NFSv3 server to serve a remote over NFS. This can be mounted
+with the NFS client built in to most operating systems, which is
+useful where FUSE isn't available, or you'd rather not use it.
+
+You can use the [filter](/filtering) flags (e.g. |--include|, |--exclude|)
+to control what is served.
+
+The server will log errors.  Use |-v| to see access logs.
+
+|--bwlimit| will be respected for file transfers.
+
+### Server options
+
+Use |--addr| to specify which IP address and port the server should
+listen on, e.g. |--addr 1.2.3.4:2049| or |--addr :2049| to listen to
+all IPs.  By default it only listens on localhost.
+
+The MOUNT protocol is served on the same port as NFS so there is no
+need for a portmapper. The server doesn't support NFS locking so tell
+the client not to use it. To mount the server on Linux use something
+like this, replacing |$PORT| with the port the server is listening on:
+
+    mount -t nfs -o port=$PORT,mountport=$PORT,tcp,nfsvers=3,nolock localhost:/ /mnt/rclone
+
+and on macOS
+
+    mount -t nfs -o port=$PORT,mountport=$PORT,tcp,vers=3,nolocks localhost:/ /mnt/rclone
+
+Any directory of the remote can be mounted by giving its path
+instead of |/|.
+
+There is no authentication - NFSv3 trusts the client to tell it who
+the user is - so don't expose the server on a public IP address.
+
+### File handles
+
+NFS clients refer to files by opaque file handles which they expect
+to stay valid, even across restarts of the server. rclone makes these
+from a hash of the remote and the path of the file, and remembers
+which path each handle refers to.
+
+With |--nfs-cache-type disk| (the default) the handles are stored in
+the rclone cache directory, or in |--nfs-cache-dir| if set, so
+clients can carry on using the mount after the server restarts. With
+|--nfs-cache-type memory| the handles are only kept in memory so
+clients will see "stale file handle" errors after a restart and need
+to remount.
+
+At most |--nfs-cache-handle-limit| handles are kept in memory. In
+memory mode, clients using a handle which has been discarded will get
+a "stale file handle" error.
+
+Handles in the disk cache which haven't been used for
+|--nfs-cache-handle-max-age| (30 days by default) are removed when the
+server starts and every hour after that. Set it to 0 to keep them
+forever.
+
+When a file or directory is renamed through the server its handle,
+and the handles of anything inside it, move to the new path, so
+clients can carry on using them.
+
+### Writing files
+
+NFS clients write files at arbitrary offsets, so |--vfs-cache-mode|
+must be at least |writes| for the server to be writable. If it is set
+lower than that rclone will use |writes| instead. Files are uploaded
+once the client stops writing to them for a few seconds, or when the
+client asks for them to be committed, subject to |--vfs-write-back|.
+`, "|", "`") + vfs.Help,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.64",
+	},
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, true, command, func() error {
+			s, err := newServer(context.Background(), f, &Opt)
+			if err != nil {
+				return err
+			}
+			err = s.Serve()
+			if err != nil {
+				return err
+			}
+			s.Wait()
+			return nil
+		})
+	},
+}
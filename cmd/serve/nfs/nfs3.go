@@ -0,0 +1,917 @@
+package nfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/vfs"
+)
+
+// NFSv3 (RFC 1813) constants
+const (
+	nfsProgram = 100003
+	nfsVersion = 3
+
+	// maxHandleBytes is the maximum size of a file handle
+	maxHandleBytes = 64
+
+	// maxIOSize is the largest READ or WRITE we support
+	maxIOSize = 1 << 20
+
+	// maxNameLen is the longest file name we support
+	maxNameLen = 255
+
+	// maxPathLen is the longest path we will decode
+	maxPathLen = 4096
+
+	// fattrSize is the size of an encoded fattr3
+	fattrSize = 84
+)
+
+// NFSv3 status codes
+const (
+	nfs3OK             = 0
+	nfs3ErrPerm        = 1
+	nfs3ErrNoEnt       = 2
+	nfs3ErrIO          = 5
+	nfs3ErrExist       = 17
+	nfs3ErrNotDir      = 20
+	nfs3ErrIsDir       = 21
+	nfs3ErrInval       = 22
+	nfs3ErrRoFs        = 30
+	nfs3ErrNameTooLong = 63
+	nfs3ErrNotEmpty    = 66
+	nfs3ErrStale       = 70
+	nfs3ErrNotSync     = 10002
+	nfs3ErrBadCookie   = 10003
+	nfs3ErrNotSupp     = 10004
+	nfs3ErrTooSmall    = 10005
+)
+
+// File types
+const (
+	nf3Reg = 1
+	nf3Dir = 2
+)
+
+// Access bits for ACCESS
+const (
+	access3Modify = 0x0004
+	access3Extend = 0x0008
+	access3Delete = 0x0010
+)
+
+// How to set times in SETATTR
+const (
+	setToServerTime = 1
+	setToClientTime = 2
+)
+
+// CREATE modes
+const (
+	createUnchecked = 0
+	createGuarded   = 1
+	createExclusive = 2
+)
+
+// stable_how for WRITE
+const (
+	fileSync = 2
+)
+
+// FSINFO properties
+const (
+	fsf3Homogeneous = 0x0008
+	fsf3CanSetTime  = 0x0010
+)
+
+// nfsStatus is an error which translates directly into an NFS status
+type nfsStatus uint32
+
+// Error satisfies the error interface
+func (e nfsStatus) Error() string {
+	return fmt.Sprintf("NFS status %d", uint32(e))
+}
+
+// Errors which don't come from the VFS
+var (
+	errNotDir      = nfsStatus(nfs3ErrNotDir)
+	errIsDir       = nfsStatus(nfs3ErrIsDir)
+	errInval       = nfsStatus(nfs3ErrInval)
+	errNameTooLong = nfsStatus(nfs3ErrNameTooLong)
+	errExist       = nfsStatus(nfs3ErrExist)
+	errNotSync     = nfsStatus(nfs3ErrNotSync)
+	errBadCookie   = nfsStatus(nfs3ErrBadCookie)
+	errTooSmall    = nfsStatus(nfs3ErrTooSmall)
+)
+
+// toStatus translates an error into an NFS status
+func toStatus(err error) uint32 {
+	if err == nil {
+		return nfs3OK
+	}
+	var status nfsStatus
+	if errors.As(err, &status) {
+		return uint32(status)
+	}
+	if err == errStale {
+		return nfs3ErrStale
+	}
+	_, uErr := fserrors.Cause(err)
+	switch uErr {
+	case vfs.OK:
+		return nfs3OK
+	case vfs.ENOENT, fs.ErrorDirNotFound, fs.ErrorObjectNotFound:
+		return nfs3ErrNoEnt
+	case vfs.EEXIST, fs.ErrorDirExists:
+		return nfs3ErrExist
+	case vfs.EPERM, fs.ErrorPermissionDenied:
+		return nfs3ErrPerm
+	case vfs.ENOTEMPTY, fs.ErrorDirectoryNotEmpty:
+		return nfs3ErrNotEmpty
+	case vfs.EROFS:
+		return nfs3ErrRoFs
+	case vfs.ENOSYS, fs.ErrorNotImplemented:
+		return nfs3ErrNotSupp
+	case vfs.EINVAL:
+		return nfs3ErrInval
+	case fs.ErrorIsDir:
+		return nfs3ErrIsDir
+	}
+	fs.Errorf(nil, "NFS IO error: %v", err)
+	return nfs3ErrIO
+}
+
+// nfsProgramInfo describes the NFS program
+var nfsProgramInfo = &program{
+	name: "NFS",
+	vers: nfsVersion,
+	procs: map[uint32]proc{
+		0:  {"NULL", (*server).null},
+		1:  {"GETATTR", (*server).getattr},
+		2:  {"SETATTR", (*server).setattr},
+		3:  {"LOOKUP", (*server).lookup},
+		4:  {"ACCESS", (*server).access},
+		5:  {"READLINK", (*server).readlink},
+		6:  {"READ", (*server).read},
+		7:  {"WRITE", (*server).write},
+		8:  {"CREATE", (*server).create},
+		9:  {"MKDIR", (*server).mkdir},
+		10: {"SYMLINK", (*server).notSupportedDirOp},
+		11: {"MKNOD", (*server).notSupportedDirOp},
+		12: {"REMOVE", (*server).remove},
+		13: {"RMDIR", (*server).rmdir},
+		14: {"RENAME", (*server).rename},
+		15: {"LINK", (*server).link},
+		16: {"READDIR", (*server).readdir},
+		17: {"READDIRPLUS", (*server).readdirplus},
+		18: {"FSSTAT", (*server).fsstat},
+		19: {"FSINFO", (*server).fsinfo},
+		20: {"PATHCONF", (*server).pathconf},
+		21: {"COMMIT", (*server).commit},
+	},
+}
+
+// lookupHandle returns the path and node that the file handle refers to
+func (s *server) lookupHandle(fh []byte) (string, vfs.Node, error) {
+	p, err := s.handles.fromHandle(fh)
+	if err != nil {
+		return "", nil, err
+	}
+	node, err := s.vfs.Stat(p)
+	if err == vfs.ENOENT {
+		return "", nil, errStale
+	} else if err != nil {
+		return "", nil, err
+	}
+	return p, node, nil
+}
+
+// lookupDir returns the path and directory that the file handle
+// refers to
+func (s *server) lookupDir(fh []byte) (string, *vfs.Dir, error) {
+	p, node, err := s.lookupHandle(fh)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, ok := node.(*vfs.Dir)
+	if !ok {
+		return "", nil, errNotDir
+	}
+	return p, dir, nil
+}
+
+// checkName checks name is a valid name for a directory entry
+func checkName(name string) error {
+	if len(name) > maxNameLen {
+		return errNameTooLong
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, '/') {
+		return errInval
+	}
+	return nil
+}
+
+// writeTime writes an nfstime3
+func writeTime(w *xdrWriter, t time.Time) {
+	w.uint32(uint32(t.Unix()))
+	w.uint32(uint32(t.Nanosecond()))
+}
+
+// readTime reads an nfstime3
+func readTime(r *xdrReader) time.Time {
+	sec := r.uint32()
+	nsec := r.uint32()
+	return time.Unix(int64(sec), int64(nsec))
+}
+
+// writeAttr writes the fattr3 for node which is at path p
+func (s *server) writeAttr(w *xdrWriter, p string, node vfs.Node) {
+	fileType, nlink := uint32(nf3Reg), uint32(1)
+	if node.IsDir() {
+		fileType, nlink = nf3Dir, 2
+	}
+	size := node.Size()
+	if size < 0 {
+		size = 0
+	}
+	modTime := node.ModTime()
+	w.uint32(fileType)
+	w.uint32(uint32(node.Mode().Perm()))
+	w.uint32(nlink)
+	w.uint32(s.vfs.Opt.UID)
+	w.uint32(s.vfs.Opt.GID)
+	w.uint64(uint64(size))
+	w.uint64(uint64(size)) // used
+	w.uint32(0)            // rdev
+	w.uint32(0)
+	w.uint64(s.fsid)
+	w.uint64(fileID(s.handles.makeHandle(p)))
+	writeTime(w, modTime) // atime
+	writeTime(w, modTime) // mtime
+	writeTime(w, modTime) // ctime
+}
+
+// writePostOpAttr writes the post_op_attr for the path p. If ok is
+// false then no attributes are written.
+func (s *server) writePostOpAttr(w *xdrWriter, p string, ok bool) {
+	if !ok {
+		w.bool(false)
+		return
+	}
+	node, err := s.vfs.Stat(p)
+	if err != nil {
+		w.bool(false)
+		return
+	}
+	w.bool(true)
+	s.writeAttr(w, p, node)
+}
+
+// writeWcc writes the wcc_data for the path p. We don't send the
+// attributes from before the operation as we can't get them
+// atomically.
+func (s *server) writeWcc(w *xdrWriter, p string, ok bool) {
+	w.bool(false)
+	s.writePostOpAttr(w, p, ok)
+}
+
+// sattr is the decoded sattr3 of the attributes we support setting
+type sattr struct {
+	setSize  bool
+	size     uint64
+	setMtime bool
+	mtime    time.Time
+}
+
+// readSetTime reads a set_atime or set_mtime
+func readSetTime(r *xdrReader) (bool, time.Time) {
+	switch r.uint32() {
+	case setToServerTime:
+		return true, time.Now()
+	case setToClientTime:
+		return true, readTime(r)
+	}
+	return false, time.Time{}
+}
+
+// readSattr reads an sattr3. The mode, uid, gid and atime are ignored
+// as the VFS can't store them.
+func readSattr(r *xdrReader) (a sattr) {
+	if r.bool() {
+		_ = r.uint32() // mode
+	}
+	if r.bool() {
+		_ = r.uint32() // uid
+	}
+	if r.bool() {
+		_ = r.uint32() // gid
+	}
+	a.setSize = r.bool()
+	if a.setSize {
+		a.size = r.uint64()
+	}
+	_, _ = readSetTime(r) // atime
+	a.setMtime, a.mtime = readSetTime(r)
+	return a
+}
+
+// setAttr applies the attributes in a to node at path p
+func (s *server) setAttr(p string, node vfs.Node, a sattr) error {
+	if a.setSize {
+		if node.IsDir() {
+			return errIsDir
+		}
+		if a.size > math.MaxInt64 {
+			return errInval
+		}
+		of, err := s.files.get(p, true)
+		if err != nil {
+			return err
+		}
+		err = of.handle.Truncate(int64(a.size))
+		s.files.release(p, of)
+		if err != nil {
+			return err
+		}
+	}
+	if a.setMtime {
+		err := node.SetModTime(a.mtime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// null does nothing
+func (s *server) null(args *xdrReader, w *xdrWriter) error {
+	return nil
+}
+
+// getattr returns the attributes of a file
+func (s *server) getattr(args *xdrReader, w *xdrWriter) error {
+	fh := args.opaque(maxHandleBytes)
+	if args.err != nil {
+		return args.err
+	}
+	p, node, err := s.lookupHandle(fh)
+	w.uint32(toStatus(err))
+	if err == nil {
+		s.writeAttr(w, p, node)
+	}
+	return nil
+}
+
+// setattr sets the attributes of a file
+func (s *server) setattr(args *xdrReader, w *xdrWriter) error {
+	fh := args.opaque(maxHandleBytes)
+	a := readSattr(args)
+	check := args.bool()
+	var guard time.Time
+	if check {
+		guard = readTime(args)
+	}
+	if args.err != nil {
+		return args.err
+	}
+	p, node, err := s.lookupHandle(fh)
+	found := err == nil
+	if err == nil && check && !node.ModTime().Equal(guard) {
+		err = errNotSync
+	}
+	if err == nil {
+		err = s.setAttr(p, node, a)
+	}
+	w.uint32(toStatus(err))
+	s.writeWcc(w, p, found)
+	return nil
+}
+
+// readDirOpArgs reads a diropargs3
+func readDirOpArgs(r *xdrReader) (fh []byte, name string) {
+	fh = r.opaque(maxHandleBytes)
+	name = r.string(maxPathLen)
+	return fh, name
+}
+
+// lookup finds a name in a directory
+func (s *server) lookup(args *xdrReader, w *xdrWriter) error {
+	fh, name := readDirOpArgs(args)
+	if args.err != nil {
+		return args.err
+	}
+	dirPath, _, err := s.lookupDir(fh)
+	if err != nil {
+		w.uint32(toStatus(err))
+		w.bool(false)
+		return nil
+	}
+	var p string
+	switch name {
+	case ".":
+		p = dirPath
+	case "..":
+		p = path.Dir(dirPath)
+		if p == "." {
+			p = ""
+		}
+	default:
+		err = checkName(name)
+		p = path.Join(dirPath, name)
+	}
+	var node vfs.Node
+	if err == nil {
+		node, err = s.vfs.Stat(p)
+	}
+	w.uint32(toStatus(err))
+	if err == nil {
+		w.opaque(s.handles.toHandle(p))
+		w.bool(true)
+		s.writeAttr(w, p, node)
+	}
+	s.writePostOpAttr(w, dirPath, true)
+	return nil
+}
+
+// access checks the permissions of a file
+func (s *server) access(args *xdrReader, w *xdrWriter) error {
+	fh := args.opaque(maxHandleBytes)
+	requested := args.uint32()
+	if args.err != nil {
+		return args.err
+	}
+	p, node, err := s.lookupHandle(fh)
+	w.uint32(toStatus(err))
+	if err != nil {
+		w.bool(false)
+		return nil
+	}
+	w.bool(true)
+	s.writeAttr(w, p, node)
+	granted := requested
+	if s.vfs.Opt.ReadOnly {
+		granted &^= access3Modify | access3Extend | access3Delete
+	}
+	w.uint32(granted)
+	return nil
+}
+
+// readlink fails as there are no symlinks
+func (s *server) readlink(args *xdrReader, w *xdrWriter) error {
+	_ = args.opaque(maxHandleBytes)
+	if args.err != nil {
+		return args.err
+	}
+	w.uint32(nfs3ErrInval)
+	w.bool(false)
+	return nil
+}
+
+// read reads data from a file
+func (s *server) read(args *xdrReader, w *xdrWriter) error {
+	fh := args.opaque(maxHandleBytes)
+	offset := args.uint64()
+	count := args.uint32()
+	if args.err != nil {
+		return args.err
+	}
+	if count > maxIOSize {
+		count = maxIOSize
+	}
+	p, node, err := s.lookupHandle(fh)
+	found := err == nil
+	if err == nil && node.IsDir() {
+		err = errIsDir
+	}
+	if err == nil && offset > math.MaxInt64 {
+		err = errInval
+	}
+	var (
+		buf []byte
+		n   int
+		eof bool
+	)
+	if err == nil {
+		var of *openFile
+		of, err = s.files.get(p, false)
+		if err == nil {
+			buf = make([]byte, count)
+			n, err = of.handle.ReadAt(buf, int64(offset))
+			s.files.release(p, of)
+			if err == io.EOF {
+				err = nil
+				eof = true
+			}
+			if int64(offset)+int64(n) >= node.Size() {
+				eof = true
+			}
+		}
+	}
+	w.uint32(toStatus(err))
+	s.writePostOpAttr(w, p, found)
+	if err == nil {
+		w.uint32(uint32(n))
+		w.bool(eof)
+		w.opaque(buf[:n])
+	}
+	return nil
+}
+
+// write writes data to a file
+//
+// The data is always reported as committed to stable storage as it
+// is in the VFS cache which is persistent.
+func (s *server) write(args *xdrReader, w *xdrWriter) error {
+	fh := args.opaque(maxHandleBytes)
+	offset := args.uint64()
+	_ = args.uint32() // count - the same as len(data)
+	_ = args.uint32() // stable
+	data := args.opaque(maxIOSize)
+	if args.err != nil {
+		return args.err
+	}
+	p, node, err := s.lookupHandle(fh)
+	found := err == nil
+	if err == nil && node.IsDir() {
+		err = errIsDir
+	}
+	if err == nil && offset > math.MaxInt64 {
+		err = errInval
+	}
+	var n int
+	if err == nil {
+		var of *openFile
+		of, err = s.files.get(p, true)
+		if err == nil {
+			n, err = of.handle.WriteAt(data, int64(offset))
+			s.files.release(p, of)
+		}
+	}
+	w.uint32(toStatus(err))
+	s.writeWcc(w, p, found)
+	if err == nil {
+		w.uint32(uint32(n))
+		w.uint32(fileSync)
+		w.fixedOpaque(s.verifier[:])
+	}
+	return nil
+}
+
+// create makes a new file
+func (s *server) create(args *xdrReader, w *xdrWriter) error {
+	fh, name := readDirOpArgs(args)
+	mode := args.uint32()
+	var a sattr
+	switch mode {
+	case createUnchecked, createGuarded:
+		a = readSattr(args)
+	case createExclusive:
+		_ = args.fixedOpaque(8) // verifier
+	default:
+		return errGarbage
+	}
+	if args.err != nil {
+		return args.err
+	}
+	dirPath, _, err := s.lookupDir(fh)
+	found := err == nil
+	if err == nil {
+		err = checkName(name)
+	}
+	p := path.Join(dirPath, name)
+	if err == nil && mode != createUnchecked {
+		if _, statErr := s.vfs.Stat(p); statErr == nil {
+			err = errExist
+		}
+	}
+	if err == nil {
+		var handle vfs.Handle
+		handle, err = s.vfs.OpenFile(p, os.O_RDWR|os.O_CREATE, s.vfs.Opt.FilePerms)
+		if err == nil {
+			// Keep the file open for the writes which will follow
+			s.files.add(p, handle, true)
+			var node vfs.Node
+			node, err = s.vfs.Stat(p)
+			if err == nil {
+				err = s.setAttr(p, node, a)
+			}
+		}
+	}
+	w.uint32(toStatus(err))
+	if err == nil {
+		w.bool(true)
+		w.opaque(s.handles.toHandle(p))
+		s.writePostOpAttr(w, p, true)
+	}
+	s.writeWcc(w, dirPath, found)
+	return nil
+}
+
+// mkdir makes a new directory
+func (s *server) mkdir(args *xdrReader, w *xdrWriter) error {
+	fh, name := readDirOpArgs(args)
+	_ = readSattr(args)
+	if args.err != nil {
+		return args.err
+	}
+	dirPath, _, err := s.lookupDir(fh)
+	found := err == nil
+	if err == nil {
+		err = checkName(name)
+	}
+	p := path.Join(dirPath, name)
+	if err == nil {
+		if _, statErr := s.vfs.Stat(p); statErr == nil {
+			err = errExist
+		}
+	}
+	if err == nil {
+		err = s.vfs.Mkdir(p, s.vfs.Opt.DirPerms)
+	}
+	w.uint32(toStatus(err))
+	if err == nil {
+		w.bool(true)
+		w.opaque(s.handles.toHandle(p))
+		s.writePostOpAttr(w, p, true)
+	}
+	s.writeWcc(w, dirPath, found)
+	return nil
+}
+
+// notSupportedDirOp fails SYMLINK and MKNOD which the VFS can't do
+func (s *server) notSupportedDirOp(args *xdrReader, w *xdrWriter) error {
+	w.uint32(nfs3ErrNotSupp)
+	s.writeWcc(w, "", false)
+	return nil
+}
+
+// link fails as hard links aren't supported
+func (s *server) link(args *xdrReader, w *xdrWriter) error {
+	w.uint32(nfs3ErrNotSupp)
+	s.writePostOpAttr(w, "", false)
+	s.writeWcc(w, "", false)
+	return nil
+}
+
+// remove removes a file
+func (s *server) remove(args *xdrReader, w *xdrWriter) error {
+	return s.removeNode(args, w, false)
+}
+
+// rmdir removes an empty directory
+func (s *server) rmdir(args *xdrReader, w *xdrWriter) error {
+	return s.removeNode(args, w, true)
+}
+
+// removeNode removes a file or an empty directory
+func (s *server) removeNode(args *xdrReader, w *xdrWriter, isDir bool) error {
+	fh, name := readDirOpArgs(args)
+	if args.err != nil {
+		return args.err
+	}
+	dirPath, _, err := s.lookupDir(fh)
+	found := err == nil
+	if err == nil {
+		err = checkName(name)
+	}
+	p := path.Join(dirPath, name)
+	var node vfs.Node
+	if err == nil {
+		node, err = s.vfs.Stat(p)
+	}
+	if err == nil {
+		if node.IsDir() && !isDir {
+			err = errIsDir
+		} else if !node.IsDir() && isDir {
+			err = errNotDir
+		}
+	}
+	if err == nil {
+		s.files.closePath(p)
+		err = s.vfs.Remove(p)
+	}
+	w.uint32(toStatus(err))
+	s.writeWcc(w, dirPath, found)
+	return nil
+}
+
+// rename renames a file or directory
+func (s *server) rename(args *xdrReader, w *xdrWriter) error {
+	fromFh, fromName := readDirOpArgs(args)
+	toFh, toName := readDirOpArgs(args)
+	if args.err != nil {
+		return args.err
+	}
+	fromDirPath, _, err := s.lookupDir(fromFh)
+	fromFound := err == nil
+	toDirPath, _, toErr := s.lookupDir(toFh)
+	toFound := toErr == nil
+	if err == nil {
+		err = toErr
+	}
+	if err == nil {
+		err = checkName(fromName)
+	}
+	if err == nil {
+		err = checkName(toName)
+	}
+	if err == nil {
+		fromPath := path.Join(fromDirPath, fromName)
+		toPath := path.Join(toDirPath, toName)
+		s.files.closePath(fromPath)
+		s.files.closePath(toPath)
+		err = s.vfs.Rename(fromPath, toPath)
+		if err == nil {
+			s.handles.rename(fromPath, toPath)
+		}
+	}
+	w.uint32(toStatus(err))
+	s.writeWcc(w, fromDirPath, fromFound)
+	s.writeWcc(w, toDirPath, toFound)
+	return nil
+}
+
+// readdir lists a directory
+func (s *server) readdir(args *xdrReader, w *xdrWriter) error {
+	return s.readDir(args, w, false)
+}
+
+// readdirplus lists a directory returning handles and attributes
+func (s *server) readdirplus(args *xdrReader, w *xdrWriter) error {
+	return s.readDir(args, w, true)
+}
+
+// readDir implements READDIR and READDIRPLUS.
+//
+// The cookie for each entry is its index in the sorted directory
+// listing plus one.
+func (s *server) readDir(args *xdrReader, w *xdrWriter, plus bool) error {
+	fh := args.opaque(maxHandleBytes)
+	cookie := args.uint64()
+	_ = args.fixedOpaque(8) // cookie verifier
+	count := args.uint32()
+	if plus {
+		// use maxcount rather than dircount as the limit
+		count = args.uint32()
+	}
+	if args.err != nil {
+		return args.err
+	}
+	dirPath, dir, err := s.lookupDir(fh)
+	found := err == nil
+	var nodes vfs.Nodes
+	if err == nil {
+		nodes, err = dir.ReadDirAll()
+	}
+	if err == nil && cookie > uint64(len(nodes)) {
+		err = errBadCookie
+	}
+
+	// Encode as many entries as will fit
+	var (
+		entries xdrWriter
+		size    = 128 // allowance for the fixed parts of the reply
+		eof     = true
+	)
+	if err == nil {
+		for i := int(cookie); i < len(nodes); i++ {
+			node := nodes[i]
+			name := node.Name()
+			p := path.Join(dirPath, name)
+			entrySize := 24 + len(name) + pad(len(name))
+			if plus {
+				entrySize += 12 + fattrSize + handleSize
+			}
+			if size+entrySize > int(count) {
+				eof = false
+				break
+			}
+			size += entrySize
+			handle := s.handles.makeHandle(p)
+			entries.bool(true)
+			entries.uint64(fileID(handle))
+			entries.string(name)
+			entries.uint64(uint64(i + 1))
+			if plus {
+				entries.bool(true)
+				s.writeAttr(&entries, p, node)
+				entries.bool(true)
+				entries.opaque(s.handles.toHandle(p))
+			}
+		}
+		if !eof && entries.Len() == 0 {
+			err = errTooSmall
+		}
+	}
+
+	w.uint32(toStatus(err))
+	s.writePostOpAttr(w, dirPath, found)
+	if err == nil {
+		var verifier [8]byte
+		w.fixedOpaque(verifier[:])
+		_, _ = w.Write(entries.Bytes())
+		w.bool(false)
+		w.bool(eof)
+	}
+	return nil
+}
+
+// fsstat returns the free space
+func (s *server) fsstat(args *xdrReader, w *xdrWriter) error {
+	fh := args.opaque(maxHandleBytes)
+	if args.err != nil {
+		return args.err
+	}
+	p, _, err := s.lookupHandle(fh)
+	w.uint32(toStatus(err))
+	s.writePostOpAttr(w, p, err == nil)
+	if err == nil {
+		total, _, free := s.vfs.Statfs()
+		const files = 1 << 32
+		w.uint64(uint64(total))
+		w.uint64(uint64(free))
+		w.uint64(uint64(free))
+		w.uint64(files)
+		w.uint64(files)
+		w.uint64(files)
+		w.uint32(0) // invarsec
+	}
+	return nil
+}
+
+// fsinfo returns the capabilities of the server
+func (s *server) fsinfo(args *xdrReader, w *xdrWriter) error {
+	fh := args.opaque(maxHandleBytes)
+	if args.err != nil {
+		return args.err
+	}
+	p, _, err := s.lookupHandle(fh)
+	w.uint32(toStatus(err))
+	s.writePostOpAttr(w, p, err == nil)
+	if err == nil {
+		w.uint32(maxIOSize) // rtmax
+		w.uint32(maxIOSize) // rtpref
+		w.uint32(4096)      // rtmult
+		w.uint32(maxIOSize) // wtmax
+		w.uint32(maxIOSize) // wtpref
+		w.uint32(4096)      // wtmult
+		w.uint32(64 * 1024) // dtpref
+		w.uint64(math.MaxInt64)
+		w.uint32(0) // time_delta
+		w.uint32(1)
+		w.uint32(fsf3Homogeneous | fsf3CanSetTime)
+	}
+	return nil
+}
+
+// pathconf returns information about file names
+func (s *server) pathconf(args *xdrReader, w *xdrWriter) error {
+	fh := args.opaque(maxHandleBytes)
+	if args.err != nil {
+		return args.err
+	}
+	p, _, err := s.lookupHandle(fh)
+	w.uint32(toStatus(err))
+	s.writePostOpAttr(w, p, err == nil)
+	if err == nil {
+		w.uint32(1)          // linkmax
+		w.uint32(maxNameLen) // name_max
+		w.bool(true)         // no_trunc
+		w.bool(true)         // chown_restricted
+		w.bool(s.vfs.Opt.CaseInsensitive)
+		w.bool(true) // case_preserving
+	}
+	return nil
+}
+
+// commit flushes a file.
+//
+// This closes the file if it is open, which starts the upload.
+func (s *server) commit(args *xdrReader, w *xdrWriter) error {
+	fh := args.opaque(maxHandleBytes)
+	_ = args.uint64() // offset
+	_ = args.uint32() // count
+	if args.err != nil {
+		return args.err
+	}
+	p, _, err := s.lookupHandle(fh)
+	found := err == nil
+	if err == nil {
+		s.files.closePath(p)
+	}
+	w.uint32(toStatus(err))
+	s.writeWcc(w, p, found)
+	if err == nil {
+		w.fixedOpaque(s.verifier[:])
+	}
+	return nil
+}
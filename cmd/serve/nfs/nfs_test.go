@@ -0,0 +1,396 @@
+package nfs
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient is a minimal NFS client for testing the server
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	xid  uint32
+}
+
+// call makes an RPC call returning the reply after the message type
+func (c *testClient) call(rpcVers, prog, vers, proc uint32, args func(w *xdrWriter)) *xdrReader {
+	c.xid++
+	w := new(xdrWriter)
+	w.uint32(c.xid)
+	w.uint32(msgCall)
+	w.uint32(rpcVers)
+	w.uint32(prog)
+	w.uint32(vers)
+	w.uint32(proc)
+	w.uint32(authNone)
+	w.opaque(nil)
+	w.uint32(authNone)
+	w.opaque(nil)
+	if args != nil {
+		args(w)
+	}
+	require.NoError(c.t, writeRecord(c.conn, w.Bytes()))
+	record, err := readRecord(c.conn)
+	require.NoError(c.t, err)
+	r := newXDRReader(record)
+	require.Equal(c.t, c.xid, r.uint32())
+	require.Equal(c.t, uint32(msgReply), r.uint32())
+	return r
+}
+
+// accepted checks the reply was accepted returning the accept status
+func (c *testClient) accepted(r *xdrReader) uint32 {
+	require.Equal(c.t, uint32(replyAccepted), r.uint32())
+	_ = r.uint32()
+	_ = r.opaque(maxAuthBytes)
+	return r.uint32()
+}
+
+// proc calls an NFS procedure returning the reader positioned at
+// the status of the results
+func (c *testClient) proc(prog, vers, proc uint32, args func(w *xdrWriter)) *xdrReader {
+	r := c.call(rpcVersion, prog, vers, proc, args)
+	require.Equal(c.t, uint32(acceptSuccess), c.accepted(r))
+	return r
+}
+
+// nfs calls an NFS procedure
+func (c *testClient) nfs(proc uint32, args func(w *xdrWriter)) *xdrReader {
+	return c.proc(nfsProgram, nfsVersion, proc, args)
+}
+
+// testAttr is the part of fattr3 the tests look at
+type testAttr struct {
+	fileType uint32
+	size     uint64
+	fileID   uint64
+}
+
+// readAttr reads an fattr3
+func readAttr(r *xdrReader) (a testAttr) {
+	a.fileType = r.uint32()
+	_ = r.fixedOpaque(16) // mode, nlink, uid, gid
+	a.size = r.uint64()
+	_ = r.fixedOpaque(24) // used, rdev, fsid
+	a.fileID = r.uint64()
+	_ = r.fixedOpaque(24) // times
+	return a
+}
+
+// skipPostOpAttr skips a post_op_attr
+func skipPostOpAttr(r *xdrReader) {
+	if r.bool() {
+		_ = readAttr(r)
+	}
+}
+
+// skipWcc skips a wcc_data
+func skipWcc(r *xdrReader) {
+	if r.bool() {
+		_ = r.fixedOpaque(24)
+	}
+	skipPostOpAttr(r)
+}
+
+// dirOp returns the arguments for a diropargs3
+func dirOp(fh []byte, name string) func(w *xdrWriter) {
+	return func(w *xdrWriter) {
+		w.opaque(fh)
+		w.string(name)
+	}
+}
+
+// fhArg returns the arguments for a call taking just a file handle
+func fhArg(fh []byte) func(w *xdrWriter) {
+	return func(w *xdrWriter) {
+		w.opaque(fh)
+	}
+}
+
+// lookup a name in a directory returning the status and handle
+func (c *testClient) lookup(dir []byte, name string) (uint32, []byte) {
+	r := c.nfs(3, dirOp(dir, name))
+	status := r.uint32()
+	if status != nfs3OK {
+		return status, nil
+	}
+	return status, r.opaque(maxHandleBytes)
+}
+
+// readDir lists a directory with READDIRPLUS returning the names
+// and whether the end was reached
+func (c *testClient) readDir(dir []byte, cookie uint64, maxCount uint32) (status uint32, names []string, cookies []uint64, eof bool) {
+	r := c.nfs(17, func(w *xdrWriter) {
+		w.opaque(dir)
+		w.uint64(cookie)
+		w.fixedOpaque(make([]byte, 8))
+		w.uint32(maxCount)
+		w.uint32(maxCount)
+	})
+	status = r.uint32()
+	skipPostOpAttr(r)
+	if status != nfs3OK {
+		return status, nil, nil, false
+	}
+	_ = r.fixedOpaque(8)
+	for r.bool() {
+		_ = r.uint64()
+		names = append(names, r.string(maxNameLen))
+		cookies = append(cookies, r.uint64())
+		skipPostOpAttr(r)
+		if r.bool() {
+			_ = r.opaque(maxHandleBytes)
+		}
+	}
+	eof = r.bool()
+	require.NoError(c.t, r.err)
+	return status, names, cookies, eof
+}
+
+// newTestServer starts a server serving a local directory with
+// hello.txt in it
+func newTestServer(t *testing.T) (s *server, dir string, c *testClient) {
+	ctx := context.Background()
+	oldCacheDir := config.GetCacheDir()
+	require.NoError(t, config.SetCacheDir(t.TempDir()))
+	oldVFSOpt := vfsflags.Opt
+	vfsflags.Opt.WriteBack = 100 * time.Millisecond
+	t.Cleanup(func() {
+		vfsflags.Opt = oldVFSOpt
+		_ = config.SetCacheDir(oldCacheDir)
+	})
+
+	dir = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello world"), 0666))
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+
+	opt := DefaultOpt
+	opt.ListenAddr = "localhost:0"
+	s, err = newServer(ctx, f, &opt)
+	require.NoError(t, err)
+	require.NoError(t, s.Serve())
+	t.Cleanup(func() {
+		s.Close()
+		s.vfs.WaitForWriters(10 * time.Second)
+		s.vfs.Shutdown()
+	})
+
+	conn, err := net.Dial("tcp", s.Addr())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return s, dir, &testClient{t: t, conn: conn}
+}
+
+func TestRPC(t *testing.T) {
+	_, _, c := newTestServer(t)
+
+	// Unknown program
+	r := c.call(rpcVersion, 100000, 2, 0, nil)
+	assert.Equal(t, uint32(acceptProgUnavail), c.accepted(r))
+
+	// Unsupported version of NFS
+	r = c.call(rpcVersion, nfsProgram, 4, 0, nil)
+	assert.Equal(t, uint32(acceptProgMismatch), c.accepted(r))
+	assert.Equal(t, uint32(3), r.uint32())
+	assert.Equal(t, uint32(3), r.uint32())
+
+	// Unknown procedure
+	r = c.call(rpcVersion, nfsProgram, nfsVersion, 99, nil)
+	assert.Equal(t, uint32(acceptProcUnavail), c.accepted(r))
+
+	// Garbage arguments
+	r = c.call(rpcVersion, nfsProgram, nfsVersion, 1, nil)
+	assert.Equal(t, uint32(acceptGarbageArgs), c.accepted(r))
+
+	// Unsupported RPC version
+	r = c.call(3, nfsProgram, nfsVersion, 0, nil)
+	assert.Equal(t, uint32(replyDenied), r.uint32())
+	assert.Equal(t, uint32(rejectRPCMismatch), r.uint32())
+
+	// NULL
+	r = c.call(rpcVersion, nfsProgram, nfsVersion, 0, nil)
+	assert.Equal(t, uint32(acceptSuccess), c.accepted(r))
+}
+
+func TestMount(t *testing.T) {
+	s, _, c := newTestServer(t)
+
+	r := c.proc(mountProgram, mountVersion, 1, func(w *xdrWriter) {
+		w.string("/")
+	})
+	require.Equal(t, uint32(mnt3OK), r.uint32())
+	assert.Equal(t, s.handles.toHandle(""), r.opaque(maxHandleBytes))
+
+	r = c.proc(mountProgram, mountVersion, 1, func(w *xdrWriter) {
+		w.string("/hello.txt")
+	})
+	assert.Equal(t, uint32(mnt3ErrNotDir), r.uint32())
+
+	r = c.proc(mountProgram, mountVersion, 1, func(w *xdrWriter) {
+		w.string("/notfound")
+	})
+	assert.Equal(t, uint32(mnt3ErrNoEnt), r.uint32())
+
+	r = c.proc(mountProgram, mountVersion, 5, nil)
+	require.True(t, r.bool())
+	assert.Equal(t, "/", r.string(maxPathLen))
+}
+
+func TestNFS(t *testing.T) {
+	s, dir, c := newTestServer(t)
+	root := s.handles.toHandle("")
+
+	// GETATTR
+	r := c.nfs(1, fhArg(root))
+	require.Equal(t, uint32(nfs3OK), r.uint32())
+	assert.Equal(t, uint32(nf3Dir), readAttr(r).fileType)
+
+	// LOOKUP
+	status, hello := c.lookup(root, "hello.txt")
+	require.Equal(t, uint32(nfs3OK), status)
+	status, _ = c.lookup(root, "notfound.txt")
+	assert.Equal(t, uint32(nfs3ErrNoEnt), status)
+	status, _ = c.lookup(hello, "file")
+	assert.Equal(t, uint32(nfs3ErrNotDir), status)
+
+	// READ
+	r = c.nfs(6, func(w *xdrWriter) {
+		w.opaque(hello)
+		w.uint64(6)
+		w.uint32(100)
+	})
+	require.Equal(t, uint32(nfs3OK), r.uint32())
+	skipPostOpAttr(r)
+	assert.Equal(t, uint32(5), r.uint32())
+	assert.True(t, r.bool())
+	assert.Equal(t, "world", string(r.opaque(maxIOSize)))
+
+	// CREATE then WRITE then COMMIT
+	create := func(name string, mode uint32) (uint32, []byte) {
+		r := c.nfs(8, func(w *xdrWriter) {
+			dirOp(root, name)(w)
+			w.uint32(mode)
+			w.fixedOpaque(make([]byte, 24)) // empty sattr3
+		})
+		status := r.uint32()
+		if status != nfs3OK {
+			return status, nil
+		}
+		require.True(t, r.bool())
+		return status, r.opaque(maxHandleBytes)
+	}
+	status, newFile := create("new.txt", createUnchecked)
+	require.Equal(t, uint32(nfs3OK), status)
+	status, _ = create("new.txt", createGuarded)
+	assert.Equal(t, uint32(nfs3ErrExist), status)
+
+	r = c.nfs(7, func(w *xdrWriter) {
+		w.opaque(newFile)
+		w.uint64(0)
+		w.uint32(6)
+		w.uint32(fileSync)
+		w.opaque([]byte("potato"))
+	})
+	require.Equal(t, uint32(nfs3OK), r.uint32())
+	skipWcc(r)
+	assert.Equal(t, uint32(6), r.uint32())
+
+	r = c.nfs(21, func(w *xdrWriter) {
+		w.opaque(newFile)
+		w.uint64(0)
+		w.uint32(0)
+	})
+	require.Equal(t, uint32(nfs3OK), r.uint32())
+	assert.Eventually(t, func() bool {
+		data, err := os.ReadFile(filepath.Join(dir, "new.txt"))
+		return err == nil && string(data) == "potato"
+	}, 10*time.Second, 50*time.Millisecond)
+
+	r = c.nfs(1, fhArg(newFile))
+	require.Equal(t, uint32(nfs3OK), r.uint32())
+	assert.Equal(t, uint64(6), readAttr(r).size)
+
+	// MKDIR
+	mkdir := func(name string) (uint32, []byte) {
+		r := c.nfs(9, func(w *xdrWriter) {
+			dirOp(root, name)(w)
+			w.fixedOpaque(make([]byte, 24)) // empty sattr3
+		})
+		status := r.uint32()
+		if status != nfs3OK {
+			return status, nil
+		}
+		require.True(t, r.bool())
+		return status, r.opaque(maxHandleBytes)
+	}
+	status, sub := mkdir("sub")
+	require.Equal(t, uint32(nfs3OK), status)
+	status, _ = mkdir("sub")
+	assert.Equal(t, uint32(nfs3ErrExist), status)
+	status, _ = mkdir("bad/name")
+	assert.Equal(t, uint32(nfs3ErrInval), status)
+
+	// READDIRPLUS
+	status, names, _, eof := c.readDir(root, 0, 64*1024)
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, []string{"hello.txt", "new.txt", "sub"}, names)
+	assert.True(t, eof)
+
+	// READDIRPLUS in pages
+	status, _, _, _ = c.readDir(root, 0, 150)
+	assert.Equal(t, uint32(nfs3ErrTooSmall), status)
+	status, names, cookies, eof := c.readDir(root, 0, 300)
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, []string{"hello.txt"}, names)
+	assert.False(t, eof)
+	status, names, _, eof = c.readDir(root, cookies[0], 64*1024)
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, []string{"new.txt", "sub"}, names)
+	assert.True(t, eof)
+
+	// RENAME keeps the handle of the file
+	status, renamed := c.lookup(root, "new.txt")
+	require.Equal(t, uint32(nfs3OK), status)
+	r = c.nfs(14, func(w *xdrWriter) {
+		dirOp(root, "new.txt")(w)
+		dirOp(sub, "moved.txt")(w)
+	})
+	require.Equal(t, uint32(nfs3OK), r.uint32())
+	status, _ = c.lookup(root, "new.txt")
+	assert.Equal(t, uint32(nfs3ErrNoEnt), status)
+	status, moved := c.lookup(sub, "moved.txt")
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, renamed, moved)
+
+	// RMDIR of a non empty directory
+	r = c.nfs(13, dirOp(root, "sub"))
+	assert.Equal(t, uint32(nfs3ErrNotEmpty), r.uint32())
+
+	// REMOVE then RMDIR
+	r = c.nfs(12, dirOp(sub, "moved.txt"))
+	assert.Equal(t, uint32(nfs3OK), r.uint32())
+	r = c.nfs(13, dirOp(root, "sub"))
+	assert.Equal(t, uint32(nfs3OK), r.uint32())
+
+	// The handle of the removed file is now stale
+	r = c.nfs(1, fhArg(moved))
+	assert.Equal(t, uint32(nfs3ErrStale), r.uint32())
+
+	// SYMLINK isn't supported
+	r = c.nfs(10, nil)
+	assert.Equal(t, uint32(nfs3ErrNotSupp), r.uint32())
+}
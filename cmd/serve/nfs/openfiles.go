@@ -0,0 +1,179 @@
+package nfs
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// NFS is stateless so clients don't open and close files, they just
+// read and write at offsets in them. Opening a VFS handle for every
+// call would be very slow so we keep the handles open until they
+// have been idle for a while, until the client COMMITs the file or
+// until the file is removed or renamed.
+
+// openFile is a VFS handle which is being kept open
+type openFile struct {
+	handle   vfs.Handle
+	write    bool      // set if opened for writing
+	inUse    int       // number of calls using the handle
+	lastUsed time.Time // when the handle was last released
+}
+
+// openFiles is a cache of open VFS handles keyed by path
+type openFiles struct {
+	mu      sync.Mutex
+	VFS     *vfs.VFS
+	timeout time.Duration
+	files   map[string]*openFile
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newOpenFiles makes a new cache of handles, closing them when they
+// have been idle for timeout
+func newOpenFiles(VFS *vfs.VFS, timeout time.Duration) *openFiles {
+	o := &openFiles{
+		VFS:     VFS,
+		timeout: timeout,
+		files:   make(map[string]*openFile),
+		stop:    make(chan struct{}),
+	}
+	o.wg.Add(1)
+	go o.expire()
+	return o
+}
+
+// get returns an open handle for path, opening it if necessary.
+//
+// Call release when finished with the handle.
+func (o *openFiles) get(path string, write bool) (*openFile, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	of := o.files[path]
+	if of != nil && (of.write || !write) {
+		of.inUse++
+		return of, nil
+	}
+	if of != nil && of.inUse > 0 {
+		// Can't upgrade a read handle while it is in use so
+		// open a new handle just for this call
+		return o.open(path, write)
+	}
+	if of != nil {
+		o.close(path, of)
+	}
+	of, err := o.open(path, write)
+	if err != nil {
+		return nil, err
+	}
+	o.files[path] = of
+	return of, nil
+}
+
+// open a new handle on path.
+//
+// Call with the lock held.
+func (o *openFiles) open(path string, write bool) (*openFile, error) {
+	flags := os.O_RDONLY
+	if write {
+		flags = os.O_RDWR
+	}
+	handle, err := o.VFS.OpenFile(path, flags, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &openFile{
+		handle: handle,
+		write:  write,
+		inUse:  1,
+	}, nil
+}
+
+// add a handle which has just been opened to the cache
+func (o *openFiles) add(path string, handle vfs.Handle, write bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if of := o.files[path]; of != nil {
+		o.close(path, of)
+	}
+	o.files[path] = &openFile{
+		handle:   handle,
+		write:    write,
+		lastUsed: time.Now(),
+	}
+}
+
+// release a handle returned by get
+func (o *openFiles) release(path string, of *openFile) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	of.inUse--
+	of.lastUsed = time.Now()
+	if o.files[path] != of && of.inUse == 0 {
+		// Not in the cache, so close it now
+		o.closeHandle(path, of)
+	}
+}
+
+// closeHandle closes the VFS handle, logging any errors
+func (o *openFiles) closeHandle(path string, of *openFile) {
+	err := of.handle.Close()
+	if err != nil {
+		fs.Errorf(path, "NFS failed to close file: %v", err)
+	}
+}
+
+// close removes of from the cache and closes it if it isn't in use.
+//
+// Call with the lock held.
+func (o *openFiles) close(path string, of *openFile) {
+	delete(o.files, path)
+	if of.inUse == 0 {
+		o.closeHandle(path, of)
+	}
+}
+
+// closePath closes the handle on path if there is one
+func (o *openFiles) closePath(path string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if of := o.files[path]; of != nil {
+		o.close(path, of)
+	}
+}
+
+// expire closes idle handles until stopped
+func (o *openFiles) expire() {
+	defer o.wg.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+		}
+		o.mu.Lock()
+		for path, of := range o.files {
+			if of.inUse == 0 && time.Since(of.lastUsed) >= o.timeout {
+				o.close(path, of)
+			}
+		}
+		o.mu.Unlock()
+	}
+}
+
+// closeAll stops the expiry and closes all the handles
+func (o *openFiles) closeAll() {
+	close(o.stop)
+	o.wg.Wait()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for path, of := range o.files {
+		o.close(path, of)
+	}
+}
@@ -0,0 +1,149 @@
+package nfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ONC RPC (RFC 5531) constants
+const (
+	rpcVersion = 2
+
+	msgCall  = 0
+	msgReply = 1
+
+	replyAccepted = 0
+	replyDenied   = 1
+
+	acceptSuccess      = 0
+	acceptProgUnavail  = 1
+	acceptProgMismatch = 2
+	acceptProcUnavail  = 3
+	acceptGarbageArgs  = 4
+
+	rejectRPCMismatch = 0
+
+	authNone = 0
+	authSys  = 1
+
+	// maxAuthBytes is the maximum size of the body of a credential
+	maxAuthBytes = 400
+
+	// maxRecordSize is the largest RPC record we will accept. This
+	// needs to be big enough for a WRITE of maxIOSize bytes.
+	maxRecordSize = maxIOSize + 64*1024
+
+	// lastFragment is set in the record marker of the last fragment
+	lastFragment = 1 << 31
+)
+
+// rpcCall is a decoded RPC call
+type rpcCall struct {
+	xid     uint32
+	prog    uint32
+	vers    uint32
+	proc    uint32
+	args    *xdrReader // the arguments of the call
+	badVers bool       // set if the RPC version wasn't supported
+}
+
+// errRecordTooBig is returned if a client sends an oversized record
+var errRecordTooBig = errors.New("RPC record too big")
+
+// readRecord reads an RPC record from in, assembling it from its
+// fragments.
+func readRecord(in io.Reader) (record []byte, err error) {
+	var header [4]byte
+	for {
+		_, err = io.ReadFull(in, header[:])
+		if err != nil {
+			return nil, err
+		}
+		marker := binary.BigEndian.Uint32(header[:])
+		size := int(marker &^ lastFragment)
+		if len(record)+size > maxRecordSize {
+			return nil, errRecordTooBig
+		}
+		start := len(record)
+		record = append(record, make([]byte, size)...)
+		_, err = io.ReadFull(in, record[start:])
+		if err != nil {
+			return nil, err
+		}
+		if marker&lastFragment != 0 {
+			return record, nil
+		}
+	}
+}
+
+// writeRecord writes record to out as a single fragment
+func writeRecord(out io.Writer, record []byte) error {
+	buf := make([]byte, 4+len(record))
+	binary.BigEndian.PutUint32(buf, uint32(len(record))|lastFragment)
+	copy(buf[4:], record)
+	_, err := out.Write(buf)
+	return err
+}
+
+// parseCall decodes the header of an RPC call.
+func parseCall(record []byte) (*rpcCall, error) {
+	r := newXDRReader(record)
+	call := &rpcCall{
+		xid: r.uint32(),
+	}
+	msgType := r.uint32()
+	if r.err == nil && msgType != msgCall {
+		return nil, fmt.Errorf("expecting RPC call but got message type %d", msgType)
+	}
+	if r.uint32() != rpcVersion {
+		call.badVers = true
+	}
+	call.prog = r.uint32()
+	call.vers = r.uint32()
+	call.proc = r.uint32()
+	// Read the credential and the verifier - AUTH_SYS credentials
+	// aren't checked as they are trivially forged anyway
+	_ = r.uint32()
+	_ = r.opaque(maxAuthBytes)
+	_ = r.uint32()
+	_ = r.opaque(maxAuthBytes)
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to decode RPC call header: %w", r.err)
+	}
+	call.args = r
+	return call, nil
+}
+
+// acceptedReply starts a reply to call with the accept status given
+func acceptedReply(call *rpcCall, status uint32) *xdrWriter {
+	w := new(xdrWriter)
+	w.uint32(call.xid)
+	w.uint32(msgReply)
+	w.uint32(replyAccepted)
+	w.uint32(authNone) // verifier
+	w.opaque(nil)
+	w.uint32(status)
+	return w
+}
+
+// mismatchReply makes a PROG_MISMATCH reply to call
+func mismatchReply(call *rpcCall, low, high uint32) *xdrWriter {
+	w := acceptedReply(call, acceptProgMismatch)
+	w.uint32(low)
+	w.uint32(high)
+	return w
+}
+
+// rpcMismatchReply makes a reply rejecting call because of the RPC version
+func rpcMismatchReply(call *rpcCall) *xdrWriter {
+	w := new(xdrWriter)
+	w.uint32(call.xid)
+	w.uint32(msgReply)
+	w.uint32(replyDenied)
+	w.uint32(rejectRPCMismatch)
+	w.uint32(rpcVersion)
+	w.uint32(rpcVersion)
+	return w
+}
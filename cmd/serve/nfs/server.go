@@ -0,0 +1,274 @@
+package nfs
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/rclone/rclone/vfs/vfsflags"
+)
+
+const (
+	// maxInFlight is the maximum number of calls processed at
+	// once on a single connection
+	maxInFlight = 16
+
+	// fileIdleTimeout is how long files are kept open after the
+	// last read or write
+	fileIdleTimeout = 5 * time.Second
+
+	// handlePruneInterval is how often unused file handles are
+	// removed from the disk cache
+	handlePruneInterval = time.Hour
+)
+
+// server contains everything to run the server
+type server struct {
+	f        fs.Fs
+	opt      Options
+	vfs      *vfs.VFS
+	ctx      context.Context // for global config
+	handles  *handleCache
+	files    *openFiles
+	fsid     uint64  // identifies the filesystem to the client
+	verifier [8]byte // changes each time the server starts
+	listener net.Listener
+	waitChan chan struct{} // for waiting on the listener to close
+	connsMu  sync.Mutex
+	conns    map[net.Conn]struct{}
+}
+
+// newServer makes a new NFS server to serve f
+func newServer(ctx context.Context, f fs.Fs, opt *Options) (*server, error) {
+	s := &server{
+		f:        f,
+		ctx:      ctx,
+		opt:      *opt,
+		waitChan: make(chan struct{}),
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	// NFS writes at random offsets so it needs the VFS cache
+	vfsOpt := vfsflags.Opt
+	if !vfsOpt.ReadOnly && vfsOpt.CacheMode < vfscommon.CacheModeWrites {
+		fs.Logf(f, "NFS server needs --vfs-cache-mode writes or full to write files - using writes")
+		vfsOpt.CacheMode = vfscommon.CacheModeWrites
+	}
+	s.vfs = vfs.New(f, &vfsOpt)
+
+	salt := fs.ConfigString(f)
+	hash := sha256.Sum256([]byte(salt))
+	s.fsid = binary.BigEndian.Uint64(hash[:])
+	binary.BigEndian.PutUint64(s.verifier[:], uint64(time.Now().UnixNano()))
+
+	var handleDir string
+	switch s.opt.HandleCacheType {
+	case "memory":
+	case "disk":
+		handleDir = s.opt.HandleCacheDir
+		if handleDir == "" {
+			handleDir = filepath.Join(config.GetCacheDir(), "serve-nfs", fmt.Sprintf("%x", hash[:8]))
+		}
+	default:
+		return nil, fmt.Errorf("unknown --nfs-cache-type %q: must be memory or disk", s.opt.HandleCacheType)
+	}
+	var err error
+	s.handles, err = newHandleCache(salt, handleDir, s.opt.HandleCacheLimit)
+	if err != nil {
+		return nil, err
+	}
+	s.files = newOpenFiles(s.vfs, fileIdleTimeout)
+	return s, nil
+}
+
+// Serve starts the NFS server in the background.
+//
+// Use s.Close() and s.Wait() to shutdown server
+func (s *server) Serve() (err error) {
+	s.listener, err = net.Listen("tcp", s.opt.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for connection: %w", err)
+	}
+	fs.Logf(nil, "NFS server listening on %v", s.listener.Addr())
+	go s.acceptConnections()
+	go s.pruneHandles()
+	return nil
+}
+
+// pruneHandles removes unused file handles from the disk cache now
+// and every handlePruneInterval until the server is closed
+func (s *server) pruneHandles() {
+	if s.opt.HandleCacheAge <= 0 {
+		return
+	}
+	ticker := time.NewTicker(handlePruneInterval)
+	defer ticker.Stop()
+	for {
+		s.handles.prune(s.opt.HandleCacheAge)
+		select {
+		case <-s.waitChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Addr returns the address the server is listening on
+func (s *server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Wait blocks while the listener is open.
+func (s *server) Wait() {
+	<-s.waitChan
+}
+
+// Close shuts the running server down
+func (s *server) Close() {
+	err := s.listener.Close()
+	if err != nil {
+		fs.Errorf(nil, "Error on closing NFS server: %v", err)
+		return
+	}
+	s.connsMu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.connsMu.Unlock()
+	s.files.closeAll()
+	close(s.waitChan)
+}
+
+// Accept connections and call them in a go routine
+func (s *server) acceptConnections() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			fs.Errorf(nil, "Failed to accept incoming connection: %v", err)
+			continue
+		}
+		go s.handleConnection(conn)
+	}
+}
+
+// handleConnection reads RPC calls from conn and replies to them
+//
+// Calls are processed concurrently as clients send many calls
+// without waiting for the replies.
+func (s *server) handleConnection(conn net.Conn) {
+	what := conn.RemoteAddr().String()
+	fs.Debugf(what, "NFS connection opened")
+	s.connsMu.Lock()
+	s.conns[conn] = struct{}{}
+	s.connsMu.Unlock()
+
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+		tokens  = make(chan struct{}, maxInFlight)
+		in      = bufio.NewReader(conn)
+	)
+	for {
+		record, err := readRecord(in)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				fs.Errorf(what, "NFS failed to read call: %v", err)
+			}
+			break
+		}
+		tokens <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-tokens }()
+			reply := s.handleCall(what, record)
+			if reply == nil {
+				return
+			}
+			writeMu.Lock()
+			err := writeRecord(conn, reply.Bytes())
+			writeMu.Unlock()
+			if err != nil {
+				fs.Debugf(what, "NFS failed to write reply: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.connsMu.Lock()
+	delete(s.conns, conn)
+	s.connsMu.Unlock()
+	_ = conn.Close()
+	fs.Debugf(what, "NFS connection closed")
+}
+
+// procFunc implements a procedure. It should decode the arguments
+// and return errGarbage if they can't be decoded, otherwise write the
+// results to w.
+type procFunc func(s *server, args *xdrReader, w *xdrWriter) error
+
+// proc describes a procedure of an RPC program
+type proc struct {
+	name string
+	fn   procFunc
+}
+
+// program describes an RPC program served by the server
+type program struct {
+	name  string
+	vers  uint32
+	procs map[uint32]proc
+}
+
+// programs are the RPC programs the server serves keyed by number
+var programs = map[uint32]*program{
+	nfsProgram:   nfsProgramInfo,
+	mountProgram: mountProgramInfo,
+}
+
+// handleCall decodes and runs the call in record returning the reply
+// or nil if no reply should be sent
+func (s *server) handleCall(what string, record []byte) *xdrWriter {
+	call, err := parseCall(record)
+	if err != nil {
+		fs.Errorf(what, "NFS dropping call: %v", err)
+		return nil
+	}
+	if call.badVers {
+		return rpcMismatchReply(call)
+	}
+	prog := programs[call.prog]
+	if prog == nil {
+		return acceptedReply(call, acceptProgUnavail)
+	}
+	if call.vers != prog.vers {
+		return mismatchReply(call, prog.vers, prog.vers)
+	}
+	proc, found := prog.procs[call.proc]
+	if !found {
+		return acceptedReply(call, acceptProcUnavail)
+	}
+	fs.Debugf(what, "%s %s", prog.name, proc.name)
+	w := acceptedReply(call, acceptSuccess)
+	err = proc.fn(s, call.args, w)
+	if err != nil {
+		fs.Errorf(what, "%s %s: %v", prog.name, proc.name, err)
+		return acceptedReply(call, acceptGarbageArgs)
+	}
+	return w
+}
@@ -0,0 +1,134 @@
+package nfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// errGarbage is returned when the arguments of a call can't be decoded
+var errGarbage = errors.New("nfs: can't decode arguments")
+
+// xdrReader decodes XDR (RFC 4506) encoded data from a buffer.
+//
+// Errors are sticky - once an error has occurred all further reads
+// return zero values and the error is available in err.
+type xdrReader struct {
+	buf []byte
+	err error
+}
+
+// newXDRReader makes a new xdrReader reading from buf
+func newXDRReader(buf []byte) *xdrReader {
+	return &xdrReader{buf: buf}
+}
+
+// next returns the next n bytes of the buffer
+func (r *xdrReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.err = errGarbage
+		return nil
+	}
+	p := r.buf[:n]
+	r.buf = r.buf[n:]
+	return p
+}
+
+// pad returns the number of padding bytes needed after n bytes
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
+
+// uint32 reads an unsigned int
+func (r *xdrReader) uint32() uint32 {
+	p := r.next(4)
+	if p == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(p)
+}
+
+// uint64 reads an unsigned hyper
+func (r *xdrReader) uint64() uint64 {
+	p := r.next(8)
+	if p == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(p)
+}
+
+// bool reads a bool
+func (r *xdrReader) bool() bool {
+	return r.uint32() != 0
+}
+
+// fixedOpaque reads opaque data of length n
+func (r *xdrReader) fixedOpaque(n int) []byte {
+	p := r.next(n)
+	r.next(pad(n))
+	return p
+}
+
+// opaque reads variable length opaque data which may be at most max
+// bytes long
+func (r *xdrReader) opaque(max int) []byte {
+	n := r.uint32()
+	if r.err == nil && n > uint32(max) {
+		r.err = errGarbage
+	}
+	return r.fixedOpaque(int(n))
+}
+
+// string reads a string which may be at most max bytes long
+func (r *xdrReader) string(max int) string {
+	return string(r.opaque(max))
+}
+
+// xdrWriter encodes XDR data
+type xdrWriter struct {
+	bytes.Buffer
+}
+
+// uint32 writes an unsigned int
+func (w *xdrWriter) uint32(x uint32) {
+	var p [4]byte
+	binary.BigEndian.PutUint32(p[:], x)
+	_, _ = w.Write(p[:])
+}
+
+// uint64 writes an unsigned hyper
+func (w *xdrWriter) uint64(x uint64) {
+	var p [8]byte
+	binary.BigEndian.PutUint64(p[:], x)
+	_, _ = w.Write(p[:])
+}
+
+// bool writes a bool
+func (w *xdrWriter) bool(x bool) {
+	if x {
+		w.uint32(1)
+	} else {
+		w.uint32(0)
+	}
+}
+
+// fixedOpaque writes opaque data whose length is known to the reader
+func (w *xdrWriter) fixedOpaque(p []byte) {
+	_, _ = w.Write(p)
+	var zeros [3]byte
+	_, _ = w.Write(zeros[:pad(len(p))])
+}
+
+// opaque writes variable length opaque data
+func (w *xdrWriter) opaque(p []byte) {
+	w.uint32(uint32(len(p)))
+	w.fixedOpaque(p)
+}
+
+// string writes a string
+func (w *xdrWriter) string(s string) {
+	w.opaque([]byte(s))
+}
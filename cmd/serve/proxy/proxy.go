@@ -49,8 +49,11 @@ config.
 This config generated must have this extra parameter
 - |_root| - root to use for the backend
 
-And it may have this parameter
+And it may have these parameters
 - |_obscure| - comma separated strings for parameters to obscure
+- |_permissions| - comma separated write operations the user may do,
+  e.g. |upload,mkdir| or |read| for none - only used by |serve http|
+  where users are read only if it isn't set
 
 If password authentication was used by the client, input to the proxy
 process (on STDIN) would look similar to this:
@@ -128,6 +131,8 @@ type Proxy struct {
 type cacheEntry struct {
 	vfs    *vfs.VFS          // stored VFS
 	pwHash [sha256.Size]byte // sha256 hash of the password/publicKey
+	secret string            // shared secret returned by the proxy, if any
+	perms  string            // permissions returned by the proxy, if any
 }
 
 // New creates a new proxy with the Options passed in
@@ -210,6 +215,8 @@ func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, er
 	if !ok {
 		return nil, errors.New("proxy: _root not set in result")
 	}
+	secret, _ := config.Get("_secret")
+	perms, _ := config.Get("_permissions")
 
 	// Find the backend
 	fsInfo, err := fs.Find(fsName)
@@ -244,6 +251,8 @@ func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, er
 		entry := cacheEntry{
 			vfs:    vfs.New(f, &vfsflags.Opt),
 			pwHash: sha256.Sum256([]byte(auth)),
+			secret: secret,
+			perms:  perms,
 		}
 		return entry, true, nil
 	})
@@ -256,6 +265,27 @@ func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, er
 // Call runs the auth proxy with the username and password/public key provided
 // returning a *vfs.VFS and the key used in the VFS cache.
 func (p *Proxy) Call(user, auth string, isPublicKey bool) (VFS *vfs.VFS, vfsKey string, err error) {
+	entry, err := p.lookup(user, auth, isPublicKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return entry.vfs, user, nil
+}
+
+// CallPermissions does Call returning a *vfs.VFS and the permissions
+// the proxy returned in the "_permissions" field, or "" if it didn't
+// return any.
+func (p *Proxy) CallPermissions(user, auth string, isPublicKey bool) (VFS *vfs.VFS, perms string, err error) {
+	entry, err := p.lookup(user, auth, isPublicKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return entry.vfs, entry.perms, nil
+}
+
+// lookup finds the cacheEntry for user in the cache, running the auth
+// proxy if it isn't there, and checks auth is correct.
+func (p *Proxy) lookup(user, auth string, isPublicKey bool) (entry cacheEntry, err error) {
 	// Look in the cache first
 	value, ok := p.vfsCache.GetMaybe(user)
 
@@ -263,14 +293,14 @@ func (p *Proxy) Call(user, auth string, isPublicKey bool) (VFS *vfs.VFS, vfsKey
 	if !ok {
 		value, err = p.call(user, auth, isPublicKey)
 		if err != nil {
-			return nil, "", err
+			return entry, err
 		}
 	}
 
 	// check we got what we were expecting
-	entry, ok := value.(cacheEntry)
+	entry, ok = value.(cacheEntry)
 	if !ok {
-		return nil, "", fmt.Errorf("proxy: value is not cache entry: %#v", value)
+		return entry, fmt.Errorf("proxy: value is not cache entry: %#v", value)
 	}
 
 	// Check the password / public key is correct in the cached entry.  This
@@ -281,12 +311,31 @@ func (p *Proxy) Call(user, auth string, isPublicKey bool) (VFS *vfs.VFS, vfsKey
 	authHash := sha256.Sum256([]byte(auth))
 	if subtle.ConstantTimeCompare(authHash[:], entry.pwHash[:]) != 1 {
 		if isPublicKey {
-			return nil, "", errors.New("proxy: incorrect public key")
+			return entry, errors.New("proxy: incorrect public key")
 		}
-		return nil, "", errors.New("proxy: incorrect password")
+		return entry, errors.New("proxy: incorrect password")
 	}
 
-	return entry.vfs, user, nil
+	return entry, nil
+}
+
+// CallSecret runs the auth proxy with the user provided returning a
+// *vfs.VFS and the secret the proxy returned in the "_secret" field.
+//
+// This is for protocols like S3 where the client proves it knows a
+// shared secret by signing the request with it but never sends it.
+// As there is no password to pass the proxy is called with a blank
+// one, so the caller must check the request with the secret returned
+// before trusting it.
+func (p *Proxy) CallSecret(user string) (VFS *vfs.VFS, secret string, err error) {
+	entry, err := p.lookup(user, "", false)
+	if err != nil {
+		return nil, "", err
+	}
+	if entry.secret == "" {
+		return nil, "", errors.New("proxy: _secret not set in result")
+	}
+	return entry.vfs, entry.secret, nil
 }
 
 // Get VFS from the cache using key - returns nil if not found
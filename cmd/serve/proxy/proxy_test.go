@@ -125,6 +125,16 @@ func TestRun(t *testing.T) {
 			assert.Nil(t, p.Get("unknown"))
 		})
 
+		// The proxy didn't return any permissions
+		t.Run("CallPermissions", func(t *testing.T) {
+			permsVFS, perms, err := p.CallPermissions(testUser, testPass, false)
+			require.NoError(t, err)
+			assert.Equal(t, vfs, permsVFS)
+			assert.Equal(t, "", perms)
+			_, _, err = p.CallPermissions(testUser, "wrong", false)
+			assert.Error(t, err)
+		})
+
 		// now try again from the cache
 		vfs, vfsKey, err = p.Call(testUser, testPass, false)
 		require.NoError(t, err)
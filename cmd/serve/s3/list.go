@@ -0,0 +1,264 @@
+package s3
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rclone/rclone/vfs"
+)
+
+// maxKeys is the maximum number of keys returned in one listing
+const maxKeys = 1000
+
+// listEntry is an object or a common prefix found when listing
+type listEntry struct {
+	key  string
+	node vfs.Node // nil for common prefixes
+}
+
+// listParams are the parameters of a ListObjects or ListObjectsV2 call
+type listParams struct {
+	prefix    string
+	delimiter string
+	marker    string // list keys after this
+	maxKeys   int
+}
+
+// splitPrefix splits prefix into the directory part and the leaf part
+func splitPrefix(prefix string) (dir, leaf string) {
+	i := strings.LastIndexByte(prefix, '/')
+	if i < 0 {
+		return "", prefix
+	}
+	return prefix[:i], prefix[i+1:]
+}
+
+// joinKey joins a directory key and a leaf
+func joinKey(dir, leaf string) string {
+	if dir == "" {
+		return leaf
+	}
+	return dir + "/" + leaf
+}
+
+// statDir finds the directory at key dir in the bucket, returning nil
+// if it isn't found.
+func statDir(VFS *vfs.VFS, bucket, dir string) (*vfs.Dir, error) {
+	node, err := VFS.Stat(path.Join(bucket, dir))
+	if err == vfs.ENOENT {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	d, ok := node.(*vfs.Dir)
+	if !ok {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// listDelimited lists the entries using "/" as a delimiter.
+//
+// This only needs to read one directory.
+func listDelimited(VFS *vfs.VFS, bucket, prefix string) (entries []listEntry, err error) {
+	dirKey, leafPrefix := splitPrefix(prefix)
+	dir, err := statDir(VFS, bucket, dirKey)
+	if dir == nil || err != nil {
+		return nil, err
+	}
+	nodes, err := dir.ReadDirAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		if !strings.HasPrefix(node.Name(), leafPrefix) || (!node.IsDir() && isTempName(node.Name())) {
+			continue
+		}
+		key := joinKey(dirKey, node.Name())
+		if node.IsDir() {
+			entries = append(entries, listEntry{key: key + "/"})
+		} else {
+			entries = append(entries, listEntry{key: key, node: node})
+		}
+	}
+	return entries, nil
+}
+
+// listRecursive lists all the objects with prefix then groups them
+// by delimiter if set.
+func listRecursive(VFS *vfs.VFS, bucket, prefix, delimiter string) (entries []listEntry, err error) {
+	dirKey, _ := splitPrefix(prefix)
+	dir, err := statDir(VFS, bucket, dirKey)
+	if dir == nil || err != nil {
+		return nil, err
+	}
+	var walk func(dir *vfs.Dir, dirKey string) error
+	walk = func(dir *vfs.Dir, dirKey string) error {
+		nodes, err := dir.ReadDirAll()
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			key := joinKey(dirKey, node.Name())
+			if node.IsDir() {
+				// Only descend into directories which can contain the prefix
+				if strings.HasPrefix(key+"/", prefix) || strings.HasPrefix(prefix, key+"/") {
+					err = walk(node.(*vfs.Dir), key)
+					if err != nil {
+						return err
+					}
+				}
+			} else if strings.HasPrefix(key, prefix) && !isTempName(node.Name()) {
+				entries = append(entries, listEntry{key: key, node: node})
+			}
+		}
+		return nil
+	}
+	err = walk(dir, dirKey)
+	if err != nil || delimiter == "" {
+		return entries, err
+	}
+
+	// Group the keys into common prefixes
+	grouped := entries[:0]
+	seen := map[string]struct{}{}
+	for _, entry := range entries {
+		i := strings.Index(entry.key[len(prefix):], delimiter)
+		if i < 0 {
+			grouped = append(grouped, entry)
+			continue
+		}
+		commonPrefix := entry.key[:len(prefix)+i+len(delimiter)]
+		if _, found := seen[commonPrefix]; !found {
+			seen[commonPrefix] = struct{}{}
+			grouped = append(grouped, listEntry{key: commonPrefix})
+		}
+	}
+	return grouped, nil
+}
+
+// list returns a page of the listing described by params and whether
+// it was truncated.
+func list(VFS *vfs.VFS, bucket string, params listParams) (entries []listEntry, truncated bool, err error) {
+	if params.delimiter == "/" {
+		entries, err = listDelimited(VFS, bucket, params.prefix)
+	} else {
+		entries, err = listRecursive(VFS, bucket, params.prefix, params.delimiter)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	if params.marker != "" {
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].key > params.marker })
+		entries = entries[i:]
+	}
+	if len(entries) > params.maxKeys {
+		entries = entries[:params.maxKeys]
+		truncated = true
+	}
+	return entries, truncated, nil
+}
+
+// urlEncodeKey encodes key if encoding-type=url was requested
+func urlEncodeKey(key string, encode bool) string {
+	if !encode {
+		return key
+	}
+	return uriEncode(key, false)
+}
+
+// listObjects serves ListObjects and ListObjectsV2
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request, bucket string) error {
+	VFS := getVFS(r.Context())
+	_, err := checkBucket(VFS, bucket)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	v2 := query.Get("list-type") == "2"
+	encode := query.Get("encoding-type") == "url"
+	params := listParams{
+		prefix:    query.Get("prefix"),
+		delimiter: query.Get("delimiter"),
+	}
+	params.maxKeys, err = parseMax(r, "max-keys", maxKeys)
+	if err != nil {
+		return err
+	}
+	result := listBucketResult{
+		Xmlns:     s3Namespace,
+		Name:      bucket,
+		Prefix:    urlEncodeKey(params.prefix, encode),
+		Delimiter: urlEncodeKey(params.delimiter, encode),
+		MaxKeys:   params.maxKeys,
+	}
+	if encode {
+		result.EncodingType = "url"
+	}
+	if v2 {
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = urlEncodeKey(query.Get("start-after"), encode)
+		params.marker = query.Get("start-after")
+		if result.ContinuationToken != "" {
+			marker, err := base64.RawURLEncoding.DecodeString(result.ContinuationToken)
+			if err != nil {
+				return errInvalidArgument.withMessage("The continuation token provided is incorrect")
+			}
+			params.marker = string(marker)
+		}
+	} else {
+		params.marker = query.Get("marker")
+		marker := urlEncodeKey(params.marker, encode)
+		result.Marker = &marker
+	}
+
+	entries, truncated, err := list(VFS, bucket, params)
+	if err != nil {
+		return err
+	}
+	fetchOwner := !v2 || query.Get("fetch-owner") == "true"
+	result.IsTruncated = truncated
+	result.Contents = make([]objectInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.node == nil {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: urlEncodeKey(entry.key, encode)})
+			continue
+		}
+		result.Contents = append(result.Contents, s.listObjectInfo(r.Context(), entry, encode, fetchOwner))
+	}
+	if truncated {
+		lastKey := entries[len(entries)-1].key
+		if v2 {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(lastKey))
+		} else {
+			result.NextMarker = urlEncodeKey(lastKey, encode)
+		}
+	}
+	if v2 {
+		keyCount := len(entries)
+		result.KeyCount = &keyCount
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
+
+// listObjectInfo describes the object in entry for a listing
+func (s *Server) listObjectInfo(ctx context.Context, entry listEntry, encode, fetchOwner bool) objectInfo {
+	info := objectInfo{
+		Key:          urlEncodeKey(entry.key, encode),
+		LastModified: formatTime(entry.node.ModTime()),
+		ETag:         s.etag(ctx, entry.node),
+		Size:         entry.node.Size(),
+		StorageClass: "STANDARD",
+	}
+	if fetchOwner {
+		owner := defaultOwner
+		info.Owner = &owner
+	}
+	return info
+}
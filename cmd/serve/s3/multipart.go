@@ -0,0 +1,517 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/atexit"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/rclone/rclone/vfs"
+)
+
+// Limits on multipart uploads
+const (
+	maxPartNumber = 10000
+	maxParts      = 1000
+	maxUploads    = 1000
+)
+
+// part is an uploaded part of a multipart upload
+type part struct {
+	number  int
+	md5     []byte
+	size    int64
+	modTime time.Time
+}
+
+// etag returns the ETag for the part
+func (p *part) etag() string {
+	return `"` + hex.EncodeToString(p.md5) + `"`
+}
+
+// upload is a multipart upload in progress
+type upload struct {
+	mu        sync.Mutex
+	id        string
+	bucket    string
+	key       string
+	vfs       *vfs.VFS
+	initiated time.Time
+	modTime   time.Time
+	dir       string // local directory the parts are stored in
+	parts     map[int]*part
+}
+
+// partPath returns the local path of part number n
+func (u *upload) partPath(n int) string {
+	return filepath.Join(u.dir, fmt.Sprintf("%05d", n))
+}
+
+// uploads stores the multipart uploads in progress
+//
+// The parts are stored in a local temporary directory until the
+// upload is completed or aborted.
+type uploads struct {
+	mu      sync.Mutex
+	dir     string
+	uploads map[string]*upload
+}
+
+// newUploads makes a new store for multipart uploads
+func newUploads() (*uploads, error) {
+	dir, err := os.MkdirTemp("", "rclone-serve-s3-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to make directory for multipart uploads: %w", err)
+	}
+	us := &uploads{
+		dir:     dir,
+		uploads: make(map[string]*upload),
+	}
+	atexit.Register(func() {
+		_ = us.cleanup()
+	})
+	return us, nil
+}
+
+// cleanup removes all the uploads in progress
+func (us *uploads) cleanup() error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.uploads = make(map[string]*upload)
+	return os.RemoveAll(us.dir)
+}
+
+// create makes a new upload
+func (us *uploads) create(VFS *vfs.VFS, bucket, key string, modTime time.Time) (*upload, error) {
+	id := random.String(32)
+	u := &upload{
+		id:        id,
+		bucket:    bucket,
+		key:       key,
+		vfs:       VFS,
+		initiated: time.Now(),
+		modTime:   modTime,
+		dir:       filepath.Join(us.dir, id),
+		parts:     make(map[int]*part),
+	}
+	err := os.MkdirAll(u.dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	us.mu.Lock()
+	us.uploads[id] = u
+	us.mu.Unlock()
+	return u, nil
+}
+
+// get finds the upload with id for bucket and key
+//
+// The VFS must match so users can't see each other's uploads.
+func (us *uploads) get(VFS *vfs.VFS, id, bucket, key string) (*upload, error) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	u, ok := us.uploads[id]
+	if !ok || u.vfs != VFS || u.bucket != bucket || u.key != key {
+		return nil, errNoSuchUpload
+	}
+	return u, nil
+}
+
+// remove the upload and its parts
+func (us *uploads) remove(u *upload) {
+	us.mu.Lock()
+	delete(us.uploads, u.id)
+	us.mu.Unlock()
+	err := os.RemoveAll(u.dir)
+	if err != nil {
+		fs.Errorf(nil, "s3: failed to remove parts of upload %q: %v", u.id, err)
+	}
+}
+
+// list returns the uploads in progress in bucket sorted by key and id
+func (us *uploads) list(VFS *vfs.VFS, bucket string) (list []*upload) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	for _, u := range us.uploads {
+		if u.vfs == VFS && u.bucket == bucket {
+			list = append(list, u)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].key != list[j].key {
+			return list[i].key < list[j].key
+		}
+		return list[i].id < list[j].id
+	})
+	return list
+}
+
+// createMultipartUpload starts a multipart upload
+func (s *Server) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	VFS := getVFS(r.Context())
+	_, err := checkBucket(VFS, bucket)
+	if err != nil {
+		return err
+	}
+	_, err = keyToPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	u, err := s.uploads.create(VFS, bucket, key, readModTime(r.Header))
+	if err != nil {
+		return err
+	}
+	writeXML(w, http.StatusOK, initiateMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: u.id,
+	})
+	return nil
+}
+
+// parsePartNumber reads the partNumber query parameter
+func parsePartNumber(r *http.Request) (int, error) {
+	n, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || n < 1 || n > maxPartNumber {
+		return 0, errInvalidArgument.withMessage("Part number must be an integer between 1 and 10000, inclusive")
+	}
+	return n, nil
+}
+
+// parseCopySourceRange parses the X-Amz-Copy-Source-Range header
+// returning offset and length for a source of size bytes.
+func parseCopySourceRange(value string, size int64) (offset, length int64, err error) {
+	if value == "" {
+		return 0, size, nil
+	}
+	start, end, found := strings.Cut(strings.TrimPrefix(value, "bytes="), "-")
+	if !found || !strings.HasPrefix(value, "bytes=") {
+		return 0, 0, errInvalidArgument.withMessage("Bad X-Amz-Copy-Source-Range")
+	}
+	offset, err = strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0, 0, errInvalidArgument.withMessage("Bad X-Amz-Copy-Source-Range")
+	}
+	last, err := strconv.ParseInt(end, 10, 64)
+	if err != nil || last < offset || last >= size {
+		return 0, 0, errInvalidRange
+	}
+	return offset, last - offset + 1, nil
+}
+
+// partSource returns the data for an UploadPart or UploadPartCopy
+// request and its size.
+func (s *Server) partSource(r *http.Request) (in io.ReadCloser, size int64, err error) {
+	copySource := r.Header.Get("X-Amz-Copy-Source")
+	if copySource == "" {
+		if r.ContentLength < 0 {
+			return nil, 0, errMissingContentLength
+		}
+		return io.NopCloser(r.Body), r.ContentLength, nil
+	}
+	srcBucket, srcKey, err := parseCopySource(copySource)
+	if err != nil {
+		return nil, 0, err
+	}
+	srcNode, err := statObject(getVFS(r.Context()), srcBucket, srcKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	if srcNode.IsDir() {
+		return nil, 0, errNoSuchKey
+	}
+	err = s.checkCopyConditions(r.Context(), r.Header, srcNode)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset, size, err := parseCopySourceRange(r.Header.Get("X-Amz-Copy-Source-Range"), srcNode.Size())
+	if err != nil {
+		return nil, 0, err
+	}
+	handle, err := srcNode.Open(os.O_RDONLY)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, err = handle.Seek(offset, io.SeekStart)
+	if err != nil {
+		_ = handle.Close()
+		return nil, 0, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(handle, size), handle}, size, nil
+}
+
+// uploadPart stores a part of a multipart upload
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, bucket, key string) (err error) {
+	u, err := s.uploads.get(getVFS(r.Context()), r.URL.Query().Get("uploadId"), bucket, key)
+	if err != nil {
+		return err
+	}
+	n, err := parsePartNumber(r)
+	if err != nil {
+		return err
+	}
+	contentMD5, err := readContentMD5(r.Header)
+	if err != nil {
+		return err
+	}
+	in, size, err := s.partSource(r)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(in, &err)
+
+	// Write the part to a temporary file and rename it into place
+	// so a concurrent upload of the same part can't corrupt it.
+	out, err := os.CreateTemp(u.dir, "part-")
+	if err != nil {
+		return err
+	}
+	hashIn := newMD5Reader(in)
+	written, err := io.Copy(out, hashIn)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && written != size {
+		err = errIncompleteBody
+	}
+	sum := hashIn.Sum()
+	if err == nil && contentMD5 != nil && !equalBytes(sum, contentMD5) {
+		err = errBadDigest
+	}
+	if err == nil {
+		u.mu.Lock()
+		err = os.Rename(out.Name(), u.partPath(n))
+		if err == nil {
+			u.parts[n] = &part{number: n, md5: sum, size: written, modTime: time.Now()}
+		}
+		u.mu.Unlock()
+	}
+	if err != nil {
+		_ = os.Remove(out.Name())
+		return err
+	}
+
+	p := &part{md5: sum}
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeXML(w, http.StatusOK, copyPartResult{
+			Xmlns:        s3Namespace,
+			LastModified: formatTime(time.Now()),
+			ETag:         p.etag(),
+		})
+		return nil
+	}
+	w.Header().Set("ETag", p.etag())
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// completeMultipartUpload joins the parts together into an object
+func (s *Server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	VFS := getVFS(r.Context())
+	u, err := s.uploads.get(VFS, r.URL.Query().Get("uploadId"), bucket, key)
+	if err != nil {
+		return err
+	}
+	var req completeMultipartUploadRequest
+	err = xml.NewDecoder(io.LimitReader(r.Body, 4*1024*1024)).Decode(&req)
+	if err != nil || len(req.Parts) == 0 {
+		return errMalformedXML
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	// Check the parts requested and open them
+	var (
+		readers []io.Reader
+		size    int64
+		md5s    = md5.New()
+	)
+	defer func() {
+		for _, in := range readers {
+			_ = in.(io.Closer).Close()
+		}
+	}()
+	for i, requested := range req.Parts {
+		if i > 0 && requested.PartNumber <= req.Parts[i-1].PartNumber {
+			return errInvalidPartOrder
+		}
+		p, ok := u.parts[requested.PartNumber]
+		if !ok || strings.Trim(requested.ETag, `"`) != hex.EncodeToString(p.md5) {
+			return errInvalidPart
+		}
+		in, err := os.Open(u.partPath(p.number))
+		if err != nil {
+			return err
+		}
+		readers = append(readers, in)
+		size += p.size
+		_, _ = md5s.Write(p.md5)
+	}
+
+	p, err := keyToPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	_, err = checkBucket(VFS, bucket)
+	if err != nil {
+		return err
+	}
+	_, err = writeFile(VFS, p, io.MultiReader(readers...), size, u.modTime, nil)
+	if err != nil {
+		return err
+	}
+	s.uploads.remove(u)
+
+	etag := fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(md5s.Sum(nil)), len(req.Parts))
+	writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: "/" + bucket + "/" + key,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     etag,
+	})
+	return nil
+}
+
+// abortMultipartUpload removes an upload and its parts
+func (s *Server) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	u, err := s.uploads.get(getVFS(r.Context()), r.URL.Query().Get("uploadId"), bucket, key)
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	s.uploads.remove(u)
+	u.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// parseMax reads a maximum count from the query parameter name
+func parseMax(r *http.Request, name string, limit int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return limit, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errInvalidArgument.withMessage(fmt.Sprintf("Bad %s", name))
+	}
+	if n > limit {
+		n = limit
+	}
+	return n, nil
+}
+
+// listParts lists the parts uploaded so far
+func (s *Server) listParts(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	u, err := s.uploads.get(getVFS(r.Context()), r.URL.Query().Get("uploadId"), bucket, key)
+	if err != nil {
+		return err
+	}
+	max, err := parseMax(r, "max-parts", maxParts)
+	if err != nil {
+		return err
+	}
+	marker, _ := strconv.Atoi(r.URL.Query().Get("part-number-marker"))
+
+	u.mu.Lock()
+	var numbers []int
+	for n := range u.parts {
+		if n > marker {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	result := listPartsResult{
+		Xmlns:            s3Namespace,
+		Bucket:           bucket,
+		Key:              key,
+		UploadID:         u.id,
+		PartNumberMarker: marker,
+		MaxParts:         max,
+	}
+	for _, n := range numbers {
+		if len(result.Parts) >= max {
+			result.IsTruncated = true
+			break
+		}
+		p := u.parts[n]
+		result.Parts = append(result.Parts, partInfo{
+			PartNumber:   n,
+			LastModified: formatTime(p.modTime),
+			ETag:         p.etag(),
+			Size:         p.size,
+		})
+		result.NextPartNumberMarker = n
+	}
+	u.mu.Unlock()
+
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
+
+// listMultipartUploads lists the uploads in progress in a bucket
+func (s *Server) listMultipartUploads(w http.ResponseWriter, r *http.Request, bucket string) error {
+	VFS := getVFS(r.Context())
+	_, err := checkBucket(VFS, bucket)
+	if err != nil {
+		return err
+	}
+	max, err := parseMax(r, "max-uploads", maxUploads)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	keyMarker := query.Get("key-marker")
+	uploadIDMarker := query.Get("upload-id-marker")
+	result := listMultipartUploadsResult{
+		Xmlns:          s3Namespace,
+		Bucket:         bucket,
+		KeyMarker:      keyMarker,
+		UploadIDMarker: uploadIDMarker,
+		Prefix:         prefix,
+		MaxUploads:     max,
+	}
+	for _, u := range s.uploads.list(VFS, bucket) {
+		if !strings.HasPrefix(u.key, prefix) || u.key < keyMarker {
+			continue
+		}
+		// Uploads for keyMarker are only listed if they are after
+		// uploadIDMarker
+		if keyMarker != "" && u.key == keyMarker && (uploadIDMarker == "" || u.id <= uploadIDMarker) {
+			continue
+		}
+		if len(result.Uploads) >= max {
+			result.IsTruncated = true
+			break
+		}
+		result.Uploads = append(result.Uploads, uploadInfo{
+			Key:       u.key,
+			UploadID:  u.id,
+			Initiated: formatTime(u.initiated),
+		})
+		result.NextKeyMarker = u.key
+		result.NextUploadIDMarker = u.id
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
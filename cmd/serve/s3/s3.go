@@ -0,0 +1,173 @@
+// Package s3 implements an S3 server for rclone
+package s3
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/proxy"
+	"github.com/rclone/rclone/cmd/serve/proxy/proxyflags"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/hash"
+	libhttp "github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	"github.com/spf13/cobra"
+)
+
+// Options contains options for the s3 server
+type Options struct {
+	HTTP           libhttp.Config
+	AuthKeys       []string
+	HashName       string
+	HashType       hash.Type
+	ForcePathStyle bool
+	NoCleanup      bool
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	HTTP:           libhttp.DefaultCfg(),
+	HashName:       "MD5",
+	HashType:       hash.MD5,
+	ForcePathStyle: true,
+	NoCleanup:      false,
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+// flagPrefix is the prefix used to uniquely identify command line flags.
+// It is intentionally empty for this package.
+const flagPrefix = ""
+
+func init() {
+	flagSet := Command.Flags()
+	libhttp.AddHTTPFlagsPrefix(flagSet, flagPrefix, &Opt.HTTP)
+	vfsflags.AddFlags(flagSet)
+	proxyflags.AddFlags(flagSet)
+	flags.StringArrayVarP(flagSet, &Opt.AuthKeys, "auth-key", "", Opt.AuthKeys, "Set key pair for v4 authorization: access_key_id,secret_access_key")
+	flags.StringVarP(flagSet, &Opt.HashName, "etag-hash", "", Opt.HashName, "Which hash to use for the ETag, or auto or blank for off")
+	flags.BoolVarP(flagSet, &Opt.ForcePathStyle, "force-path-style", "", Opt.ForcePathStyle, "If true use path style access if false use virtual hosted style")
+	flags.BoolVarP(flagSet, &Opt.NoCleanup, "no-cleanup", "", Opt.NoCleanup, "Not to cleanup empty folder after object is deleted")
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "s3 remote:path",
+	Short: `Serve remote:path over s3.`,
+	Long: strings.ReplaceAll(`Run a basic S3 server to serve a remote over the S3 protocol.
+This can be used by any S3 compatible client, including rclone's own
+s3 backend, to read and write the remote.
+
+Each top level directory of the remote is presented as an S3 bucket
+and the files and directories below that are presented as objects
+whose keys are their paths relative to the bucket. Creating a bucket
+makes a top level directory and removing an empty bucket removes it.
+
+### Authentication
+
+Use |--auth-key access_key_id,secret_access_key| to set the
+credentials clients must use. Requests are checked with AWS Signature
+Version 4, either in the |Authorization| header or as a presigned URL.
+|--auth-key| may be repeated to allow several key pairs. If no keys are
+set then no authentication is done.
+
+If |--auth-proxy| is set then the proxy is called with the access key
+id of the request as |user| and a blank |pass|. As S3 clients never
+send their secret, the proxy must return the secret access key for
+that user in the |_secret| field of its output so the request
+signature can be checked. The request is only allowed if it was
+signed with that secret.
+
+Signatures must include the |host| header and the date in their
+credential scope must match the date the request was signed.
+
+### Supported operations
+
+- ListBuckets, CreateBucket, HeadBucket, DeleteBucket
+- ListObjects and ListObjectsV2 with prefix, delimiter and paging
+- GetObject (including |Range| requests), HeadObject, PutObject,
+  CopyObject, DeleteObject and DeleteObjects
+- CreateMultipartUpload, UploadPart, CompleteMultipartUpload,
+  AbortMultipartUpload, ListParts and ListMultipartUploads
+
+Modification times are read and set using the |X-Amz-Meta-Mtime|
+header in the same way as rclone's s3 backend. Other metadata is not
+stored.
+
+Parts of multipart uploads are stored in a temporary directory on the
+local disk until the upload is completed or aborted.
+
+Objects are uploaded to a temporary file ending |.rclone-s3-partial|
+in the same directory which is renamed into place once the upload is
+complete and its |Content-MD5| and |X-Amz-Content-Sha256| (if set)
+have been checked. A failed upload leaves any existing object alone.
+
+### Object keys
+
+S3 keys which can't be represented as paths on the remote, for
+example those with empty path segments such as |a//b|, are rejected.
+Empty directories are removed after the last object in them is deleted
+unless |--no-cleanup| is set.
+
+### ETags
+
+Use |--etag-hash| to choose the hash used for the ETag of objects.
+The default is MD5 which is what S3 clients expect. If the remote
+doesn't support the hash then it will be calculated when needed by
+reading the object. Use |auto| to pick the first hash the remote
+supports, or blank to compute ETags from the modification time and
+size only.
+
+`, "|", "`") + libhttp.Help(flagPrefix) + vfs.Help + proxy.Help,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.64",
+	},
+	RunE: func(command *cobra.Command, args []string) error {
+		var f fs.Fs
+		if proxyflags.Opt.AuthProxy == "" {
+			cmd.CheckArgs(1, 1, command, args)
+			f = cmd.NewFsSrc(args)
+		} else {
+			cmd.CheckArgs(0, 0, command, args)
+		}
+		err := setHashType(f, &Opt)
+		if err != nil {
+			return err
+		}
+		cmd.Run(false, false, command, func() error {
+			s, err := newServer(context.Background(), f, &Opt)
+			if err != nil {
+				return err
+			}
+			s.Serve()
+			s.Wait()
+			return nil
+		})
+		return nil
+	},
+}
+
+// setHashType sets opt.HashType from opt.HashName
+func setHashType(f fs.Fs, opt *Options) error {
+	opt.HashType = hash.None
+	switch opt.HashName {
+	case "":
+	case "auto":
+		if f != nil {
+			opt.HashType = f.Hashes().GetOne()
+		}
+	default:
+		err := opt.HashType.Set(opt.HashName)
+		if err != nil {
+			return err
+		}
+	}
+	if opt.HashType != hash.None {
+		fs.Debugf(f, "Using hash %v for ETag", opt.HashType)
+	}
+	return nil
+}
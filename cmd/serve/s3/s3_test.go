@@ -0,0 +1,325 @@
+// Serve s3 tests set up a server and run the integration tests
+// for the s3 remote against it.
+//
+// We skip tests on platforms with troublesome character mappings
+
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/cmd/serve/servetest"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBindAddress = "localhost:0"
+	testProxyUser   = "user"
+)
+
+// TestS3 runs the s3 server then runs the unit tests for the
+// s3 remote against it.
+func TestS3(t *testing.T) {
+	// Configure and start the server
+	start := func(f fs.Fs) (configmap.Simple, func()) {
+		opt := DefaultOpt
+		opt.HTTP.ListenAddr = []string{testBindAddress}
+		opt.HashType = hash.MD5
+		accessKey, secretKey := testAccessKey, testSecretKey
+		if f == nil {
+			// The proxy returns the secret for the user
+			accessKey, secretKey = testProxyUser, testProxyUser+"-secret"
+		} else {
+			opt.AuthKeys = []string{accessKey + "," + secretKey}
+		}
+
+		// Start the server
+		s, err := newServer(context.Background(), f, &opt)
+		require.NoError(t, err)
+		s.Serve()
+
+		// Config for the backend we'll use to connect to the server
+		config := configmap.Simple{
+			"type":              "s3",
+			"provider":          "Other",
+			"endpoint":          s.Server.URLs()[0],
+			"access_key_id":     accessKey,
+			"secret_access_key": secretKey,
+			"list_version":      "2",
+		}
+
+		return config, func() {
+			assert.NoError(t, s.Shutdown())
+			s.Wait()
+		}
+	}
+
+	servetest.Run(t, "s3", start)
+}
+
+// testServer starts a server without authentication serving a
+// temporary directory returning the server and its URL
+func testServer(t *testing.T) (*Server, string) {
+	f, err := fs.NewFs(context.Background(), t.TempDir())
+	require.NoError(t, err)
+	opt := DefaultOpt
+	opt.HTTP.ListenAddr = []string{testBindAddress}
+	opt.HashType = hash.MD5
+	s, err := newServer(context.Background(), f, &opt)
+	require.NoError(t, err)
+	s.Serve()
+	t.Cleanup(func() {
+		assert.NoError(t, s.Shutdown())
+		s.Wait()
+	})
+	return s, strings.TrimSuffix(s.Server.URLs()[0], "/")
+}
+
+// do makes an http request returning the response and the body
+func do(t *testing.T, method, url string, body string, headers ...string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	out, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(out)
+}
+
+func TestObjects(t *testing.T) {
+	_, url := testServer(t)
+
+	resp, _ := do(t, "PUT", url+"/bucket", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, body := do(t, "PUT", url+"/bucket", "")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Contains(t, body, "BucketAlreadyOwnedByYou")
+
+	resp, _ = do(t, "PUT", url+"/bucket/dir/file.txt", "0123456789", "X-Amz-Meta-Mtime", "1000000000.5")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"781e5e245d69b566979b86e28d23f2c7"`, resp.Header.Get("ETag"))
+
+	resp, body = do(t, "GET", url+"/bucket/dir/file.txt", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0123456789", body)
+	assert.Equal(t, "1000000000.5", resp.Header.Get("X-Amz-Meta-Mtime"))
+	assert.Equal(t, `"781e5e245d69b566979b86e28d23f2c7"`, resp.Header.Get("ETag"))
+
+	resp, body = do(t, "GET", url+"/bucket/dir/file.txt", "", "Range", "bytes=2-4")
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "234", body)
+
+	resp, _ = do(t, "HEAD", url+"/bucket/dir/file.txt", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(10), resp.ContentLength)
+
+	resp, _ = do(t, "PUT", url+"/bucket/copy.txt", "", "X-Amz-Copy-Source", "/bucket/dir/file.txt")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, body = do(t, "GET", url+"/bucket/copy.txt", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0123456789", body)
+
+	resp, body = do(t, "PUT", url+"/bucket/bad.txt", "hello", "Content-Md5", "1B2M2Y8AsgTpgAmY7PhCfg==")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "BadDigest")
+	resp, _ = do(t, "HEAD", url+"/bucket/bad.txt", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// A failed overwrite must leave the existing object alone
+	resp, body = do(t, "PUT", url+"/bucket/copy.txt", "hello", "Content-Md5", "1B2M2Y8AsgTpgAmY7PhCfg==")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "BadDigest")
+	resp, body = do(t, "GET", url+"/bucket/copy.txt", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0123456789", body)
+
+	// The temporary upload files shouldn't be left behind or listed
+	resp, body = do(t, "GET", url+"/bucket?list-type=2", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotContains(t, body, tempSuffix)
+
+	resp, body = do(t, "DELETE", url+"/bucket", "")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Contains(t, body, "BucketNotEmpty")
+
+	for _, key := range []string{"dir/file.txt", "copy.txt", "missing.txt"} {
+		resp, _ = do(t, "DELETE", url+"/bucket/"+key, "")
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+	resp, body = do(t, "GET", url+"/bucket/dir/file.txt", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, body, "NoSuchKey")
+
+	// The empty dir should have been removed so the bucket can be deleted
+	resp, _ = do(t, "DELETE", url+"/bucket", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, body = do(t, "GET", url+"/bucket/file.txt", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, body, "NoSuchBucket")
+}
+
+func TestListObjects(t *testing.T) {
+	_, url := testServer(t)
+
+	do(t, "PUT", url+"/bucket", "")
+	for _, key := range []string{"a.txt", "b/1.txt", "b/2.txt", "b/c/3.txt", "d.txt"} {
+		resp, _ := do(t, "PUT", url+"/bucket/"+key, key)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	list := func(query string) (keys []string, prefixes []string, result listBucketResult) {
+		resp, body := do(t, "GET", url+"/bucket?"+query, "")
+		require.Equal(t, http.StatusOK, resp.StatusCode, body)
+		require.NoError(t, xml.Unmarshal([]byte(body), &result))
+		for _, content := range result.Contents {
+			keys = append(keys, content.Key)
+		}
+		for _, prefix := range result.CommonPrefixes {
+			prefixes = append(prefixes, prefix.Prefix)
+		}
+		return keys, prefixes, result
+	}
+
+	keys, prefixes, _ := list("list-type=2")
+	assert.Equal(t, []string{"a.txt", "b/1.txt", "b/2.txt", "b/c/3.txt", "d.txt"}, keys)
+	assert.Nil(t, prefixes)
+
+	keys, prefixes, _ = list("list-type=2&delimiter=/")
+	assert.Equal(t, []string{"a.txt", "d.txt"}, keys)
+	assert.Equal(t, []string{"b/"}, prefixes)
+
+	keys, prefixes, _ = list("list-type=2&delimiter=/&prefix=b/")
+	assert.Equal(t, []string{"b/1.txt", "b/2.txt"}, keys)
+	assert.Equal(t, []string{"b/c/"}, prefixes)
+
+	keys, prefixes, _ = list("list-type=2&prefix=b/c")
+	assert.Equal(t, []string{"b/c/3.txt"}, keys)
+	assert.Nil(t, prefixes)
+
+	keys, prefixes, _ = list("delimiter=.&prefix=b/")
+	assert.Nil(t, keys)
+	assert.Equal(t, []string{"b/1.", "b/2.", "b/c/3."}, prefixes)
+
+	// Page through the results
+	var all []string
+	token := ""
+	for i := 0; ; i++ {
+		require.Less(t, i, 10)
+		keys, _, result := list(fmt.Sprintf("list-type=2&max-keys=2&continuation-token=%s", token))
+		all = append(all, keys...)
+		if !result.IsTruncated {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	assert.Equal(t, []string{"a.txt", "b/1.txt", "b/2.txt", "b/c/3.txt", "d.txt"}, all)
+
+	keys, _, result := list("max-keys=2&marker=b/1.txt")
+	assert.Equal(t, []string{"b/2.txt", "b/c/3.txt"}, keys)
+	assert.True(t, result.IsTruncated)
+	assert.Equal(t, "b/c/3.txt", result.NextMarker)
+}
+
+func TestMultipart(t *testing.T) {
+	_, url := testServer(t)
+
+	do(t, "PUT", url+"/bucket", "")
+	resp, body := do(t, "POST", url+"/bucket/dir/multi.txt?uploads", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var initiate initiateMultipartUploadResult
+	require.NoError(t, xml.Unmarshal([]byte(body), &initiate))
+	uploadURL := url + "/bucket/dir/multi.txt?uploadId=" + initiate.UploadID
+
+	var complete completeMultipartUploadRequest
+	for i, data := range []string{"hello ", "multipart ", "world"} {
+		partNumber := i + 1
+		resp, _ := do(t, "PUT", fmt.Sprintf("%s&partNumber=%d", uploadURL, partNumber), data)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+	}
+
+	resp, body = do(t, "GET", uploadURL, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var parts listPartsResult
+	require.NoError(t, xml.Unmarshal([]byte(body), &parts))
+	assert.Len(t, parts.Parts, 3)
+
+	// Parts out of order should be rejected
+	badComplete := completeMultipartUploadRequest{Parts: []completedPart{complete.Parts[1], complete.Parts[0]}}
+	completeBody, err := xml.Marshal(badComplete)
+	require.NoError(t, err)
+	resp, body = do(t, "POST", uploadURL, string(completeBody))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "InvalidPartOrder")
+
+	completeBody, err = xml.Marshal(complete)
+	require.NoError(t, err)
+	resp, body = do(t, "POST", uploadURL, string(completeBody))
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Contains(t, body, "-3")
+
+	resp, body = do(t, "GET", url+"/bucket/dir/multi.txt", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello multipart world", body)
+
+	// The upload should be gone now
+	resp, body = do(t, "GET", uploadURL, "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, body, "NoSuchUpload")
+
+	// Check abort
+	resp, body = do(t, "POST", url+"/bucket/aborted.txt?uploads", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, xml.Unmarshal([]byte(body), &initiate))
+	resp, _ = do(t, "DELETE", url+"/bucket/aborted.txt?uploadId="+initiate.UploadID, "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(t, "HEAD", url+"/bucket/aborted.txt", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAuth(t *testing.T) {
+	f, err := fs.NewFs(context.Background(), t.TempDir())
+	require.NoError(t, err)
+	opt := DefaultOpt
+	opt.HTTP.ListenAddr = []string{testBindAddress}
+	opt.AuthKeys = []string{testAccessKey + "," + testSecretKey}
+	s, err := newServer(context.Background(), f, &opt)
+	require.NoError(t, err)
+	s.Serve()
+	defer func() {
+		assert.NoError(t, s.Shutdown())
+		s.Wait()
+	}()
+	url := strings.TrimSuffix(s.Server.URLs()[0], "/")
+
+	resp, body := do(t, "GET", url+"/", "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, body, "AccessDenied")
+
+	resp, body = do(t, "GET", url+"/", "", "Authorization", "AWS4-HMAC-SHA256 Credential=unknown/20130524/us-east-1/s3/aws4_request,SignedHeaders=host,Signature=abc", "X-Amz-Date", "20130524T000000Z", "X-Amz-Content-Sha256", emptySHA256)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, body, "InvalidAccessKeyId")
+
+	_, err = newServer(context.Background(), f, &Options{AuthKeys: []string{"nocomma"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,894 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ncw/swift/v2"
+	"github.com/rclone/rclone/cmd/serve/proxy"
+	"github.com/rclone/rclone/cmd/serve/proxy/proxyflags"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	rclonehash "github.com/rclone/rclone/fs/hash"
+	libhttp "github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+)
+
+// metaMtime is the header used to read and write modification times.
+// This is compatible with rclone's s3 backend.
+const metaMtime = "X-Amz-Meta-Mtime"
+
+// maxKeyLength is the maximum length of an object key in bytes
+const maxKeyLength = 1024
+
+// emptyMD5 is the MD5 of no data
+const emptyMD5 = "d41d8cd98f00b204e9800998ecf8427e"
+
+// Server is an S3 server backed by an rclone VFS
+type Server struct {
+	*libhttp.Server
+	f       fs.Fs
+	_vfs    *vfs.VFS // don't use directly, use getVFS
+	opt     Options
+	proxy   *proxy.Proxy
+	keys    map[string]string // access key id => secret access key
+	uploads *uploads
+	baseURL string
+	ctx     context.Context // for global config
+	nowFn   func() time.Time
+}
+
+// ctxKey is the type of context keys used in this package
+type ctxKey int
+
+const ctxKeyVFS ctxKey = iota
+
+// newServer makes a new S3 server to serve the remote
+func newServer(ctx context.Context, f fs.Fs, opt *Options) (s *Server, err error) {
+	s = &Server{
+		f:     f,
+		ctx:   ctx,
+		opt:   *opt,
+		keys:  make(map[string]string, len(opt.AuthKeys)),
+		nowFn: time.Now,
+	}
+	for _, authKey := range opt.AuthKeys {
+		accessKey, secretKey, found := strings.Cut(authKey, ",")
+		if !found || accessKey == "" || secretKey == "" {
+			return nil, fmt.Errorf("bad --auth-key %q: must be access_key_id,secret_access_key", authKey)
+		}
+		s.keys[accessKey] = secretKey
+	}
+	if proxyflags.Opt.AuthProxy != "" {
+		s.proxy = proxy.New(ctx, &proxyflags.Opt)
+	} else {
+		s._vfs = vfs.New(f, &vfsflags.Opt)
+		if len(s.keys) == 0 {
+			fs.Logf(f, "No --auth-key set so serving without authentication")
+		}
+	}
+	s.uploads, err = newUploads()
+	if err != nil {
+		return nil, err
+	}
+
+	s.Server, err = libhttp.NewServer(ctx, libhttp.WithConfig(s.opt.HTTP))
+	if err != nil {
+		return nil, fmt.Errorf("failed to init server: %w", err)
+	}
+	s.baseURL = strings.Trim(s.opt.HTTP.BaseURL, "/")
+	if s.baseURL != "" {
+		s.baseURL = "/" + s.baseURL
+	}
+
+	router := s.Server.Router()
+	router.Use(
+		middleware.SetHeader("Accept-Ranges", "bytes"),
+		middleware.SetHeader("Server", "rclone/"+fs.Version),
+	)
+	router.Handle("/*", s)
+	router.Handle("/", s)
+	return s, nil
+}
+
+// Serve runs the s3 server in the background.
+//
+// Use s.Close() and s.Wait() to shutdown server
+func (s *Server) Serve() {
+	s.Server.Serve()
+	fs.Logf(s.f, "Starting s3 server on %s", s.URLs())
+}
+
+// Shutdown the server and remove any incomplete multipart uploads
+func (s *Server) Shutdown() error {
+	err := s.Server.Shutdown()
+	if cleanErr := s.uploads.cleanup(); err == nil {
+		err = cleanErr
+	}
+	return err
+}
+
+// getVFS gets the VFS in use for this request
+func getVFS(ctx context.Context) *vfs.VFS {
+	VFS, _ := ctx.Value(ctxKeyVFS).(*vfs.VFS)
+	return VFS
+}
+
+// authenticate checks the signature on the request and returns the
+// VFS the request should use.
+func (s *Server) authenticate(r *http.Request) (VFS *vfs.VFS, err error) {
+	sig, err := parseSignatureV4(r)
+	if err != nil {
+		fs.Debugf(nil, "s3: %s: bad signature: %v", r.RemoteAddr, err)
+		if isPresigned(r) {
+			return nil, errAuthorizationQueryParametersError
+		}
+		return nil, errAuthorizationHeaderMalformed
+	}
+	if s.proxy == nil && len(s.keys) == 0 {
+		return s._vfs, nil
+	}
+	if sig == nil {
+		return nil, errAccessDenied
+	}
+	var secret string
+	if s.proxy != nil {
+		VFS, secret, err = s.proxy.CallSecret(sig.cred.accessKey)
+		if err != nil {
+			fs.Infof(nil, "s3: %s: proxy auth failed for %q: %v", r.RemoteAddr, sig.cred.accessKey, err)
+			return nil, errInvalidAccessKeyID
+		}
+	} else {
+		var ok bool
+		secret, ok = s.keys[sig.cred.accessKey]
+		if !ok {
+			return nil, errInvalidAccessKeyID
+		}
+		VFS = s._vfs
+	}
+	err = sig.checkTime(r, s.nowFn())
+	if err != nil {
+		return nil, err
+	}
+	err = sig.verify(r, s.baseURL+r.URL.Path, secret)
+	if err != nil {
+		return nil, err
+	}
+	return VFS, nil
+}
+
+// writeError writes err to the client as an S3 XML error
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var s3Err *s3Error
+	if !errors.As(err, &s3Err) {
+		switch {
+		case errors.Is(err, vfs.ENOENT):
+			s3Err = errNoSuchKey
+		case errors.Is(err, vfs.EROFS), errors.Is(err, vfs.EPERM):
+			s3Err = errAccessDenied
+		default:
+			fs.Errorf(r.URL.Path, "s3: %s %s failed: %v", r.Method, r.URL, err)
+			s3Err = errInternalError
+		}
+	}
+	fs.Debugf(r.URL.Path, "s3: %s %s from %s: %v", r.Method, r.URL, r.RemoteAddr, s3Err)
+	if r.Method == "HEAD" {
+		w.WriteHeader(s3Err.StatusCode)
+		return
+	}
+	writeXML(w, s3Err.StatusCode, errorResponse{
+		Code:      s3Err.Code,
+		Message:   s3Err.Message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("X-Amz-Request-Id"),
+	})
+}
+
+// bucketAndKey splits the request into bucket and key
+func (s *Server) bucketAndKey(r *http.Request) (bucket, key string) {
+	urlPath := strings.TrimPrefix(r.URL.Path, "/")
+	if !s.opt.ForcePathStyle {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if net.ParseIP(host) == nil {
+			if i := strings.IndexByte(host, '.'); i > 0 {
+				return host[:i], urlPath
+			}
+		}
+	}
+	bucket, key, _ = strings.Cut(urlPath, "/")
+	return bucket, key
+}
+
+// ServeHTTP dispatches the S3 requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Amz-Request-Id", strings.ToUpper(random.String(16)))
+	fs.Debugf(r.URL.Path, "%s %s from %s", r.Method, r.URL.RawQuery, r.RemoteAddr)
+
+	VFS, err := s.authenticate(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), ctxKeyVFS, VFS))
+
+	bucket, key := s.bucketAndKey(r)
+	query := r.URL.Query()
+	if bucket == "" {
+		if r.Method == "GET" {
+			err = s.listBuckets(w, r)
+		} else {
+			err = errMethodNotAllowed
+		}
+	} else if !validBucketName(bucket) {
+		err = errInvalidBucketName
+	} else if key == "" {
+		switch {
+		case r.Method == "GET" && query.Has("location"):
+			err = s.getBucketLocation(w, r, bucket)
+		case r.Method == "GET" && query.Has("uploads"):
+			err = s.listMultipartUploads(w, r, bucket)
+		case r.Method == "GET":
+			err = s.listObjects(w, r, bucket)
+		case r.Method == "HEAD":
+			err = s.headBucket(w, r, bucket)
+		case r.Method == "PUT":
+			err = s.createBucket(w, r, bucket)
+		case r.Method == "DELETE":
+			err = s.deleteBucket(w, r, bucket)
+		case r.Method == "POST" && query.Has("delete"):
+			err = s.deleteObjects(w, r, bucket)
+		default:
+			err = errMethodNotAllowed
+		}
+	} else if len(key) > maxKeyLength {
+		err = errKeyTooLong
+	} else {
+		switch {
+		case r.Method == "GET" && query.Has("uploadId"):
+			err = s.listParts(w, r, bucket, key)
+		case r.Method == "GET", r.Method == "HEAD":
+			err = s.getObject(w, r, bucket, key)
+		case r.Method == "PUT" && query.Has("uploadId"):
+			err = s.uploadPart(w, r, bucket, key)
+		case r.Method == "PUT" && r.Header.Get("X-Amz-Copy-Source") != "":
+			err = s.copyObject(w, r, bucket, key)
+		case r.Method == "PUT":
+			err = s.putObject(w, r, bucket, key)
+		case r.Method == "POST" && query.Has("uploads"):
+			err = s.createMultipartUpload(w, r, bucket, key)
+		case r.Method == "POST" && query.Has("uploadId"):
+			err = s.completeMultipartUpload(w, r, bucket, key)
+		case r.Method == "DELETE" && query.Has("uploadId"):
+			err = s.abortMultipartUpload(w, r, bucket, key)
+		case r.Method == "DELETE":
+			err = s.deleteObject(w, r, bucket, key)
+		default:
+			err = errMethodNotAllowed
+		}
+	}
+	if err != nil {
+		s.writeError(w, r, err)
+	}
+}
+
+// validBucketName checks the bucket can be used as a directory name
+func validBucketName(bucket string) bool {
+	return bucket != "." && bucket != ".." && !strings.ContainsAny(bucket, "/\\")
+}
+
+// keyToPath converts bucket and key into a path in the VFS
+//
+// It returns an error if the key can't be represented as a path.
+func keyToPath(bucket, key string) (string, error) {
+	trimmed := strings.TrimSuffix(key, "/")
+	for _, segment := range strings.Split(trimmed, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", errInvalidArgument.withMessage(fmt.Sprintf("Object key %q is not supported", key))
+		}
+	}
+	return bucket + "/" + trimmed, nil
+}
+
+// checkBucket checks the bucket exists
+func checkBucket(VFS *vfs.VFS, bucket string) (*vfs.Dir, error) {
+	node, err := VFS.Stat(bucket)
+	if err == vfs.ENOENT {
+		return nil, errNoSuchBucket
+	} else if err != nil {
+		return nil, err
+	}
+	dir, ok := node.(*vfs.Dir)
+	if !ok {
+		return nil, errNoSuchBucket
+	}
+	return dir, nil
+}
+
+// listBuckets lists the top level directories as buckets
+func (s *Server) listBuckets(w http.ResponseWriter, r *http.Request) error {
+	VFS := getVFS(r.Context())
+	root, err := VFS.Root()
+	if err != nil {
+		return err
+	}
+	nodes, err := root.ReadDirAll()
+	if err != nil {
+		return err
+	}
+	result := listAllMyBucketsResult{
+		Xmlns:   s3Namespace,
+		Owner:   defaultOwner,
+		Buckets: []bucketInfo{},
+	}
+	for _, node := range nodes {
+		if !node.IsDir() || !validBucketName(node.Name()) {
+			continue
+		}
+		result.Buckets = append(result.Buckets, bucketInfo{
+			Name:         node.Name(),
+			CreationDate: formatTime(node.ModTime()),
+		})
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
+
+// getBucketLocation returns an empty location constraint
+func (s *Server) getBucketLocation(w http.ResponseWriter, r *http.Request, bucket string) error {
+	_, err := checkBucket(getVFS(r.Context()), bucket)
+	if err != nil {
+		return err
+	}
+	writeXML(w, http.StatusOK, locationConstraint{Xmlns: s3Namespace})
+	return nil
+}
+
+// headBucket checks a bucket exists
+func (s *Server) headBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	_, err := checkBucket(getVFS(r.Context()), bucket)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// createBucket makes a top level directory
+func (s *Server) createBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	VFS := getVFS(r.Context())
+	_, err := VFS.Stat(bucket)
+	if err == nil {
+		return errBucketAlreadyOwnedByYou
+	} else if err != vfs.ENOENT {
+		return err
+	}
+	err = VFS.Mkdir(bucket, 0777)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// deleteBucket removes an empty top level directory
+func (s *Server) deleteBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	dir, err := checkBucket(getVFS(r.Context()), bucket)
+	if err != nil {
+		return err
+	}
+	err = dir.Remove()
+	if err == vfs.ENOTEMPTY {
+		return errBucketNotEmpty
+	} else if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// etag returns the ETag for node
+func (s *Server) etag(ctx context.Context, node vfs.Node) string {
+	if s.opt.HashType != rclonehash.None {
+		if o, ok := node.DirEntry().(fs.Object); ok {
+			sum, err := o.Hash(ctx, s.opt.HashType)
+			if err == nil && sum != "" {
+				return `"` + sum + `"`
+			}
+		}
+	}
+	// Make an ETag which doesn't look like an MD5 so clients don't
+	// try to use it as a checksum.
+	return fmt.Sprintf(`"%x-%x"`, node.ModTime().UnixNano(), node.Size())
+}
+
+// statObject finds the file for bucket and key
+func statObject(VFS *vfs.VFS, bucket, key string) (vfs.Node, error) {
+	_, err := checkBucket(VFS, bucket)
+	if err != nil {
+		return nil, err
+	}
+	p, err := keyToPath(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	node, err := VFS.Stat(p)
+	if err == vfs.ENOENT {
+		return nil, errNoSuchKey
+	} else if err != nil {
+		return nil, err
+	}
+	// Directories can only be read as directory markers
+	if node.IsDir() != strings.HasSuffix(key, "/") {
+		return nil, errNoSuchKey
+	}
+	return node, nil
+}
+
+// setObjectHeaders sets the headers describing node
+func (s *Server) setObjectHeaders(ctx context.Context, w http.ResponseWriter, node vfs.Node) {
+	h := w.Header()
+	h.Set("ETag", s.etag(ctx, node))
+	h.Set("Last-Modified", node.ModTime().UTC().Format(http.TimeFormat))
+	h.Set(metaMtime, swift.TimeToFloatString(node.ModTime()))
+	contentType := "application/octet-stream"
+	if o, ok := node.DirEntry().(fs.Object); ok {
+		contentType = fs.MimeType(ctx, o)
+	}
+	h.Set("Content-Type", contentType)
+}
+
+// getObject serves GET and HEAD requests for an object
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	VFS := getVFS(r.Context())
+	node, err := statObject(VFS, bucket, key)
+	if err != nil {
+		return err
+	}
+	s.setObjectHeaders(r.Context(), w, node)
+	if node.IsDir() {
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	if r.Method == "HEAD" {
+		w.Header().Set("Content-Length", strconv.FormatInt(node.Size(), 10))
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	if rng := r.Header.Get("Range"); rng != "" && !strings.HasPrefix(rng, "bytes=") {
+		return errInvalidRange
+	}
+	in, err := node.Open(os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := in.Close()
+		if err != nil {
+			fs.Errorf(node.Path(), "Failed to close file: %v", err)
+		}
+	}()
+
+	// Account the transfer
+	if o, ok := node.DirEntry().(fs.Object); ok {
+		tr := accounting.Stats(r.Context()).NewTransfer(o)
+		defer tr.Done(r.Context(), nil)
+	}
+
+	// http.ServeContent deals with Range and conditional requests
+	http.ServeContent(w, r, node.Name(), node.ModTime(), in)
+	return nil
+}
+
+// readModTime reads the modification time from the request headers
+// returning the zero time if not set.
+func readModTime(h http.Header) time.Time {
+	if value := h.Get(metaMtime); value != "" {
+		modTime, err := swift.FloatStringToTime(value)
+		if err == nil {
+			return modTime
+		}
+		fs.Debugf(nil, "s3: ignoring bad %s header %q: %v", metaMtime, value, err)
+	}
+	return time.Time{}
+}
+
+// mkdirAll makes the directory dirPath and any parents
+func mkdirAll(VFS *vfs.VFS, dirPath string) (*vfs.Dir, error) {
+	dir, err := VFS.Root()
+	if err != nil {
+		return nil, err
+	}
+	if dirPath == "" || dirPath == "." {
+		return dir, nil
+	}
+	for _, name := range strings.Split(dirPath, "/") {
+		dir, err = dir.Mkdir(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dir, nil
+}
+
+// tempSuffix is the suffix of the temporary files objects are
+// uploaded to before being renamed into place
+const tempSuffix = ".rclone-s3-partial"
+
+// isTempName returns true if name is a temporary upload file
+func isTempName(name string) bool {
+	return strings.HasSuffix(name, tempSuffix)
+}
+
+// writeFile writes in to the file at filePath in the VFS returning
+// the number of bytes written.
+//
+// The data is written to a temporary file in the same directory which
+// is renamed over filePath only if the write succeeds, so a failed
+// upload leaves any existing object alone.
+//
+// If size is >= 0 then it checks exactly size bytes were written. If
+// check is not nil it is called after the data has been written and
+// the upload is abandoned if it returns an error.
+func writeFile(VFS *vfs.VFS, filePath string, in io.Reader, size int64, modTime time.Time, check func() error) (n int64, err error) {
+	dir, leaf := path.Split(filePath)
+	_, err = mkdirAll(VFS, path.Clean(dir))
+	if err != nil {
+		return 0, err
+	}
+	tempPath := dir + "." + leaf + "." + strings.ToLower(random.String(8)) + tempSuffix
+	handle, err := VFS.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0777)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if removeErr := VFS.Remove(tempPath); removeErr != nil && removeErr != vfs.ENOENT {
+			fs.Errorf(tempPath, "s3: failed to remove incomplete file: %v", removeErr)
+		}
+	}()
+	n, err = io.Copy(handle, in)
+	if err == nil && size >= 0 && n != size {
+		err = errIncompleteBody
+	}
+	closeErr := handle.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && check != nil {
+		err = check()
+	}
+	if err != nil {
+		return n, err
+	}
+	if !modTime.IsZero() {
+		err = VFS.Chtimes(tempPath, modTime, modTime)
+		if err != nil {
+			fs.Errorf(filePath, "s3: failed to set modification time: %v", err)
+		}
+	}
+	err = VFS.Rename(tempPath, filePath)
+	if err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// errIncompleteBody is returned if the body is shorter than the Content-Length
+var errIncompleteBody = &s3Error{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header", http.StatusBadRequest}
+
+// readContentMD5 reads the Content-MD5 header if set
+func readContentMD5(h http.Header) ([]byte, error) {
+	value := h.Get("Content-Md5")
+	if value == "" {
+		return nil, nil
+	}
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sum) != md5.Size {
+		return nil, errInvalidDigest
+	}
+	return sum, nil
+}
+
+// md5Reader calculates the MD5 of the data read through it
+type md5Reader struct {
+	in io.Reader
+	h  hash.Hash
+}
+
+func newMD5Reader(in io.Reader) *md5Reader {
+	return &md5Reader{in: in, h: md5.New()}
+}
+
+// Read data calculating the MD5
+func (mr *md5Reader) Read(p []byte) (n int, err error) {
+	n, err = mr.in.Read(p)
+	_, _ = mr.h.Write(p[:n])
+	return n, err
+}
+
+// Sum returns the MD5 of the data read so far
+func (mr *md5Reader) Sum() []byte {
+	return mr.h.Sum(nil)
+}
+
+// putObject uploads an object
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	VFS := getVFS(r.Context())
+	_, err := checkBucket(VFS, bucket)
+	if err != nil {
+		return err
+	}
+	p, err := keyToPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	if r.ContentLength < 0 {
+		return errMissingContentLength
+	}
+	contentMD5, err := readContentMD5(r.Header)
+	if err != nil {
+		return err
+	}
+
+	// Keys ending in / are directory markers
+	if strings.HasSuffix(key, "/") {
+		if r.ContentLength != 0 {
+			return errInvalidArgument.withMessage("Directory markers must be empty")
+		}
+		_, err = mkdirAll(VFS, p)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("ETag", `"`+emptyMD5+`"`)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	in := newMD5Reader(r.Body)
+	checkMD5 := func() error {
+		if contentMD5 != nil && !equalBytes(in.Sum(), contentMD5) {
+			return errBadDigest
+		}
+		return nil
+	}
+	_, err = writeFile(VFS, p, in, r.ContentLength, readModTime(r.Header), checkMD5)
+	if err != nil {
+		return err
+	}
+	sum := in.Sum()
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum)+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// equalBytes returns true if a and b are the same
+func equalBytes(a, b []byte) bool {
+	return string(a) == string(b)
+}
+
+// parseCopySource parses the X-Amz-Copy-Source header into bucket and key
+func parseCopySource(value string) (bucket, key string, err error) {
+	value, _, _ = strings.Cut(value, "?") // ignore versionId
+	value, err = url.PathUnescape(value)
+	if err != nil {
+		return "", "", errInvalidArgument.withMessage("Bad X-Amz-Copy-Source")
+	}
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(value, "/"), "/")
+	if bucket == "" || key == "" {
+		return "", "", errInvalidArgument.withMessage("Bad X-Amz-Copy-Source")
+	}
+	return bucket, key, nil
+}
+
+// checkCopyConditions checks the x-amz-copy-source-if-* headers
+func (s *Server) checkCopyConditions(ctx context.Context, h http.Header, node vfs.Node) error {
+	etag := s.etag(ctx, node)
+	if match := h.Get("X-Amz-Copy-Source-If-Match"); match != "" && match != etag && match != "*" {
+		return errPreconditionFailed
+	}
+	if noneMatch := h.Get("X-Amz-Copy-Source-If-None-Match"); noneMatch != "" && (noneMatch == etag || noneMatch == "*") {
+		return errPreconditionFailed
+	}
+	if since := h.Get("X-Amz-Copy-Source-If-Modified-Since"); since != "" {
+		t, err := http.ParseTime(since)
+		if err == nil && !node.ModTime().After(t) {
+			return errPreconditionFailed
+		}
+	}
+	if since := h.Get("X-Amz-Copy-Source-If-Unmodified-Since"); since != "" {
+		t, err := http.ParseTime(since)
+		if err == nil && node.ModTime().After(t) {
+			return errPreconditionFailed
+		}
+	}
+	return nil
+}
+
+// copyObject copies an object within the VFS
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	VFS := getVFS(r.Context())
+	srcBucket, srcKey, err := parseCopySource(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return err
+	}
+	srcNode, err := statObject(VFS, srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	if srcNode.IsDir() {
+		return errNoSuchKey
+	}
+	err = s.checkCopyConditions(r.Context(), r.Header, srcNode)
+	if err != nil {
+		return err
+	}
+	_, err = checkBucket(VFS, bucket)
+	if err != nil {
+		return err
+	}
+	dstPath, err := keyToPath(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	// Work out the modification time for the destination
+	modTime := srcNode.ModTime()
+	replace := strings.EqualFold(r.Header.Get("X-Amz-Metadata-Directive"), "REPLACE")
+	if replace {
+		modTime = readModTime(r.Header)
+		if modTime.IsZero() {
+			modTime = s.nowFn()
+		}
+	}
+
+	if dstPath == srcNode.Path() {
+		// Copying an object to itself is used to update the metadata
+		if !replace {
+			return errInvalidArgument.withMessage("This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata")
+		}
+		err = srcNode.SetModTime(modTime)
+		if err != nil {
+			return err
+		}
+	} else {
+		in, err := srcNode.Open(os.O_RDONLY)
+		if err != nil {
+			return err
+		}
+		_, err = writeFile(VFS, dstPath, in, srcNode.Size(), modTime, nil)
+		closeErr := in.Close()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+
+	dstNode, err := VFS.Stat(dstPath)
+	if err != nil {
+		return err
+	}
+	writeXML(w, http.StatusOK, copyObjectResult{
+		Xmlns:        s3Namespace,
+		LastModified: formatTime(dstNode.ModTime()),
+		ETag:         s.etag(r.Context(), dstNode),
+	})
+	return nil
+}
+
+// removeObject removes the object at bucket, key and tidies up any
+// directories left empty.
+func (s *Server) removeObject(VFS *vfs.VFS, bucket, key string) error {
+	node, err := statObject(VFS, bucket, key)
+	if err == errNoSuchKey {
+		// S3 doesn't return an error deleting missing objects
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err = node.Remove()
+	if err == vfs.ENOTEMPTY && node.IsDir() {
+		// Directory markers for non-empty directories are virtual
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !s.opt.NoCleanup {
+		s.removeEmptyParents(VFS, path.Dir(node.Path()), bucket)
+	}
+	return nil
+}
+
+// removeEmptyParents removes dirPath and its parents while they are
+// empty stopping at the bucket.
+func (s *Server) removeEmptyParents(VFS *vfs.VFS, dirPath, bucket string) {
+	for dirPath != bucket && dirPath != "." && dirPath != "" && dirPath != "/" {
+		node, err := VFS.Stat(dirPath)
+		if err != nil || !node.IsDir() {
+			return
+		}
+		nodes, err := node.(*vfs.Dir).ReadDirAll()
+		if err != nil || len(nodes) != 0 {
+			return
+		}
+		err = node.Remove()
+		if err != nil {
+			fs.Debugf(dirPath, "s3: failed to remove empty directory: %v", err)
+			return
+		}
+		dirPath = path.Dir(dirPath)
+	}
+}
+
+// deleteObject deletes an object
+func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	err := s.removeObject(getVFS(r.Context()), bucket, key)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// maxDeleteObjects is the maximum number of objects in a DeleteObjects call
+const maxDeleteObjects = 1000
+
+// deleteObjects deletes several objects in one request
+func (s *Server) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string) error {
+	VFS := getVFS(r.Context())
+	_, err := checkBucket(VFS, bucket)
+	if err != nil {
+		return err
+	}
+	var req deleteRequest
+	err = xml.NewDecoder(io.LimitReader(r.Body, 4*1024*1024)).Decode(&req)
+	if err != nil || len(req.Objects) > maxDeleteObjects {
+		return errMalformedXML
+	}
+	result := deleteResult{Xmlns: s3Namespace}
+	for _, object := range req.Objects {
+		err := s.removeObject(VFS, bucket, object.Key)
+		if err != nil {
+			var s3Err *s3Error
+			if !errors.As(err, &s3Err) {
+				fs.Errorf(object.Key, "s3: delete failed: %v", err)
+				s3Err = errInternalError
+			}
+			result.Errors = append(result.Errors, deleteError{
+				Key:     object.Key,
+				Code:    s3Err.Code,
+				Message: s3Err.Message,
+			})
+		} else if !req.Quiet {
+			result.Deleted = append(result.Deleted, deletedObject{Key: object.Key})
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
@@ -0,0 +1,435 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Constants used by AWS Signature Version 4
+const (
+	signV4Algorithm    = "AWS4-HMAC-SHA256"
+	iso8601Format      = "20060102T150405Z"
+	scopeDateFormat    = "20060102"
+	unsignedPayload    = "UNSIGNED-PAYLOAD"
+	streamingPayload   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	emptySHA256        = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	maxClockSkew       = 15 * time.Minute
+	maxPresignedExpiry = 7 * 24 * time.Hour
+)
+
+// credential is the parsed Credential part of a v4 signature
+type credential struct {
+	accessKey string
+	date      string
+	region    string
+	service   string
+}
+
+// scope returns the credential scope
+func (c credential) scope() string {
+	return strings.Join([]string{c.date, c.region, c.service, "aws4_request"}, "/")
+}
+
+// signatureV4 describes a v4 signature found in a request
+type signatureV4 struct {
+	cred          credential
+	signedHeaders []string
+	signature     string
+	date          time.Time
+	payloadHash   string
+	presigned     bool
+}
+
+// parseCredential parses "AKID/20130524/us-east-1/s3/aws4_request"
+func parseCredential(s string) (c credential, err error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return c, fmt.Errorf("malformed credential %q", s)
+	}
+	return credential{
+		accessKey: parts[0],
+		date:      parts[1],
+		region:    parts[2],
+		service:   parts[3],
+	}, nil
+}
+
+// isPresigned returns true if the request uses a presigned v4 URL
+func isPresigned(r *http.Request) bool {
+	return r.URL.Query().Get("X-Amz-Algorithm") != ""
+}
+
+// parseSignatureV4 reads the signature from the Authorization header
+// or from the query parameters of a presigned URL.
+//
+// It returns nil, nil if the request isn't signed at all.
+func parseSignatureV4(r *http.Request) (sig *signatureV4, err error) {
+	sig = &signatureV4{}
+	var dateString string
+	if isPresigned(r) {
+		q := r.URL.Query()
+		if q.Get("X-Amz-Algorithm") != signV4Algorithm {
+			return nil, errors.New("unsupported signature algorithm")
+		}
+		sig.presigned = true
+		sig.cred, err = parseCredential(q.Get("X-Amz-Credential"))
+		if err != nil {
+			return nil, err
+		}
+		sig.signedHeaders = strings.Split(q.Get("X-Amz-SignedHeaders"), ";")
+		sig.signature = q.Get("X-Amz-Signature")
+		dateString = q.Get("X-Amz-Date")
+		sig.payloadHash = unsignedPayload
+		if h := r.Header.Get("X-Amz-Content-Sha256"); h != "" {
+			sig.payloadHash = h
+		}
+	} else {
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			return nil, nil
+		}
+		if !strings.HasPrefix(auth, signV4Algorithm+" ") {
+			return nil, errors.New("only AWS signature version 4 is supported")
+		}
+		for _, field := range strings.Split(strings.TrimPrefix(auth, signV4Algorithm+" "), ",") {
+			key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+			if !found {
+				return nil, fmt.Errorf("malformed authorization field %q", field)
+			}
+			switch key {
+			case "Credential":
+				sig.cred, err = parseCredential(value)
+				if err != nil {
+					return nil, err
+				}
+			case "SignedHeaders":
+				sig.signedHeaders = strings.Split(value, ";")
+			case "Signature":
+				sig.signature = value
+			}
+		}
+		dateString = r.Header.Get("X-Amz-Date")
+		if dateString == "" {
+			dateString = r.Header.Get("Date")
+		}
+		sig.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		if sig.payloadHash == "" {
+			return nil, errors.New("missing X-Amz-Content-Sha256 header")
+		}
+	}
+	if sig.cred.accessKey == "" || sig.signature == "" || len(sig.signedHeaders) == 0 {
+		return nil, errors.New("incomplete signature")
+	}
+	if !sig.signsHeader("host") {
+		return nil, errors.New("host header not signed")
+	}
+	sig.date, err = time.Parse(iso8601Format, dateString)
+	if err != nil {
+		sig.date, err = http.ParseTime(dateString)
+		if err != nil {
+			return nil, fmt.Errorf("bad signature date %q", dateString)
+		}
+	}
+	if scopeDate := sig.date.UTC().Format(scopeDateFormat); sig.cred.date != scopeDate {
+		return nil, fmt.Errorf("credential date %q doesn't match signature date %q", sig.cred.date, scopeDate)
+	}
+	return sig, nil
+}
+
+// signsHeader returns true if the header called name is signed
+func (sig *signatureV4) signsHeader(name string) bool {
+	for _, signed := range sig.signedHeaders {
+		if signed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTime checks the signature is being used at an acceptable time
+func (sig *signatureV4) checkTime(r *http.Request, now time.Time) error {
+	if !sig.presigned {
+		skew := now.Sub(sig.date)
+		if skew > maxClockSkew || skew < -maxClockSkew {
+			return errRequestTimeTooSkewed
+		}
+		return nil
+	}
+	expires, err := strconv.Atoi(r.URL.Query().Get("X-Amz-Expires"))
+	if err != nil || expires < 0 || time.Duration(expires)*time.Second > maxPresignedExpiry {
+		return errAuthorizationQueryParametersError
+	}
+	if now.Before(sig.date.Add(-maxClockSkew)) || now.After(sig.date.Add(time.Duration(expires)*time.Second)) {
+		return errAccessDenied.withMessage("Request has expired")
+	}
+	return nil
+}
+
+// uriEncode encodes s as described in the AWS signature documentation
+func uriEncode(s string, encodeSlash bool) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+// canonicalQuery returns the canonical query string for the request
+func canonicalQuery(query url.Values, presigned bool) string {
+	var keys []string
+	for key := range query {
+		if presigned && key == "X-Amz-Signature" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// canonicalRequest returns the canonical form of the request
+//
+// urlPath is the unescaped path the client used which may differ from
+// r.URL.Path if a prefix has been stripped.
+func (sig *signatureV4) canonicalRequest(r *http.Request, urlPath string) string {
+	var headers strings.Builder
+	for _, name := range sig.signedHeaders {
+		var value string
+		if name == "host" {
+			value = r.Host
+		} else if name == "content-length" && r.Header.Get("Content-Length") == "" && r.ContentLength >= 0 {
+			value = strconv.FormatInt(r.ContentLength, 10)
+		} else {
+			values := r.Header.Values(name)
+			for i := range values {
+				values[i] = strings.Join(strings.Fields(values[i]), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(value)
+		headers.WriteByte('\n')
+	}
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(urlPath, false),
+		canonicalQuery(r.URL.Query(), sig.presigned),
+		headers.String(),
+		strings.Join(sig.signedHeaders, ";"),
+		sig.payloadHash,
+	}, "\n")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signingKey derives the signing key from the secret and credential
+func signingKey(secret string, cred credential) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), cred.date)
+	key = hmacSHA256(key, cred.region)
+	key = hmacSHA256(key, cred.service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// expectedSignature calculates the signature the request should have
+// if it was signed with secret
+func (sig *signatureV4) expectedSignature(r *http.Request, urlPath string, secret string) string {
+	stringToSign := strings.Join([]string{
+		signV4Algorithm,
+		sig.date.UTC().Format(iso8601Format),
+		sig.cred.scope(),
+		sha256Hex([]byte(sig.canonicalRequest(r, urlPath))),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(signingKey(secret, sig.cred), stringToSign))
+}
+
+// verify checks the request was signed with secret
+//
+// If the payload is sent with aws-chunked encoding then the body of
+// the request is replaced with a reader which decodes and checks it.
+// Otherwise, unless the payload is unsigned, the body is replaced
+// with a reader which checks it has the SHA256 that was signed.
+func (sig *signatureV4) verify(r *http.Request, urlPath string, secret string) error {
+	expected := sig.expectedSignature(r, urlPath, secret)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(sig.signature)) != 1 {
+		return errSignatureDoesNotMatch
+	}
+	switch sig.payloadHash {
+	case unsignedPayload:
+	case streamingPayload:
+		decodedLength, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil {
+			return errMissingContentLength
+		}
+		r.Body = newChunkedReader(r.Body, signingKey(secret, sig.cred), sig, expected)
+		r.ContentLength = decodedLength
+	default:
+		sum, err := hex.DecodeString(sig.payloadHash)
+		if err != nil || len(sum) != sha256.Size {
+			return errInvalidArgument.withMessage("Bad X-Amz-Content-Sha256")
+		}
+		if r.Body != nil {
+			r.Body = newSHA256Reader(r.Body, sum)
+		}
+	}
+	return nil
+}
+
+// sha256Reader checks the body of a request has the SHA256 that was
+// signed, returning an error instead of io.EOF if it doesn't
+type sha256Reader struct {
+	in   io.ReadCloser
+	h    hash.Hash
+	want []byte
+}
+
+func newSHA256Reader(in io.ReadCloser, want []byte) *sha256Reader {
+	return &sha256Reader{in: in, h: sha256.New(), want: want}
+}
+
+// Read the body calculating the SHA256
+func (sr *sha256Reader) Read(p []byte) (n int, err error) {
+	n, err = sr.in.Read(p)
+	_, _ = sr.h.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(sr.h.Sum(nil), sr.want) {
+		err = errContentSHA256Mismatch
+	}
+	return n, err
+}
+
+// Close the underlying body
+func (sr *sha256Reader) Close() error {
+	return sr.in.Close()
+}
+
+// chunkedReader decodes a body sent with aws-chunked encoding and
+// checks the signature of each chunk
+type chunkedReader struct {
+	in       io.ReadCloser
+	br       *bufio.Reader
+	key      []byte
+	sig      *signatureV4
+	prevSig  string
+	chunk    []byte
+	buf      []byte
+	finished bool
+	err      error
+}
+
+func newChunkedReader(in io.ReadCloser, key []byte, sig *signatureV4, seedSignature string) *chunkedReader {
+	return &chunkedReader{
+		in:      in,
+		br:      bufio.NewReader(in),
+		key:     key,
+		sig:     sig,
+		prevSig: seedSignature,
+	}
+}
+
+// maxChunkSize limits the memory used decoding a single chunk
+const maxChunkSize = 16 * 1024 * 1024
+
+// readChunk reads the next chunk into cr.chunk checking its signature
+func (cr *chunkedReader) readChunk() error {
+	header, err := cr.br.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("reading chunk header: %w", err)
+	}
+	header = strings.TrimRight(header, "\r\n")
+	sizeString, params, _ := strings.Cut(header, ";")
+	size, err := strconv.ParseInt(sizeString, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return fmt.Errorf("bad chunk size %q", sizeString)
+	}
+	if !strings.HasPrefix(params, "chunk-signature=") {
+		return errors.New("missing chunk signature")
+	}
+	chunkSig := strings.TrimPrefix(params, "chunk-signature=")
+	if int64(cap(cr.buf)) < size+2 {
+		cr.buf = make([]byte, size+2)
+	}
+	data := cr.buf[:size+2]
+	_, err = io.ReadFull(cr.br, data)
+	if err != nil {
+		return fmt.Errorf("reading chunk: %w", err)
+	}
+	if !bytes.Equal(data[size:], []byte("\r\n")) {
+		return errors.New("chunk not terminated with CRLF")
+	}
+	data = data[:size]
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-PAYLOAD",
+		cr.sig.date.UTC().Format(iso8601Format),
+		cr.sig.cred.scope(),
+		cr.prevSig,
+		emptySHA256,
+		sha256Hex(data),
+	}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(cr.key, stringToSign))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(chunkSig)) != 1 {
+		return errSignatureDoesNotMatch
+	}
+	cr.prevSig = chunkSig
+	cr.chunk = data
+	if size == 0 {
+		cr.finished = true
+	}
+	return nil
+}
+
+// Read the decoded body
+func (cr *chunkedReader) Read(p []byte) (n int, err error) {
+	for len(cr.chunk) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		if cr.finished {
+			return 0, io.EOF
+		}
+		cr.err = cr.readChunk()
+	}
+	n = copy(p, cr.chunk)
+	cr.chunk = cr.chunk[n:]
+	return n, nil
+}
+
+// Close the underlying body
+func (cr *chunkedReader) Close() error {
+	return cr.in.Close()
+}
@@ -0,0 +1,279 @@
+package s3
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// s3Namespace is the XML namespace of S3 responses
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// s3Error is an error which can be returned to the client
+type s3Error struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+// Error satisfies the error interface
+func (e *s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// withMessage returns a copy of the error with a different message
+func (e *s3Error) withMessage(message string) *s3Error {
+	newErr := *e
+	newErr.Message = message
+	return &newErr
+}
+
+// Errors returned to the client
+var (
+	errAccessDenied                      = &s3Error{"AccessDenied", "Access Denied", http.StatusForbidden}
+	errAuthorizationHeaderMalformed      = &s3Error{"AuthorizationHeaderMalformed", "The authorization header is malformed", http.StatusBadRequest}
+	errAuthorizationQueryParametersError = &s3Error{"AuthorizationQueryParametersError", "The presigned URL query parameters are invalid", http.StatusBadRequest}
+	errBadDigest                         = &s3Error{"BadDigest", "The Content-MD5 you specified did not match what was received", http.StatusBadRequest}
+	errBucketAlreadyOwnedByYou           = &s3Error{"BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it", http.StatusConflict}
+	errBucketNotEmpty                    = &s3Error{"BucketNotEmpty", "The bucket you tried to delete is not empty", http.StatusConflict}
+	errContentSHA256Mismatch             = &s3Error{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed", http.StatusBadRequest}
+	errInternalError                     = &s3Error{"InternalError", "We encountered an internal error. Please try again", http.StatusInternalServerError}
+	errInvalidAccessKeyID                = &s3Error{"InvalidAccessKeyId", "The AWS access key ID you provided does not exist in our records", http.StatusForbidden}
+	errInvalidArgument                   = &s3Error{"InvalidArgument", "Invalid Argument", http.StatusBadRequest}
+	errInvalidBucketName                 = &s3Error{"InvalidBucketName", "The specified bucket is not valid", http.StatusBadRequest}
+	errInvalidDigest                     = &s3Error{"InvalidDigest", "The Content-MD5 you specified is not valid", http.StatusBadRequest}
+	errInvalidPart                       = &s3Error{"InvalidPart", "One or more of the specified parts could not be found", http.StatusBadRequest}
+	errInvalidPartOrder                  = &s3Error{"InvalidPartOrder", "The list of parts was not in ascending order", http.StatusBadRequest}
+	errInvalidRange                      = &s3Error{"InvalidRange", "The requested range is not satisfiable", http.StatusRequestedRangeNotSatisfiable}
+	errKeyTooLong                        = &s3Error{"KeyTooLongError", "Your key is too long", http.StatusBadRequest}
+	errMalformedXML                      = &s3Error{"MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema", http.StatusBadRequest}
+	errMethodNotAllowed                  = &s3Error{"MethodNotAllowed", "The specified method is not allowed against this resource", http.StatusMethodNotAllowed}
+	errMissingContentLength              = &s3Error{"MissingContentLength", "You must provide the Content-Length HTTP header", http.StatusLengthRequired}
+	errNoSuchBucket                      = &s3Error{"NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound}
+	errNoSuchKey                         = &s3Error{"NoSuchKey", "The specified key does not exist", http.StatusNotFound}
+	errNoSuchUpload                      = &s3Error{"NoSuchUpload", "The specified multipart upload does not exist", http.StatusNotFound}
+	errPreconditionFailed                = &s3Error{"PreconditionFailed", "At least one of the preconditions you specified did not hold", http.StatusPreconditionFailed}
+	errRequestTimeTooSkewed              = &s3Error{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large", http.StatusForbidden}
+	errSignatureDoesNotMatch             = &s3Error{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided", http.StatusForbidden}
+)
+
+// errorResponse is the XML body of an error
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string
+	Message   string
+	Resource  string `xml:",omitempty"`
+	RequestID string `xml:"RequestId"`
+}
+
+// owner is returned in bucket and object listings
+type owner struct {
+	ID          string
+	DisplayName string
+}
+
+// defaultOwner is the owner of all buckets and objects
+var defaultOwner = owner{
+	ID:          "rclone",
+	DisplayName: "rclone",
+}
+
+// bucketInfo describes one bucket in listAllMyBucketsResult
+type bucketInfo struct {
+	Name         string
+	CreationDate string
+}
+
+// listAllMyBucketsResult is returned by ListBuckets
+type listAllMyBucketsResult struct {
+	XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Owner   owner        `xml:"Owner"`
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+// objectInfo describes one object in a listing
+type objectInfo struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	Owner        *owner `xml:",omitempty"`
+	StorageClass string
+}
+
+// commonPrefix describes one common prefix in a listing
+type commonPrefix struct {
+	Prefix string
+}
+
+// listBucketResult is returned by ListObjects and ListObjectsV2
+type listBucketResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Xmlns                 string   `xml:"xmlns,attr"`
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty"`
+	EncodingType          string `xml:",omitempty"`
+	MaxKeys               int
+	IsTruncated           bool
+	Contents              []objectInfo
+	CommonPrefixes        []commonPrefix
+	Marker                *string `xml:",omitempty"`
+	NextMarker            string  `xml:",omitempty"`
+	KeyCount              *int    `xml:",omitempty"`
+	ContinuationToken     string  `xml:",omitempty"`
+	NextContinuationToken string  `xml:",omitempty"`
+	StartAfter            string  `xml:",omitempty"`
+}
+
+// copyObjectResult is returned by CopyObject
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	LastModified string
+	ETag         string
+}
+
+// copyPartResult is returned by UploadPartCopy
+type copyPartResult struct {
+	XMLName      xml.Name `xml:"CopyPartResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	LastModified string
+	ETag         string
+}
+
+// objectIdentifier names an object to delete in DeleteObjects
+type objectIdentifier struct {
+	Key string
+}
+
+// deleteRequest is the body of a DeleteObjects request
+type deleteRequest struct {
+	XMLName xml.Name           `xml:"Delete"`
+	Quiet   bool               `xml:"Quiet"`
+	Objects []objectIdentifier `xml:"Object"`
+}
+
+// deletedObject describes a successfully deleted object
+type deletedObject struct {
+	Key string
+}
+
+// deleteError describes an object which couldn't be deleted
+type deleteError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+// deleteResult is returned by DeleteObjects
+type deleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+// initiateMultipartUploadResult is returned by CreateMultipartUpload
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string
+	Key      string
+	UploadID string `xml:"UploadId"`
+}
+
+// completedPart is a part named in CompleteMultipartUpload
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// completeMultipartUploadRequest is the body of a CompleteMultipartUpload request
+type completeMultipartUploadRequest struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// completeMultipartUploadResult is returned by CompleteMultipartUpload
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+// partInfo describes an uploaded part in ListParts
+type partInfo struct {
+	PartNumber   int
+	LastModified string
+	ETag         string
+	Size         int64
+}
+
+// listPartsResult is returned by ListParts
+type listPartsResult struct {
+	XMLName              xml.Name `xml:"ListPartsResult"`
+	Xmlns                string   `xml:"xmlns,attr"`
+	Bucket               string
+	Key                  string
+	UploadID             string `xml:"UploadId"`
+	PartNumberMarker     int
+	NextPartNumberMarker int
+	MaxParts             int
+	IsTruncated          bool
+	Parts                []partInfo `xml:"Part"`
+}
+
+// uploadInfo describes an upload in ListMultipartUploads
+type uploadInfo struct {
+	Key       string
+	UploadID  string `xml:"UploadId"`
+	Initiated string
+}
+
+// listMultipartUploadsResult is returned by ListMultipartUploads
+type listMultipartUploadsResult struct {
+	XMLName            xml.Name `xml:"ListMultipartUploadsResult"`
+	Xmlns              string   `xml:"xmlns,attr"`
+	Bucket             string
+	KeyMarker          string
+	UploadIDMarker     string `xml:"UploadIdMarker"`
+	NextKeyMarker      string
+	NextUploadIDMarker string `xml:"NextUploadIdMarker"`
+	Prefix             string
+	MaxUploads         int
+	IsTruncated        bool
+	Uploads            []uploadInfo `xml:"Upload"`
+}
+
+// locationConstraint is returned by GetBucketLocation
+type locationConstraint struct {
+	XMLName  xml.Name `xml:"LocationConstraint"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:",chardata"`
+}
+
+// formatTime formats t as used in XML responses
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// writeXML writes v as the XML body of the response
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		fs.Errorf(nil, "s3: failed to marshal XML response: %v", err)
+		status = http.StatusInternalServerError
+		body = nil
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(body)
+}
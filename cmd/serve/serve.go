@@ -9,7 +9,9 @@ import (
 	"github.com/rclone/rclone/cmd/serve/docker"
 	"github.com/rclone/rclone/cmd/serve/ftp"
 	"github.com/rclone/rclone/cmd/serve/http"
+	"github.com/rclone/rclone/cmd/serve/nfs"
 	"github.com/rclone/rclone/cmd/serve/restic"
+	"github.com/rclone/rclone/cmd/serve/s3"
 	"github.com/rclone/rclone/cmd/serve/sftp"
 	"github.com/rclone/rclone/cmd/serve/webdav"
 	"github.com/spf13/cobra"
@@ -17,6 +19,7 @@ import (
 
 func init() {
 	Command.AddCommand(http.Command)
+	Command.AddCommand(s3.Command)
 	if webdav.Command != nil {
 		Command.AddCommand(webdav.Command)
 	}
@@ -32,6 +35,7 @@ func init() {
 	if sftp.Command != nil {
 		Command.AddCommand(sftp.Command)
 	}
+	Command.AddCommand(nfs.Command)
 	if docker.Command != nil {
 		Command.AddCommand(docker.Command)
 	}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"strings"
 )
 
 func main() {
@@ -28,6 +29,14 @@ func main() {
 		"type":     "local",
 		"_root":    root,
 		"_obscure": "pass",
+		"_secret":  in["user"] + "-secret",
+	}
+	switch {
+	case strings.HasPrefix(in["user"], "readonly"):
+		out["_permissions"] = "read"
+	case strings.HasPrefix(in["user"], "noperms"):
+	default:
+		out["_permissions"] = "write"
 	}
 	json.NewEncoder(os.Stdout).Encode(&out)
 	if err != nil {
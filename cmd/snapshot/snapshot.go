@@ -0,0 +1,94 @@
+// Package snapshot provides the snapshot command.
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keep sync.KeepOpt
+)
+
+func init() {
+	cmd.Root.AddCommand(snapshotCommand)
+	snapshotCommand.AddCommand(listCommand)
+	snapshotCommand.AddCommand(pruneCommand)
+	cmdFlags := pruneCommand.Flags()
+	flags.IntVarP(cmdFlags, &keep.Daily, "keep-daily", "", keep.Daily, "Keep the newest snapshot for this many days")
+	flags.IntVarP(cmdFlags, &keep.Weekly, "keep-weekly", "", keep.Weekly, "Keep the newest snapshot for this many weeks")
+}
+
+var snapshotCommand = &cobra.Command{
+	Use:   "snapshot <action> remote:path",
+	Short: `List and prune the snapshots made by sync --snapshot.`,
+	Long: `List and prune the snapshots made by ` + "`rclone sync --snapshot`" + `.
+
+The snapshots are the directories in remote:path named after the time
+they were taken, for example ` + "`2023-06-01T093000Z`" + `. Other
+directories in remote:path are ignored, as are the incomplete snapshots
+whose names end in ` + "`.partial`" + `.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.64",
+	},
+}
+
+var listCommand = &cobra.Command{
+	Use:   "list remote:path",
+	Short: `List the snapshots in remote:path.`,
+	Long: `List the snapshots in remote:path, oldest first.
+
+Each line has the name of the snapshot directory followed by the
+time it was taken in the local time zone.
+
+    rclone snapshot list remote:backup
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		fsrc := cmd.NewFsDir(args)
+		cmd.Run(false, false, command, func() error {
+			snapshots, err := sync.ListSnapshots(context.Background(), fsrc)
+			if err != nil {
+				return err
+			}
+			for _, snapshot := range snapshots {
+				fmt.Printf("%s  %s\n", snapshot.Name, snapshot.Time.Local().Format("2006-01-02 15:04:05"))
+			}
+			return nil
+		})
+	},
+}
+
+var pruneCommand = &cobra.Command{
+	Use:   "prune remote:path",
+	Short: `Remove old snapshots from remote:path.`,
+	Long: `Remove the snapshots in remote:path which aren't kept by
+` + "`--keep-daily`" + ` and ` + "`--keep-weekly`" + `.
+
+The newest snapshot is kept for each of the N most recent days (or ISO
+weeks) which have snapshots, and the newest snapshot is always kept.
+At least one of the flags must be set.
+
+    rclone snapshot prune --keep-daily 7 --keep-weekly 8 remote:backup
+
+Use ` + "`--dry-run`" + ` to see which snapshots would be removed.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		fdst := cmd.NewFsDir(args)
+		cmd.Run(true, false, command, func() error {
+			if keep.IsZero() {
+				return errors.New("need --keep-daily or --keep-weekly")
+			}
+			_, err := sync.PruneSnapshots(context.Background(), fdst, keep)
+			return err
+		})
+	},
+}
@@ -3,6 +3,7 @@ package sync
 
 import (
 	"context"
+	"errors"
 
 	"github.com/rclone/rclone/cmd"
 	"github.com/rclone/rclone/fs/config/flags"
@@ -13,12 +14,17 @@ import (
 
 var (
 	createEmptySrcDirs = false
+	snapshot           = false
+	keep               sync.KeepOpt
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs on destination after sync")
+	flags.BoolVarP(cmdFlags, &snapshot, "snapshot", "", snapshot, "Sync to a new timestamped snapshot directory in the destination")
+	flags.IntVarP(cmdFlags, &keep.Daily, "keep-daily", "", keep.Daily, "With --snapshot keep the newest snapshot for this many days")
+	flags.IntVarP(cmdFlags, &keep.Weekly, "keep-weekly", "", keep.Weekly, "With --snapshot keep the newest snapshot for this many weeks")
 }
 
 var commandDefinition = &cobra.Command{
@@ -59,11 +65,48 @@ destination that is inside the source directory.
 
 **Note**: Use the ` + "`rclone dedupe`" + ` command to deal with "Duplicate object/directory found in source/destination - ignoring" errors.
 See [this forum post](https://forum.rclone.org/t/sync-not-clearing-duplicates/14372) for more info.
+
+### Snapshots
+
+With ` + "`--snapshot`" + ` each sync is made to a new directory in dest:path named
+after the time in UTC, for example ` + "`2023-06-01T093000Z`" + `, so dest:path
+keeps a history of the source.
+
+    rclone sync --snapshot /home/user remote:backup
+
+Files which haven't changed since the previous snapshot are copied
+from it with server-side copy (as with ` + "`--copy-dest`" + `) so only the changed
+files are uploaded. On remotes without server-side copy all the files
+are uploaded each time.
+
+The snapshot is made in a directory with ` + "`.partial`" + ` added to its name
+which is renamed once the sync has succeeded. If the sync fails then
+the next run (or retry) completes the incomplete snapshot instead of
+starting a new one. ` + "`--copy-dest`" + ` and ` + "`--compare-dest`" + ` can't be used
+with ` + "`--snapshot`" + `.
+
+Use ` + "`--keep-daily N`" + ` and ` + "`--keep-weekly N`" + ` to remove old snapshots after
+a successful sync. The newest snapshot is kept for each of the N most
+recent days (or ISO weeks) which have snapshots. The newest snapshot is
+always kept, and if neither flag is set no snapshots are removed.
+
+Use the [snapshot](/commands/rclone_snapshot/) command to list and
+prune the snapshots.
 `,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(2, 2, command, args)
 		fsrc, srcFileName, fdst := cmd.NewFsSrcFileDst(args)
 		cmd.Run(true, true, command, func() error {
+			if snapshot {
+				if srcFileName != "" {
+					return errors.New("--snapshot needs a directory as the source")
+				}
+				_, err := sync.SnapshotSync(context.Background(), fdst, fsrc, createEmptySrcDirs, keep)
+				return err
+			}
+			if !keep.IsZero() {
+				return errors.New("--keep-daily and --keep-weekly need --snapshot")
+			}
 			if srcFileName == "" {
 				return sync.Sync(context.Background(), fdst, fsrc, createEmptySrcDirs)
 			}
@@ -0,0 +1,137 @@
+// Package uploads provides the uploads command.
+package uploads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+var (
+	minAge fs.Duration
+)
+
+func init() {
+	cmd.Root.AddCommand(uploadsCommand)
+	uploadsCommand.AddCommand(listCommand)
+	uploadsCommand.AddCommand(abortCommand)
+	cmdFlags := abortCommand.Flags()
+	flags.FVarP(cmdFlags, &minAge, "min-age", "", "Only abort uploads which haven't been updated for this long")
+}
+
+var uploadsCommand = &cobra.Command{
+	Use:   "uploads <action> [remote:path]",
+	Short: `List and abort the uploads recorded by --resume-uploads.`,
+	Long: `List and abort the interrupted uploads recorded by ` + "`--resume-uploads`" + `.
+
+When ` + "`--resume-uploads`" + ` is in use rclone records the multi-thread
+uploads it starts so that if they are interrupted they can be resumed
+by the next copy or sync of the same file. Uploads which are never
+resumed leave their parts on the remote, which may be charged for, so
+use these commands to find and remove them.
+`,
+	Annotations: map[string]string{
+		"versionIntroduced": "v1.64",
+	},
+}
+
+// filterRecords returns the records matching the command line
+func filterRecords(recs []operations.ResumeRecord, args []string) (out []operations.ResumeRecord) {
+	prefix := ""
+	if len(args) > 0 {
+		prefix = strings.TrimRight(args[0], "/")
+	}
+	for _, rec := range recs {
+		key := rec.Key()
+		if prefix != "" && key != prefix && !strings.HasPrefix(key, prefix+"/") {
+			continue
+		}
+		if minAge > 0 && time.Since(rec.Updated) < time.Duration(minAge) {
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+var listCommand = &cobra.Command{
+	Use:   "list [remote:path]",
+	Short: `List the uploads which can be resumed.`,
+	Long: `List the uploads which can be resumed, oldest first, optionally
+only those in remote:path.
+
+Each line shows the time the upload was started, the time it was last
+updated, how much of it was uploaded and the path being uploaded to.
+
+    rclone uploads list
+    rclone uploads list s3:bucket/path
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(0, 1, command, args)
+		cmd.Run(false, false, command, func() error {
+			recs, err := operations.ListResumableUploads(context.Background())
+			if err != nil {
+				return err
+			}
+			for _, rec := range filterRecords(recs, args) {
+				done := int64(len(rec.Done)) * rec.ChunkSize
+				if done > rec.Size {
+					done = rec.Size
+				}
+				fmt.Printf("%s  %s  %9v/%-9v  %s\n",
+					rec.Started.Local().Format("2006-01-02 15:04:05"),
+					rec.Updated.Local().Format("2006-01-02 15:04:05"),
+					fs.SizeSuffix(done), fs.SizeSuffix(rec.Size), rec.Key())
+			}
+			return nil
+		})
+	},
+}
+
+var abortCommand = &cobra.Command{
+	Use:   "abort [remote:path]",
+	Short: `Abort uploads, removing the parts already uploaded.`,
+	Long: `Abort the uploads in remote:path, or the ones which haven't been
+updated for ` + "`--min-age`" + `, removing the parts already uploaded and
+forgetting about them. At least one of these must be given.
+
+    rclone uploads abort --min-age 7d
+    rclone uploads abort s3:bucket/path
+
+Use ` + "`--dry-run`" + ` to see which uploads would be aborted.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(0, 1, command, args)
+		cmd.Run(false, false, command, func() error {
+			if len(args) == 0 && minAge <= 0 {
+				return errors.New("need remote:path or --min-age")
+			}
+			ctx := context.Background()
+			recs, err := operations.ListResumableUploads(ctx)
+			if err != nil {
+				return err
+			}
+			var errCount int
+			for _, rec := range filterRecords(recs, args) {
+				err = operations.AbortResumableUpload(ctx, rec)
+				if err != nil {
+					errCount++
+					continue
+				}
+				fs.Infof(rec.Key(), "Aborted upload")
+			}
+			if errCount > 0 {
+				return fmt.Errorf("failed to abort %d uploads", errCount)
+			}
+			return nil
+		})
+	},
+}
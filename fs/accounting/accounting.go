@@ -61,7 +61,8 @@ type Account struct {
 	exit    chan struct{} // channel that will be closed when transfer is finished
 	withBuf bool          // is using a buffered in
 
-	tokenBucket buckets // per file bandwidth limiter (may be nil)
+	tokenBucket buckets   // per file bandwidth limiter (may be nil)
+	bwLimits    []bwLimit // per remote and per group bandwidth limiters
 
 	values accountValues
 }
@@ -326,6 +327,9 @@ func (acc *Account) accountRead(n int) {
 
 	TokenBucket.LimitBandwidth(TokenBucketSlotAccounting, n)
 	acc.limitPerFileBandwidth(n)
+	for _, l := range acc.bwLimits {
+		l.limiter.limit(l.slot, n)
+	}
 }
 
 // read bytes from the io.Reader passed in and account them
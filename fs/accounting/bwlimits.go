@@ -0,0 +1,316 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// bwLimiter holds the upload and download bandwidth limits for a
+// remote or a stats group
+type bwLimiter struct {
+	mu    sync.RWMutex // protects the variables below
+	bw    fs.BwPair    // the limit the token buckets were made with
+	fixed bool         // set if the limit was set with the rc so shouldn't be changed by the config
+	tbs   buckets      // only the Tx and Rx slots are used
+}
+
+// update sets the limit to bw unless it was set with the rc
+func (l *bwLimiter) update(bw fs.BwPair) {
+	l.mu.RLock()
+	unchanged := l.fixed || l.bw == bw
+	l.mu.RUnlock()
+	if unchanged {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.fixed {
+		l._set(bw)
+	}
+}
+
+// set the limit to bw
+//
+// If fixed is set then the config won't change it afterwards
+func (l *bwLimiter) set(bw fs.BwPair, fixed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fixed = fixed
+	l._set(bw)
+}
+
+// set the limit to bw - call with lock held
+func (l *bwLimiter) _set(bw fs.BwPair) {
+	l.bw = bw
+	if bw.IsSet() {
+		l.tbs = newTokenBucket(bw)
+	} else {
+		l.tbs._setOff()
+	}
+}
+
+// limit sleeps for the correct amount of time for the passage of n
+// bytes in direction i which should be TokenBucketSlotTransportTx or
+// TokenBucketSlotTransportRx
+func (l *bwLimiter) limit(i TokenBucketSlot, n int) {
+	l.mu.RLock()
+	tb := l.tbs[i]
+	l.mu.RUnlock()
+	if tb != nil {
+		err := tb.WaitN(context.Background(), n)
+		if err != nil {
+			fs.Errorf(nil, "Token bucket error: %v", err)
+		}
+	}
+}
+
+// rcParams returns the current limit in the same form as core/bwlimit
+func (l *bwLimiter) rcParams() rc.Params {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var bp = fs.BwPair{Tx: -1, Rx: -1}
+	if l.tbs[TokenBucketSlotTransportTx] != nil {
+		bp.Tx = fs.SizeSuffix(l.tbs[TokenBucketSlotTransportTx].Limit())
+	}
+	if l.tbs[TokenBucketSlotTransportRx] != nil {
+		bp.Rx = fs.SizeSuffix(l.tbs[TokenBucketSlotTransportRx].Limit())
+	}
+	return rc.Params{
+		"rate":             bp.String(),
+		"bytesPerSecondTx": int64(bp.Tx),
+		"bytesPerSecondRx": int64(bp.Rx),
+		"fixed":            l.fixed,
+	}
+}
+
+// remoteBwLimits holds the bandwidth limiters for each remote by
+// config section name
+var remoteBwLimits = struct {
+	mu sync.Mutex
+	m  map[string]*bwLimiter
+}{
+	m: map[string]*bwLimiter{},
+}
+
+// remoteName returns the name of the config section of f
+func remoteName(f fs.Info) string {
+	name := f.Name()
+	// Remove the suffix added for overridden config
+	if i := strings.IndexRune(name, '{'); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// getRemoteBwLimiter returns the bandwidth limiter for the remote
+// called name, making it if necessary
+func getRemoteBwLimiter(name string) *bwLimiter {
+	remoteBwLimits.mu.Lock()
+	defer remoteBwLimits.mu.Unlock()
+	l := remoteBwLimits.m[name]
+	if l == nil {
+		l = &bwLimiter{}
+		remoteBwLimits.m[name] = l
+	}
+	return l
+}
+
+// remoteBwLimiter returns the bandwidth limiter for f, updated from
+// the bwlimit key in its config section.
+func remoteBwLimiter(f fs.Info) *bwLimiter {
+	name := remoteName(f)
+	l := getRemoteBwLimiter(name)
+	var bw fs.BwPair
+	if value, found := fs.ConfigFileGetInherited(name, "bwlimit"); found {
+		var tt fs.BwTimetable
+		err := tt.Set(value)
+		if err != nil {
+			fs.Errorf(nil, "Bad bwlimit in config for remote %q: %v", name, err)
+			return l
+		}
+		bw = tt.LimitAt(time.Now()).Bandwidth
+	}
+	l.update(bw)
+	return l
+}
+
+// bwLimit is a bandwidth limiter to apply to the bytes of an Account
+// in one direction
+type bwLimit struct {
+	limiter *bwLimiter
+	slot    TokenBucketSlot
+}
+
+// bwLimits returns the bandwidth limits to apply to a transfer in
+// this group from srcFs to dstFs, either of which may be nil.
+//
+// The bytes count as downloaded from srcFs and uploaded to dstFs, and
+// for the group limit as downloaded or uploaded if srcFs or dstFs
+// isn't local.
+func (s *StatsInfo) bwLimits(srcFs, dstFs fs.Info) (limits []bwLimit) {
+	s.bwLimit.update(s.ci.BwLimitGroup.LimitAt(time.Now()).Bandwidth)
+	if srcFs != nil {
+		limits = append(limits, bwLimit{limiter: remoteBwLimiter(srcFs), slot: TokenBucketSlotTransportRx})
+		if !srcFs.Features().IsLocal {
+			limits = append(limits, bwLimit{limiter: &s.bwLimit, slot: TokenBucketSlotTransportRx})
+		}
+	}
+	if dstFs != nil {
+		limits = append(limits, bwLimit{limiter: remoteBwLimiter(dstFs), slot: TokenBucketSlotTransportTx})
+		if !dstFs.Features().IsLocal {
+			limits = append(limits, bwLimit{limiter: &s.bwLimit, slot: TokenBucketSlotTransportTx})
+		}
+	}
+	return limits
+}
+
+// parseRate parses the rate parameter which must be a single
+// bandwidth limit
+func parseRate(in rc.Params) (bw fs.BwPair, err error) {
+	rate, err := in.GetString("rate")
+	if err != nil {
+		return bw, err
+	}
+	var bws fs.BwTimetable
+	err = bws.Set(rate)
+	if err != nil {
+		return bw, fmt.Errorf("bad bwlimit: %w", err)
+	}
+	if len(bws) != 1 {
+		return bw, errors.New("need exactly 1 bandwidth setting")
+	}
+	return bws[0].Bandwidth, nil
+}
+
+// read and set the per remote and per group bandwidth limits
+func rcBwlimits(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	remote, err := in.GetString("remote")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	group, err := in.GetString("group")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	var l *bwLimiter
+	switch {
+	case remote != "" && group != "":
+		return nil, errors.New("can't set both remote and group")
+	case remote != "":
+		l = getRemoteBwLimiter(strings.TrimSuffix(remote, ":"))
+	case group != "":
+		stats := groups.get(group)
+		if stats == nil {
+			return nil, fmt.Errorf("group %q not found", group)
+		}
+		l = &stats.bwLimit
+	}
+	if l == nil {
+		if in["rate"] != nil {
+			return nil, errors.New("need remote or group to set the rate")
+		}
+		return listBwLimits(), nil
+	}
+	if in["rate"] != nil {
+		bw, err := parseRate(in)
+		if err != nil {
+			return nil, err
+		}
+		l.set(bw, true)
+		if remote != "" {
+			fs.Logf(nil, "Bandwidth limit for remote %q set to %v", remote, &bw)
+		} else {
+			fs.Logf(nil, "Bandwidth limit for group %q set to %v", group, &bw)
+		}
+	}
+	return l.rcParams(), nil
+}
+
+// listBwLimits returns the per remote and per group bandwidth limits
+func listBwLimits() rc.Params {
+	remotes := rc.Params{}
+	remoteBwLimits.mu.Lock()
+	for name, l := range remoteBwLimits.m {
+		remotes[name] = l.rcParams()
+	}
+	remoteBwLimits.mu.Unlock()
+	groupLimits := rc.Params{}
+	for _, name := range groups.names() {
+		if stats := groups.get(name); stats != nil {
+			groupLimits[name] = stats.bwLimit.rcParams()
+		}
+	}
+	return rc.Params{
+		"remotes": remotes,
+		"groups":  groupLimits,
+	}
+}
+
+// Remote control for the per remote and per group bandwidth limits
+func init() {
+	rc.Add(rc.Call{
+		Path:  "core/bwlimits",
+		Fn:    rcBwlimits,
+		Title: "Read or set the bandwidth limits for a remote or a group.",
+		Help: `
+This reads or sets the bandwidth limits for the transfers to and from
+a remote, or in a stats group, eg an rc job.
+
+Parameters
+
+- remote - name of the remote, eg "s3" (optional)
+- group - name of the stats group, eg "job/1" (optional)
+- rate - the bandwidth limit to set (optional)
+
+If neither remote or group is passed in, then the limits of all the
+remotes used and all the groups are returned
+
+    rclone rc core/bwlimits
+    {
+        "groups": {
+            "job/1": {
+                "bytesPerSecondRx": -1,
+                "bytesPerSecondTx": 1048576,
+                "fixed": false,
+                "rate": "1Mi:off"
+            }
+        },
+        "remotes": {
+            "s3": {
+                "bytesPerSecondRx": 524288,
+                "bytesPerSecondTx": 1048576,
+                "fixed": true,
+                "rate": "1Mi:512Ki"
+            }
+        }
+    }
+
+If remote or group is passed in then the limit for just that one is
+returned, after setting it to rate if that is passed in too
+
+    rclone rc core/bwlimits remote=s3 rate=1M:512k
+    {
+        "bytesPerSecondRx": 524288,
+        "bytesPerSecondTx": 1048576,
+        "fixed": true,
+        "rate": "1Mi:512Ki"
+    }
+
+The format of rate is exactly the same as passed to --bwlimit except
+only one bandwidth may be specified. The first bandwidth is the upload
+limit and the second the download limit.
+
+The limits for a remote are read from the "bwlimit" key in its config
+section and the limits for a group from the BwLimitGroup config
+option, which can be set with _config when starting a job. A limit
+set with this call is marked as "fixed" and overrides those.
+`,
+	})
+}
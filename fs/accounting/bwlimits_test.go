@@ -0,0 +1,127 @@
+package accounting
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setBwLimitConfig makes the config file return the bwlimit passed in
+// for each remote, undoing it at the end of the test
+func setBwLimitConfig(t *testing.T, limits map[string]string) {
+	oldConfigFileGet := fs.ConfigFileGet
+	fs.ConfigFileGet = func(section, key string) (string, bool) {
+		if key != "bwlimit" {
+			return "", false
+		}
+		value, found := limits[section]
+		return value, found
+	}
+	remoteBwLimits.mu.Lock()
+	remoteBwLimits.m = map[string]*bwLimiter{}
+	remoteBwLimits.mu.Unlock()
+	t.Cleanup(func() {
+		fs.ConfigFileGet = oldConfigFileGet
+	})
+}
+
+func TestBwLimits(t *testing.T) {
+	ctx := context.Background()
+	setBwLimitConfig(t, map[string]string{
+		"remote1": "1M:512k",
+		"remote2": "2M",
+	})
+	ctx, ci := fs.AddConfig(ctx)
+	require.NoError(t, ci.BwLimitGroup.Set("4M:off"))
+	stats := NewStats(ctx)
+
+	remote1 := mockfs.NewFs(ctx, "remote1", "")
+	remote2 := mockfs.NewFs(ctx, "remote2{AbCdE}", "")
+	local := mockfs.NewFs(ctx, "local", "")
+	local.Features().IsLocal = true
+
+	// Remote to remote uses both the remote limits and the group
+	// limit in both directions
+	limits := stats.bwLimits(remote1, remote2)
+	require.Len(t, limits, 4)
+	assert.Equal(t, TokenBucketSlotTransportRx, limits[0].slot)
+	assert.Equal(t, "1Mi:512Ki", limits[0].limiter.rcParams()["rate"])
+	assert.Equal(t, &stats.bwLimit, limits[1].limiter)
+	assert.Equal(t, TokenBucketSlotTransportRx, limits[1].slot)
+	assert.Equal(t, TokenBucketSlotTransportTx, limits[2].slot)
+	assert.Equal(t, "2Mi", limits[2].limiter.rcParams()["rate"])
+	assert.Equal(t, &stats.bwLimit, limits[3].limiter)
+	assert.Equal(t, TokenBucketSlotTransportTx, limits[3].slot)
+	assert.Equal(t, "4Mi:off", stats.bwLimit.rcParams()["rate"])
+
+	// Local to remote only uses the group limit for the upload
+	limits = stats.bwLimits(local, remote2)
+	require.Len(t, limits, 3)
+	assert.Equal(t, "off", limits[0].limiter.rcParams()["rate"])
+	assert.Equal(t, TokenBucketSlotTransportTx, limits[1].slot)
+	assert.Equal(t, TokenBucketSlotTransportTx, limits[2].slot)
+
+	// Unknown destination
+	limits = stats.bwLimits(remote1, nil)
+	require.Len(t, limits, 2)
+
+	// Changes in the config are picked up
+	setBwLimitConfig(t, map[string]string{
+		"remote1": "off",
+	})
+	limits = stats.bwLimits(remote1, nil)
+	assert.Equal(t, "off", limits[0].limiter.rcParams()["rate"])
+}
+
+func TestRcBwLimits(t *testing.T) {
+	ctx := context.Background()
+	setBwLimitConfig(t, map[string]string{
+		"remote1": "1M",
+	})
+	call := rc.Calls.Get("core/bwlimits")
+	require.NotNil(t, call)
+
+	// Set the limit for a remote
+	out, err := call.Fn(ctx, rc.Params{"remote": "remote1:", "rate": "10M:1M"})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"bytesPerSecondTx": int64(10485760),
+		"bytesPerSecondRx": int64(1048576),
+		"rate":             "10Mi:1Mi",
+		"fixed":            true,
+	}, out)
+
+	// The config doesn't override the limit set with the rc
+	remote1 := mockfs.NewFs(ctx, "remote1", "")
+	l := remoteBwLimiter(remote1)
+	assert.Equal(t, "10Mi:1Mi", l.rcParams()["rate"])
+
+	// Set the limit for a group
+	stats := StatsGroup(ctx, "test-bwlimits")
+	defer groups.delete("test-bwlimits")
+	out, err = call.Fn(ctx, rc.Params{"group": "test-bwlimits", "rate": "off"})
+	require.NoError(t, err)
+	assert.Equal(t, "off", out["rate"])
+	assert.Equal(t, true, stats.bwLimit.fixed)
+
+	// List the limits
+	out, err = call.Fn(ctx, rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, "10Mi:1Mi", out["remotes"].(rc.Params)["remote1"].(rc.Params)["rate"])
+	assert.Equal(t, "off", out["groups"].(rc.Params)["test-bwlimits"].(rc.Params)["rate"])
+
+	// Errors
+	_, err = call.Fn(ctx, rc.Params{"group": "not-found"})
+	assert.Error(t, err)
+	_, err = call.Fn(ctx, rc.Params{"rate": "1M"})
+	assert.Error(t, err)
+	_, err = call.Fn(ctx, rc.Params{"remote": "remote1", "group": "test-bwlimits"})
+	assert.Error(t, err)
+	_, err = call.Fn(ctx, rc.Params{"remote": "remote1", "rate": "08:00,1M 12:00,off"})
+	assert.Error(t, err)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/pacer"
 	"github.com/rclone/rclone/lib/terminal"
 )
 
@@ -58,6 +59,8 @@ type StatsInfo struct {
 	group             string
 	startTime         time.Time // the moment these stats were initialized or reset
 	average           averageValues
+	adaptive          *pacer.Adaptive // controls the number of transfers with --adaptive-transfers
+	bwLimit           bwLimiter       // bandwidth limit for the transfers in this group
 }
 
 type averageValues struct {
@@ -127,10 +130,28 @@ func (s *StatsInfo) RemoteStats() (out rc.Params, err error) {
 	if s.errors > 0 {
 		out["lastError"] = s.lastError.Error()
 	}
+	if adaptive := s.getAdaptive(); adaptive != nil {
+		out["adaptiveTransfers"] = adaptive.Stats()
+	}
 
 	return out, nil
 }
 
+// SetAdaptive sets the controller for the number of transfers to
+// show in the stats
+func (s *StatsInfo) SetAdaptive(adaptive *pacer.Adaptive) {
+	s.mu.Lock()
+	s.adaptive = adaptive
+	s.mu.Unlock()
+}
+
+// getAdaptive returns the controller for the number of transfers or nil
+func (s *StatsInfo) getAdaptive() *pacer.Adaptive {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.adaptive
+}
+
 // speed returns the average speed of the transfer in bytes/second
 //
 // Call with lock held
@@ -660,6 +681,7 @@ func (s *StatsInfo) ResetCounters() {
 	s.renames = 0
 	s.startedTransfers = nil
 	s.oldDuration = 0
+	s.adaptive = nil
 
 	s.stopAverageLoop()
 	s.average = averageValues{stop: make(chan bool)}
@@ -747,6 +769,18 @@ func (s *StatsInfo) NewTransfer(obj fs.DirEntry) *Transfer {
 	return tr
 }
 
+// NewTransferTo adds a transfer of obj to the Fs f to the stats.
+//
+// This is the same as NewTransfer except that the upload bandwidth
+// limits for f are applied too.
+func (s *StatsInfo) NewTransferTo(obj fs.DirEntry, f fs.Info) *Transfer {
+	tr := newTransfer(s, obj)
+	tr.dstFs = f
+	s.transferring.add(tr)
+	s.startAverageLoop()
+	return tr
+}
+
 // NewTransferRemoteSize adds a transfer to the stats based on remote and size.
 func (s *StatsInfo) NewTransferRemoteSize(remote string, size int64) *Transfer {
 	tr := newTransferRemoteSize(s, remote, size, false, "")
@@ -770,6 +804,40 @@ func (s *StatsInfo) DoneTransferring(remote string, ok bool) {
 	}
 }
 
+// TransferDoneFn is called with the name of the stats group and a
+// snapshot of each transfer or check when it is done
+type TransferDoneFn func(group string, tr TransferSnapshot)
+
+var (
+	transferDoneMu sync.RWMutex
+	transferDoneFn TransferDoneFn
+)
+
+// SetTransferDone sets fn to be called when each transfer or check in
+// any stats group is done, or removes it if fn is nil.
+//
+// This can be used to collect all the transfers as Transferred only
+// returns the last MaxCompletedTransfers of them.
+func SetTransferDone(fn TransferDoneFn) {
+	transferDoneMu.Lock()
+	transferDoneFn = fn
+	transferDoneMu.Unlock()
+}
+
+// transferDone calls the TransferDoneFn if set
+func (s *StatsInfo) transferDone(tr *Transfer) {
+	transferDoneMu.RLock()
+	fn := transferDoneFn
+	transferDoneMu.RUnlock()
+	if fn == nil {
+		return
+	}
+	s.mu.RLock()
+	group := s.group
+	s.mu.RUnlock()
+	fn(group, tr.Snapshot())
+}
+
 // SetCheckQueue sets the number of queued checks
 func (s *StatsInfo) SetCheckQueue(n int, size int64) {
 	s.mu.Lock()
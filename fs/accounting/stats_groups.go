@@ -87,6 +87,15 @@ Returns the following values:
 
 ` + "```" + `
 {
+	"adaptiveTransfers": the state of --adaptive-transfers:
+		{
+			"limit": number of transfers currently allowed,
+			"min": minimum number of transfers,
+			"max": maximum number of transfers,
+			"active": number of transfers in progress,
+			"increases": times the limit was increased,
+			"decreases": times the limit was decreased
+		},
 	"bytes": total transferred bytes since the start of the group,
 	"checks": number of files checked,
 	"deletes" : number of files deleted,
@@ -120,6 +129,7 @@ Returns the following values:
 }
 ` + "```" + `
 Values for "transferring", "checking" and "lastError" are only assigned if data is available.
+The value for "adaptiveTransfers" is only present if --adaptive-transfers is in use.
 The value for "eta" is null if an eta cannot be determined.
 `,
 	})
@@ -285,6 +295,12 @@ func StatsGroup(ctx context.Context, group string) *StatsInfo {
 	return stats
 }
 
+// LookupStatsGroup gets stats by group name or returns nil if the
+// group doesn't exist.
+func LookupStatsGroup(group string) *StatsInfo {
+	return groups.get(group)
+}
+
 // GlobalStats returns special stats used for global accounting.
 func GlobalStats() *StatsInfo {
 	return StatsGroup(context.Background(), globalStats)
@@ -391,6 +407,9 @@ func (sg *statsGroups) sum(ctx context.Context) *StatsInfo {
 			stats.average.mu.Lock()
 			sum.average.speed += stats.average.speed
 			stats.average.mu.Unlock()
+			if sum.adaptive == nil {
+				sum.adaptive = stats.adaptive
+			}
 		}
 		stats.mu.RUnlock()
 	}
@@ -50,7 +50,9 @@ type Transfer struct {
 	size      int64
 	startedAt time.Time
 	checking  bool
-	what      string // what kind of transfer this is
+	what      string  // what kind of transfer this is
+	srcFs     fs.Info // Fs the transfer is from, if known
+	dstFs     fs.Info // Fs the transfer is to, if known
 
 	// Protects all below
 	//
@@ -70,7 +72,11 @@ func newCheckingTransfer(stats *StatsInfo, obj fs.DirEntry, what string) *Transf
 
 // newTransfer instantiates new transfer.
 func newTransfer(stats *StatsInfo, obj fs.DirEntry) *Transfer {
-	return newTransferRemoteSize(stats, obj.Remote(), obj.Size(), false, "")
+	tr := newTransferRemoteSize(stats, obj.Remote(), obj.Size(), false, "")
+	if o, ok := obj.(fs.Object); ok {
+		tr.srcFs = o.Fs()
+	}
+	return tr
 }
 
 func newTransferRemoteSize(stats *StatsInfo, remote string, size int64, checking bool, what string) *Transfer {
@@ -122,6 +128,7 @@ func (tr *Transfer) Done(ctx context.Context, err error) {
 	} else {
 		tr.stats.DoneTransferring(tr.remote, err == nil)
 	}
+	tr.stats.transferDone(tr)
 	tr.stats.PruneTransfers()
 }
 
@@ -146,6 +153,7 @@ func (tr *Transfer) Account(ctx context.Context, in io.ReadCloser) *Account {
 	tr.mu.Lock()
 	if tr.acc == nil {
 		tr.acc = newAccountSizeName(ctx, tr.stats, in, tr.size, tr.remote)
+		tr.acc.bwLimits = tr.stats.bwLimits(tr.srcFs, tr.dstFs)
 	} else {
 		tr.acc.UpdateReader(ctx, in)
 	}
@@ -29,6 +29,14 @@ var (
 		return errors.New("no config file set handler")
 	}
 
+	// Read the secret called name from the secret providers
+	//
+	// This is a function pointer to decouple the secret
+	// providers from the fs
+	ConfigSecretGet = func(name string) (string, error) {
+		return "", errors.New("no secret providers set")
+	}
+
 	// Check if the config file has the named section
 	//
 	// This is a function pointer to decouple the config
@@ -64,6 +72,9 @@ type ConfigInfo struct {
 	ModifyWindow            time.Duration
 	Checkers                int
 	Transfers               int
+	AdaptiveTransfers       bool // vary the number of transfers with the throughput
+	AdaptiveTransfersMin    int
+	AdaptiveTransfersMax    int
 	ConnectTimeout          time.Duration // Connect timeout
 	Timeout                 time.Duration // Data channel timeout
 	ExpectContinueTimeout   time.Duration
@@ -96,6 +107,7 @@ type ConfigInfo struct {
 	BufferSize              SizeSuffix
 	BwLimit                 BwTimetable
 	BwLimitFile             BwTimetable
+	BwLimitGroup            BwTimetable
 	TPSLimit                float64
 	TPSLimitBurst           int
 	BindAddr                net.IP
@@ -107,6 +119,9 @@ type ConfigInfo struct {
 	StatsFileNameLength     int
 	AskPassword             bool
 	PasswordCommand         SpaceSepList
+	SecretCommand           SpaceSepList
+	SecretKeystore          string
+	SecretStore             string
 	UseServerModTime        bool
 	MaxTransfer             SizeSuffix
 	MaxDuration             time.Duration
@@ -127,6 +142,7 @@ type ConfigInfo struct {
 	MultiThreadCutoff       SizeSuffix
 	MultiThreadStreams      int
 	MultiThreadSet          bool   // whether MultiThreadStreams was set (set in fs/config/configflags)
+	ResumeUploads           bool   // record multi-thread uploads so they can be resumed
 	OrderBy                 string // instructions on how to order the transfer
 	UploadHeaders           []*HTTPOption
 	DownloadHeaders         []*HTTPOption
@@ -158,6 +174,8 @@ func NewConfig() *ConfigInfo {
 	c.ModifyWindow = time.Nanosecond
 	c.Checkers = 8
 	c.Transfers = 4
+	c.AdaptiveTransfersMin = 1
+	c.AdaptiveTransfersMax = 64
 	c.ConnectTimeout = 60 * time.Second
 	c.Timeout = 5 * 60 * time.Second
 	c.ExpectContinueTimeout = 1 * time.Second
@@ -113,16 +113,18 @@ var (
 )
 
 var (
-	configPath string
-	cacheDir   string
-	data       Storage
-	dataLoaded bool
+	configPath   string
+	configFormat string
+	cacheDir     string
+	data         Storage
+	dataLoaded   bool
 )
 
 func init() {
 	// Set the function pointers up in fs
 	fs.ConfigFileGet = FileGetFlag
 	fs.ConfigFileSet = SetValueAndSave
+	fs.ConfigSecretGet = GetSecret
 	fs.ConfigFileHasSection = func(section string) bool {
 		return LoadedData().HasSection(section)
 	}
@@ -336,6 +338,26 @@ func SetConfigPath(path string) (err error) {
 	return nil
 }
 
+// GetConfigFormat returns the format of the config file set with
+// SetConfigFormat or "" if it should be worked out from the config
+// path
+func GetConfigFormat() string {
+	return configFormat
+}
+
+// SetConfigFormat sets the format of the config file which should be
+// "ini", "json", "yaml" or "dir" or "" to work it out from the config
+// path
+func SetConfigFormat(format string) error {
+	switch format {
+	case "", "ini", "json", "yaml", "dir":
+	default:
+		return fmt.Errorf("unknown config format %q - must be ini, json, yaml or dir", format)
+	}
+	configFormat = format
+	return nil
+}
+
 // SetData sets new config file storage
 func SetData(newData Storage) {
 	// If no config file, use in-memory config (which is the default)
@@ -397,7 +419,14 @@ func SaveConfig() {
 // SetValueAndSave sets the key to the value and saves just that
 // value in the config file.  It loads the old config file in from
 // disk first and overwrites the given value only.
+//
+// If --secret-store is set then passwords are saved there and a
+// secret:// reference to them is saved in the config instead.
 func SetValueAndSave(name, key, value string) error {
+	value, err := saveSecret(name, key, value)
+	if err != nil {
+		return err
+	}
 	// Set the value in config in case we fail to reload it
 	LoadedData().SetValue(name, key, value)
 	// Save it again
@@ -449,7 +478,7 @@ func updateRemote(ctx context.Context, name string, keyValues rc.Params, opt Upd
 		ctx = suppressConfirm(ctx)
 	}
 
-	fsType := FileGet(name, "type")
+	fsType := FileGetResolved(name, "type")
 	if fsType == "" {
 		return nil, errors.New("couldn't find type field in config")
 	}
@@ -617,6 +646,32 @@ func FileGet(section, key string) string {
 	return getWithDefault(section, key, defaultVal)
 }
 
+// FileGetResolved gets the config key under section like FileGet,
+// reading it from the sections section inherits from if it isn't set
+// there.
+func FileGetResolved(section, key string) string {
+	if value, found := fs.ConfigFileGetInherited(section, key); found {
+		return value
+	}
+	return os.Getenv(fs.ConfigToEnv(section, key))
+}
+
+// FileKeyListResolved returns the keys set in section and the
+// sections it inherits from, without the inherit key itself.
+func FileKeyListResolved(section string) (keys []string) {
+	seen := map[string]struct{}{}
+	for _, name := range fs.ConfigInheritChain(section) {
+		for _, key := range LoadedData().GetKeyList(name) {
+			if _, found := seen[key]; found || key == fs.ConfigInheritKey {
+				continue
+			}
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 // FileSet sets the key in section to value.  It doesn't save
 // the config file.
 func FileSet(section, key, value string) {
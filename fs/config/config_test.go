@@ -5,9 +5,11 @@ package config_test
 import (
 	"testing"
 
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/config/configfile"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConfigLoad(t *testing.T) {
@@ -26,3 +28,29 @@ func TestConfigLoad(t *testing.T) {
 	expect = []string{"type", "nounc"}
 	assert.Equal(t, expect, keys)
 }
+
+func TestConfigInherit(t *testing.T) {
+	defer testConfigFile(t, simpleOptions, "inherit.conf")()
+
+	config.FileSet("base", "type", "config_test_remote")
+	config.FileSet("base", "bool", "true")
+	config.FileSet("base", "pass", "potato")
+	config.FileSet("child", "inherit", "base")
+	config.FileSet("child", "pass", "jersey royal")
+	config.FileSet("child", "extra", "42")
+
+	assert.Equal(t, "", config.FileGet("child", "type"))
+	assert.Equal(t, "config_test_remote", config.FileGetResolved("child", "type"))
+	assert.Equal(t, "true", config.FileGetResolved("child", "bool"))
+	assert.Equal(t, "jersey royal", config.FileGetResolved("child", "pass"))
+	assert.Equal(t, "", config.FileGetResolved("child", "missing"))
+	assert.Equal(t, []string{"pass", "extra", "type", "bool"}, config.FileKeyListResolved("child"))
+
+	// The backend is found from the inherited type
+	ri, _, _, m, err := fs.ConfigFs("child:path")
+	require.NoError(t, err)
+	assert.Equal(t, "config_test_remote", ri.Name)
+	value, ok := m.Get("bool")
+	assert.True(t, ok)
+	assert.Equal(t, "true", value)
+}
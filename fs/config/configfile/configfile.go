@@ -4,6 +4,7 @@ package configfile
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,9 +16,41 @@ import (
 	"github.com/rclone/rclone/lib/file"
 )
 
-// Install installs the config file handler
+// Install installs the config file handler for the format of the
+// config file
 func Install() {
-	config.SetData(&Storage{})
+	switch configFormat() {
+	case "json":
+		config.SetData(NewFileStorage(jsonFormat))
+	case "yaml":
+		config.SetData(NewFileStorage(yamlFormat))
+	case "dir":
+		config.SetData(&DirStorage{})
+	default:
+		config.SetData(&Storage{})
+	}
+}
+
+// configFormat returns the format of the config file set with
+// --config-format or worked out from the config path.
+func configFormat() string {
+	if format := config.GetConfigFormat(); format != "" {
+		return format
+	}
+	configPath := config.GetConfigPath()
+	if configPath == "" {
+		return "ini"
+	}
+	if fi, err := os.Stat(configPath); err == nil && fi.IsDir() {
+		return "dir"
+	}
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return "ini"
 }
 
 // Storage implements config.Storage for saving and loading config
@@ -64,30 +97,45 @@ func (s *Storage) _load() (err error) {
 		return config.ErrorConfigFileNotFound
 	}
 
-	fd, err := os.Open(configPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return config.ErrorConfigFileNotFound
-		}
+	data, fi, err := readFile(configPath)
+	if os.IsNotExist(err) {
+		return config.ErrorConfigFileNotFound
+	} else if err != nil {
 		return err
 	}
-	defer fs.CheckClose(fd, &err)
 
 	// Update s.fi with the current file info
-	s.fi, _ = os.Stat(configPath)
+	s.fi = fi
 
-	cryptReader, err := config.Decrypt(fd)
+	gc, err := goconfig.LoadFromReader(bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
+	s.gc = gc
 
-	gc, err := goconfig.LoadFromReader(cryptReader)
+	return nil
+}
+
+// readFile reads the file at path, decrypting it if necessary, and
+// returns its contents and the file info
+func readFile(path string) (data []byte, fi os.FileInfo, err error) {
+	fd, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	s.gc = gc
+	defer fs.CheckClose(fd, &err)
 
-	return nil
+	fi, _ = os.Stat(path)
+
+	cryptReader, err := config.Decrypt(fd)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = io.ReadAll(cryptReader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, fi, nil
 }
 
 // Load the config from permanent storage, decrypting if necessary
@@ -107,14 +155,36 @@ func (s *Storage) Save() error {
 		return fmt.Errorf("failed to save config file, path is empty")
 	}
 
+	var buf bytes.Buffer
+	if err := goconfig.SaveConfigData(s.gc, &buf); err != nil {
+		return fmt.Errorf("failed to save config file: %w", err)
+	}
+
+	fi, err := writeFile(configPath, &buf)
+	if err != nil {
+		return err
+	}
+
+	// Update s.fi with the newly written file
+	s.fi = fi
+
+	return nil
+}
+
+// writeFile writes the data in buf to the file at path, encrypting
+// it if necessary, returning the new file info.
+//
+// The data is written to a temporary file which is renamed over the
+// original so the file is never left half written.
+func writeFile(configPath string, buf *bytes.Buffer) (os.FileInfo, error) {
 	dir, name := filepath.Split(configPath)
 	err := file.MkdirAll(dir, os.ModePerm)
 	if err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
 	}
 	f, err := os.CreateTemp(dir, name)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file for new config: %w", err)
+		return nil, fmt.Errorf("failed to create temp file for new config: %w", err)
 	}
 	defer func() {
 		_ = f.Close()
@@ -123,19 +193,14 @@ func (s *Storage) Save() error {
 		}
 	}()
 
-	var buf bytes.Buffer
-	if err := goconfig.SaveConfigData(s.gc, &buf); err != nil {
-		return fmt.Errorf("failed to save config file: %w", err)
-	}
-
-	if err := config.Encrypt(&buf, f); err != nil {
-		return err
+	if err := config.Encrypt(buf, f); err != nil {
+		return nil, err
 	}
 
 	_ = f.Sync()
 	err = f.Close()
 	if err != nil {
-		return fmt.Errorf("failed to close config file: %w", err)
+		return nil, fmt.Errorf("failed to close config file: %w", err)
 	}
 
 	var fileMode os.FileMode = 0600
@@ -155,19 +220,17 @@ func (s *Storage) Save() error {
 	}
 
 	if err = os.Rename(configPath, configPath+".old"); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to move previous config to backup location: %w", err)
+		return nil, fmt.Errorf("failed to move previous config to backup location: %w", err)
 	}
 	if err = os.Rename(f.Name(), configPath); err != nil {
-		return fmt.Errorf("failed to move newly written config from %s to final location: %v", f.Name(), err)
+		return nil, fmt.Errorf("failed to move newly written config from %s to final location: %v", f.Name(), err)
 	}
 	if err := os.Remove(configPath + ".old"); err != nil && !os.IsNotExist(err) {
 		fs.Errorf(nil, "Failed to remove backup config file: %v", err)
 	}
 
-	// Update s.fi with the newly written file
-	s.fi, _ = os.Stat(configPath)
-
-	return nil
+	fi, _ := os.Stat(configPath)
+	return fi, nil
 }
 
 // Serialize the config into a string
@@ -0,0 +1,271 @@
+package configfile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+)
+
+// dirFile is a file in the config directory holding a single section
+type dirFile struct {
+	name   string   // file name in the directory
+	format *format  // format of the file
+	saved  *section // section as last loaded or saved
+}
+
+// DirStorage implements config.Storage for saving and loading config
+// data in a directory where each file is one remote.
+//
+// The format of each file is chosen by its extension and the name of
+// the remote is the file name without the extension. Files with
+// unknown extensions are read as INI with the whole file name as the
+// name of the remote. Hidden files and directories are ignored.
+//
+// This is useful for config supplied as one file per remote, for
+// example from a Kubernetes ConfigMap or Secret.
+type DirStorage struct {
+	mu    sync.Mutex          // to protect the following variables
+	mem   memoryStorage       // config loaded
+	files map[string]*dirFile // files the sections were loaded from
+	sig   string              // names, sizes and modtimes of the files when last loaded
+}
+
+// dirEntries returns the files in the config directory which might
+// contain config and a signature to detect changes to them.
+//
+// Symlinks are followed as Kubernetes mounts files in this way.
+func dirEntries(dir string) (fis []os.FileInfo, sig string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	var b strings.Builder
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		fi, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		fis = append(fis, fi)
+		fmt.Fprintf(&b, "%s:%d:%d\n", fi.Name(), fi.Size(), fi.ModTime().UnixNano())
+	}
+	return fis, b.String(), nil
+}
+
+// Check to see if we need to reload the config
+//
+// mu must be held when calling this
+func (s *DirStorage) _check() {
+	if configPath := config.GetConfigPath(); configPath != "" {
+		_, sig, err := dirEntries(configPath)
+		if err == nil && (s.files == nil || sig != s.sig) {
+			fs.Debugf(nil, "Config directory has changed externally - reloading")
+			err := s._load()
+			if err != nil {
+				fs.Errorf(nil, "Failed to read config directory - using previous config: %v", err)
+			}
+		}
+	}
+}
+
+// _load the config from permanent storage, decrypting if necessary
+//
+// mu must be held when calling this
+func (s *DirStorage) _load() (err error) {
+	configPath := config.GetConfigPath()
+	if configPath == "" {
+		return config.ErrorConfigFileNotFound
+	}
+
+	fis, sig, err := dirEntries(configPath)
+	if os.IsNotExist(err) {
+		return config.ErrorConfigFileNotFound
+	} else if err != nil {
+		return err
+	}
+
+	var ss sections
+	files := map[string]*dirFile{}
+	for _, fi := range fis {
+		data, _, err := readFile(filepath.Join(configPath, fi.Name()))
+		if err != nil {
+			return err
+		}
+		f, name := formatForFile(fi.Name())
+		if old, found := files[name]; found {
+			fs.Errorf(nil, "Ignoring config file %q as remote %q already read from %q", fi.Name(), name, old.name)
+			continue
+		}
+		section, err := f.decodeSection(name, data)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %q: %w", fi.Name(), err)
+		}
+		ss = append(ss, section)
+		files[name] = &dirFile{
+			name:   fi.Name(),
+			format: f,
+			saved:  section.copy(),
+		}
+	}
+	s.mem.sections = ss
+	s.files = files
+	s.sig = sig
+
+	return nil
+}
+
+// Load the config from permanent storage, decrypting if necessary
+func (s *DirStorage) Load() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s._load()
+}
+
+// Save the config to permanent storage, encrypting if necessary.
+//
+// Only the files for the remotes which have changed are written, so
+// a read only directory can be used if the config isn't changed.
+func (s *DirStorage) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	configPath := config.GetConfigPath()
+	if configPath == "" {
+		return fmt.Errorf("failed to save config directory, path is empty")
+	}
+	if s.files == nil {
+		s.files = map[string]*dirFile{}
+	}
+
+	for _, section := range s.mem.sections {
+		f := s.files[section.name]
+		if f == nil {
+			f = &dirFile{
+				name:   section.name + iniFormat.extensions[0],
+				format: iniFormat,
+			}
+			s.files[section.name] = f
+		} else if f.saved != nil && f.saved.equal(section) {
+			continue
+		}
+		data, err := f.format.encodeSection(section)
+		if err != nil {
+			return fmt.Errorf("failed to save config file %q: %w", f.name, err)
+		}
+		_, err = writeFile(filepath.Join(configPath, f.name), bytes.NewBuffer(data))
+		if err != nil {
+			return err
+		}
+		f.saved = section.copy()
+	}
+
+	// Remove the files of deleted remotes
+	for name, f := range s.files {
+		if s.mem.hasSection(name) {
+			continue
+		}
+		err := os.Remove(filepath.Join(configPath, f.name))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove config file: %w", err)
+		}
+		delete(s.files, name)
+	}
+
+	// Update s.sig with the newly written files
+	_, s.sig, _ = dirEntries(configPath)
+
+	return nil
+}
+
+// Serialize the config into a string in the INI format
+func (s *DirStorage) Serialize() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	data, err := encodeINI(s.mem.sections)
+	if err != nil {
+		return "", fmt.Errorf("failed to save config file: %w", err)
+	}
+	return string(data), nil
+}
+
+// HasSection returns true if section exists in the config directory
+func (s *DirStorage) HasSection(section string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	return s.mem.hasSection(section)
+}
+
+// DeleteSection removes the named section and all config from the
+// config directory
+func (s *DirStorage) DeleteSection(section string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	s.mem.deleteSection(section)
+}
+
+// GetSectionList returns a slice of strings with names for all the
+// sections
+func (s *DirStorage) GetSectionList() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	return s.mem.sections.names()
+}
+
+// GetKeyList returns the keys in this section
+func (s *DirStorage) GetKeyList(section string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	return s.mem.getKeyList(section)
+}
+
+// GetValue returns the key in section with a found flag
+func (s *DirStorage) GetValue(section string, key string) (value string, found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	return s.mem.getValue(section, key)
+}
+
+// SetValue sets the value under key in section
+func (s *DirStorage) SetValue(section string, key string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	if isOnTheFly(section) {
+		fs.Logf(nil, "Can't save config %q for on the fly backend %q", key, section)
+		return
+	}
+	s.mem.setValue(section, key, value)
+}
+
+// DeleteKey removes the key under section
+func (s *DirStorage) DeleteKey(section string, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	return s.mem.deleteKey(section, key)
+}
+
+// Check the interface is satisfied
+var _ config.Storage = (*DirStorage)(nil)
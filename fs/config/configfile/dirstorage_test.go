@@ -0,0 +1,126 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Make a temporary config directory with the files passed in
+func setConfigDir(t *testing.T, files map[string]string) (dir string, cleanup func()) {
+	dir = t.TempDir()
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0600))
+	}
+	old := config.GetConfigPath()
+	assert.NoError(t, config.SetConfigPath(dir))
+	return dir, func() {
+		assert.NoError(t, config.SetConfigPath(old))
+	}
+}
+
+func TestDirStorage(t *testing.T) {
+	dir, cleanup := setConfigDir(t, map[string]string{
+		"one.conf":   "[one]\ntype = number1\nfruit = potato\n",
+		"two":        "type = number2\nfruit = apple\n",
+		"three.json": `{"type": "number3", "fruit": "banana"}`,
+		"four.yaml":  "type: number4\nfruit: cherry\n",
+		".hidden":    "type = hidden\n",
+	})
+	defer cleanup()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0700))
+	data := &DirStorage{}
+
+	require.NoError(t, data.Load())
+
+	assert.Equal(t, []string{"four", "one", "three", "two"}, data.GetSectionList())
+	assert.Equal(t, []string{"type", "fruit"}, data.GetKeyList("three"))
+	value, ok := data.GetValue("four", "fruit")
+	assert.True(t, ok)
+	assert.Equal(t, "cherry", value)
+	value, ok = data.GetValue("two", "type")
+	assert.True(t, ok)
+	assert.Equal(t, "number2", value)
+
+	buf, err := data.Serialize()
+	require.NoError(t, err)
+	assert.Equal(t, `[four]
+type = number4
+fruit = cherry
+
+[one]
+type = number1
+fruit = potato
+
+[three]
+type = number3
+fruit = banana
+
+[two]
+type = number2
+fruit = apple
+
+`, toUnix(buf))
+
+	// Check the unchanged files aren't written
+	unchanged := filepath.Join(dir, "four.yaml")
+	fi, err := os.Stat(unchanged)
+	require.NoError(t, err)
+
+	data.SetValue("three", "extra", "42")
+	data.SetValue("five", "type", "number5")
+	data.DeleteSection("two")
+	require.NoError(t, data.Save())
+
+	fi2, err := os.Stat(unchanged)
+	require.NoError(t, err)
+	assert.Equal(t, fi.ModTime(), fi2.ModTime())
+	_, err = os.Stat(filepath.Join(dir, "two"))
+	assert.True(t, os.IsNotExist(err))
+	b, err := os.ReadFile(filepath.Join(dir, "three.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"type\": \"number3\",\n  \"fruit\": \"banana\",\n  \"extra\": \"42\"\n}\n", string(b))
+	b, err = os.ReadFile(filepath.Join(dir, "five.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "[five]\ntype = number5\n\n", toUnix(string(b)))
+
+	// Load what we saved into a fresh storage
+	data = &DirStorage{}
+	require.NoError(t, data.Load())
+	assert.Equal(t, []string{"five", "four", "one", "three"}, data.GetSectionList())
+}
+
+func TestDirStorageReload(t *testing.T) {
+	dir, cleanup := setConfigDir(t, map[string]string{
+		"one.conf": "type = number1\n",
+	})
+	defer cleanup()
+	data := &DirStorage{}
+
+	require.NoError(t, data.Load())
+	assert.False(t, data.HasSection("two"))
+
+	// Add a new remote and check we magically reloaded it
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "two.json"), []byte(`{"type": "number2"}`), 0600))
+	value, ok := data.GetValue("two", "type")
+	assert.True(t, ok)
+	assert.Equal(t, "number2", value)
+}
+
+func TestDirStorageDoesNotExist(t *testing.T) {
+	dir, cleanup := setConfigDir(t, nil)
+	defer cleanup()
+	require.NoError(t, config.SetConfigPath(filepath.Join(dir, "missing")))
+	data := &DirStorage{}
+
+	err := data.Load()
+	require.Equal(t, config.ErrorConfigFileNotFound, err)
+
+	// check that using data doesn't crash
+	_, ok := data.GetValue("one", "type")
+	assert.False(t, ok)
+}
@@ -0,0 +1,202 @@
+package configfile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+)
+
+// FileStorage implements config.Storage for saving and loading config
+// data in a single JSON or YAML file.
+type FileStorage struct {
+	mu     sync.Mutex    // to protect the following variables
+	format *format       // format of the file
+	mem    memoryStorage // config loaded
+	fi     os.FileInfo   // stat of the file when last loaded
+}
+
+// NewFileStorage makes a config.Storage for a file in format f
+func NewFileStorage(f *format) *FileStorage {
+	return &FileStorage{
+		format: f,
+	}
+}
+
+// Check to see if we need to reload the config
+//
+// mu must be held when calling this
+func (s *FileStorage) _check() {
+	if configPath := config.GetConfigPath(); configPath != "" {
+		fi, err := os.Stat(configPath)
+		if err == nil {
+			if s.fi == nil || !fi.ModTime().Equal(s.fi.ModTime()) || fi.Size() != s.fi.Size() {
+				fs.Debugf(nil, "Config file has changed externally - reloading")
+				err := s._load()
+				if err != nil {
+					fs.Errorf(nil, "Failed to read config file - using previous config: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// _load the config from permanent storage, decrypting if necessary
+//
+// mu must be held when calling this
+func (s *FileStorage) _load() (err error) {
+	configPath := config.GetConfigPath()
+	if configPath == "" {
+		return config.ErrorConfigFileNotFound
+	}
+
+	data, fi, err := readFile(configPath)
+	if os.IsNotExist(err) {
+		return config.ErrorConfigFileNotFound
+	} else if err != nil {
+		return err
+	}
+
+	// Update s.fi with the current file info
+	s.fi = fi
+
+	ss, err := s.format.decode(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s config file: %w", s.format.name, err)
+	}
+	s.mem.sections = ss
+
+	return nil
+}
+
+// Load the config from permanent storage, decrypting if necessary
+func (s *FileStorage) Load() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s._load()
+}
+
+// _serialize the sections into the file format
+//
+// mu must be held when calling this
+func (s *FileStorage) _serialize() ([]byte, error) {
+	data, err := s.format.encode(s.mem.sections)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save config file: %w", err)
+	}
+	return data, nil
+}
+
+// Save the config to permanent storage, encrypting if necessary
+func (s *FileStorage) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	configPath := config.GetConfigPath()
+	if configPath == "" {
+		return fmt.Errorf("failed to save config file, path is empty")
+	}
+
+	data, err := s._serialize()
+	if err != nil {
+		return err
+	}
+
+	fi, err := writeFile(configPath, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	// Update s.fi with the newly written file
+	s.fi = fi
+
+	return nil
+}
+
+// Serialize the config into a string
+func (s *FileStorage) Serialize() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	data, err := s._serialize()
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// HasSection returns true if section exists in the config file
+func (s *FileStorage) HasSection(section string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	return s.mem.hasSection(section)
+}
+
+// DeleteSection removes the named section and all config from the
+// config file
+func (s *FileStorage) DeleteSection(section string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	s.mem.deleteSection(section)
+}
+
+// GetSectionList returns a slice of strings with names for all the
+// sections
+func (s *FileStorage) GetSectionList() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	return s.mem.sections.names()
+}
+
+// GetKeyList returns the keys in this section
+func (s *FileStorage) GetKeyList(section string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	return s.mem.getKeyList(section)
+}
+
+// GetValue returns the key in section with a found flag
+func (s *FileStorage) GetValue(section string, key string) (value string, found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	return s.mem.getValue(section, key)
+}
+
+// SetValue sets the value under key in section
+func (s *FileStorage) SetValue(section string, key string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	if isOnTheFly(section) {
+		fs.Logf(nil, "Can't save config %q for on the fly backend %q", key, section)
+		return
+	}
+	s.mem.setValue(section, key, value)
+}
+
+// DeleteKey removes the key under section
+func (s *FileStorage) DeleteKey(section string, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s._check()
+	return s.mem.deleteKey(section, key)
+}
+
+// Check the interface is satisfied
+var _ config.Storage = (*FileStorage)(nil)
@@ -0,0 +1,159 @@
+package configfile
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rclone/rclone/fs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var configJSON = `{
+  "one": {
+    "type": "number1",
+    "fruit": "potato"
+  },
+  "two": {
+    "type": "number2",
+    "fruit": "apple",
+    "topping": "nuts"
+  },
+  "three": {
+    "type": "number3",
+    "fruit": "banana"
+  }
+}
+`
+
+var configYAML = `one:
+  type: number1
+  fruit: potato
+two:
+  type: number2
+  fruit: apple
+  topping: nuts
+three:
+  type: number3
+  fruit: banana
+`
+
+func testFileStorage(t *testing.T, f *format, in string) {
+	defer setConfigFile(t, in)()
+	data := NewFileStorage(f)
+
+	require.NoError(t, data.Load())
+
+	buf, err := data.Serialize()
+	require.NoError(t, err)
+	assert.Equal(t, in, buf)
+	assert.True(t, data.HasSection("one"))
+	assert.False(t, data.HasSection("missing"))
+	assert.Equal(t, []string{"one", "two", "three"}, data.GetSectionList())
+	assert.Equal(t, []string{"type", "fruit", "topping"}, data.GetKeyList("two"))
+	assert.Equal(t, []string(nil), data.GetKeyList("unicorn"))
+	value, ok := data.GetValue("three", "fruit")
+	assert.True(t, ok)
+	assert.Equal(t, "banana", value)
+	_, ok = data.GetValue("three", "missing")
+	assert.False(t, ok)
+
+	data.SetValue("one", "extra", "42")
+	data.SetValue(":local", "extra", "42")
+	data.DeleteKey("one", "type")
+	data.DeleteSection("two")
+	assert.False(t, data.HasSection(":local"))
+	require.NoError(t, data.Save())
+
+	// Load what we saved into a fresh storage
+	data = NewFileStorage(f)
+	require.NoError(t, data.Load())
+	assert.Equal(t, []string{"one", "three"}, data.GetSectionList())
+	assert.Equal(t, []string{"fruit", "extra"}, data.GetKeyList("one"))
+	value, ok = data.GetValue("one", "extra")
+	assert.True(t, ok)
+	assert.Equal(t, "42", value)
+}
+
+func TestFileStorageJSON(t *testing.T) {
+	testFileStorage(t, jsonFormat, configJSON)
+}
+
+func TestFileStorageYAML(t *testing.T) {
+	testFileStorage(t, yamlFormat, configYAML)
+}
+
+func TestFileStorageValues(t *testing.T) {
+	for _, test := range []struct {
+		f  *format
+		in string
+	}{
+		{jsonFormat, `{"remote": {"type": "local", "number": 1.50, "bool": true, "empty": null}}`},
+		{yamlFormat, "remote:\n  type: local\n  number: 1.50\n  bool: true\n  empty:\n"},
+	} {
+		ss, err := test.f.decode([]byte(test.in))
+		require.NoError(t, err, test.f.name)
+		require.Len(t, ss, 1)
+		assert.Equal(t, map[string]string{
+			"type":   "local",
+			"number": "1.50",
+			"bool":   "true",
+			"empty":  "",
+		}, ss[0].values, test.f.name)
+
+		// Values which aren't strings are written as strings
+		out, err := test.f.encode(ss)
+		require.NoError(t, err)
+		ss2, err := test.f.decode(out)
+		require.NoError(t, err)
+		assert.Equal(t, ss, ss2)
+	}
+
+	// Nested values are an error
+	_, err := jsonFormat.decode([]byte(`{"remote": {"type": {"a": "b"}}}`))
+	assert.Error(t, err)
+	_, err = yamlFormat.decode([]byte("remote:\n  type: [a, b]\n"))
+	assert.Error(t, err)
+	_, err = yamlFormat.decode([]byte("- remote\n"))
+	assert.Error(t, err)
+}
+
+func TestFileStorageReload(t *testing.T) {
+	defer setConfigFile(t, configYAML)()
+	data := NewFileStorage(yamlFormat)
+
+	require.NoError(t, data.Load())
+	_, ok := data.GetValue("three", "appended")
+	assert.False(t, ok)
+
+	// Now write a new value on the end
+	out, err := os.OpenFile(config.GetConfigPath(), os.O_APPEND|os.O_WRONLY, 0777)
+	require.NoError(t, err)
+	_, err = out.WriteString("  appended: what magic\n")
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	// And check we magically reloaded it
+	value, ok := data.GetValue("three", "appended")
+	assert.True(t, ok)
+	assert.Equal(t, "what magic", value)
+}
+
+func TestFileStorageDoesNotExist(t *testing.T) {
+	defer setConfigFile(t, configJSON)()
+	data := NewFileStorage(jsonFormat)
+
+	require.NoError(t, os.Remove(config.GetConfigPath()))
+
+	err := data.Load()
+	require.Equal(t, config.ErrorConfigFileNotFound, err)
+
+	// check that using data doesn't crash
+	_, ok := data.GetValue("three", "fruit")
+	assert.False(t, ok)
+	data.SetValue("one", "extra", "42")
+	require.NoError(t, data.Save())
+	buf, err := os.ReadFile(config.GetConfigPath())
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"one\": {\n    \"extra\": \"42\"\n  }\n}\n", string(buf))
+}
@@ -0,0 +1,400 @@
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Unknwon/goconfig" //nolint:misspell // Don't include misspell when running golangci-lint
+	"gopkg.in/yaml.v3"
+)
+
+// format describes how to read and write the config in a file format
+type format struct {
+	name          string
+	extensions    []string                                         // file extensions for this format
+	decode        func(data []byte) (sections, error)              // decode the whole config
+	encode        func(ss sections) ([]byte, error)                // encode the whole config
+	decodeSection func(name string, data []byte) (*section, error) // decode a file with a single section
+	encodeSection func(s *section) ([]byte, error)                 // encode a single section as a file
+}
+
+var (
+	iniFormat = &format{
+		name:          "ini",
+		extensions:    []string{".conf", ".ini"},
+		decode:        decodeINI,
+		encode:        encodeINI,
+		decodeSection: decodeINISection,
+		encodeSection: func(s *section) ([]byte, error) { return encodeINI(sections{s}) },
+	}
+	jsonFormat = &format{
+		name:          "json",
+		extensions:    []string{".json"},
+		decode:        decodeJSON,
+		encode:        encodeJSON,
+		decodeSection: decodeJSONSection,
+		encodeSection: encodeJSONSection,
+	}
+	yamlFormat = &format{
+		name:          "yaml",
+		extensions:    []string{".yaml", ".yml"},
+		decode:        decodeYAML,
+		encode:        encodeYAML,
+		decodeSection: decodeYAMLSection,
+		encodeSection: encodeYAMLSection,
+	}
+	formats = []*format{iniFormat, jsonFormat, yamlFormat}
+)
+
+// formatForFile returns the format of the file from its extension
+// and the name of the section it should contain.
+//
+// Files without a known extension are INI and the section name is
+// the whole file name.
+func formatForFile(fileName string) (f *format, name string) {
+	lowerName := strings.ToLower(fileName)
+	for _, f := range formats {
+		for _, ext := range f.extensions {
+			if strings.HasSuffix(lowerName, ext) && len(fileName) > len(ext) {
+				return f, fileName[:len(fileName)-len(ext)]
+			}
+		}
+	}
+	return iniFormat, fileName
+}
+
+// configValue converts a decoded JSON scalar into a config value
+func configValue(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return x, nil
+	case bool, json.Number:
+		return fmt.Sprint(x), nil
+	}
+	return "", fmt.Errorf("config values must be strings, numbers or booleans, not %T", v)
+}
+
+// decodeINI decodes the whole config in the INI format
+func decodeINI(data []byte) (sections, error) {
+	gc, err := goconfig.LoadFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var ss sections
+	for _, name := range gc.GetSectionList() {
+		s := newSection(name)
+		for _, key := range gc.GetKeyList(name) {
+			value, _ := gc.GetValue(name, key)
+			s.set(key, value)
+		}
+		ss = append(ss, s)
+	}
+	return ss, nil
+}
+
+// encodeINI encodes the config in the INI format
+func encodeINI(ss sections) ([]byte, error) {
+	gc, err := goconfig.LoadFromReader(bytes.NewReader(nil))
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		for _, key := range s.keys {
+			gc.SetValue(s.name, key, s.values[key])
+		}
+	}
+	var buf bytes.Buffer
+	if err := goconfig.SaveConfigData(gc, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeINISection decodes a file with the keys of a single section.
+//
+// The file may start with a [name] line which is ignored.
+func decodeINISection(name string, data []byte) (*section, error) {
+	gc, err := goconfig.LoadFromReader(bytes.NewReader(append([]byte("["+name+"]\n"), data...)))
+	if err != nil {
+		return nil, err
+	}
+	s := newSection(name)
+	for _, sectionName := range gc.GetSectionList() {
+		for _, key := range gc.GetKeyList(sectionName) {
+			value, _ := gc.GetValue(sectionName, key)
+			s.set(key, value)
+		}
+	}
+	return s, nil
+}
+
+// jsonObject reads a JSON object from dec calling fn with each key
+// which should read the value
+func jsonObject(dec *json.Decoder, fn func(key string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return errors.New("expecting a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		err = fn(key)
+		if err != nil {
+			return err
+		}
+	}
+	// Read the closing }
+	_, err = dec.Token()
+	return err
+}
+
+// jsonSection reads the keys of a section from a JSON object
+func jsonSection(dec *json.Decoder, name string) (*section, error) {
+	s := newSection(name)
+	err := jsonObject(dec, func(key string) error {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err != nil {
+			return err
+		}
+		value, err := configValue(v)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", name, key, err)
+		}
+		s.set(key, value)
+		return nil
+	})
+	return s, err
+}
+
+// newJSONDecoder makes a decoder for data
+func newJSONDecoder(data []byte) *json.Decoder {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec
+}
+
+// decodeJSON decodes the whole config from a JSON object of objects
+func decodeJSON(data []byte) (ss sections, err error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	dec := newJSONDecoder(data)
+	err = jsonObject(dec, func(name string) error {
+		s, err := jsonSection(dec, name)
+		if err != nil {
+			return err
+		}
+		ss = append(ss, s)
+		return nil
+	})
+	return ss, err
+}
+
+// decodeJSONSection decodes a file with a single section as a JSON
+// object
+func decodeJSONSection(name string, data []byte) (*section, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return newSection(name), nil
+	}
+	return jsonSection(newJSONDecoder(data), name)
+}
+
+// writeJSONString writes s to buf as a JSON string
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimRight(out.Bytes(), "\n"))
+	return nil
+}
+
+// writeJSONSection writes the keys of s to buf as a JSON object
+// indented by indent
+func writeJSONSection(buf *bytes.Buffer, s *section, indent string) error {
+	buf.WriteString("{")
+	for i, key := range s.keys {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n" + indent + "  ")
+		if err := writeJSONString(buf, key); err != nil {
+			return err
+		}
+		buf.WriteString(": ")
+		if err := writeJSONString(buf, s.values[key]); err != nil {
+			return err
+		}
+	}
+	if len(s.keys) > 0 {
+		buf.WriteString("\n" + indent)
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+// encodeJSON encodes the config as a JSON object of objects
+func encodeJSON(ss sections) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, s := range ss {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		if err := writeJSONString(&buf, s.name); err != nil {
+			return nil, err
+		}
+		buf.WriteString(": ")
+		if err := writeJSONSection(&buf, s, "  "); err != nil {
+			return nil, err
+		}
+	}
+	if len(ss) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// encodeJSONSection encodes a single section as a JSON object
+func encodeJSONSection(s *section) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSONSection(&buf, s, ""); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// yamlMapping returns the mapping in the YAML document node or nil
+// if the document is empty
+func yamlMapping(doc *yaml.Node) (*yaml.Node, error) {
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	node := doc.Content[0]
+	if node.Kind != yaml.MappingNode {
+		if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("line %d: expecting a YAML mapping", node.Line)
+	}
+	return node, nil
+}
+
+// yamlSection reads the keys of a section from a YAML mapping node
+func yamlSection(name string, node *yaml.Node) (*section, error) {
+	s := newSection(name)
+	if node == nil || (node.Kind == yaml.ScalarNode && node.Tag == "!!null") {
+		return s, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: line %d: expecting a YAML mapping", name, node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%s: %s: config values must be strings, numbers or booleans", name, key.Value)
+		}
+		// Use the text of the value so "yes" and "1.50" are read as written
+		if value.Tag == "!!null" {
+			s.set(key.Value, "")
+		} else {
+			s.set(key.Value, value.Value)
+		}
+	}
+	return s, nil
+}
+
+// decodeYAML decodes the whole config from a YAML mapping of mappings
+func decodeYAML(data []byte) (ss sections, err error) {
+	var doc yaml.Node
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	node, err := yamlMapping(&doc)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		s, err := yamlSection(node.Content[i].Value, node.Content[i+1])
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+	return ss, nil
+}
+
+// decodeYAMLSection decodes a file with a single section as a YAML
+// mapping
+func decodeYAMLSection(name string, data []byte) (*section, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	node, err := yamlMapping(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return yamlSection(name, node)
+}
+
+// yamlString makes a YAML node for the string s
+func yamlString(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// yamlNode returns the keys of s as a YAML mapping node
+func yamlNode(s *section) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, key := range s.keys {
+		node.Content = append(node.Content, yamlString(key), yamlString(s.values[key]))
+	}
+	return node
+}
+
+// encodeYAML encodes the config as a YAML mapping of mappings
+func encodeYAML(ss sections) ([]byte, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, s := range ss {
+		node.Content = append(node.Content, yamlString(s.name), yamlNode(s))
+	}
+	return encodeYAMLNode(node)
+}
+
+// encodeYAMLSection encodes a single section as a YAML mapping
+func encodeYAMLSection(s *section) ([]byte, error) {
+	return encodeYAMLNode(yamlNode(s))
+}
+
+// encodeYAMLNode encodes node indented by 2 spaces
+func encodeYAMLNode(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
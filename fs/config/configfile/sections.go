@@ -0,0 +1,156 @@
+package configfile
+
+import "strings"
+
+// section is a named section of the config with its keys in the
+// order they were read
+type section struct {
+	name   string
+	keys   []string
+	values map[string]string
+}
+
+// newSection makes an empty section called name
+func newSection(name string) *section {
+	return &section{
+		name:   name,
+		values: map[string]string{},
+	}
+}
+
+// set key to value in the section
+func (s *section) set(key, value string) {
+	if _, found := s.values[key]; !found {
+		s.keys = append(s.keys, key)
+	}
+	s.values[key] = value
+}
+
+// remove key from the section returning true if it was found
+func (s *section) remove(key string) bool {
+	if _, found := s.values[key]; !found {
+		return false
+	}
+	delete(s.values, key)
+	for i, k := range s.keys {
+		if k == key {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// copy returns a copy of the section
+func (s *section) copy() *section {
+	c := newSection(s.name)
+	for _, key := range s.keys {
+		c.set(key, s.values[key])
+	}
+	return c
+}
+
+// equal returns true if s and other have the same keys and values in
+// the same order
+func (s *section) equal(other *section) bool {
+	if len(s.keys) != len(other.keys) {
+		return false
+	}
+	for i, key := range s.keys {
+		if other.keys[i] != key || other.values[key] != s.values[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// sections is the config as a list of sections in the order they
+// were read
+type sections []*section
+
+// find returns the section called name or nil if not found
+func (ss sections) find(name string) *section {
+	for _, s := range ss {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// names returns the names of the sections
+func (ss sections) names() []string {
+	names := make([]string, 0, len(ss))
+	for _, s := range ss {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// memoryStorage implements the config.Storage methods which don't
+// need persistent storage on a list of sections.
+//
+// The caller should hold the lock and check for reloads.
+type memoryStorage struct {
+	sections sections
+}
+
+// hasSection returns true if section exists
+func (m *memoryStorage) hasSection(name string) bool {
+	return m.sections.find(name) != nil
+}
+
+// deleteSection removes the named section
+func (m *memoryStorage) deleteSection(name string) {
+	for i, s := range m.sections {
+		if s.name == name {
+			m.sections = append(m.sections[:i], m.sections[i+1:]...)
+			return
+		}
+	}
+}
+
+// getKeyList returns the keys in the section
+func (m *memoryStorage) getKeyList(name string) []string {
+	s := m.sections.find(name)
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s.keys...)
+}
+
+// getValue returns the key in section with a found flag
+func (m *memoryStorage) getValue(name, key string) (value string, found bool) {
+	s := m.sections.find(name)
+	if s == nil {
+		return "", false
+	}
+	value, found = s.values[key]
+	return value, found
+}
+
+// setValue sets the value under key in section making the section if
+// necessary
+func (m *memoryStorage) setValue(name, key, value string) {
+	s := m.sections.find(name)
+	if s == nil {
+		s = newSection(name)
+		m.sections = append(m.sections, s)
+	}
+	s.set(key, value)
+}
+
+// deleteKey removes the key under section
+func (m *memoryStorage) deleteKey(name, key string) bool {
+	s := m.sections.find(name)
+	if s == nil {
+		return false
+	}
+	return s.remove(key)
+}
+
+// isOnTheFly returns true if the section is for an on the fly
+// backend which can't be saved
+func isOnTheFly(name string) bool {
+	return strings.HasPrefix(name, ":")
+}
@@ -24,6 +24,7 @@ var (
 	verbose         int
 	quiet           bool
 	configPath      string
+	configFormat    string
 	cacheDir        string
 	tempDir         string
 	dumpHeaders     bool
@@ -49,7 +50,11 @@ func AddFlags(ci *fs.ConfigInfo, flagSet *pflag.FlagSet) {
 	flags.DurationVarP(flagSet, &ci.ModifyWindow, "modify-window", "", ci.ModifyWindow, "Max time diff to be considered the same")
 	flags.IntVarP(flagSet, &ci.Checkers, "checkers", "", ci.Checkers, "Number of checkers to run in parallel")
 	flags.IntVarP(flagSet, &ci.Transfers, "transfers", "", ci.Transfers, "Number of file transfers to run in parallel")
+	flags.BoolVarP(flagSet, &ci.AdaptiveTransfers, "adaptive-transfers", "", ci.AdaptiveTransfers, "Vary the number of file transfers with the throughput, starting at --transfers")
+	flags.IntVarP(flagSet, &ci.AdaptiveTransfersMin, "adaptive-transfers-min", "", ci.AdaptiveTransfersMin, "Minimum number of file transfers with --adaptive-transfers")
+	flags.IntVarP(flagSet, &ci.AdaptiveTransfersMax, "adaptive-transfers-max", "", ci.AdaptiveTransfersMax, "Maximum number of file transfers with --adaptive-transfers")
 	flags.StringVarP(flagSet, &configPath, "config", "", config.GetConfigPath(), "Config file")
+	flags.StringVarP(flagSet, &configFormat, "config-format", "", "", "Format of the config file: ini|json|yaml|dir (default from the config path)")
 	flags.StringVarP(flagSet, &cacheDir, "cache-dir", "", config.GetCacheDir(), "Directory rclone will use for caching")
 	flags.StringVarP(flagSet, &tempDir, "temp-dir", "", os.TempDir(), "Directory rclone will use for temporary files")
 	flags.BoolVarP(flagSet, &ci.CheckSum, "checksum", "c", ci.CheckSum, "Skip based on checksum (if available) & size, not mod-time & size")
@@ -67,6 +72,9 @@ func AddFlags(ci *fs.ConfigInfo, flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &ci.InsecureSkipVerify, "no-check-certificate", "", ci.InsecureSkipVerify, "Do not verify the server SSL certificate (insecure)")
 	flags.BoolVarP(flagSet, &ci.AskPassword, "ask-password", "", ci.AskPassword, "Allow prompt for password for encrypted configuration")
 	flags.FVarP(flagSet, &ci.PasswordCommand, "password-command", "", "Command for supplying password for encrypted configuration")
+	flags.FVarP(flagSet, &ci.SecretCommand, "secret-command", "", "Command for reading and writing secret:// config values")
+	flags.StringVarP(flagSet, &ci.SecretKeystore, "secret-keystore", "", ci.SecretKeystore, "Path to an encrypted keystore file for secret:// config values")
+	flags.StringVarP(flagSet, &ci.SecretStore, "secret-store", "", ci.SecretStore, "Where rclone config saves passwords: command|keystore (default: config file)")
 	flags.BoolVarP(flagSet, &deleteBefore, "delete-before", "", false, "When synchronizing, delete files on destination before transferring")
 	flags.BoolVarP(flagSet, &deleteDuring, "delete-during", "", false, "When synchronizing, delete files during transfer")
 	flags.BoolVarP(flagSet, &deleteAfter, "delete-after", "", false, "When synchronizing, delete files on destination after transferring (default)")
@@ -105,6 +113,7 @@ func AddFlags(ci *fs.ConfigInfo, flagSet *pflag.FlagSet) {
 	flags.FVarP(flagSet, &ci.StatsLogLevel, "stats-log-level", "", "Log level to show --stats output DEBUG|INFO|NOTICE|ERROR")
 	flags.FVarP(flagSet, &ci.BwLimit, "bwlimit", "", "Bandwidth limit in KiB/s, or use suffix B|K|M|G|T|P or a full timetable")
 	flags.FVarP(flagSet, &ci.BwLimitFile, "bwlimit-file", "", "Bandwidth limit per file in KiB/s, or use suffix B|K|M|G|T|P or a full timetable")
+	flags.FVarP(flagSet, &ci.BwLimitGroup, "bwlimit-group", "", "Bandwidth limit per stats group (e.g. rc job) in KiB/s, or use suffix B|K|M|G|T|P or a full timetable")
 	flags.FVarP(flagSet, &ci.BufferSize, "buffer-size", "", "In memory buffer size when reading files for each --transfer")
 	flags.FVarP(flagSet, &ci.StreamingUploadCutoff, "streaming-upload-cutoff", "", "Cutoff for switching to chunked upload if file size is unknown, upload starts after reaching cutoff or when file ends")
 	flags.FVarP(flagSet, &ci.Dump, "dump", "", "List of items to dump from: "+fs.DumpFlagsList)
@@ -126,6 +135,7 @@ func AddFlags(ci *fs.ConfigInfo, flagSet *pflag.FlagSet) {
 	flags.StringVarP(flagSet, &ci.ClientKey, "client-key", "", ci.ClientKey, "Client SSL private key (PEM) for mutual TLS auth")
 	flags.FVarP(flagSet, &ci.MultiThreadCutoff, "multi-thread-cutoff", "", "Use multi-thread downloads for files above this size")
 	flags.IntVarP(flagSet, &ci.MultiThreadStreams, "multi-thread-streams", "", ci.MultiThreadStreams, "Max number of streams to use for multi-thread downloads")
+	flags.BoolVarP(flagSet, &ci.ResumeUploads, "resume-uploads", "", ci.ResumeUploads, "Resume interrupted multi-thread uploads if the source is unchanged")
 	flags.BoolVarP(flagSet, &ci.UseJSONLog, "use-json-log", "", ci.UseJSONLog, "Use json log format")
 	flags.StringVarP(flagSet, &ci.OrderBy, "order-by", "", ci.OrderBy, "Instructions on how to order the transfers, e.g. 'size,descending'")
 	flags.StringArrayVarP(flagSet, &uploadHeaders, "header-upload", "", nil, "Set HTTP header for upload transactions")
@@ -303,6 +313,9 @@ func SetFlags(ci *fs.ConfigInfo) {
 	if err := config.SetConfigPath(configPath); err != nil {
 		log.Fatalf("--config: Failed to set %q as config path: %v", configPath, err)
 	}
+	if err := config.SetConfigFormat(configFormat); err != nil {
+		log.Fatalf("--config-format: %v", err)
+	}
 
 	// Set path to cache dir
 	if err := config.SetCacheDir(cacheDir); err != nil {
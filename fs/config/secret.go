@@ -0,0 +1,354 @@
+// Secret providers for secret:// config values
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/nacl/secretbox"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/obscure"
+)
+
+// ErrSecretNotFound is returned by a SecretProvider if the secret
+// isn't found
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider reads and writes the secrets referred to by
+// secret://name config values
+type SecretProvider interface {
+	// Name of the provider for messages
+	Name() string
+
+	// Get the secret called name returning ErrSecretNotFound if
+	// it wasn't found
+	Get(name string) (value string, err error)
+
+	// Set the secret called name to value
+	Set(name, value string) error
+}
+
+// secretProviders returns the configured secret providers in the
+// order they should be read
+func secretProviders() (providers []SecretProvider) {
+	ci := fs.GetConfig(context.Background())
+	providers = append(providers, envSecrets{})
+	if len(ci.SecretCommand) != 0 {
+		providers = append(providers, commandSecrets(ci.SecretCommand))
+	}
+	if ci.SecretKeystore != "" {
+		providers = append(providers, &keystoreSecrets{path: ci.SecretKeystore})
+	}
+	return providers
+}
+
+// secretStore returns the provider to write secrets to or nil if
+// they should be written to the config file
+func secretStore() (SecretProvider, error) {
+	ci := fs.GetConfig(context.Background())
+	switch ci.SecretStore {
+	case "":
+		return nil, nil
+	case "command":
+		if len(ci.SecretCommand) == 0 {
+			return nil, errors.New("--secret-store command needs --secret-command")
+		}
+		return commandSecrets(ci.SecretCommand), nil
+	case "keystore":
+		if ci.SecretKeystore == "" {
+			return nil, errors.New("--secret-store keystore needs --secret-keystore")
+		}
+		return &keystoreSecrets{path: ci.SecretKeystore}, nil
+	}
+	return nil, fmt.Errorf("unknown --secret-store %q - must be command or keystore", ci.SecretStore)
+}
+
+// GetSecret reads the secret called name from the secret providers.
+//
+// The environment is read first, then the --secret-command then the
+// --secret-keystore.
+func GetSecret(name string) (value string, err error) {
+	if name == "" {
+		return "", errors.New("empty secret name")
+	}
+	for _, provider := range secretProviders() {
+		value, err = provider.Get(name)
+		if err == nil {
+			fs.Debugf(nil, "Read secret %q from %s", name, provider.Name())
+			return value, nil
+		}
+		if err != ErrSecretNotFound {
+			return "", fmt.Errorf("%s: %w", provider.Name(), err)
+		}
+	}
+	return "", ErrSecretNotFound
+}
+
+// secretName returns the name of the secret used to store key of the
+// remote called name
+func secretName(name, key string) string {
+	return name + "/" + key
+}
+
+// saveSecret stores value in the --secret-store if it is a password
+// of the remote called name, returning the secret:// reference to
+// save in the config file instead.
+//
+// If the value shouldn't be saved as a secret it is returned
+// unchanged.
+func saveSecret(name, key, value string) (string, error) {
+	if value == "" || strings.HasPrefix(value, fs.SecretPrefix) {
+		return value, nil
+	}
+	store, err := secretStore()
+	if err != nil || store == nil {
+		return value, err
+	}
+	ri, err := fs.Find(FileGetResolved(name, "type"))
+	if err != nil {
+		return value, nil
+	}
+	opt := ri.Options.Get(key)
+	if opt == nil || !opt.IsPassword {
+		return value, nil
+	}
+	// Passwords are obscured by this point
+	secret, err := obscure.Reveal(value)
+	if err != nil {
+		secret = value
+	}
+	ref := secretName(name, key)
+	err = store.Set(ref, secret)
+	if err != nil {
+		return value, fmt.Errorf("failed to save %q in %s: %w", ref, store.Name(), err)
+	}
+	fs.Debugf(nil, "Saved %q in %s", ref, store.Name())
+	return fs.SecretPrefix + ref, nil
+}
+
+// envSecrets reads secrets from RCLONE_SECRET_NAME environment
+// variables
+type envSecrets struct{}
+
+// secretToEnv converts a secret name into an environment variable name
+func secretToEnv(name string) string {
+	return "RCLONE_SECRET_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// Name of the provider
+func (envSecrets) Name() string {
+	return "environment"
+}
+
+// Get the secret called name
+func (envSecrets) Get(name string) (string, error) {
+	value, ok := os.LookupEnv(secretToEnv(name))
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// Set the secret called name to value
+func (envSecrets) Set(name, value string) error {
+	return errors.New("can't save secrets in the environment")
+}
+
+// commandSecrets reads and writes secrets with an external command.
+//
+// The command is run with "get name" to read a secret which it should
+// print on stdout, and with "set name" to write the secret which is
+// passed on stdin. The "get" command should exit with status 1 if the
+// secret isn't found so the next provider is tried; any other failure
+// is an error.
+type commandSecrets []string
+
+// secretCommandNotFound is the exit status of the "get" command if
+// the secret isn't found
+const secretCommandNotFound = 1
+
+// Name of the provider
+func (c commandSecrets) Name() string {
+	return "--secret-command"
+}
+
+// run the command with the arguments
+func (c commandSecrets) run(stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(c[0], append(append([]string{}, c[1:]...), args...)...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ers := strings.TrimSpace(stderr.String()); ers != "" {
+			return "", fmt.Errorf("%w: %s", err, ers)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// Get the secret called name
+func (c commandSecrets) Get(name string) (string, error) {
+	out, err := c.run("", "get", name)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == secretCommandNotFound {
+		return "", ErrSecretNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out, "\r\n"), nil
+}
+
+// Set the secret called name to value
+func (c commandSecrets) Set(name, value string) error {
+	_, err := c.run(value, "set", name)
+	return err
+}
+
+// keystoreHeader is the first line of a keystore file
+const keystoreHeader = "RCLONE_KEYSTORE_V0:"
+
+// keystoreMu protects the keystore files from concurrent updates
+var keystoreMu sync.Mutex
+
+// keystoreSecrets stores secrets in a local file encrypted with the
+// password in RCLONE_SECRET_KEYSTORE_PASS or the config password.
+type keystoreSecrets struct {
+	path string
+}
+
+// Name of the provider
+func (k *keystoreSecrets) Name() string {
+	return "keystore " + k.path
+}
+
+// key returns the encryption key for the keystore
+func (k *keystoreSecrets) key() (key [32]byte, err error) {
+	if password := os.Getenv("RCLONE_SECRET_KEYSTORE_PASS"); password != "" {
+		password, err = checkPassword(password)
+		if err != nil {
+			return key, err
+		}
+		return sha256.Sum256([]byte("[" + password + "][rclone-keystore]")), nil
+	}
+	if len(configKey) != 0 {
+		copy(key[:], configKey)
+		return key, nil
+	}
+	return key, errors.New("set RCLONE_SECRET_KEYSTORE_PASS or a config password to use the keystore")
+}
+
+// load reads the secrets from the keystore which is empty if it
+// doesn't exist yet
+func (k *keystoreSecrets) load() (secrets map[string]string, err error) {
+	secrets = map[string]string{}
+	data, err := os.ReadFile(k.path)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	} else if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte(keystoreHeader)) {
+		return nil, errors.New("not a keystore file")
+	}
+	box, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data[len(keystoreHeader):])))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode keystore: %w", err)
+	}
+	if len(box) < 24+secretbox.Overhead {
+		return nil, errors.New("keystore too short")
+	}
+	key, err := k.key()
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], box[:24])
+	plain, ok := secretbox.Open(nil, box[24:], &nonce, &key)
+	if !ok {
+		return nil, errors.New("couldn't decrypt keystore, most likely wrong password")
+	}
+	err = json.Unmarshal(plain, &secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	return secrets, nil
+}
+
+// save writes the secrets to the keystore
+func (k *keystoreSecrets) save(secrets map[string]string) error {
+	key, err := k.key()
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return fmt.Errorf("failed to make nonce: %w", err)
+	}
+	box := secretbox.Seal(nonce[:], plain, &nonce, &key)
+	data := keystoreHeader + "\n" + base64.StdEncoding.EncodeToString(box) + "\n"
+	err = os.MkdirAll(filepath.Dir(k.path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	tmp := k.path + ".tmp"
+	err = os.WriteFile(tmp, []byte(data), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.path)
+}
+
+// Get the secret called name
+func (k *keystoreSecrets) Get(name string) (string, error) {
+	keystoreMu.Lock()
+	defer keystoreMu.Unlock()
+	secrets, err := k.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// Set the secret called name to value
+func (k *keystoreSecrets) Set(name, value string) error {
+	keystoreMu.Lock()
+	defer keystoreMu.Unlock()
+	secrets, err := k.load()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return k.save(secrets)
+}
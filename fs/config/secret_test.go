@@ -0,0 +1,136 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSecretEnv(t *testing.T) {
+	_, err := config.GetSecret("test/env-potato")
+	assert.Equal(t, config.ErrSecretNotFound, err)
+
+	t.Setenv("RCLONE_SECRET_TEST_ENV_POTATO", "jersey royal")
+	value, err := config.GetSecret("test/env-potato")
+	require.NoError(t, err)
+	assert.Equal(t, "jersey royal", value)
+
+	_, err = config.GetSecret("")
+	assert.Error(t, err)
+}
+
+func TestGetSecretCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	ctx := context.Background()
+	ci := fs.GetConfig(ctx)
+	oldSecretCommand := ci.SecretCommand
+	defer func() {
+		ci.SecretCommand = oldSecretCommand
+	}()
+
+	// A secret store which keeps each secret in a file in dir
+	dir := t.TempDir()
+	script := `case "$1" in
+get) [ -f "$0/$2" ] && cat "$0/$2" ;;
+set) mkdir -p "$(dirname "$0/$2")" && cat > "$0/$2" ;;
+*) exit 1 ;;
+esac`
+	ci.SecretCommand = fs.SpaceSepList{"sh", "-c", script, dir}
+
+	_, err := config.GetSecret("remote/pass")
+	assert.Equal(t, config.ErrSecretNotFound, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "remote"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "remote", "pass"), []byte("potato\n"), 0600))
+	value, err := config.GetSecret("remote/pass")
+	require.NoError(t, err)
+	assert.Equal(t, "potato", value)
+
+	// The environment is read first
+	t.Setenv("RCLONE_SECRET_REMOTE_PASS", "env potato")
+	value, err = config.GetSecret("remote/pass")
+	require.NoError(t, err)
+	assert.Equal(t, "env potato", value)
+
+	// Exit status 1 means the secret isn't found
+	ci.SecretCommand = fs.SpaceSepList{"sh", "-c", "exit 1"}
+	_, err = config.GetSecret("remote/other")
+	assert.Equal(t, config.ErrSecretNotFound, err)
+
+	// A failing command is an error
+	ci.SecretCommand = fs.SpaceSepList{"sh", "-c", "echo broken >&2; exit 2"}
+	_, err = config.GetSecret("remote/other")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+}
+
+func TestSecretKeystore(t *testing.T) {
+	defer testConfigFile(t, simpleOptions, "secret.conf")()
+	ctx := context.Background()
+	ci := fs.GetConfig(ctx)
+	oldSecretKeystore, oldSecretStore := ci.SecretKeystore, ci.SecretStore
+	defer func() {
+		ci.SecretKeystore, ci.SecretStore = oldSecretKeystore, oldSecretStore
+	}()
+	keystore := filepath.Join(t.TempDir(), "keystore")
+	ci.SecretKeystore = keystore
+	ci.SecretStore = "keystore"
+
+	// The password isn't saved if the keystore has no password
+	_, err := config.CreateRemote(ctx, "nopass", "config_test_remote", rc.Params{"pass": "potato"}, config.UpdateRemoteOpt{})
+	require.NoError(t, err)
+	assert.Equal(t, "", config.FileGet("nopass", "pass"))
+	_, err = os.Stat(keystore)
+	assert.True(t, os.IsNotExist(err))
+
+	t.Setenv("RCLONE_SECRET_KEYSTORE_PASS", "keystore password")
+
+	// The password is saved in the keystore
+	_, err = config.CreateRemote(ctx, "test", "config_test_remote", rc.Params{"bool": true, "pass": "potato"}, config.UpdateRemoteOpt{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", config.FileGet("test", "bool"))
+	assert.Equal(t, "secret://test/pass", config.FileGet("test", "pass"))
+	data, err := os.ReadFile(keystore)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "potato")
+
+	value, err := config.GetSecret("test/pass")
+	require.NoError(t, err)
+	assert.Equal(t, "potato", value)
+
+	// The secret is read obscured from the configmap like a password
+	ri, err := fs.Find("config_test_remote")
+	require.NoError(t, err)
+	m := fs.ConfigMap(ri, "test", nil)
+	pass, ok := m.Get("pass")
+	require.True(t, ok)
+	assert.Equal(t, "potato", obscure.MustReveal(pass))
+
+	// Without --secret-store passwords are saved in the config file
+	ci.SecretStore = ""
+	_, err = config.UpdateRemote(ctx, "test", rc.Params{"pass": "potato2"}, config.UpdateRemoteOpt{})
+	require.NoError(t, err)
+	assert.Equal(t, "potato2", obscure.MustReveal(config.FileGet("test", "pass")))
+
+	// A wrong password can't read the keystore
+	t.Setenv("RCLONE_SECRET_KEYSTORE_PASS", "wrong password")
+	_, err = config.GetSecret("test/pass")
+	assert.Error(t, err)
+
+	// Passwords aren't saved to unknown stores
+	ci.SecretStore = "potato"
+	_, err = config.UpdateRemote(ctx, "test", rc.Params{"pass": "potato3"}, config.UpdateRemoteOpt{})
+	require.NoError(t, err)
+	assert.Equal(t, "potato2", obscure.MustReveal(config.FileGet("test", "pass")))
+}
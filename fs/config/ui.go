@@ -280,7 +280,7 @@ func ShowRemotes() {
 	fmt.Printf("%-20s %s\n", "Name", "Type")
 	fmt.Printf("%-20s %s\n", "====", "====")
 	for _, remote := range remotes {
-		fmt.Printf("%-20s %s\n", remote, FileGet(remote, "type"))
+		fmt.Printf("%-20s %s\n", remote, FileGetResolved(remote, "type"))
 	}
 }
 
@@ -295,7 +295,7 @@ func ChooseRemote() string {
 // mustFindByName finds the RegInfo for the remote name passed in or
 // exits with a fatal error.
 func mustFindByName(name string) *fs.RegInfo {
-	fsType := FileGet(name, "type")
+	fsType := FileGetResolved(name, "type")
 	if fsType == "" {
 		log.Fatalf("Couldn't find type of fs for %q", name)
 	}
@@ -303,9 +303,16 @@ func mustFindByName(name string) *fs.RegInfo {
 }
 
 // printRemoteOptions prints the options of the remote
-func printRemoteOptions(name string, prefix string, sep string) {
+//
+// If resolved is set then the options the remote inherits are
+// printed too.
+func printRemoteOptions(name string, prefix string, sep string, resolved bool) {
 	fs := mustFindByName(name)
-	for _, key := range LoadedData().GetKeyList(name) {
+	keys := LoadedData().GetKeyList(name)
+	if resolved {
+		keys = FileKeyListResolved(name)
+	}
+	for _, key := range keys {
 		isPassword := false
 		for _, option := range fs.Options {
 			if option.Name == key && option.IsPassword {
@@ -314,6 +321,9 @@ func printRemoteOptions(name string, prefix string, sep string) {
 			}
 		}
 		value := FileGet(name, key)
+		if resolved {
+			value = FileGetResolved(name, key)
+		}
 		if isPassword && value != "" {
 			fmt.Printf("%s%s%s*** ENCRYPTED ***\n", prefix, key, sep)
 		} else {
@@ -324,13 +334,20 @@ func printRemoteOptions(name string, prefix string, sep string) {
 
 // listRemoteOptions lists the options of the remote
 func listRemoteOptions(name string) {
-	printRemoteOptions(name, "- ", ": ")
+	printRemoteOptions(name, "- ", ": ", false)
 }
 
 // ShowRemote shows the contents of the remote in config file format
 func ShowRemote(name string) {
 	fmt.Printf("[%s]\n", name)
-	printRemoteOptions(name, "", " = ")
+	printRemoteOptions(name, "", " = ", false)
+}
+
+// ShowRemoteResolved shows the contents of the remote in config file
+// format with the options it inherits from other sections filled in
+func ShowRemoteResolved(name string) {
+	fmt.Printf("[%s]\n", name)
+	printRemoteOptions(name, "", " = ", true)
 }
 
 // OkRemote prints the contents of the remote and ask if it is OK
@@ -634,6 +651,26 @@ func ShowConfig() {
 	fmt.Printf("%s", str)
 }
 
+// ShowConfigResolved prints the config options of all the remotes
+// with the options they inherit from other sections filled in.
+//
+// Sections without a type are only used to inherit from so are left
+// out.
+func ShowConfigResolved() {
+	shown := false
+	for _, remote := range LoadedData().GetSectionList() {
+		if FileGetResolved(remote, "type") == "" {
+			continue
+		}
+		ShowRemoteResolved(remote)
+		fmt.Println()
+		shown = true
+	}
+	if !shown {
+		fmt.Printf("; empty config\n")
+	}
+}
+
 // EditConfig edits the config file interactively
 func EditConfig(ctx context.Context) (err error) {
 	for {
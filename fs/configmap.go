@@ -3,11 +3,102 @@
 package fs
 
 import (
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
 )
 
+// SecretPrefix is the prefix of a config value which refers to a
+// secret stored in the secret providers, e.g. secret://name
+const SecretPrefix = "secret://"
+
+// secretCache holds the secrets read so far by name so each is only
+// read from the secret providers once
+var (
+	secretCacheMu sync.Mutex
+	secretCache   = map[string]string{}
+)
+
+// getSecret reads the secret called name using the cache if possible
+func getSecret(name string) (string, error) {
+	secretCacheMu.Lock()
+	defer secretCacheMu.Unlock()
+	if value, ok := secretCache[name]; ok {
+		return value, nil
+	}
+	value, err := ConfigSecretGet(name)
+	if err != nil {
+		return "", err
+	}
+	secretCache[name] = value
+	return value, nil
+}
+
+// secretErrors records the first error from reading the secrets of
+// a configmap.Map
+type secretErrors struct {
+	mu  sync.Mutex
+	err error
+}
+
+// set err as the error if there isn't one already
+func (e *secretErrors) set(err error) {
+	e.mu.Lock()
+	if e.err == nil {
+		e.err = err
+	}
+	e.mu.Unlock()
+}
+
+// Err returns the first error from reading a secret or nil
+func (e *secretErrors) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// A configmap.Getter which resolves secret:// references in the
+// values read from another configmap.Getter
+type secretGetter struct {
+	getter configmap.Getter
+	fsInfo *RegInfo
+	errs   *secretErrors
+}
+
+// Get a config item, reading it from the secret providers if it is a
+// secret:// reference
+//
+// If the secret can't be read the error is recorded in errs and the
+// item is not found.
+func (s secretGetter) Get(key string) (value string, ok bool) {
+	value, ok = s.getter.Get(key)
+	if !ok || !strings.HasPrefix(value, SecretPrefix) {
+		return value, ok
+	}
+	name := value[len(SecretPrefix):]
+	secret, err := getSecret(name)
+	if err != nil {
+		s.errs.set(fmt.Errorf("failed to read secret %q for config %q: %w", name, key, err))
+		return "", false
+	}
+	// Passwords are stored obscured in the config so obscure the
+	// secret to match
+	if s.fsInfo != nil {
+		if opt := s.fsInfo.Options.Get(key); opt != nil && opt.IsPassword {
+			secret, err = obscure.Obscure(secret)
+			if err != nil {
+				s.errs.set(fmt.Errorf("failed to obscure secret %q for config %q: %w", name, key, err))
+				return "", false
+			}
+		}
+	}
+	return secret, true
+}
+
 // A configmap.Getter to read from the environment RCLONE_CONFIG_backend_option_name
 type configEnvVars string
 
@@ -76,17 +167,55 @@ func (section setConfigFile) Set(key, value string) {
 	}
 }
 
+// ConfigInheritKey is the config file key naming the section a
+// remote inherits the config it doesn't set itself from, e.g.
+// inherit = s3-base
+const ConfigInheritKey = "inherit"
+
+// ConfigInheritChain returns section followed by the sections it
+// inherits from in the order they should be read.
+//
+// Loops in the inheritance are reported and broken.
+func ConfigInheritChain(section string) (chain []string) {
+	seen := map[string]struct{}{}
+	for section != "" {
+		if _, found := seen[section]; found {
+			Errorf(nil, "Ignoring %s = %q in section %q of the config file as it makes a loop", ConfigInheritKey, section, chain[len(chain)-1])
+			break
+		}
+		seen[section] = struct{}{}
+		chain = append(chain, section)
+		section, _ = ConfigFileGet(section, ConfigInheritKey)
+	}
+	return chain
+}
+
+// ConfigFileGetInherited gets the config key under section from the
+// config file, reading it from the sections section inherits from if
+// it isn't set there.
+//
+// An empty value stops the key being inherited but is otherwise
+// ignored, so the default is used.
+func ConfigFileGetInherited(section, key string) (value string, ok bool) {
+	if key == ConfigInheritKey {
+		value, ok = ConfigFileGet(section, key)
+		return value, ok && value != ""
+	}
+	for _, section := range ConfigInheritChain(section) {
+		value, ok = ConfigFileGet(section, key)
+		if ok {
+			return value, value != ""
+		}
+	}
+	return "", false
+}
+
 // A configmap.Getter to read from the config file
 type getConfigFile string
 
-// Get a config item from the config file
+// Get a config item from the config file following any inherit keys
 func (section getConfigFile) Get(key string) (value string, ok bool) {
-	value, ok = ConfigFileGet(string(section), key)
-	// Ignore empty lines in the config file
-	if value == "" {
-		ok = false
-	}
-	return value, ok
+	return ConfigFileGetInherited(string(section), key)
 }
 
 // ConfigMap creates a configmap.Map from the *RegInfo and the
@@ -96,31 +225,41 @@ func (section getConfigFile) Get(key string) (value string, ok bool) {
 // If fsInfo is nil then the returned configmap.Map should only be
 // used for reading non backend specific parameters, such as "type".
 func ConfigMap(fsInfo *RegInfo, configName string, connectionStringConfig configmap.Simple) (config *configmap.Map) {
+	config, _ = configMap(fsInfo, configName, connectionStringConfig)
+	return config
+}
+
+// configMap makes the config for ConfigMap returning the errors
+// from reading any secret:// references too
+func configMap(fsInfo *RegInfo, configName string, connectionStringConfig configmap.Simple) (config *configmap.Map, errs *secretErrors) {
 	// Create the config
 	config = configmap.New()
+	errs = new(secretErrors)
 
 	// Read the config, more specific to least specific
+	//
+	// Any of these may be secret:// references
 
 	// Config from connection string
 	if len(connectionStringConfig) > 0 {
-		config.AddGetter(connectionStringConfig, configmap.PriorityNormal)
+		config.AddGetter(secretGetter{connectionStringConfig, fsInfo, errs}, configmap.PriorityNormal)
 	}
 
 	// flag values
 	if fsInfo != nil {
-		config.AddGetter(&regInfoValues{fsInfo, false}, configmap.PriorityNormal)
+		config.AddGetter(secretGetter{&regInfoValues{fsInfo, false}, fsInfo, errs}, configmap.PriorityNormal)
 	}
 
 	// remote specific environment vars
-	config.AddGetter(configEnvVars(configName), configmap.PriorityNormal)
+	config.AddGetter(secretGetter{configEnvVars(configName), fsInfo, errs}, configmap.PriorityNormal)
 
 	// backend specific environment vars
 	if fsInfo != nil {
-		config.AddGetter(optionEnvVars{fsInfo: fsInfo}, configmap.PriorityNormal)
+		config.AddGetter(secretGetter{optionEnvVars{fsInfo: fsInfo}, fsInfo, errs}, configmap.PriorityNormal)
 	}
 
 	// config file
-	config.AddGetter(getConfigFile(configName), configmap.PriorityConfig)
+	config.AddGetter(secretGetter{getConfigFile(configName), fsInfo, errs}, configmap.PriorityConfig)
 
 	// default values
 	if fsInfo != nil {
@@ -129,5 +268,5 @@ func ConfigMap(fsInfo *RegInfo, configName string, connectionStringConfig config
 
 	// Set Config
 	config.AddSetter(setConfigFile(configName))
-	return config
+	return config, errs
 }
@@ -147,6 +147,18 @@ type Features struct {
 	// It truncates any existing object
 	OpenWriterAt func(ctx context.Context, remote string, size int64) (WriterAtCloser, error)
 
+	// OpenChunkWriter starts a multipart upload of src to remote
+	// returning the chunk size and a ChunkWriter to upload the
+	// chunks with, possibly concurrently.
+	//
+	// It truncates any existing object
+	OpenChunkWriter func(ctx context.Context, remote string, src ObjectInfo, options ...OpenOption) (info ChunkWriterInfo, writer ChunkWriter, err error)
+
+	// ResumeChunkWriter carries on with a multipart upload of src
+	// to remote started by OpenChunkWriter. The state is from the
+	// ResumeState method of the ChunkWriter.
+	ResumeChunkWriter func(ctx context.Context, remote string, src ObjectInfo, state []byte, options ...OpenOption) (info ChunkWriterInfo, writer ChunkWriter, err error)
+
 	// UserInfo returns info about the connected user
 	UserInfo func(ctx context.Context) (map[string]string, error)
 
@@ -297,6 +309,12 @@ func (ft *Features) Fill(ctx context.Context, f Fs) *Features {
 	if do, ok := f.(OpenWriterAter); ok {
 		ft.OpenWriterAt = do.OpenWriterAt
 	}
+	if do, ok := f.(OpenChunkWriter); ok {
+		ft.OpenChunkWriter = do.OpenChunkWriter
+	}
+	if do, ok := f.(ResumeChunkWriter); ok {
+		ft.ResumeChunkWriter = do.ResumeChunkWriter
+	}
 	if do, ok := f.(UserInfoer); ok {
 		ft.UserInfo = do.UserInfo
 	}
@@ -386,6 +404,12 @@ func (ft *Features) Mask(ctx context.Context, f Fs) *Features {
 	if mask.OpenWriterAt == nil {
 		ft.OpenWriterAt = nil
 	}
+	if mask.OpenChunkWriter == nil {
+		ft.OpenChunkWriter = nil
+	}
+	if mask.ResumeChunkWriter == nil {
+		ft.ResumeChunkWriter = nil
+	}
 	if mask.UserInfo == nil {
 		ft.UserInfo = nil
 	}
@@ -616,6 +640,24 @@ type OpenWriterAter interface {
 	OpenWriterAt(ctx context.Context, remote string, size int64) (WriterAtCloser, error)
 }
 
+// OpenChunkWriter is an optional interface for Fs
+type OpenChunkWriter interface {
+	// OpenChunkWriter starts a multipart upload of src to remote
+	// returning the chunk size and a ChunkWriter to upload the
+	// chunks with, possibly concurrently.
+	//
+	// It truncates any existing object
+	OpenChunkWriter(ctx context.Context, remote string, src ObjectInfo, options ...OpenOption) (info ChunkWriterInfo, writer ChunkWriter, err error)
+}
+
+// ResumeChunkWriter is an optional interface for Fs
+type ResumeChunkWriter interface {
+	// ResumeChunkWriter carries on with a multipart upload of src
+	// to remote started by OpenChunkWriter. The state is from the
+	// ResumeState method of the ChunkWriter.
+	ResumeChunkWriter(ctx context.Context, remote string, src ObjectInfo, state []byte, options ...OpenOption) (info ChunkWriterInfo, writer ChunkWriter, err error)
+}
+
 // UserInfoer is an optional interface for Fs
 type UserInfoer interface {
 	// UserInfo returns info about the connected user
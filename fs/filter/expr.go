@@ -0,0 +1,528 @@
+// Filter expressions set with --filter-expr
+
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// exprKind is the type of a value in a filter expression
+type exprKind int
+
+// The types of values
+const (
+	kindString exprKind = iota
+	kindSize
+	kindDuration
+	kindTime
+)
+
+// String returns the name of the type for error messages
+func (k exprKind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindSize:
+		return "size"
+	case kindDuration:
+		return "duration"
+	case kindTime:
+		return "time"
+	}
+	return "unknown"
+}
+
+// exprValue is a value in a filter expression
+type exprValue struct {
+	str string    // for kindString
+	num int64     // for kindSize and kindDuration
+	t   time.Time // for kindTime
+}
+
+// exprEnv is the file a filter expression is evaluated against
+type exprEnv struct {
+	remote   string
+	size     int64
+	modTime  time.Time
+	metadata fs.Metadata
+	mimeType func() string // may be nil
+	now      time.Time
+}
+
+// getMimeType returns the MIME type of the file
+func (env *exprEnv) getMimeType() string {
+	if env.mimeType != nil {
+		return env.mimeType()
+	}
+	if mimeType, ok := env.metadata["content-type"]; ok && mimeType != "" {
+		return mimeType
+	}
+	return fs.MimeTypeFromName(env.remote)
+}
+
+// exprField is a property of the file which can be used in a filter
+// expression
+type exprField struct {
+	kind    exprKind
+	modTime bool // set if the field needs the modification time
+	get     func(env *exprEnv) exprValue
+}
+
+// exprFields are the fields available in filter expressions
+var exprFields = map[string]exprField{
+	"name": {kind: kindString, get: func(env *exprEnv) exprValue {
+		return exprValue{str: path.Base(env.remote)}
+	}},
+	"path": {kind: kindString, get: func(env *exprEnv) exprValue {
+		return exprValue{str: env.remote}
+	}},
+	"ext": {kind: kindString, get: func(env *exprEnv) exprValue {
+		return exprValue{str: strings.TrimPrefix(path.Ext(env.remote), ".")}
+	}},
+	"size": {kind: kindSize, get: func(env *exprEnv) exprValue {
+		return exprValue{num: env.size}
+	}},
+	"modtime": {kind: kindTime, modTime: true, get: func(env *exprEnv) exprValue {
+		return exprValue{t: env.modTime}
+	}},
+	"age": {kind: kindDuration, modTime: true, get: func(env *exprEnv) exprValue {
+		return exprValue{num: int64(env.now.Sub(env.modTime))}
+	}},
+	"mime": {kind: kindString, get: func(env *exprEnv) exprValue {
+		return exprValue{str: env.getMimeType()}
+	}},
+}
+
+// exprNode is a compiled boolean filter expression
+type exprNode func(env *exprEnv) bool
+
+// filterExpr is a parsed --filter-expr
+type filterExpr struct {
+	text        string    // the expression as given
+	node        exprNode  // the compiled expression
+	now         time.Time // time the expression was parsed for age
+	usesModTime bool      // set if the expression needs the modification time
+	usesMeta    bool      // set if the expression needs the metadata
+}
+
+// include returns whether the expression is true for the file
+func (e *filterExpr) include(remote string, size int64, modTime time.Time, metadata fs.Metadata, mimeType func() string) bool {
+	return e.node(&exprEnv{
+		remote:   remote,
+		size:     size,
+		modTime:  modTime,
+		metadata: metadata,
+		mimeType: mimeType,
+		now:      e.now,
+	})
+}
+
+// exprTokenKind is the type of a token in a filter expression
+type exprTokenKind int
+
+// The types of token
+const (
+	tokenEOF    exprTokenKind = iota
+	tokenOp                   // operators and brackets
+	tokenString               // quoted strings
+	tokenWord                 // field names and unquoted literals
+)
+
+// exprToken is a token in a filter expression
+type exprToken struct {
+	kind exprTokenKind
+	text string // the operator, word or unquoted string
+	pos  int    // offset in the expression for errors
+}
+
+// exprOps are the operators, longest first so they are matched greedily
+var exprOps = []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "!", "<", ">", "~", "(", ")", "[", "]"}
+
+// isWordByte returns true if c can be part of an unquoted word
+func isWordByte(c byte) bool {
+	return c >= 0x80 || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("._:+-/", c) >= 0
+}
+
+// lexExpr splits the expression into tokens
+func lexExpr(text string) (tokens []exprToken, err error) {
+	i := 0
+outer:
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '"':
+			// Find the end of the string allowing for \" escapes
+			end := i + 1
+			for ; end < len(text) && text[end] != '"'; end++ {
+				if text[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(text) {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			s, err := strconv.Unquote(text[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("bad string at position %d: %w", i+1, err)
+			}
+			tokens = append(tokens, exprToken{kind: tokenString, text: s, pos: i})
+			i = end + 1
+			continue
+		case c == '\'':
+			end := strings.IndexByte(text[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			tokens = append(tokens, exprToken{kind: tokenString, text: text[i+1 : i+1+end], pos: i})
+			i += end + 2
+			continue
+		case isWordByte(c):
+			start := i
+			for i < len(text) && isWordByte(text[i]) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenWord, text: text[start:i], pos: start})
+			continue
+		}
+		for _, op := range exprOps {
+			if strings.HasPrefix(text[i:], op) {
+				tokens = append(tokens, exprToken{kind: tokenOp, text: op, pos: i})
+				i += len(op)
+				continue outer
+			}
+		}
+		return nil, fmt.Errorf("unexpected %q at position %d", c, i+1)
+	}
+	tokens = append(tokens, exprToken{kind: tokenEOF, pos: len(text)})
+	return tokens, nil
+}
+
+// exprOperand is one side of a comparison
+type exprOperand struct {
+	field *exprField // nil for a literal
+	text  string     // the literal or the name of the field
+}
+
+// exprParser parses a filter expression
+type exprParser struct {
+	tokens     []exprToken
+	pos        int
+	ignoreCase bool
+	expr       *filterExpr
+}
+
+// newFilterExpr parses the filter expression in text
+func newFilterExpr(text string, ignoreCase bool) (*filterExpr, error) {
+	tokens, err := lexExpr(text)
+	if err != nil {
+		return nil, err
+	}
+	expr := &filterExpr{
+		text: text,
+		now:  time.Now(),
+	}
+	p := &exprParser{
+		tokens:     tokens,
+		ignoreCase: ignoreCase,
+		expr:       expr,
+	}
+	expr.node, err = p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+	return expr, nil
+}
+
+// peek returns the next token without consuming it
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isOp returns true if the next token is the operator op
+func (p *exprParser) isOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokenOp && tok.text == op
+}
+
+// expectOp consumes the operator op or returns an error
+func (p *exprParser) expectOp(op string) error {
+	if !p.isOp(op) {
+		return p.errorf(p.peek(), "expecting %q", op)
+	}
+	p.next()
+	return nil
+}
+
+// errorf makes an error at the position of tok
+func (p *exprParser) errorf(tok exprToken, format string, a ...interface{}) error {
+	what := fmt.Sprintf(format, a...)
+	if tok.kind == tokenEOF {
+		return fmt.Errorf("%s at end of expression", what)
+	}
+	return fmt.Errorf("%s at position %d", what, tok.pos+1)
+}
+
+// parseOr parses expressions joined with ||
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(env *exprEnv) bool { return a(env) || b(env) }
+	}
+	return left, nil
+}
+
+// parseAnd parses expressions joined with &&
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(env *exprEnv) bool { return a(env) && b(env) }
+	}
+	return left, nil
+}
+
+// parseNot parses !, bracketed expressions and comparisons
+func (p *exprParser) parseNot() (exprNode, error) {
+	switch {
+	case p.isOp("!"):
+		p.next()
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) bool { return !node(env) }, nil
+	case p.isOp("("):
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expectOp(")")
+	}
+	return p.parseComparison()
+}
+
+// parseOperand parses a field, meta["key"] or a literal
+func (p *exprParser) parseOperand() (operand exprOperand, err error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return exprOperand{text: tok.text}, nil
+	case tokenWord:
+	default:
+		return operand, p.errorf(tok, "expecting a field or a value")
+	}
+	if field, ok := exprFields[tok.text]; ok {
+		if field.modTime {
+			p.expr.usesModTime = true
+		}
+		return exprOperand{field: &field, text: tok.text}, nil
+	}
+	if tok.text != "meta" || !p.isOp("[") {
+		return exprOperand{text: tok.text}, nil
+	}
+	p.next()
+	keyTok := p.next()
+	if keyTok.kind != tokenString && keyTok.kind != tokenWord {
+		return operand, p.errorf(keyTok, "expecting a metadata key")
+	}
+	if err := p.expectOp("]"); err != nil {
+		return operand, err
+	}
+	p.expr.usesMeta = true
+	key := strings.ToLower(keyTok.text)
+	return exprOperand{
+		field: &exprField{kind: kindString, get: func(env *exprEnv) exprValue {
+			return exprValue{str: env.metadata[key]}
+		}},
+		text: fmt.Sprintf("meta[%q]", key),
+	}, nil
+}
+
+// parseLiteral converts text into a value of kind
+func parseLiteral(kind exprKind, text string) (v exprValue, err error) {
+	switch kind {
+	case kindString:
+		v.str = text
+	case kindSize:
+		var size fs.SizeSuffix
+		err = size.Set(text)
+		v.num = int64(size)
+	case kindDuration:
+		var d time.Duration
+		d, err = fs.ParseDuration(text)
+		v.num = int64(d)
+	case kindTime:
+		v.t, err = fs.ParseTime(text)
+	}
+	return v, err
+}
+
+// compareValues returns -1, 0 or 1 if a is less than, equal to or
+// greater than b
+func compareValues(kind exprKind, a, b exprValue, ignoreCase bool) int {
+	switch kind {
+	case kindString:
+		if ignoreCase {
+			return strings.Compare(strings.ToLower(a.str), strings.ToLower(b.str))
+		}
+		return strings.Compare(a.str, b.str)
+	case kindSize, kindDuration:
+		switch {
+		case a.num < b.num:
+			return -1
+		case a.num > b.num:
+			return 1
+		}
+	case kindTime:
+		switch {
+		case a.t.Before(b.t):
+			return -1
+		case a.t.After(b.t):
+			return 1
+		}
+	}
+	return 0
+}
+
+// exprComparisons are the comparison operators and the test they
+// make on the result of compareValues
+var exprComparisons = map[string]func(c int) bool{
+	"==": func(c int) bool { return c == 0 },
+	"!=": func(c int) bool { return c != 0 },
+	"<":  func(c int) bool { return c < 0 },
+	"<=": func(c int) bool { return c <= 0 },
+	">":  func(c int) bool { return c > 0 },
+	">=": func(c int) bool { return c >= 0 },
+}
+
+// exprSwapped are the comparisons to use if the operands are swapped
+var exprSwapped = map[string]string{
+	"==": "==", "!=": "!=",
+	"<": ">", "<=": ">=",
+	">": "<", ">=": "<=",
+}
+
+// parseComparison parses a comparison or a field on its own which is
+// true if it isn't empty
+func (p *exprParser) parseComparison() (exprNode, error) {
+	leftTok := p.peek()
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	opTok := p.peek()
+	_, isComparison := exprComparisons[opTok.text]
+	isMatch := opTok.text == "~" || opTok.text == "!~"
+	if opTok.kind != tokenOp || !(isComparison || isMatch) {
+		// A value on its own
+		switch {
+		case left.field == nil && (left.text == "true" || left.text == "false"):
+			value := left.text == "true"
+			return func(env *exprEnv) bool { return value }, nil
+		case left.field != nil && left.field.kind == kindString:
+			get := left.field.get
+			return func(env *exprEnv) bool { return get(env).str != "" }, nil
+		}
+		return nil, p.errorf(leftTok, "expecting a comparison after %q", left.text)
+	}
+	p.next()
+	rightTok := p.peek()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op := opTok.text
+
+	// Put the field on the left
+	if left.field == nil {
+		if right.field == nil {
+			return nil, p.errorf(leftTok, "comparison of %q and %q needs a field", left.text, right.text)
+		}
+		if isMatch {
+			return nil, p.errorf(rightTok, "pattern for %q must be on the right", op)
+		}
+		left, right = right, left
+		leftTok, rightTok = rightTok, leftTok
+		op = exprSwapped[op]
+	}
+	kind := left.field.kind
+	get := left.field.get
+
+	if isMatch {
+		if right.field != nil {
+			return nil, p.errorf(rightTok, "pattern for %q must be a string", op)
+		}
+		if kind != kindString {
+			return nil, p.errorf(leftTok, "can't match %s %q with a pattern", kind, left.text)
+		}
+		pattern := right.text
+		if p.ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, p.errorf(rightTok, "bad pattern: %v", err)
+		}
+		want := op == "~"
+		return func(env *exprEnv) bool { return re.MatchString(get(env).str) == want }, nil
+	}
+
+	test := exprComparisons[op]
+	ignoreCase := p.ignoreCase
+	if right.field != nil {
+		if right.field.kind != kind {
+			return nil, p.errorf(opTok, "can't compare %s %q with %s %q", kind, left.text, right.field.kind, right.text)
+		}
+		getRight := right.field.get
+		return func(env *exprEnv) bool {
+			return test(compareValues(kind, get(env), getRight(env), ignoreCase))
+		}, nil
+	}
+	value, err := parseLiteral(kind, right.text)
+	if err != nil {
+		return nil, p.errorf(rightTok, "bad %s %q for %q: %v", kind, right.text, left.text, err)
+	}
+	return func(env *exprEnv) bool {
+		return test(compareValues(kind, get(env), value, ignoreCase))
+	}, nil
+}
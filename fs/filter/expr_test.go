@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFilterExprErrors(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{``, `expecting a field or a value at end of expression`},
+		{`size >`, `expecting a field or a value at end of expression`},
+		{`size > 1G &&`, `expecting a field or a value at end of expression`},
+		{`(size > 1G`, `expecting ")" at end of expression`},
+		{`size > 1G)`, `unexpected ")" at position 10`},
+		{`size`, `expecting a comparison after "size" at position 1`},
+		{`potato`, `expecting a comparison after "potato" at position 1`},
+		{`1 == 2`, `comparison of "1" and "2" needs a field at position 1`},
+		{`size > potato`, `bad size "potato" for "size": bad suffix 'o' at position 8`},
+		{`size == name`, `can't compare size "size" with string "name" at position 6`},
+		{`size ~ "1"`, `can't match size "size" with a pattern at position 1`},
+		{`name ~ "("`, "bad pattern: error parsing regexp: missing closing ): `(` at position 8"},
+		{`"a" ~ name`, `pattern for "~" must be on the right at position 7`},
+		{`name ~ path`, `pattern for "~" must be a string at position 8`},
+		{`name == "potato`, `unterminated string at position 9`},
+		{`name == 'potato`, `unterminated string at position 9`},
+		{`name = "potato"`, `unexpected '=' at position 6`},
+		{`meta[] == "a"`, `expecting a metadata key at position 6`},
+		{`meta["a" == "b"`, `expecting "]" at position 10`},
+	} {
+		_, err := newFilterExpr(test.in, false)
+		assert.EqualError(t, err, test.want, test.in)
+	}
+}
+
+func TestFilterExprInclude(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		in         string
+		remote     string
+		size       int64
+		modTime    time.Time
+		metadata   fs.Metadata
+		ignoreCase bool
+		want       bool
+	}{
+		{in: `true`, want: true},
+		{in: `false`, want: false},
+		{in: `size > 1M`, size: 2 << 20, want: true},
+		{in: `size > 1M`, size: 1 << 20, want: false},
+		{in: `size >= 1M`, size: 1 << 20, want: true},
+		{in: `1M < size`, size: 2 << 20, want: true},
+		{in: `1M < size`, size: 1 << 20, want: false},
+		{in: `size <= 100B`, size: 100, want: true},
+		{in: `name == "file.jpg"`, remote: "dir/file.jpg", want: true},
+		{in: `name == file.jpg`, remote: "dir/file.jpg", want: true},
+		{in: `name == "FILE.jpg"`, remote: "dir/file.jpg", want: false},
+		{in: `name == "FILE.jpg"`, remote: "dir/file.jpg", ignoreCase: true, want: true},
+		{in: `name != "file.jpg"`, remote: "dir/file.jpg", want: false},
+		{in: `path == "dir/file.jpg"`, remote: "dir/file.jpg", want: true},
+		{in: `ext == jpg`, remote: "dir/file.jpg", want: true},
+		{in: `ext`, remote: "dir/file", want: false},
+		{in: `!ext`, remote: "dir/file", want: true},
+		{in: `name ~ "^file\\.(jpg|png)$"`, remote: "dir/file.png", want: true},
+		{in: `name ~ '^file\.(jpg|png)$'`, remote: "dir/file.gif", want: false},
+		{in: `name !~ '^file\.(jpg|png)$'`, remote: "dir/file.gif", want: true},
+		{in: `path ~ "^DIR/"`, remote: "dir/file.jpg", ignoreCase: true, want: true},
+		{in: `mime ~ "^image/"`, remote: "file.jpg", want: true},
+		{in: `mime == "text/plain"`, remote: "file.jpg", metadata: fs.Metadata{"content-type": "text/plain"}, want: true},
+		{in: `age < 1d`, modTime: now.Add(-time.Hour), want: true},
+		{in: `age < 1d`, modTime: now.Add(-48 * time.Hour), want: false},
+		{in: `modtime >= 2023-01-01`, modTime: now, want: true},
+		{in: `modtime >= "2023-01-01 00:00:00"`, modTime: now, want: true},
+		{in: `modtime < 2023-01-01`, modTime: now, want: false},
+		{in: `meta[owner] == alice`, metadata: fs.Metadata{"owner": "alice"}, want: true},
+		{in: `meta["Owner"] == "alice"`, metadata: fs.Metadata{"owner": "bob"}, want: false},
+		{in: `meta["owner"]`, metadata: nil, want: false},
+		{in: `size > 1M && ext == jpg`, remote: "a.jpg", size: 2 << 20, want: true},
+		{in: `size > 1M && ext == jpg`, remote: "a.png", size: 2 << 20, want: false},
+		{in: `ext == png || ext == jpg && size > 1M`, remote: "a.png", want: true},
+		{in: `(ext == png || ext == jpg) && size > 1M`, remote: "a.png", want: false},
+		{in: `!(ext == png) && !(size > 1M)`, remote: "a.jpg", want: true},
+	} {
+		e, err := newFilterExpr(test.in, test.ignoreCase)
+		require.NoError(t, err, test.in)
+		e.now = now
+		got := e.include(test.remote, test.size, test.modTime, test.metadata, nil)
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+func TestFilterExprUses(t *testing.T) {
+	for _, test := range []struct {
+		in          string
+		usesModTime bool
+		usesMeta    bool
+	}{
+		{`size > 1M`, false, false},
+		{`age > 1d`, true, false},
+		{`modtime > 2023-01-01 || name == a`, true, false},
+		{`meta["owner"] == bob`, false, true},
+	} {
+		e, err := newFilterExpr(test.in, false)
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.usesModTime, e.usesModTime, test.in)
+		assert.Equal(t, test.usesMeta, e.usesMeta, test.in)
+	}
+}
+
+func TestNewFilterFilterExpr(t *testing.T) {
+	opt := DefaultOpt
+	opt.FilterExpr = `ext == jpg && size < 100B`
+	f, err := NewFilter(&opt)
+	require.NoError(t, err)
+	testInclude(t, f, []includeTest{
+		{"file1.jpg", 99, 0, true},
+		{"file2.jpg", 100, 0, false},
+		{"file3.png", 99, 0, false},
+	})
+	assert.False(t, f.InActive())
+	assert.Contains(t, f.DumpFilters(), "--- Filter expression ---\next == jpg && size < 100B")
+
+	opt.FilterExpr = `size >`
+	_, err = NewFilter(&opt)
+	assert.EqualError(t, err, "bad --filter-expr: expecting a field or a value at end of expression")
+}
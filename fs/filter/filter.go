@@ -24,6 +24,7 @@ type Opt struct {
 	DeleteExcluded bool
 	RulesOpt       // embedded so we don't change the JSON API
 	ExcludeFile    []string
+	IgnoreFile     []string
 	FilesFrom      []string
 	FilesFromRaw   []string
 	MetaRules      RulesOpt
@@ -31,6 +32,7 @@ type Opt struct {
 	MaxAge         fs.Duration
 	MinSize        fs.SizeSuffix
 	MaxSize        fs.SizeSuffix
+	FilterExpr     string
 	IgnoreCase     bool
 }
 
@@ -53,8 +55,10 @@ type Filter struct {
 	fileRules   rules
 	dirRules    rules
 	metaRules   rules
-	files       FilesMap // files if filesFrom
-	dirs        FilesMap // dirs from filesFrom
+	files       FilesMap     // files if filesFrom
+	dirs        FilesMap     // dirs from filesFrom
+	ignoreFiles *ignoreFiles // rules read from IgnoreFile in each directory
+	expr        *filterExpr  // parsed FilterExpr
 }
 
 // NewFilter parses the command line options and creates a Filter
@@ -92,6 +96,17 @@ func NewFilter(opt *Opt) (f *Filter, err error) {
 		return nil, err
 	}
 
+	if len(f.Opt.IgnoreFile) > 0 {
+		f.ignoreFiles = newIgnoreFiles()
+	}
+
+	if f.Opt.FilterExpr != "" {
+		f.expr, err = newFilterExpr(f.Opt.FilterExpr, f.Opt.IgnoreCase)
+		if err != nil {
+			return nil, fmt.Errorf("bad --filter-expr: %w", err)
+		}
+	}
+
 	inActive := f.InActive()
 
 	for _, rule := range f.Opt.FilesFrom {
@@ -254,7 +269,9 @@ func (f *Filter) InActive() bool {
 		f.fileRules.len() == 0 &&
 		f.dirRules.len() == 0 &&
 		f.metaRules.len() == 0 &&
-		len(f.Opt.ExcludeFile) == 0)
+		len(f.Opt.ExcludeFile) == 0 &&
+		len(f.Opt.IgnoreFile) == 0 &&
+		f.expr == nil)
 }
 
 // IncludeRemote returns whether this remote passes the filter rules.
@@ -306,6 +323,9 @@ func (f *Filter) IncludeDirectory(ctx context.Context, fs fs.Fs) func(string) (b
 			_, include := f.dirs[remote]
 			return include, nil
 		}
+		if f.ignoreFiles != nil && f.ignoreFiles.excluded(ignoreFsFromContext(ctx, fs), remote, true) {
+			return false, nil
+		}
 		remote += "/"
 		return f.dirRules.include(remote), nil
 	}
@@ -332,6 +352,13 @@ func (f *Filter) DirContainsExcludeFile(ctx context.Context, fremote fs.Fs, remo
 // Include returns whether this object should be included into the
 // sync or not
 func (f *Filter) Include(remote string, size int64, modTime time.Time, metadata fs.Metadata) bool {
+	return f.include(remote, size, modTime, metadata, nil)
+}
+
+// include returns whether this object should be included into the
+// sync or not. mimeType is used to read the MIME type if the filter
+// expression needs it and may be nil.
+func (f *Filter) include(remote string, size int64, modTime time.Time, metadata fs.Metadata, mimeType func() string) bool {
 	// filesFrom takes precedence
 	if f.files != nil {
 		_, include := f.files[remote]
@@ -349,6 +376,9 @@ func (f *Filter) Include(remote string, size int64, modTime time.Time, metadata
 	if f.Opt.MaxSize >= 0 && size > int64(f.Opt.MaxSize) {
 		return false
 	}
+	if f.expr != nil && !f.expr.include(remote, size, modTime, metadata, mimeType) {
+		return false
+	}
 	if f.metaRules.len() > 0 {
 		metadatas := make([]string, 0, len(metadata)+1)
 		for key, value := range metadata {
@@ -369,16 +399,21 @@ func (f *Filter) Include(remote string, size int64, modTime time.Time, metadata
 // IncludeObject returns whether this object should be included into
 // the sync or not. This is a convenience function to avoid calling
 // o.ModTime(), which is an expensive operation.
+//
+// This also checks the rules read from the --ignore-file files.
 func (f *Filter) IncludeObject(ctx context.Context, o fs.Object) bool {
+	if f.files == nil && f.ignoreFiles != nil && f.ignoreFiles.excluded(ignoreFsFromContext(ctx, o.Fs()), o.Remote(), false) {
+		return false
+	}
 	var modTime time.Time
 
-	if !f.ModTimeFrom.IsZero() || !f.ModTimeTo.IsZero() {
+	if !f.ModTimeFrom.IsZero() || !f.ModTimeTo.IsZero() || (f.expr != nil && f.expr.usesModTime) {
 		modTime = o.ModTime(ctx)
 	} else {
 		modTime = time.Unix(0, 0)
 	}
 	var metadata fs.Metadata
-	if f.metaRules.len() > 0 {
+	if f.metaRules.len() > 0 || (f.expr != nil && f.expr.usesMeta) {
 		var err error
 		metadata, err = fs.GetMetadata(ctx, o)
 		if err != nil {
@@ -387,7 +422,9 @@ func (f *Filter) IncludeObject(ctx context.Context, o fs.Object) bool {
 		}
 
 	}
-	return f.Include(o.Remote(), o.Size(), modTime, metadata)
+	return f.include(o.Remote(), o.Size(), modTime, metadata, func() string {
+		return fs.MimeType(ctx, o)
+	})
 }
 
 // DumpFilters dumps the filters in textual form, 1 per line
@@ -407,6 +444,14 @@ func (f *Filter) DumpFilters() string {
 	for _, dirRule := range f.dirRules.rules {
 		rules = append(rules, dirRule.String())
 	}
+	if len(f.Opt.IgnoreFile) > 0 {
+		rules = append(rules, "--- Ignore files read in each directory ---")
+		rules = append(rules, f.Opt.IgnoreFile...)
+	}
+	if f.expr != nil {
+		rules = append(rules, "--- Filter expression ---")
+		rules = append(rules, f.expr.text)
+	}
 	if f.metaRules.len() > 0 {
 		rules = append(rules, "--- Metadata filter rules ---")
 		for _, metaRule := range f.metaRules.rules {
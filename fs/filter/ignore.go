@@ -0,0 +1,348 @@
+// Rules read from gitignore style files in each directory
+
+package filter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// maxIgnoreFileSize is the largest ignore file which will be read
+const maxIgnoreFileSize = 1024 * 1024
+
+// ignoreRule is one rule from an ignore file
+type ignoreRule struct {
+	include bool           // set for ! rules which include the path again
+	dirOnly bool           // set if the rule only matches directories
+	re      *regexp.Regexp // matches the path relative to the directory of the ignore file
+}
+
+// ignoreRules are the rules read from one ignore file
+type ignoreRules []ignoreRule
+
+// match returns whether any of the rules match the path relative to
+// the directory of the ignore file and if so whether it is excluded.
+//
+// As in gitignore the last matching rule wins.
+func (rs ignoreRules) match(relative string, isDir bool) (matched bool, excluded bool) {
+	for i := len(rs) - 1; i >= 0; i-- {
+		r := rs[i]
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(relative) {
+			return true, !r.include
+		}
+	}
+	return false, false
+}
+
+// ignoreToRegexp converts a gitignore pattern into a regexp matching
+// paths relative to the directory of the ignore file.
+//
+// The pattern should have had any leading ! and trailing / removed.
+func ignoreToRegexp(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	var re strings.Builder
+	if ignoreCase {
+		re.WriteString("(?i)")
+	}
+	re.WriteString("^")
+	// Patterns with a / are anchored to the directory, otherwise
+	// they match at any level below it
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/") && (i == 0 || pattern[i-1] == '/'):
+			re.WriteString("(?:.*/)?")
+			i += 3
+		case pattern[i:] == "**" && i > 0 && pattern[i-1] == '/':
+			re.WriteString(".*")
+			i += 2
+		case c == '*':
+			re.WriteString("[^/]*")
+			i++
+		case c == '?':
+			re.WriteString("[^/]")
+			i++
+		case c == '[':
+			class, n := ignoreClass(pattern[i:])
+			if n == 0 {
+				re.WriteString(`\[`)
+				i++
+			} else {
+				re.WriteString(class)
+				i += n
+			}
+		case c == '\\' && i+1 < len(pattern):
+			re.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i += 2
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			i++
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+// ignoreClass converts the character class at the start of pattern
+// into a regexp returning it and the number of bytes of pattern used.
+//
+// If there is no closing ] then it returns 0 bytes used.
+func ignoreClass(pattern string) (class string, n int) {
+	var re strings.Builder
+	re.WriteString("[")
+	i := 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		re.WriteString("^")
+		i++
+	}
+	for start := i; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == ']' && i > start:
+			re.WriteString("]")
+			return re.String(), i + 1
+		case c == '-':
+			re.WriteByte(c)
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			re.WriteString(classChar(pattern[i]))
+		default:
+			re.WriteString(classChar(c))
+		}
+	}
+	return "", 0
+}
+
+// classChar returns c quoted for use in a regexp character class
+func classChar(c byte) string {
+	if c >= 0x80 || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+		return string([]byte{c})
+	}
+	return `\` + string([]byte{c})
+}
+
+// parseIgnoreFile parses the contents of a gitignore style file
+func parseIgnoreFile(data []byte, ignoreCase bool) (rules ignoreRules, err error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		// Remove trailing spaces unless they are quoted with \
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		var r ignoreRule
+		if line[0] == '!' {
+			r.include = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.re, err = ignoreToRegexp(line, ignoreCase)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern %q: %w", line, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// ignoreKey indexes the rules read from the ignore files
+type ignoreKey struct {
+	fs  string // the Fs the ignore files were read from as returned by ignoreFsName
+	dir string // the directory the ignore files were read from
+}
+
+// ignoreFiles holds the rules read from the ignore files found in
+// each directory
+type ignoreFiles struct {
+	mu   sync.RWMutex
+	dirs map[ignoreKey][]ignoreRules // rules for each ignore file name indexed as Opt.IgnoreFile
+}
+
+// newIgnoreFiles makes an empty ignoreFiles
+func newIgnoreFiles() *ignoreFiles {
+	return &ignoreFiles{
+		dirs: map[ignoreKey][]ignoreRules{},
+	}
+}
+
+// set the rules read from the ignore files in a directory, removing
+// any rules read before if files is empty
+func (ifs *ignoreFiles) set(key ignoreKey, files []ignoreRules) {
+	ifs.mu.Lock()
+	defer ifs.mu.Unlock()
+	if len(files) == 0 {
+		delete(ifs.dirs, key)
+		return
+	}
+	ifs.dirs[key] = files
+}
+
+// excludedPath returns whether the rules read from fsName in the
+// directories above remote exclude it, ignoring whether its parents
+// are excluded.
+//
+// The rules from the deepest directory take precedence, as do the
+// rules from later ignore file names.
+//
+// mu must be held when calling this
+func (ifs *ignoreFiles) excludedPath(fsName, remote string, isDir bool) bool {
+	for dir := remote; dir != ""; {
+		if slash := strings.LastIndexByte(dir, '/'); slash >= 0 {
+			dir = dir[:slash]
+		} else {
+			dir = ""
+		}
+		files := ifs.dirs[ignoreKey{fs: fsName, dir: dir}]
+		if files == nil {
+			continue
+		}
+		relative := remote
+		if dir != "" {
+			relative = remote[len(dir)+1:]
+		}
+		for i := len(files) - 1; i >= 0; i-- {
+			if matched, excluded := files[i].match(relative, isDir); matched {
+				return excluded
+			}
+		}
+	}
+	return false
+}
+
+// excluded returns whether remote is excluded by the ignore files
+// read from fsName.
+//
+// As in gitignore a path is excluded if any of its parent directories
+// are, even if a rule would include the path itself.
+func (ifs *ignoreFiles) excluded(fsName, remote string, isDir bool) bool {
+	remote = strings.Trim(remote, "/")
+	ifs.mu.RLock()
+	defer ifs.mu.RUnlock()
+	if len(ifs.dirs) == 0 {
+		return false
+	}
+	for i := 0; i < len(remote); i++ {
+		if remote[i] == '/' && ifs.excludedPath(fsName, remote[:i], true) {
+			return true
+		}
+	}
+	return ifs.excludedPath(fsName, remote, isDir)
+}
+
+// ignoreFsName returns the name the rules read from f are stored under
+func ignoreFsName(f fs.Info) string {
+	if f == nil {
+		return ""
+	}
+	return f.Name() + ":" + f.Root()
+}
+
+// Context key for the Fs the ignore files are read from
+type ignoreFsContextKeyType struct{}
+
+var ignoreFsContextKey = ignoreFsContextKeyType{}
+
+// SetIgnoreFs returns a context which makes the listings done with it
+// read the --ignore-file rules from f only and apply those rules to
+// the listings of any Fs.
+//
+// This is used when syncing so the rules are read from the source and
+// the destination is filtered with the same rules.
+func SetIgnoreFs(ctx context.Context, f fs.Info) context.Context {
+	return context.WithValue(ctx, ignoreFsContextKey, ignoreFsName(f))
+}
+
+// ignoreFsFromContext returns the name of the Fs whose ignore files
+// apply to the listings of f done with ctx
+func ignoreFsFromContext(ctx context.Context, f fs.Info) string {
+	if ctx != nil {
+		if name, ok := ctx.Value(ignoreFsContextKey).(string); ok {
+			return name
+		}
+	}
+	return ignoreFsName(f)
+}
+
+// LoadIgnoreFiles reads the rules from the ignore files set with
+// --ignore-file in entries, the listing of dir on fremote.
+//
+// This should be called with the listing of a directory before its
+// entries are filtered as the rules apply to the directory itself.
+//
+// If ctx was made with SetIgnoreFs for a different Fs then the ignore
+// files aren't read.
+func (f *Filter) LoadIgnoreFiles(ctx context.Context, fremote fs.Info, dir string, entries fs.DirEntries) error {
+	if f.ignoreFiles == nil {
+		return nil
+	}
+	fsName := ignoreFsName(fremote)
+	if fsName != ignoreFsFromContext(ctx, fremote) {
+		return nil
+	}
+	var files []ignoreRules
+	for i, name := range f.Opt.IgnoreFile {
+		for _, entry := range entries {
+			o, ok := entry.(fs.Object)
+			if !ok || path.Base(o.Remote()) != name {
+				continue
+			}
+			rules, err := readIgnoreFile(ctx, o, f.Opt.IgnoreCase)
+			if err != nil {
+				return fmt.Errorf("failed to read ignore file %q: %w", o.Remote(), err)
+			}
+			fs.Debugf(o, "Read %d rules from ignore file", len(rules))
+			if files == nil {
+				files = make([]ignoreRules, len(f.Opt.IgnoreFile))
+			}
+			files[i] = rules
+		}
+	}
+	// This clears the rules if the ignore files have gone
+	f.ignoreFiles.set(ignoreKey{fs: fsName, dir: dir}, files)
+	return nil
+}
+
+// readIgnoreFile reads and parses the ignore file o
+func readIgnoreFile(ctx context.Context, o fs.Object, ignoreCase bool) (rules ignoreRules, err error) {
+	if o.Size() > maxIgnoreFileSize {
+		return nil, fmt.Errorf("file is bigger than %d bytes", maxIgnoreFileSize)
+	}
+	in, err := o.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(in, maxIgnoreFileSize+1))
+	closeErr := in.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxIgnoreFileSize {
+		return nil, fmt.Errorf("file is bigger than %d bytes", maxIgnoreFileSize)
+	}
+	return parseIgnoreFile(data, ignoreCase)
+}
@@ -0,0 +1,196 @@
+package filter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockdir"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreToRegexp(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.o", "a.o", true},
+		{"*.o", "dir/sub/a.o", true},
+		{"*.o", "a.oo", false},
+		{"/a.o", "a.o", true},
+		{"/a.o", "dir/a.o", false},
+		{"dir/a.o", "dir/a.o", true},
+		{"dir/a.o", "sub/dir/a.o", false},
+		{"**/dir/a.o", "sub/dir/a.o", true},
+		{"**/dir/a.o", "dir/a.o", true},
+		{"dir/**", "dir/a/b", true},
+		{"dir/**", "dir", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a**b", "axxb", true},
+		{"a**b", "ax/xb", false},
+		{"a?c", "abc", true},
+		{"a?c", "a/c", false},
+		{"a.b", "axb", false},
+		{"[!a]b", "cb", true},
+		{"[!a]b", "ab", false},
+		{"[a-c]b", "bb", true},
+		{"[]]b", "]b", true},
+		{"[.]b", ".b", true},
+		{"[.]b", "xb", false},
+		{`[\d]`, "d", true},
+		{`[\d]`, "1", false},
+		{"[x", "[x", true},
+		{`\#x`, "#x", true},
+		{"é*", "été", true},
+	} {
+		re, err := ignoreToRegexp(test.pattern, false)
+		require.NoError(t, err, test.pattern)
+		assert.Equal(t, test.want, re.MatchString(test.path), "%q matching %q with %q", test.pattern, test.path, re)
+	}
+
+	re, err := ignoreToRegexp("*.JPG", true)
+	require.NoError(t, err)
+	assert.True(t, re.MatchString("dir/photo.jpg"))
+}
+
+func TestIgnoreFilesExcluded(t *testing.T) {
+	rules, err := parseIgnoreFile([]byte("# comment\n\n*.log\n!keep.log\nbuild/\n/top  \nspace\\ \r\n\\!bang\n"), false)
+	require.NoError(t, err)
+	require.Len(t, rules, 6)
+	subRules, err := parseIgnoreFile([]byte("!*.log\ndeep.txt\n"), false)
+	require.NoError(t, err)
+
+	ifs := newIgnoreFiles()
+	assert.False(t, ifs.excluded("", "a.log", false))
+	ifs.set(ignoreKey{dir: ""}, []ignoreRules{rules})
+	ifs.set(ignoreKey{dir: "sub"}, []ignoreRules{subRules})
+
+	for _, test := range []struct {
+		remote string
+		isDir  bool
+		want   bool
+	}{
+		{"", true, false},
+		{"a.log", false, true},
+		{"keep.log", false, false},
+		{"dir/a.log", false, true},
+		{"sub/a.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"dir/build/a.txt", false, true},
+		{"top", false, true},
+		{"dir/top", false, false},
+		{"space ", false, true},
+		{"!bang", false, true},
+		{"deep.txt", false, false},
+		{"sub/deep.txt", false, true},
+		{"sub/dir/deep.txt", false, true},
+		{"/sub/dir/deep.txt/", true, true},
+	} {
+		assert.Equal(t, test.want, ifs.excluded("", test.remote, test.isDir), test.remote)
+		assert.False(t, ifs.excluded("other:", test.remote, test.isDir), test.remote)
+	}
+}
+
+func TestFilterLoadIgnoreFiles(t *testing.T) {
+	ctx := context.Background()
+	opt := DefaultOpt
+	opt.IgnoreFile = []string{".gitignore", ".rcloneignore"}
+	f, err := NewFilter(&opt)
+	require.NoError(t, err)
+	assert.False(t, f.InActive())
+	include := func(remote string) bool {
+		return f.IncludeObject(ctx, mockobject.Object(remote))
+	}
+
+	// Nothing is excluded until the ignore files are read
+	includeDirectory := f.IncludeDirectory(ctx, nil)
+	assert.True(t, include("a.log"))
+	includeDir, err := includeDirectory("tmp")
+	require.NoError(t, err)
+	assert.True(t, includeDir)
+
+	entries := fs.DirEntries{
+		mockobject.Object(".rcloneignore").WithContent([]byte("!b.log\n"), mockobject.SeekModeNone),
+		mockobject.Object(".gitignore").WithContent([]byte("*.log\ntmp/\n"), mockobject.SeekModeNone),
+		mockobject.Object("a.log"),
+		mockdir.New("tmp"),
+	}
+	require.NoError(t, f.LoadIgnoreFiles(ctx, nil, "", entries))
+
+	assert.False(t, include("a.log"))
+	assert.False(t, include("dir/a.log"))
+	assert.True(t, include("b.log"))
+	assert.True(t, include("a.txt"))
+	assert.False(t, include("tmp/a.txt"))
+	includeDir, err = includeDirectory("tmp")
+	require.NoError(t, err)
+	assert.False(t, includeDir)
+	includeDir, err = includeDirectory("dir")
+	require.NoError(t, err)
+	assert.True(t, includeDir)
+
+	// Rules in sub directories are scoped to that directory
+	entries = fs.DirEntries{
+		mockobject.Object("dir/.gitignore").WithContent([]byte("!a.log\n/c.txt\n"), mockobject.SeekModeNone),
+	}
+	require.NoError(t, f.LoadIgnoreFiles(ctx, nil, "dir", entries))
+	assert.True(t, include("dir/a.log"))
+	assert.False(t, include("dir/c.txt"))
+	assert.True(t, include("dir/sub/c.txt"))
+	assert.True(t, include("c.txt"))
+	assert.False(t, include("a.log"))
+
+	// The rules are removed if the ignore files go away
+	require.NoError(t, f.LoadIgnoreFiles(ctx, nil, "dir", fs.DirEntries{mockobject.Object("dir/c.txt")}))
+	assert.False(t, include("dir/a.log"))
+	assert.True(t, include("dir/c.txt"))
+
+	// Bad patterns are an error
+	entries = fs.DirEntries{
+		mockobject.Object("bad/.gitignore").WithContent([]byte("[z-a]\n"), mockobject.SeekModeNone),
+	}
+	assert.Error(t, f.LoadIgnoreFiles(ctx, nil, "bad", entries))
+}
+
+func TestFilterSetIgnoreFs(t *testing.T) {
+	ctx := context.Background()
+	opt := DefaultOpt
+	opt.IgnoreFile = []string{".gitignore"}
+	f, err := NewFilter(&opt)
+	require.NoError(t, err)
+	fsrc := mockfs.NewFs(ctx, "src", "root")
+	fdst := mockfs.NewFs(ctx, "dst", "root")
+	srcCtx := SetIgnoreFs(ctx, fsrc)
+	include := func(ctx context.Context, remote string) bool {
+		return f.IncludeObject(ctx, mockobject.Object(remote))
+	}
+
+	// The ignore files in the dst aren't read
+	entries := fs.DirEntries{
+		mockobject.Object(".gitignore").WithContent([]byte("*.txt\n"), mockobject.SeekModeNone),
+	}
+	require.NoError(t, f.LoadIgnoreFiles(srcCtx, fdst, "", entries))
+	assert.True(t, include(srcCtx, "a.txt"))
+
+	// The rules from the src apply to listings of both
+	entries = fs.DirEntries{
+		mockobject.Object(".gitignore").WithContent([]byte("*.log\n"), mockobject.SeekModeNone),
+	}
+	require.NoError(t, f.LoadIgnoreFiles(srcCtx, fsrc, "", entries))
+	assert.False(t, include(srcCtx, "a.log"))
+	assert.True(t, include(srcCtx, "a.txt"))
+	includeDir, err := f.IncludeDirectory(srcCtx, fdst)("sub.log")
+	require.NoError(t, err)
+	assert.False(t, includeDir)
+
+	// Rules read from one Fs don't apply to another without SetIgnoreFs
+	includeDir, err = f.IncludeDirectory(ctx, fdst)("sub.log")
+	require.NoError(t, err)
+	assert.True(t, includeDir)
+}
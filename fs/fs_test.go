@@ -15,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/lib/pacer"
 	"github.com/stretchr/testify/assert"
@@ -380,3 +381,139 @@ func TestOptionGetters(t *testing.T) {
 	}
 
 }
+
+func TestSecretGetter(t *testing.T) {
+	oldConfigSecretGet := ConfigSecretGet
+	reads := 0
+	ConfigSecretGet = func(name string) (string, error) {
+		reads++
+		if name == "potato" {
+			return "jersey royal", nil
+		}
+		return "", errors.New("secret not found")
+	}
+	defer func() {
+		ConfigSecretGet = oldConfigSecretGet
+		secretCache = map[string]string{}
+	}()
+
+	fsInfo := &RegInfo{
+		Name:   "local",
+		Prefix: "local",
+		Options: Options{{
+			Name:       "pass",
+			IsPassword: true,
+		}},
+	}
+	values := configmap.Simple{
+		"user":    "secret://potato",
+		"pass":    "secret://potato",
+		"missing": "secret://missing",
+		"plain":   "potato",
+	}
+	errs := new(secretErrors)
+	getter := secretGetter{values, fsInfo, errs}
+
+	value, ok := getter.Get("user")
+	assert.True(t, ok)
+	assert.Equal(t, "jersey royal", value)
+
+	// Passwords are returned obscured
+	value, ok = getter.Get("pass")
+	assert.True(t, ok)
+	assert.Equal(t, "jersey royal", obscure.MustReveal(value))
+
+	// The secret was only read once
+	assert.Equal(t, 1, reads)
+
+	value, ok = getter.Get("plain")
+	assert.True(t, ok)
+	assert.Equal(t, "potato", value)
+
+	_, ok = getter.Get("not_found")
+	assert.False(t, ok)
+	assert.NoError(t, errs.Err())
+
+	// Secrets which can't be read are recorded as errors
+	value, ok = getter.Get("missing")
+	assert.False(t, ok)
+	assert.Equal(t, "", value)
+	assert.EqualError(t, errs.Err(), `failed to read secret "missing" for config "missing": secret not found`)
+}
+
+func TestConfigFsSecretError(t *testing.T) {
+	oldConfigSecretGet := ConfigSecretGet
+	ConfigSecretGet = func(name string) (string, error) {
+		return "", errors.New("secret not found")
+	}
+	defer func() {
+		ConfigSecretGet = oldConfigSecretGet
+	}()
+	Register(&RegInfo{
+		Name: "secrettest",
+		Options: Options{{
+			Name:       "pass",
+			IsPassword: true,
+		}},
+	})
+
+	_, _, _, _, err := ConfigFs(":secrettest:")
+	assert.NoError(t, err)
+
+	_, _, _, _, err = ConfigFs(":secrettest,pass='secret://missing':")
+	assert.EqualError(t, err, `failed to read secret "missing" for config "pass": secret not found`)
+}
+
+func TestConfigInherit(t *testing.T) {
+	config := map[string]map[string]string{
+		"s3-base":    {"type": "s3", "provider": "AWS", "region": "eu-west-1"},
+		"child":      {"inherit": "s3-base", "region": "us-east-1", "provider": "", "empty": ""},
+		"grandchild": {"inherit": "child", "bucket": "potato"},
+		"loop1":      {"inherit": "loop2", "key": "loop1"},
+		"loop2":      {"inherit": "loop1", "other": "loop2"},
+	}
+	oldConfigFileGet := ConfigFileGet
+	ConfigFileGet = func(section, key string) (string, bool) {
+		value, ok := config[section][key]
+		return value, ok
+	}
+	defer func() {
+		ConfigFileGet = oldConfigFileGet
+	}()
+
+	assert.Equal(t, []string{"s3-base"}, ConfigInheritChain("s3-base"))
+	assert.Equal(t, []string{"grandchild", "child", "s3-base"}, ConfigInheritChain("grandchild"))
+	assert.Equal(t, []string{"loop1", "loop2"}, ConfigInheritChain("loop1"))
+	assert.Equal(t, []string{"missing"}, ConfigInheritChain("missing"))
+
+	getter := getConfigFile("grandchild")
+	for _, test := range []struct {
+		key       string
+		wantValue string
+		wantOk    bool
+	}{
+		{"type", "s3", true},
+		{"provider", "", false},
+		{"region", "us-east-1", true},
+		{"bucket", "potato", true},
+		{"inherit", "child", true},
+		{"empty", "", false},
+		{"not_found", "", false},
+	} {
+		gotValue, gotOk := getter.Get(test.key)
+		assert.Equal(t, test.wantValue, gotValue, test.key)
+		assert.Equal(t, test.wantOk, gotOk, test.key)
+	}
+
+	// Only the sections after the empty value are overridden
+	value, ok := getConfigFile("s3-base").Get("provider")
+	assert.True(t, ok)
+	assert.Equal(t, "AWS", value)
+
+	// Loops don't hang
+	value, ok = getConfigFile("loop1").Get("other")
+	assert.True(t, ok)
+	assert.Equal(t, "loop2", value)
+	_, ok = getConfigFile("loop2").Get("missing")
+	assert.False(t, ok)
+}
@@ -449,6 +449,51 @@ func ShouldRetryHTTP(resp *http.Response, retryErrorCodes []int) bool {
 	return false
 }
 
+// rateLimitErrorStrings is a list of phrases which when we find it in
+// an error, we know the remote is rate limiting.
+var rateLimitErrorStrings = []string{
+	http.StatusText(http.StatusTooManyRequests),    // HTTP 429
+	http.StatusText(http.StatusServiceUnavailable), // HTTP 503
+	"SlowDown",          // s3
+	"rateLimitExceeded", // google
+	"too_many_requests", // b2
+}
+
+// IsRateLimited looks at an error and tries to work out if the remote
+// is rate limiting, eg by returning HTTP 429 Too Many Requests or 503
+// Service Unavailable.
+func IsRateLimited(err error) (rateLimited bool) {
+	if err == nil {
+		return false
+	}
+	if IsRetryAfterError(err) {
+		return true
+	}
+	liberrors.Walk(err, func(err error) bool {
+		// Check for an HTTP status, eg from the AWS SDK
+		if x, ok := err.(interface {
+			StatusCode() int
+		}); ok {
+			switch x.StatusCode() {
+			case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+				rateLimited = true
+				return true
+			}
+		}
+		return false
+	})
+	if rateLimited {
+		return true
+	}
+	errString := err.Error()
+	for _, phrase := range rateLimitErrorStrings {
+		if strings.Contains(errString, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
 // ContextError checks to see if ctx is in error.
 //
 // If it is in error then it overwrites *perr with the context error
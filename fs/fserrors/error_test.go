@@ -196,6 +196,33 @@ func TestRetryAfter(t *testing.T) {
 	assert.Contains(t, e.Error(), "try again after")
 }
 
+type statusCodeError int
+
+func (e statusCodeError) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusCodeError) StatusCode() int { return int(e) }
+
+func TestIsRateLimited(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, false},
+		{errors.New("potato"), false},
+		{NewErrorRetryAfter(time.Second), true},
+		{statusCodeError(429), true},
+		{statusCodeError(503), true},
+		{statusCodeError(500), false},
+		{fmt.Errorf("wrapped: %w", statusCodeError(429)), true},
+		{RetryError(statusCodeError(503)), true},
+		{errors.New("HTTP error 429 (429 Too Many Requests)"), true},
+		{errors.New("SlowDown: Please reduce your request rate"), true},
+		{errors.New("HTTP error 500 (500 Internal Server Error)"), false},
+	} {
+		assert.Equal(t, test.want, IsRateLimited(test.err), fmt.Sprintf("%v", test.err))
+	}
+}
+
 func TestContextError(t *testing.T) {
 	var err = io.EOF
 	ctx, cancel := context.WithCancel(context.Background())
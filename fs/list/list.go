@@ -33,6 +33,13 @@ func DirSorted(ctx context.Context, f fs.Fs, includeAll bool, dir string) (entri
 		fs.Debugf(dir, "Excluded")
 		return nil, nil
 	}
+	if !includeAll {
+		// Read the rules for this directory before filtering it
+		err = fi.LoadIgnoreFiles(ctx, f, dir, entries)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return filterAndSortDir(ctx, entries, includeAll, dir, fi.IncludeObject, fi.IncludeDirectory(ctx, f))
 }
 
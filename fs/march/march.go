@@ -35,6 +35,7 @@ type March struct {
 	srcListDir listDirFn // function to call to list a directory in the src
 	dstListDir listDirFn // function to call to list a directory in the dst
 	transforms []matchTransformFn
+	srcFirst   bool // list the src before the dst as the dst filters need the src ignore files
 }
 
 // Marcher is called on each match
@@ -51,6 +52,8 @@ type Marcher interface {
 // Note: this will flag filter-aware backends on the source side
 func (m *March) init(ctx context.Context) {
 	ci := fs.GetConfig(ctx)
+	fi := filter.GetConfig(ctx)
+	m.srcFirst = len(fi.Opt.IgnoreFile) > 0
 	m.srcListDir = m.makeListDir(ctx, m.Fsrc, m.SrcIncludeAll)
 	if !m.NoTraverse {
 		m.dstListDir = m.makeListDir(ctx, m.Fdst, m.DstIncludeAll)
@@ -80,10 +83,16 @@ type listDirFn func(dir string) (entries fs.DirEntries, err error)
 func (m *March) makeListDir(ctx context.Context, f fs.Fs, includeAll bool) listDirFn {
 	ci := fs.GetConfig(ctx)
 	fi := filter.GetConfig(ctx)
-	if !(ci.UseListR && f.Features().ListR != nil) && // !--fast-list active and
+	listCtx := m.Ctx
+	if len(fi.Opt.IgnoreFile) > 0 {
+		// Read the ignore files from the src only and filter the dst with them too
+		listCtx = filter.SetIgnoreFs(listCtx, m.Fsrc)
+	}
+	if (!(ci.UseListR && f.Features().ListR != nil) || // (!--fast-list active or
+		len(fi.Opt.IgnoreFile) > 0) && // --ignore-file which needs each src directory listed first) and
 		!(ci.NoTraverse && fi.HaveFilesFrom()) { // !(--files-from and --no-traverse)
 		return func(dir string) (entries fs.DirEntries, err error) {
-			dirCtx := filter.SetUseFilter(m.Ctx, f.Features().FilterAware && !includeAll) // make filter-aware backends constrain List
+			dirCtx := filter.SetUseFilter(listCtx, f.Features().FilterAware && !includeAll) // make filter-aware backends constrain List
 			return list.DirSorted(dirCtx, f, includeAll, dir)
 		}
 	}
@@ -395,6 +404,9 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 			defer wg.Done()
 			srcList, srcListErr = m.srcListDir(job.srcRemote)
 		}()
+		if m.srcFirst {
+			wg.Wait()
+		}
 	}
 	if !m.NoTraverse && !job.noDst {
 		wg.Add(1)
@@ -50,7 +50,7 @@ func NewFs(ctx context.Context, path string) (Fs, error) {
 		// These need to work as filesystem names as the VFS cache will use them
 		configName += suffix
 	}
-	f, err := fsInfo.NewFs(ctx, configName, fsPath, config)
+	f, err := fsInfo.NewFs(withPacerName(ctx, configName), configName, fsPath, config)
 	if f != nil && (err == nil || err == ErrorIsFile) {
 		addReverse(f, fsInfo)
 	}
@@ -63,13 +63,22 @@ func NewFs(ctx context.Context, path string) (Fs, error) {
 //
 // Remotes are looked up in the config file.  If the remote isn't
 // found then NotFoundInConfigFile will be returned.
+//
+// Any secret:// references in the config are read here and an error
+// is returned if they can't be.
 func ConfigFs(path string) (fsInfo *RegInfo, configName, fsPath string, config *configmap.Map, err error) {
 	// Parse the remote path
 	fsInfo, configName, fsPath, connectionStringConfig, err := ParseRemote(path)
 	if err != nil {
 		return
 	}
-	config = ConfigMap(fsInfo, configName, connectionStringConfig)
+	config, errs := configMap(fsInfo, configName, connectionStringConfig)
+	// Read the options now so any secret:// references which
+	// can't be read are reported as an error
+	for _, opt := range fsInfo.Options {
+		_, _ = config.Get(opt.Name)
+	}
+	err = errs.Err()
 	return
 }
 
@@ -263,15 +263,30 @@ func (o *MemoryObject) SetModTime(ctx context.Context, modTime time.Time) error
 	return nil
 }
 
+// clampOffset returns offset limited to the size of the content
+func (o *MemoryObject) clampOffset(offset int64) int64 {
+	if offset < 0 {
+		return 0
+	}
+	if offset > o.Size() {
+		return o.Size()
+	}
+	return offset
+}
+
 // Open opens the file for read.  Call Close() on the returned io.ReadCloser
 func (o *MemoryObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
 	content := o.content
 	for _, option := range options {
 		switch x := option.(type) {
 		case *fs.RangeOption:
-			content = o.content[x.Start:x.End]
+			offset, limit := x.Decode(o.Size())
+			content = o.content[o.clampOffset(offset):]
+			if limit >= 0 && limit < int64(len(content)) {
+				content = content[:limit]
+			}
 		case *fs.SeekOption:
-			content = o.content[x.Offset:]
+			content = o.content[o.clampOffset(x.Offset):]
 		default:
 			if option.Mandatory() {
 				fs.Logf(o, "Unsupported mandatory option: %v", option)
@@ -126,7 +126,11 @@ func TestMemoryObject(t *testing.T) {
 
 	rc, err := o.Open(context.Background(), &fs.RangeOption{Start: 1, End: 3})
 	assert.NoError(t, err)
-	checkOpen(rc, "ot")
+	checkOpen(rc, "ota")
+
+	rc, err = o.Open(context.Background(), &fs.RangeOption{Start: -1, End: 2})
+	assert.NoError(t, err)
+	checkOpen(rc, "to")
 
 	rc, err = o.Open(context.Background(), &fs.SeekOption{Offset: 3})
 	assert.NoError(t, err)
@@ -104,3 +104,43 @@ func TestListDirSorted(t *testing.T) {
 	assert.Equal(t, "sub dir/ignore dir/.ignore", str(0))
 	assert.Equal(t, "sub dir/ignore dir/should be ignored", str(1))
 }
+
+// TestListDirSortedIgnoreFile tests the rules in --ignore-file are read
+// while listing
+func TestListDirSortedIgnoreFile(t *testing.T) {
+	r := fstest.NewRun(t)
+
+	files := []fstest.Item{
+		r.WriteObject(context.Background(), ".gitignore", "*.o\n/build/\n", t1),
+		r.WriteObject(context.Background(), "a.c", "main", t1),
+		r.WriteObject(context.Background(), "a.o", "object", t1),
+		r.WriteObject(context.Background(), "build/a", "binary", t1),
+		r.WriteObject(context.Background(), "src/.gitignore", "!keep.o\n", t1),
+		r.WriteObject(context.Background(), "src/b.o", "object", t1),
+		r.WriteObject(context.Background(), "src/keep.o", "object", t1),
+		r.WriteObject(context.Background(), "src/build/b", "binary", t1),
+	}
+	r.CheckRemoteItems(t, files...)
+
+	opt := filter.DefaultOpt
+	opt.IgnoreFile = []string{".gitignore"}
+	fi, err := filter.NewFilter(&opt)
+	require.NoError(t, err)
+	ctx := filter.ReplaceConfig(context.Background(), fi)
+
+	listNames := func(dir string) (names []string) {
+		items, err := list.DirSorted(ctx, r.Fremote, false, dir)
+		require.NoError(t, err)
+		for _, item := range items {
+			name := item.Remote()
+			if _, ok := item.(fs.Directory); ok {
+				name += "/"
+			}
+			names = append(names, name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{".gitignore", "a.c", "src/"}, listNames(""))
+	assert.Equal(t, []string{"src/.gitignore", "src/build/", "src/keep.o"}, listNames("src"))
+}
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/lib/pool"
+	"github.com/rclone/rclone/lib/readers"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -32,9 +35,9 @@ func doMultiThreadCopy(ctx context.Context, f fs.Fs, src fs.Object) bool {
 	if src.Size() < int64(ci.MultiThreadCutoff) {
 		return false
 	}
-	// ...source doesn't support it
+	// ...destination doesn't support it
 	dstFeatures := f.Features()
-	if dstFeatures.OpenWriterAt == nil {
+	if dstFeatures.OpenWriterAt == nil && dstFeatures.OpenChunkWriter == nil {
 		return false
 	}
 	// ...if --multi-thread-streams not in use and source and
@@ -47,13 +50,15 @@ func doMultiThreadCopy(ctx context.Context, f fs.Fs, src fs.Object) bool {
 
 // state for a multi-thread copy
 type multiThreadCopyState struct {
-	ctx      context.Context
-	partSize int64
-	size     int64
-	wc       fs.WriterAtCloser
-	src      fs.Object
-	acc      *accounting.Account
-	streams  int
+	ctx         context.Context
+	partSize    int64
+	size        int64
+	wc          fs.WriterAtCloser
+	chunkWriter fs.ChunkWriter
+	src         fs.Object
+	acc         *accounting.Account
+	streams     int
+	pool        *pool.Pool // buffers for the chunks of a chunked copy
 }
 
 // Copy a single stream into place
@@ -122,6 +127,86 @@ func (mc *multiThreadCopyState) copyStream(ctx context.Context, stream int) (err
 	return nil
 }
 
+// chunkReader reads the source of a chunk, accounting for the data
+// read and checking none of it is missing.
+type chunkReader struct {
+	mc   *multiThreadCopyState
+	in   io.Reader
+	left int64 // bytes still to read
+}
+
+// Read implements io.Reader
+func (r *chunkReader) Read(p []byte) (n int, err error) {
+	// Check if context cancelled and exit if so
+	if r.mc.ctx.Err() != nil {
+		return 0, r.mc.ctx.Err()
+	}
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	if len(p) > multithreadBufferSize {
+		p = p[:multithreadBufferSize]
+	}
+	n, err = r.in.Read(p)
+	r.left -= int64(n)
+	if n > 0 {
+		if accErr := r.mc.acc.AccountRead(n); accErr != nil {
+			return n, fmt.Errorf("multipart copy: accounting failed: %w", accErr)
+		}
+	}
+	if err == io.EOF && r.left > 0 {
+		return n, fmt.Errorf("multipart copy: %d bytes missing from source: %w", r.left, io.ErrUnexpectedEOF)
+	} else if err != nil && err != io.EOF {
+		return n, fmt.Errorf("multipart copy: read failed: %w", err)
+	}
+	if r.left <= 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Copy a single chunk to the chunk writer
+//
+// The chunk is streamed to the chunk writer through a buffer from the
+// pool so it can be retried.
+func (mc *multiThreadCopyState) copyChunk(ctx context.Context, chunk, chunks int) (err error) {
+	ci := fs.GetConfig(ctx)
+	defer func() {
+		if err != nil {
+			fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d failed: %v", chunk+1, chunks, err)
+		}
+	}()
+	start := int64(chunk) * mc.partSize
+	end := start + mc.partSize
+	if end > mc.size {
+		end = mc.size
+	}
+
+	fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d (%d-%d) size %v starting", chunk+1, chunks, start, end, fs.SizeSuffix(end-start))
+
+	rc, err := NewReOpen(ctx, mc.src, ci.LowLevelRetries, &fs.RangeOption{Start: start, End: end - 1})
+	if err != nil {
+		return fmt.Errorf("multipart copy: failed to open source: %w", err)
+	}
+	defer fs.CheckClose(rc, &err)
+
+	buf := mc.pool.Get()
+	defer mc.pool.Put(buf)
+	in := readers.NewRepeatableReaderBuffer(&chunkReader{mc: mc, in: rc, left: end - start}, buf)
+
+	// Write the chunk
+	nw, err := mc.chunkWriter.WriteChunk(ctx, chunk, in)
+	if err != nil {
+		return fmt.Errorf("multipart copy: write failed: %w", err)
+	}
+	if nw != end-start {
+		return fmt.Errorf("multipart copy: wrote %d bytes but expected to write %d", nw, end-start)
+	}
+
+	fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d (%d-%d) size %v finished", chunk+1, chunks, start, end, fs.SizeSuffix(end-start))
+	return nil
+}
+
 // Calculate the chunk sizes and updated number of streams
 func (mc *multiThreadCopyState) calculateChunks() {
 	partSize := mc.size / int64(mc.streams)
@@ -139,11 +224,14 @@ func (mc *multiThreadCopyState) calculateChunks() {
 	}
 }
 
-// Copy src to (f, remote) using streams download threads and the OpenWriterAt feature
+// Copy src to (f, remote) using streams threads
+//
+// This uses the OpenWriterAt feature if available otherwise the
+// OpenChunkWriter feature.
 func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object, streams int, tr *accounting.Transfer) (newDst fs.Object, err error) {
-	openWriterAt := f.Features().OpenWriterAt
-	if openWriterAt == nil {
-		return nil, errors.New("multi-thread copy: OpenWriterAt not supported")
+	features := f.Features()
+	if features.OpenWriterAt == nil && features.OpenChunkWriter == nil {
+		return nil, errors.New("multi-thread copy: neither OpenWriterAt nor OpenChunkWriter supported")
 	}
 	if src.Size() < 0 {
 		return nil, errors.New("multi-thread copy: can't copy unknown sized file")
@@ -151,6 +239,9 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 	if src.Size() == 0 {
 		return nil, errors.New("multi-thread copy: can't copy zero sized file")
 	}
+	if features.OpenWriterAt == nil {
+		return multiThreadCopyChunked(ctx, f, remote, src, streams, tr)
+	}
 
 	g, gCtx := errgroup.WithContext(ctx)
 	mc := &multiThreadCopyState{
@@ -165,7 +256,7 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 	mc.acc = tr.Account(ctx, nil)
 
 	// create write file handle
-	mc.wc, err = openWriterAt(gCtx, remote, mc.size)
+	mc.wc, err = features.OpenWriterAt(gCtx, remote, mc.size)
 	if err != nil {
 		return nil, fmt.Errorf("multipart copy: failed to open destination: %w", err)
 	}
@@ -186,18 +277,143 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 		return nil, fmt.Errorf("multi-thread copy: failed to close object after copy: %w", closeErr)
 	}
 
-	obj, err := f.NewObject(ctx, remote)
+	obj, err := multiThreadCopyFinish(ctx, f, remote, src)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.Debugf(src, "Finished multi-thread copy with %d parts of size %v", mc.streams, fs.SizeSuffix(mc.partSize))
+	return obj, nil
+}
+
+// Copy src to (f, remote) using streams upload threads and the
+// OpenChunkWriter feature
+func multiThreadCopyChunked(ctx context.Context, f fs.Fs, remote string, src fs.Object, streams int, tr *accounting.Transfer) (newDst fs.Object, err error) {
+	ci := fs.GetConfig(ctx)
+
+	var options []fs.OpenOption
+	for _, option := range ci.UploadHeaders {
+		options = append(options, option)
+	}
+	if ci.MetadataSet != nil {
+		options = append(options, fs.MetadataOption(ci.MetadataSet))
+	}
+	info, chunkWriter, resumer, err := openChunkWriter(ctx, f, remote, src, options)
+	if err != nil {
+		return nil, fmt.Errorf("multipart copy: failed to open destination: %w", err)
+	}
+	if resumer != nil {
+		defer resumer.close()
+	}
+	if info.ChunkSize <= 0 {
+		return nil, fmt.Errorf("multipart copy: invalid chunk size %d", info.ChunkSize)
+	}
+
+	g, gCtx := errgroup.WithContext(ctx)
+	mc := &multiThreadCopyState{
+		ctx:         gCtx,
+		partSize:    info.ChunkSize,
+		size:        src.Size(),
+		chunkWriter: chunkWriter,
+		src:         src,
+		acc:         tr.Account(ctx, nil),
+	}
+	// Use the concurrency the backend would like unless
+	// --multi-thread-streams was set explicitly
+	if info.Concurrency > 0 && !ci.MultiThreadSet {
+		streams = info.Concurrency
+	}
+	chunks := int((mc.size + mc.partSize - 1) / mc.partSize)
+	if streams > chunks {
+		streams = chunks
+	}
+	mc.streams = streams
+	g.SetLimit(streams)
+	mc.pool = pool.New(time.Minute, int(mc.partSize), streams, ci.UseMmap)
+	defer mc.pool.Flush()
+
+	var done map[int]bool
+	if resumer != nil {
+		done = resumer.isDone()
+	}
+
+	fs.Debugf(src, "Starting multi-thread copy with %d chunks of size %v with %d parallel streams", chunks, fs.SizeSuffix(mc.partSize), streams)
+	for chunk := 0; chunk < chunks; chunk++ {
+		// Fail fast if one of the chunks has failed
+		if gCtx.Err() != nil {
+			break
+		}
+		if done[chunk] {
+			// Account the chunk uploaded by a previous run
+			// without limiting the bandwidth
+			size := mc.partSize
+			if start := int64(chunk) * mc.partSize; start+size > mc.size {
+				size = mc.size - start
+			}
+			mc.acc.ServerSideCopyEnd(size)
+			continue
+		}
+		chunk := chunk
+		g.Go(func() error {
+			err := mc.copyChunk(gCtx, chunk, chunks)
+			if err == nil && resumer != nil {
+				resumer.done(chunk)
+			}
+			return err
+		})
+	}
+	err = g.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		if resumer != nil {
+			fs.Infof(src, "multi-thread copy: leaving parts of failed upload so it can be resumed")
+		} else if info.LeavePartsOnError {
+			fs.Debugf(src, "multi-thread copy: leaving parts of failed upload")
+		} else if abortErr := chunkWriter.Abort(context.Background()); abortErr != nil {
+			fs.Debugf(src, "multi-thread copy: failed to abort upload: %v", abortErr)
+		}
+		return nil, err
+	}
+	err = chunkWriter.Close(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("multi-thread copy: failed to finalise upload: %w", err)
+	}
+	if resumer != nil {
+		resumer.remove()
+	}
+
+	obj, err := multiThreadCopyFinish(ctx, f, remote, src)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.Debugf(src, "Finished multi-thread copy with %d chunks of size %v", chunks, fs.SizeSuffix(mc.partSize))
+	return obj, nil
+}
+
+// Find the object made by a multi-thread copy and set its
+// modification time if the backend didn't set it already
+func multiThreadCopyFinish(ctx context.Context, f fs.Fs, remote string, src fs.Object) (obj fs.Object, err error) {
+	obj, err = f.NewObject(ctx, remote)
 	if err != nil {
 		return nil, fmt.Errorf("multi-thread copy: failed to find object after copy: %w", err)
 	}
 
-	err = obj.SetModTime(ctx, src.ModTime(ctx))
+	modTime := src.ModTime(ctx)
+	dt := obj.ModTime(ctx).Sub(modTime)
+	if dt < 0 {
+		dt = -dt
+	}
+	if dt <= fs.GetModifyWindow(ctx, f, src.Fs()) {
+		return obj, nil
+	}
+	err = obj.SetModTime(ctx, modTime)
 	switch err {
 	case nil, fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
 	default:
 		return nil, fmt.Errorf("multi-thread copy: failed to set modification time: %w", err)
 	}
-
-	fs.Debugf(src, "Finished multi-thread copy with %d parts of size %v", mc.streams, fs.SizeSuffix(mc.partSize))
 	return obj, nil
 }
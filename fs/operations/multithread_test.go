@@ -2,7 +2,10 @@ package operations
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"sync"
 	"testing"
 
 	"github.com/rclone/rclone/fs/accounting"
@@ -59,6 +62,11 @@ func TestDoMultiThreadCopy(t *testing.T) {
 
 	f.Features().OpenWriterAt = nil
 	assert.False(t, doMultiThreadCopy(ctx, f, src))
+	f.Features().OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+		panic("don't call me")
+	}
+	assert.True(t, doMultiThreadCopy(ctx, f, src))
+	f.Features().OpenChunkWriter = nil
 	f.Features().OpenWriterAt = nullWriterAt
 	assert.True(t, doMultiThreadCopy(ctx, f, src))
 
@@ -146,3 +154,161 @@ func TestMultithreadCopy(t *testing.T) {
 	}
 
 }
+
+// testChunkWriter implements fs.ChunkWriter using an fs.WriterAtCloser
+type testChunkWriter struct {
+	wc        fs.WriterAtCloser
+	chunkSize int64
+	failChunk int // chunk number to fail or -1
+	mu        sync.Mutex
+	chunks    int
+	inFlight  int // chunks being written now
+	maxFlight int // most chunks written at once
+	closed    bool
+	aborted   bool
+}
+
+func (w *testChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	if chunkNumber == w.failChunk {
+		return 0, errors.New("chunk failed")
+	}
+	w.mu.Lock()
+	w.inFlight++
+	if w.inFlight > w.maxFlight {
+		w.maxFlight = w.inFlight
+	}
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.inFlight--
+		w.mu.Unlock()
+	}()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.wc.WriteAt(data, int64(chunkNumber)*w.chunkSize)
+	w.mu.Lock()
+	w.chunks++
+	w.mu.Unlock()
+	return int64(n), err
+}
+
+func (w *testChunkWriter) Close(ctx context.Context) error {
+	w.closed = true
+	return w.wc.Close()
+}
+
+func (w *testChunkWriter) Abort(ctx context.Context) error {
+	w.aborted = true
+	return w.wc.Close()
+}
+
+// testChunkWriterFs wraps an Fs with OpenWriterAt so it only has
+// OpenChunkWriter
+type testChunkWriterFs struct {
+	fs.Fs
+	features *fs.Features
+	writer   *testChunkWriter
+}
+
+func newTestChunkWriterFs(f fs.Fs, failChunk int, concurrency int) *testChunkWriterFs {
+	cf := &testChunkWriterFs{Fs: f}
+	features := *f.Features()
+	features.OpenWriterAt = nil
+	features.OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+		info := fs.ChunkWriterInfo{ChunkSize: multithreadChunkSize, Concurrency: concurrency}
+		wc, err := f.Features().OpenWriterAt(ctx, remote, src.Size())
+		if err != nil {
+			return info, nil, err
+		}
+		cf.writer = &testChunkWriter{wc: wc, chunkSize: info.ChunkSize, failChunk: failChunk}
+		return info, cf.writer, nil
+	}
+	cf.features = &features
+	return cf
+}
+
+func (f *testChunkWriterFs) Features() *fs.Features {
+	return f.features
+}
+
+func TestMultithreadCopyChunked(t *testing.T) {
+	r := fstest.NewRun(t)
+	ctx := context.Background()
+	if r.Flocal.Features().OpenWriterAt == nil {
+		t.Skip("local doesn't support OpenWriterAt")
+	}
+
+	for _, test := range []struct {
+		size       int
+		streams    int
+		wantChunks int
+	}{
+		{size: multithreadChunkSize - 1, streams: 2, wantChunks: 1},
+		{size: multithreadChunkSize * 3, streams: 2, wantChunks: 3},
+		{size: multithreadChunkSize*3 + 1, streams: 4, wantChunks: 4},
+	} {
+		t.Run(fmt.Sprintf("%+v", test), func(t *testing.T) {
+			var err error
+			contents := random.String(test.size)
+			t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+			file1 := r.WriteObject(ctx, "file1", contents, t1)
+
+			src, err := r.Fremote.NewObject(ctx, "file1")
+			require.NoError(t, err)
+			accounting.GlobalStats().ResetCounters()
+			tr := accounting.GlobalStats().NewTransfer(src)
+
+			defer func() {
+				tr.Done(ctx, err)
+			}()
+			f := newTestChunkWriterFs(r.Flocal, -1, 0)
+			dst, err := multiThreadCopy(ctx, f, "file1", src, test.streams, tr)
+			require.NoError(t, err)
+			assert.Equal(t, src.Size(), dst.Size())
+			assert.Equal(t, test.wantChunks, f.writer.chunks)
+			assert.True(t, f.writer.closed)
+			assert.False(t, f.writer.aborted)
+
+			fstest.CheckListingWithPrecision(t, r.Flocal, []fstest.Item{file1}, nil, fs.GetModifyWindow(ctx, r.Flocal, r.Fremote))
+			require.NoError(t, dst.Remove(ctx))
+		})
+	}
+
+	t.Run("Concurrency", func(t *testing.T) {
+		var err error
+		contents := random.String(multithreadChunkSize * 4)
+		r.WriteObject(ctx, "file3", contents, fstest.Time("2001-02-03T04:05:06.499999999Z"))
+		src, err := r.Fremote.NewObject(ctx, "file3")
+		require.NoError(t, err)
+		tr := accounting.GlobalStats().NewTransfer(src)
+		defer func() {
+			tr.Done(ctx, err)
+		}()
+		f := newTestChunkWriterFs(r.Flocal, -1, 1)
+		dst, err := multiThreadCopy(ctx, f, "file3", src, 4, tr)
+		require.NoError(t, err)
+		assert.Equal(t, 4, f.writer.chunks)
+		assert.Equal(t, 1, f.writer.maxFlight)
+		require.NoError(t, dst.Remove(ctx))
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		var err error
+		contents := random.String(multithreadChunkSize * 3)
+		r.WriteObject(ctx, "file2", contents, fstest.Time("2001-02-03T04:05:06.499999999Z"))
+		src, err := r.Fremote.NewObject(ctx, "file2")
+		require.NoError(t, err)
+		tr := accounting.GlobalStats().NewTransfer(src)
+		defer func() {
+			tr.Done(ctx, err)
+		}()
+		f := newTestChunkWriterFs(r.Flocal, 1, 0)
+		_, err = multiThreadCopy(ctx, f, "file2", src, 2, tr)
+		require.Error(t, err)
+		assert.True(t, f.writer.aborted)
+		assert.False(t, f.writer.closed)
+		err = nil
+	})
+}
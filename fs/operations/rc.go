@@ -435,6 +435,7 @@ This returns info about the remote passed in;
                 "MergeDirs": false,
                 "MetadataInfo": true,
                 "Move": true,
+                "OpenChunkWriter": false,
                 "OpenWriterAt": true,
                 "PublicLink": false,
                 "Purge": true,
@@ -442,6 +443,7 @@ This returns info about the remote passed in;
                 "PutUnchecked": false,
                 "ReadMetadata": true,
                 "ReadMimeType": false,
+                "ResumeChunkWriter": false,
                 "ServerSideAcrossConfigs": false,
                 "SetTier": false,
                 "SetWrapper": false,
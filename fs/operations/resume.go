@@ -0,0 +1,339 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// resumeFacility is the name of the database the resumable uploads
+// are recorded in
+const resumeFacility = "resume"
+
+// ResumeRecord describes a multi-thread upload which can be resumed
+type ResumeRecord struct {
+	Fs          string    // config string of the destination Fs
+	Remote      string    // path of the object in the destination Fs
+	Fingerprint string    // fingerprint of the source when the upload started
+	Size        int64     // size of the source
+	ChunkSize   int64     // size of the chunks
+	Done        []int     // numbers of the chunks which have been uploaded
+	State       []byte    // state of the upload from the backend
+	Started     time.Time // when the upload was started
+	Updated     time.Time // when the record was last saved
+}
+
+// Key returns the key of the record in the database
+func (r *ResumeRecord) Key() string {
+	return fspath.JoinRootPath(r.Fs, r.Remote)
+}
+
+// kvResumeGet: read a record from the database
+type kvResumeGet struct {
+	key   string
+	rec   ResumeRecord
+	found bool
+}
+
+func (op *kvResumeGet) Do(ctx context.Context, b kv.Bucket) error {
+	data := b.Get([]byte(op.key))
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, &op.rec); err != nil {
+		return fmt.Errorf("invalid record: %w", err)
+	}
+	op.found = true
+	return nil
+}
+
+// kvResumePut: write a record to the database
+type kvResumePut struct {
+	rec *ResumeRecord
+}
+
+func (op *kvResumePut) Do(ctx context.Context, b kv.Bucket) error {
+	data, err := json.Marshal(op.rec)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	return b.Put([]byte(op.rec.Key()), data)
+}
+
+// kvResumeDelete: remove a record from the database
+type kvResumeDelete struct {
+	key string
+}
+
+func (op *kvResumeDelete) Do(ctx context.Context, b kv.Bucket) error {
+	return b.Delete([]byte(op.key))
+}
+
+// kvResumeList: read all the records in the database
+type kvResumeList struct {
+	recs []ResumeRecord
+}
+
+func (op *kvResumeList) Do(ctx context.Context, b kv.Bucket) error {
+	return b.ForEach(func(bkey, data []byte) error {
+		var rec ResumeRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			fs.Errorf(nil, "%s: invalid resume record: %v", bkey, err)
+			return nil
+		}
+		op.recs = append(op.recs, rec)
+		return nil
+	})
+}
+
+// ListResumableUploads returns the uploads which were interrupted and
+// can be resumed, oldest first.
+func ListResumableUploads(ctx context.Context) (recs []ResumeRecord, err error) {
+	db, err := kv.Start(ctx, resumeFacility, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = db.Stop(false)
+	}()
+	op := &kvResumeList{}
+	err = db.Do(false, op)
+	if err == kv.ErrEmpty {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	sort.Slice(op.recs, func(i, j int) bool {
+		return op.recs[i].Started.Before(op.recs[j].Started)
+	})
+	return op.recs, nil
+}
+
+// AbortResumableUpload cancels the upload described by rec, removing
+// any parts already uploaded, and forgets about it.
+func AbortResumableUpload(ctx context.Context, rec ResumeRecord) (err error) {
+	db, err := kv.Start(ctx, resumeFacility, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Stop(false)
+	}()
+	if SkipDestructive(ctx, rec.Key(), "abort upload") {
+		return nil
+	}
+	f, err := cache.Get(ctx, rec.Fs)
+	if err != nil {
+		return err
+	}
+	abortErr := abortResumeRecord(ctx, f, rec)
+	if abortErr != nil {
+		fs.Errorf(rec.Key(), "Failed to abort upload - parts may need removing by hand: %v", abortErr)
+	}
+	err = db.Do(true, &kvResumeDelete{key: rec.Key()})
+	if err != nil {
+		return fmt.Errorf("failed to remove resume record: %w", err)
+	}
+	return abortErr
+}
+
+// abortResumeRecord cancels the upload described by rec on f
+func abortResumeRecord(ctx context.Context, f fs.Fs, rec ResumeRecord) error {
+	resume := f.Features().ResumeChunkWriter
+	if resume == nil {
+		return errors.New("backend can't resume uploads")
+	}
+	src := object.NewStaticObjectInfo(rec.Remote, rec.Started, rec.Size, true, nil, f)
+	_, writer, err := resume(ctx, rec.Remote, src, rec.State)
+	if err != nil {
+		return err
+	}
+	return writer.Abort(ctx)
+}
+
+// uploadResumer keeps the record of a resumable upload up to date
+type uploadResumer struct {
+	db     *kv.DB
+	writer fs.ChunkWriterResumer
+	mu     sync.Mutex // protects rec
+	rec    ResumeRecord
+}
+
+// openChunkWriter opens a chunk writer to upload src to remote.
+//
+// If --resume-uploads is set and the backend supports it then the
+// upload is recorded and returned in ur so it can be carried on if
+// it is interrupted. If there is a record of an upload of the same
+// source to remote then that is resumed.
+func openChunkWriter(ctx context.Context, f fs.Fs, remote string, src fs.Object, options []fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, ur *uploadResumer, err error) {
+	ci := fs.GetConfig(ctx)
+	features := f.Features()
+
+	var wrappedSrc fs.ObjectInfo = src
+	// We try to pass the original object if possible
+	if src.Remote() != remote {
+		wrappedSrc = fs.NewOverrideRemote(src, remote)
+	}
+	if !ci.ResumeUploads || features.ResumeChunkWriter == nil {
+		info, writer, err = features.OpenChunkWriter(ctx, remote, wrappedSrc, options...)
+		return info, writer, nil, err
+	}
+
+	db, err := kv.Start(ctx, resumeFacility, nil)
+	if err != nil {
+		fs.Errorf(src, "Can't make upload resumable: %v", err)
+		info, writer, err = features.OpenChunkWriter(ctx, remote, wrappedSrc, options...)
+		return info, writer, nil, err
+	}
+	ur = &uploadResumer{
+		db: db,
+		rec: ResumeRecord{
+			Fs:          fs.ConfigString(f),
+			Remote:      remote,
+			Fingerprint: fs.Fingerprint(ctx, src, true),
+			Size:        src.Size(),
+		},
+	}
+
+	// Resume the previous upload if possible
+	info, writer, err = ur.resume(ctx, f, wrappedSrc, options)
+	if err != nil {
+		fs.Errorf(src, "Failed to resume upload - starting again: %v", err)
+	}
+	if writer != nil {
+		return info, writer, ur, nil
+	}
+
+	info, writer, err = features.OpenChunkWriter(ctx, remote, wrappedSrc, options...)
+	if err != nil {
+		ur.close()
+		return info, nil, nil, err
+	}
+	w, ok := writer.(fs.ChunkWriterResumer)
+	if !ok {
+		ur.close()
+		return info, writer, nil, nil
+	}
+	ur.writer = w
+	ur.rec.ChunkSize = info.ChunkSize
+	ur.rec.Started = time.Now()
+	ur.save()
+	return info, writer, ur, nil
+}
+
+// resume the previous upload to ur.rec.Remote if there is one.
+//
+// It returns a nil writer and no error if there is nothing to resume.
+func (ur *uploadResumer) resume(ctx context.Context, f fs.Fs, src fs.ObjectInfo, options []fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	op := &kvResumeGet{key: ur.rec.Key()}
+	err = ur.db.Do(false, op)
+	if err == kv.ErrEmpty || (err == nil && !op.found) {
+		return info, nil, nil
+	}
+	old := op.rec
+	defer func() {
+		if writer == nil {
+			ur.remove()
+		}
+	}()
+	if err != nil {
+		return info, nil, err
+	}
+	if old.Fingerprint != ur.rec.Fingerprint || old.Size != ur.rec.Size {
+		fs.Infof(src, "Not resuming upload as source has changed since it started")
+		if err := abortResumeRecord(ctx, f, old); err != nil {
+			fs.Debugf(src, "Failed to abort old upload: %v", err)
+		}
+		return info, nil, nil
+	}
+	info, writer, err = f.Features().ResumeChunkWriter(ctx, ur.rec.Remote, src, old.State, options...)
+	if err != nil {
+		return info, nil, err
+	}
+	w, ok := writer.(fs.ChunkWriterResumer)
+	if !ok || info.ChunkSize != old.ChunkSize {
+		if err := writer.Abort(ctx); err != nil {
+			fs.Debugf(src, "Failed to abort old upload: %v", err)
+		}
+		return info, nil, fmt.Errorf("chunk size changed from %v to %v", fs.SizeSuffix(old.ChunkSize), fs.SizeSuffix(info.ChunkSize))
+	}
+	ur.writer = w
+	ur.rec.ChunkSize = old.ChunkSize
+	ur.rec.Done = old.Done
+	ur.rec.State = old.State
+	ur.rec.Started = old.Started
+	fs.Infof(src, "Resuming upload started at %v with %d chunks already uploaded", old.Started.Format(time.RFC3339), len(old.Done))
+	return info, writer, nil
+}
+
+// isDone returns a set of the chunks which were uploaded already
+func (ur *uploadResumer) isDone() map[int]bool {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	done := make(map[int]bool, len(ur.rec.Done))
+	for _, chunk := range ur.rec.Done {
+		done[chunk] = true
+	}
+	return done
+}
+
+// done records that chunk has been uploaded
+func (ur *uploadResumer) done(chunk int) {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	// Read the state under the lock so it includes all the chunks
+	// in Done
+	state, err := ur.writer.ResumeState()
+	if err != nil {
+		fs.Errorf(ur.rec.Key(), "Failed to read upload state: %v", err)
+		return
+	}
+	ur.rec.Done = append(ur.rec.Done, chunk)
+	ur.rec.State = state
+	ur.saveLocked()
+}
+
+// save the record to the database
+func (ur *uploadResumer) save() {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+	state, err := ur.writer.ResumeState()
+	if err != nil {
+		fs.Errorf(ur.rec.Key(), "Failed to read upload state: %v", err)
+		return
+	}
+	ur.rec.State = state
+	ur.saveLocked()
+}
+
+// save the record to the database with the lock held
+func (ur *uploadResumer) saveLocked() {
+	ur.rec.Updated = time.Now()
+	err := ur.db.Do(true, &kvResumePut{rec: &ur.rec})
+	if err != nil {
+		fs.Errorf(ur.rec.Key(), "Failed to save upload state: %v", err)
+	}
+}
+
+// remove the record from the database
+func (ur *uploadResumer) remove() {
+	err := ur.db.Do(true, &kvResumeDelete{key: ur.rec.Key()})
+	if err != nil {
+		fs.Errorf(ur.rec.Key(), "Failed to remove upload state: %v", err)
+	}
+}
+
+// close the database
+func (ur *uploadResumer) close() {
+	_ = ur.db.Stop(false)
+}
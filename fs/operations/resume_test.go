@@ -0,0 +1,200 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/kv"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testResumableFs wraps an Fs giving it a resumable OpenChunkWriter
+// which keeps the chunks in memory until the upload is closed, like
+// an object store keeps the parts of a multipart upload.
+type testResumableFs struct {
+	fs.Fs
+	features  *fs.Features
+	mu        sync.Mutex
+	uploads   map[string]map[int][]byte // chunks of each upload by ID
+	nextID    int
+	failChunk int   // chunk number to fail or -1
+	written   []int // chunk numbers written
+}
+
+func newTestResumableFs(f fs.Fs) *testResumableFs {
+	rf := &testResumableFs{
+		Fs:        f,
+		uploads:   map[string]map[int][]byte{},
+		failChunk: -1,
+	}
+	features := *f.Features()
+	features.OpenWriterAt = nil
+	info := fs.ChunkWriterInfo{ChunkSize: multithreadChunkSize}
+	features.OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+		rf.mu.Lock()
+		defer rf.mu.Unlock()
+		rf.nextID++
+		id := fmt.Sprintf("upload%d", rf.nextID)
+		rf.uploads[id] = map[int][]byte{}
+		return info, &testResumableWriter{f: rf, id: id, src: src}, nil
+	}
+	features.ResumeChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, state []byte, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+		rf.mu.Lock()
+		defer rf.mu.Unlock()
+		id := string(state)
+		if _, ok := rf.uploads[id]; !ok {
+			return info, nil, errors.New("upload not found")
+		}
+		return info, &testResumableWriter{f: rf, id: id, src: src}, nil
+	}
+	rf.features = &features
+	return rf
+}
+
+func (f *testResumableFs) Features() *fs.Features {
+	return f.features
+}
+
+// testResumableWriter is a resumable fs.ChunkWriter for testResumableFs
+type testResumableWriter struct {
+	f   *testResumableFs
+	id  string
+	src fs.ObjectInfo
+}
+
+func (w *testResumableWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	if chunkNumber == w.f.failChunk {
+		return 0, errors.New("chunk failed")
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, err
+	}
+	w.f.mu.Lock()
+	defer w.f.mu.Unlock()
+	w.f.uploads[w.id][chunkNumber] = data
+	w.f.written = append(w.f.written, chunkNumber)
+	return int64(len(data)), nil
+}
+
+func (w *testResumableWriter) ResumeState() ([]byte, error) {
+	return []byte(w.id), nil
+}
+
+func (w *testResumableWriter) Close(ctx context.Context) error {
+	w.f.mu.Lock()
+	chunks := w.f.uploads[w.id]
+	delete(w.f.uploads, w.id)
+	w.f.mu.Unlock()
+	var buf bytes.Buffer
+	for i := 0; i < len(chunks); i++ {
+		buf.Write(chunks[i])
+	}
+	_, err := w.f.Fs.Put(ctx, &buf, w.src)
+	return err
+}
+
+func (w *testResumableWriter) Abort(ctx context.Context) error {
+	w.f.mu.Lock()
+	defer w.f.mu.Unlock()
+	delete(w.f.uploads, w.id)
+	return nil
+}
+
+func TestMultithreadCopyResume(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("kv not supported")
+	}
+	r := fstest.NewRun(t)
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.ResumeUploads = true
+
+	// Keep the database open between the uploads
+	db, err := kv.Start(ctx, resumeFacility, nil)
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Stop(true)
+	}()
+
+	f := newTestResumableFs(r.Flocal)
+	cache.Put(fs.ConfigString(f), f)
+	defer cache.Clear()
+
+	copyFile := func(remote string) (fs.Object, error) {
+		src, err := r.Fremote.NewObject(ctx, remote)
+		require.NoError(t, err)
+		tr := accounting.GlobalStats().NewTransfer(src)
+		dst, err := multiThreadCopy(ctx, f, remote, src, 1, tr)
+		tr.Done(ctx, err)
+		return dst, err
+	}
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	file1 := r.WriteObject(ctx, "file1", random.String(multithreadChunkSize*4), t1)
+
+	// Fail the first upload part way through
+	f.failChunk = 2
+	_, err = copyFile("file1")
+	require.Error(t, err)
+	recs, err := ListResumableUploads(ctx)
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "file1", recs[0].Remote)
+	assert.Equal(t, []int{0, 1}, recs[0].Done)
+	assert.Len(t, f.uploads, 1)
+
+	// The second upload only sends the missing chunks
+	f.failChunk = -1
+	f.written = nil
+	dst, err := copyFile("file1")
+	require.NoError(t, err)
+	assert.Equal(t, file1.Size, dst.Size())
+	sort.Ints(f.written)
+	assert.Equal(t, []int{2, 3}, f.written)
+	assert.Len(t, f.uploads, 0)
+	recs, err = ListResumableUploads(ctx)
+	require.NoError(t, err)
+	assert.Len(t, recs, 0)
+	fstest.CheckListingWithPrecision(t, r.Flocal, []fstest.Item{file1}, nil, fs.GetModifyWindow(ctx, r.Flocal, r.Fremote))
+
+	// A changed source starts the upload again
+	f.failChunk = 1
+	_, err = copyFile("file1")
+	require.Error(t, err)
+	file1 = r.WriteObject(ctx, "file1", random.String(multithreadChunkSize*3), t1)
+	f.failChunk = -1
+	f.written = nil
+	_, err = copyFile("file1")
+	require.NoError(t, err)
+	sort.Ints(f.written)
+	assert.Equal(t, []int{0, 1, 2}, f.written)
+	assert.Len(t, f.uploads, 0)
+	fstest.CheckListingWithPrecision(t, r.Flocal, []fstest.Item{file1}, nil, fs.GetModifyWindow(ctx, r.Flocal, r.Fremote))
+
+	// Aborting removes the parts and the record
+	f.failChunk = 1
+	_, err = copyFile("file1")
+	require.Error(t, err)
+	recs, err = ListResumableUploads(ctx)
+	require.NoError(t, err)
+	require.Len(t, recs, 1)
+	assert.Len(t, f.uploads, 1)
+	require.NoError(t, AbortResumableUpload(ctx, recs[0]))
+	assert.Len(t, f.uploads, 0)
+	recs, err = ListResumableUploads(ctx)
+	require.NoError(t, err)
+	assert.Len(t, recs, 0)
+}
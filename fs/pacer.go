@@ -19,16 +19,29 @@ type logCalculator struct {
 	pacer.Calculator
 }
 
+// pacerNameKey is the context key for the name of the remote being
+// made by NewFs
+type pacerNameKey struct{}
+
+// withPacerName returns a context which makes NewPacer report rate
+// limiting for the remote called name
+func withPacerName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, pacerNameKey{}, name)
+}
+
 // NewPacer creates a Pacer for the given Fs and Calculator.
 func NewPacer(ctx context.Context, c pacer.Calculator) *Pacer {
 	ci := GetConfig(ctx)
+	name, _ := ctx.Value(pacerNameKey{}).(string)
 	retries := ci.LowLevelRetries
 	if retries <= 0 {
 		retries = 1
 	}
 	p := &Pacer{
 		Pacer: pacer.New(
-			pacer.InvokerOption(pacerInvoker),
+			pacer.InvokerOption(func(try, retries int, f pacer.Paced) (bool, error) {
+				return pacerInvoker(name, try, retries, f)
+			}),
 			pacer.MaxConnectionsOption(ci.Checkers+ci.Transfers),
 			pacer.RetriesOption(retries),
 			pacer.CalculatorOption(c),
@@ -84,11 +97,24 @@ func (p *Pacer) ModifyCalculator(f func(pacer.Calculator)) {
 	})
 }
 
-func pacerInvoker(try, retries int, f pacer.Paced) (retry bool, err error) {
+// pacerInvoker calls f, telling any pacer.Adaptive controllers
+// registered for the remote called name if it is rate limiting.
+func pacerInvoker(name string, try, retries int, f pacer.Paced) (retry bool, err error) {
 	retry, err = f()
 	if retry {
 		Debugf("pacer", "low level retry %d/%d (error %v)", try, retries, err)
+		if name != "" && isRateLimited(err) {
+			pacer.RateLimited(name)
+		}
 		err = fserrors.RetryError(err)
 	}
 	return
 }
+
+// isRateLimited returns true if err shows the remote is rate limiting
+func isRateLimited(err error) bool {
+	if _, ok := pacer.IsRetryAfter(err); ok {
+		return true
+	}
+	return fserrors.IsRateLimited(err)
+}
@@ -0,0 +1,191 @@
+// Parse cron expressions for the scheduler
+
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64        // bit set for each value which matches
+	domStar, dowStar              bool          // set if the day of month or week was *
+	every                         time.Duration // if set run this often instead
+}
+
+// cronField describes a field of a cron expression
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names for the values starting at min if set
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// cronShortcuts are the @ names which can be used instead of the
+// five fields
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses a cron expression.
+//
+// This is either the standard five fields "minute hour day-of-month
+// month day-of-week", one of the shortcuts like "@daily" or "@every
+// duration".
+func parseCron(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+		if err != nil {
+			return nil, fmt.Errorf("bad @every duration: %w", err)
+		}
+		if every < time.Minute {
+			return nil, errors.New("@every duration must be at least 1m")
+		}
+		return &cronSchedule{every: every}, nil
+	}
+	if shortcut, ok := cronShortcuts[strings.ToLower(expr)]; ok {
+		expr = shortcut
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("need %d fields in cron expression %q but got %d", len(cronFields), expr, len(fields))
+	}
+	var bits [5]uint64
+	for i, field := range fields {
+		var err error
+		bits[i], err = cronFields[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("bad %s in cron expression %q: %w", cronFields[i].name, expr, err)
+		}
+	}
+	// Sunday can be 0 or 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1 << 0
+	}
+	return &cronSchedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parse a comma separated list of values, ranges and steps
+func (cf *cronField) parse(field string) (bits uint64, err error) {
+	for _, item := range strings.Split(field, ",") {
+		start, end, step := cf.min, cf.max, 1
+		rangePart := item
+		if i := strings.IndexRune(item, '/'); i >= 0 {
+			rangePart = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad step in %q", item)
+			}
+		}
+		switch {
+		case rangePart == "*":
+		case strings.ContainsRune(rangePart, '-'):
+			i := strings.IndexRune(rangePart, '-')
+			if start, err = cf.value(rangePart[:i]); err != nil {
+				return 0, err
+			}
+			if end, err = cf.value(rangePart[i+1:]); err != nil {
+				return 0, err
+			}
+			if end < start {
+				return 0, fmt.Errorf("bad range %q", rangePart)
+			}
+		default:
+			if start, err = cf.value(rangePart); err != nil {
+				return 0, err
+			}
+			// a/n means a to max in steps of n
+			if step == 1 {
+				end = start
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single number or name
+func (cf *cronField) value(s string) (int, error) {
+	for i, name := range cf.names {
+		if strings.EqualFold(s, name) {
+			return cf.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad value %q", s)
+	}
+	if v < cf.min || v > cf.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, cf.min, cf.max)
+	}
+	return v, nil
+}
+
+// dayMatches returns true if the day of t matches.
+//
+// Like cron, if both the day of month and day of week are restricted
+// then either may match.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// next returns the first time the schedule matches after t or the
+// zero time if it never matches.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Add(c.every)
+	}
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	// A schedule which matches at all will match within 5 years
+	// (e.g. 29th February on a Monday)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
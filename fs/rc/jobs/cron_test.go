@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@every potato",
+		"@every 10s",
+		"@sometimes",
+	} {
+		_, err := parseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Saturday
+	start := time.Date(2023, 7, 15, 10, 17, 30, 0, time.UTC)
+	for _, test := range []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2023, 7, 15, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2023, 7, 15, 10, 30, 0, 0, time.UTC)},
+		{"5/15 * * * *", time.Date(2023, 7, 15, 10, 20, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2023, 7, 15, 11, 0, 0, 0, time.UTC)},
+		{"17 10 * * *", time.Date(2023, 7, 16, 10, 17, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2023, 7, 16, 2, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2023, 7, 15, 13, 0, 0, 0, time.UTC)},
+		{"0,45 10 * * *", time.Date(2023, 7, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 0 * * mon-fri", time.Date(2023, 7, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2023, 7, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// day of month or day of week when both are restricted
+		{"0 0 20 * sun", time.Date(2023, 7, 16, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2023, 7, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2023, 7, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2023, 7, 16, 0, 0, 0, 0, time.UTC)},
+		{"@every 1h30m", time.Date(2023, 7, 15, 11, 47, 30, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}},
+	} {
+		c, err := parseCron(test.expr)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.want, c.next(start), test.expr)
+	}
+}
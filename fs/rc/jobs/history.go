@@ -0,0 +1,495 @@
+// Keep a persistent history of the jobs
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// historyFacility is the name of the database the job history is
+// kept in
+const historyFacility = "jobs"
+
+// historyMaxTransfers is the maximum number of transfers recorded
+// for each job. Only the most recent are kept.
+var historyMaxTransfers = 1000
+
+// historyKeyTime is the format of the time at the start of the keys
+// which makes them sort in time order
+const historyKeyTime = "20060102T150405.000000000Z"
+
+// HistoryTransfer describes a file transferred by a job
+type HistoryTransfer struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	Bytes       int64     `json:"bytes"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	Error       string    `json:"error"`
+}
+
+// HistoryRecord describes a finished job in the history
+type HistoryRecord struct {
+	ID          int64             `json:"id"`
+	Group       string            `json:"group"`
+	Path        string            `json:"path"`     // rc command, e.g. "sync/sync"
+	Remotes     []string          `json:"remotes"`  // the fs, srcFs and dstFs parameters
+	Schedule    string            `json:"schedule"` // ID of the schedule which started the job, if any
+	StartTime   time.Time         `json:"startTime"`
+	EndTime     time.Time         `json:"endTime"`
+	Duration    float64           `json:"duration"`
+	Success     bool              `json:"success"`
+	Error       string            `json:"error"`
+	Stats       rc.Params         `json:"stats"`       // final core/stats for the group
+	Transferred []HistoryTransfer `json:"transferred"` // the last historyMaxTransfers transfers done by the job
+	Omitted     int               `json:"omitted"`     // number of earlier transfers left out of Transferred
+}
+
+// newHistoryTransfer makes a HistoryTransfer from tr
+func newHistoryTransfer(tr accounting.TransferSnapshot) HistoryTransfer {
+	htr := HistoryTransfer{
+		Name:        tr.Name,
+		Size:        tr.Size,
+		Bytes:       tr.Bytes,
+		StartedAt:   tr.StartedAt,
+		CompletedAt: tr.CompletedAt,
+	}
+	if tr.Error != nil {
+		htr.Error = tr.Error.Error()
+	}
+	return htr
+}
+
+// key returns the key of the record in the database
+func (rec *HistoryRecord) key() string {
+	return historyKey(rec.StartTime) + "-" + strconv.FormatInt(rec.ID, 10)
+}
+
+// historyKey returns the start of the keys for jobs started at t
+func historyKey(t time.Time) string {
+	return t.UTC().Format(historyKeyTime)
+}
+
+// matches returns true if the record matches the filters passed in
+func (rec *HistoryRecord) matches(status, remote string) bool {
+	switch status {
+	case "success":
+		if !rec.Success {
+			return false
+		}
+	case "failed":
+		if rec.Success {
+			return false
+		}
+	}
+	if remote != "" {
+		found := false
+		for _, r := range rec.Remotes {
+			if r == remote || strings.HasPrefix(r, strings.TrimRight(remote, "/")+"/") || (strings.HasSuffix(remote, ":") && strings.HasPrefix(r, remote)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// jobHistory keeps the history of the jobs
+type jobHistory struct {
+	mu     sync.Mutex
+	db     *kv.DB        // database the jobs are recorded in or nil if the history isn't being kept
+	maxAge time.Duration // remove records older than this if set
+
+	transferMu  sync.Mutex
+	transferred map[string]*groupTransfers // transfers done by the stats groups of the running jobs
+}
+
+// groupTransfers collects the last historyMaxTransfers transfers done
+// by a stats group
+type groupTransfers struct {
+	jobs      int               // number of running jobs using the group
+	transfers []HistoryTransfer // ring buffer of the transfers
+	next      int               // index of the oldest transfer once transfers is full
+	omitted   int               // number of transfers overwritten
+}
+
+// add records htr overwriting the oldest transfer if full
+func (gt *groupTransfers) add(htr HistoryTransfer) {
+	if len(gt.transfers) < historyMaxTransfers {
+		gt.transfers = append(gt.transfers, htr)
+		return
+	}
+	gt.transfers[gt.next] = htr
+	gt.next = (gt.next + 1) % len(gt.transfers)
+	gt.omitted++
+}
+
+// take returns the transfers oldest first and the number omitted
+// and resets gt
+func (gt *groupTransfers) take() (transfers []HistoryTransfer, omitted int) {
+	transfers, omitted = gt.transfers, gt.omitted
+	if gt.next > 0 {
+		transfers = append(append([]HistoryTransfer(nil), transfers[gt.next:]...), transfers[:gt.next]...)
+	}
+	gt.transfers, gt.next, gt.omitted = nil, 0, 0
+	return transfers, omitted
+}
+
+var history = jobHistory{
+	transferred: map[string]*groupTransfers{},
+}
+
+// startJob starts collecting the transfers done by group
+func (h *jobHistory) startJob(group string) {
+	h.transferMu.Lock()
+	defer h.transferMu.Unlock()
+	gt := h.transferred[group]
+	if gt == nil {
+		gt = &groupTransfers{}
+		h.transferred[group] = gt
+	}
+	gt.jobs++
+}
+
+// finishJob returns the transfers done by group since the last call,
+// and how many were omitted, and stops collecting them if no other
+// jobs are using group
+func (h *jobHistory) finishJob(group string) (transfers []HistoryTransfer, omitted int) {
+	h.transferMu.Lock()
+	defer h.transferMu.Unlock()
+	gt := h.transferred[group]
+	if gt == nil {
+		return nil, 0
+	}
+	transfers, omitted = gt.take()
+	gt.jobs--
+	if gt.jobs <= 0 {
+		delete(h.transferred, group)
+	}
+	return transfers, omitted
+}
+
+// transferDone is called by accounting when each transfer or check
+// is done. Checks aren't recorded as they are counted in the stats.
+func (h *jobHistory) transferDone(group string, tr accounting.TransferSnapshot) {
+	if tr.Checked {
+		return
+	}
+	h.transferMu.Lock()
+	defer h.transferMu.Unlock()
+	if gt := h.transferred[group]; gt != nil {
+		gt.add(newHistoryTransfer(tr))
+	}
+}
+
+// StartHistory starts keeping a persistent history of the jobs,
+// removing the records older than maxAge if it is set.
+func StartHistory(ctx context.Context, maxAge time.Duration) error {
+	if !kv.Supported() {
+		return errors.New("job history not supported on this OS")
+	}
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	if history.db != nil {
+		return errors.New("job history already started")
+	}
+	db, err := kv.Start(ctx, historyFacility, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open job history: %w", err)
+	}
+	history.db = db
+	history.maxAge = maxAge
+	history.prune()
+	accounting.SetTransferDone(history.transferDone)
+	return nil
+}
+
+// stopHistory stops keeping the history
+func stopHistory() {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	accounting.SetTransferDone(nil)
+	if history.db != nil {
+		_ = history.db.Stop(false)
+		history.db = nil
+	}
+}
+
+// prune removes the records older than maxAge - call with lock held
+func (h *jobHistory) prune() {
+	if h.maxAge <= 0 {
+		return
+	}
+	op := &historyPrune{before: historyKey(time.Now().Add(-h.maxAge))}
+	err := h.db.Do(true, op)
+	if err == kv.ErrEmpty {
+		return
+	} else if err != nil {
+		fs.Errorf(nil, "Job history: failed to remove old records: %v", err)
+		return
+	}
+	if op.n > 0 {
+		fs.Debugf(nil, "Job history: removed %d old records", op.n)
+	}
+}
+
+// historyPrune: remove the records with keys before the key
+type historyPrune struct {
+	before string
+	n      int
+}
+
+func (op *historyPrune) Do(ctx context.Context, b kv.Bucket) error {
+	var keys [][]byte
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && string(k) < op.before; k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	op.n = len(keys)
+	return nil
+}
+
+// historyPut: add a record
+type historyPut struct {
+	rec *HistoryRecord
+}
+
+func (op *historyPut) Do(ctx context.Context, b kv.Bucket) error {
+	data, err := json.Marshal(op.rec)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	return b.Put([]byte(op.rec.key()), data)
+}
+
+// historyQuery: read the records matching the filters, newest first
+type historyQuery struct {
+	from, to time.Time
+	status   string
+	remote   string
+	limit    int
+	recs     []HistoryRecord
+}
+
+func (op *historyQuery) Do(ctx context.Context, b kv.Bucket) error {
+	var start []byte
+	if !op.from.IsZero() {
+		start = []byte(historyKey(op.from))
+	}
+	// Find the newest record at or before to
+	c := b.Cursor()
+	var k, data []byte
+	if op.to.IsZero() {
+		k, data = c.Last()
+	} else {
+		end := []byte(historyKey(op.to))
+		k, data = c.Seek(end)
+		if k == nil {
+			k, data = c.Last()
+		}
+		for k != nil && bytes.Compare(k, end) > 0 {
+			k, data = c.Prev()
+		}
+	}
+	for ; k != nil; k, data = c.Prev() {
+		if start != nil && bytes.Compare(k, start) < 0 {
+			break
+		}
+		var rec HistoryRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			fs.Errorf(nil, "%s: invalid job history record: %v", k, err)
+			continue
+		}
+		if rec.matches(op.status, op.remote) {
+			op.recs = append(op.recs, rec)
+			if op.limit > 0 && len(op.recs) >= op.limit {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// recordJob adds the finished job to the history if it is being kept.
+//
+// Only jobs which were run with _async, failed or used their stats
+// group are recorded so polling calls like core/stats aren't.
+func recordJob(job *Job) {
+	transferred, omitted := history.finishJob(job.Group)
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	if history.db == nil {
+		return
+	}
+	job.mu.Lock()
+	rec := &HistoryRecord{
+		ID:        job.ID,
+		Group:     job.Group,
+		Path:      job.path,
+		Remotes:   job.remotes,
+		Schedule:  job.Schedule,
+		StartTime: job.StartTime,
+		EndTime:   job.EndTime,
+		Duration:  job.Duration,
+		Success:   job.Success,
+		Error:     job.Error,
+	}
+	async := job.async
+	job.mu.Unlock()
+	stats := accounting.LookupStatsGroup(rec.Group)
+	if !async && rec.Success && stats == nil {
+		return
+	}
+	if stats != nil {
+		var err error
+		rec.Stats, err = stats.RemoteStats()
+		if err != nil {
+			fs.Errorf(nil, "Job history: failed to read stats for job %d: %v", rec.ID, err)
+		}
+		rec.Transferred = transferred
+		rec.Omitted = omitted
+	}
+	err := history.db.Do(true, &historyPut{rec: rec})
+	if err != nil {
+		fs.Errorf(nil, "Job history: failed to record job %d: %v", rec.ID, err)
+	}
+	history.prune()
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/history",
+		Fn:    rcJobHistory,
+		Title: "Reads the history of finished jobs",
+		Help: `This returns the finished jobs recorded by --rc-job-history, newest
+first. The history is kept on disk so isn't lost when rclone rcd is
+restarted.
+
+Only jobs which were run with _async, failed or transferred or checked
+files are recorded. The last 1000 files transferred by each job are
+recorded with it. Checks are only counted in the stats.
+
+Parameters:
+
+- from - only return jobs started at or after this time (string, optional)
+- to - only return jobs started at or before this time (string, optional)
+- status - "success" or "failed" to only return those jobs (string, optional)
+- remote - only return jobs using this remote, e.g. "s3:" or "s3:bucket" (string, optional)
+- limit - return at most this many jobs (integer, default 100)
+
+The times are in RFC3339 format, e.g. "2023-07-15T10:00:00Z", or may
+be a duration before now, e.g. "24h".
+
+Results:
+
+- jobs - an array of jobs, each with
+    - id - id of the job
+    - group - stats group of the job
+    - path - the rc command, e.g. "sync/sync"
+    - remotes - the fs, srcFs and dstFs parameters
+    - schedule - id of the schedule which started the job, if any
+    - startTime - time the job started
+    - endTime - time the job finished
+    - duration - time in seconds that the job ran for
+    - success - boolean - true for success false otherwise
+    - error - error from the job or empty string for no error
+    - stats - the final core/stats for the group of the job
+    - transferred - the last 1000 files transferred by the job in the
+      same form as core/transferred
+    - omitted - the number of earlier transfers left out of transferred
+`,
+	})
+}
+
+// parseHistoryTime parses a time parameter for job/history
+func parseHistoryTime(in rc.Params, key string) (t time.Time, err error) {
+	value, err := in.GetString(key)
+	if rc.IsErrParamNotFound(err) {
+		return t, nil
+	} else if err != nil {
+		return t, err
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+	ago, err := fs.ParseDuration(value)
+	if err != nil {
+		return t, rc.NewErrParamInvalid(fmt.Errorf("%s: need an RFC3339 time or a duration: %q", key, value))
+	}
+	return time.Now().Add(-ago), nil
+}
+
+// Returns the history of the jobs
+func rcJobHistory(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	op := &historyQuery{limit: 100}
+	op.from, err = parseHistoryTime(in, "from")
+	if err != nil {
+		return nil, err
+	}
+	op.to, err = parseHistoryTime(in, "to")
+	if err != nil {
+		return nil, err
+	}
+	op.status, err = in.GetString("status")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	switch op.status {
+	case "", "success", "failed":
+	default:
+		return nil, rc.NewErrParamInvalid(fmt.Errorf("status must be success or failed: %q", op.status))
+	}
+	op.remote, err = in.GetString("remote")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	limit, err := in.GetInt64("limit")
+	if err == nil {
+		op.limit = int(limit)
+	} else if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+
+	history.mu.Lock()
+	db := history.db
+	if db == nil {
+		history.mu.Unlock()
+		return nil, errors.New("job history isn't being kept - use --rc-job-history")
+	}
+	err = db.Do(false, op)
+	history.mu.Unlock()
+	if err != nil && err != kv.ErrEmpty {
+		return nil, err
+	}
+	if op.recs == nil {
+		op.recs = []HistoryRecord{}
+	}
+	out = make(rc.Params)
+	err = rc.Reshape(&out, rc.Params{"jobs": op.recs})
+	if err != nil {
+		return nil, fmt.Errorf("reshape failed in job history: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// read the job history with the parameters passed in
+func readHistory(t *testing.T, in rc.Params) []HistoryRecord {
+	call := rc.Calls.Get("job/history")
+	require.NotNil(t, call)
+	out, err := call.Fn(context.Background(), in)
+	require.NoError(t, err)
+	var recs []HistoryRecord
+	require.NoError(t, rc.Reshape(&recs, out["jobs"]))
+	return recs
+}
+
+func TestHistoryRecordMatches(t *testing.T) {
+	rec := HistoryRecord{Success: true, Remotes: []string{"s3:bucket/dir", "/tmp/local"}}
+	for _, test := range []struct {
+		status, remote string
+		want           bool
+	}{
+		{"", "", true},
+		{"success", "", true},
+		{"failed", "", false},
+		{"", "s3:", true},
+		{"", "s3:bucket", true},
+		{"", "s3:bucket/", true},
+		{"", "s3:bucket/dir", true},
+		{"", "s3:buck", false},
+		{"", "/tmp", true},
+		{"", "/tmp/loc", false},
+		{"", "b2:", false},
+		{"failed", "s3:", false},
+	} {
+		assert.Equal(t, test.want, rec.matches(test.status, test.remote), test)
+	}
+}
+
+func TestJobHistory(t *testing.T) {
+	if !kv.Supported() {
+		t.Skip("kv not supported on this OS")
+	}
+	ctx := context.Background()
+	call := rc.Calls.Get("job/history")
+	require.NotNil(t, call)
+	_, err := call.Fn(ctx, rc.Params{})
+	require.Error(t, err)
+
+	require.NoError(t, StartHistory(ctx, time.Hour))
+	defer stopHistory()
+	require.Error(t, StartHistory(ctx, time.Hour))
+
+	start := time.Now()
+	jobs := newJobs()
+
+	// A successful synchronous job without stats isn't recorded
+	_, _, err = jobs.NewJob(ctx, noopFn, rc.Params{"_path": "test/noop"})
+	require.NoError(t, err)
+	assert.Len(t, readHistory(t, rc.Params{}), 0)
+
+	// A failed job is recorded
+	failFn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		return nil, errors.New("potato")
+	}
+	failJob, _, err := jobs.NewJob(ctx, failFn, rc.Params{"_path": "test/fail", "fs": "s3:bucket"})
+	require.Error(t, err)
+
+	// A job which transferred files is recorded with its stats and
+	// its last historyMaxTransfers transfers but not its checks
+	oldMaxTransfers := historyMaxTransfers
+	historyMaxTransfers = 20
+	defer func() { historyMaxTransfers = oldMaxTransfers }()
+	nTransfers := historyMaxTransfers + 10
+	statsFn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		stats := accounting.Stats(ctx)
+		stats.Bytes(42)
+		for i := 0; i < nTransfers; i++ {
+			stats.NewTransferRemoteSize(fmt.Sprintf("file%d", i), 1).Done(ctx, nil)
+			stats.NewCheckingTransfer(object.NewStaticObjectInfo(fmt.Sprintf("check%d", i), time.Now(), 1, true, nil, nil), "checking").Done(ctx, nil)
+		}
+		return nil, nil
+	}
+	statsJob, _, err := jobs.NewJob(ctx, statsFn, rc.Params{"_path": "test/stats", "srcFs": "/tmp/src", "dstFs": "b2:bucket"})
+	require.NoError(t, err)
+
+	// An async job is recorded
+	asyncJob, _, err := jobs.NewJob(ctx, noopFn, rc.Params{"_path": "test/noop", "_async": true, "fs": "s3:other"})
+	require.NoError(t, err)
+	var recs []HistoryRecord
+	for i := 0; i < 100; i++ {
+		recs = readHistory(t, rc.Params{})
+		if len(recs) == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Len(t, recs, 3)
+
+	// Newest first
+	assert.Equal(t, asyncJob.ID, recs[0].ID)
+	assert.True(t, recs[0].Success)
+	assert.Equal(t, statsJob.ID, recs[1].ID)
+	assert.Equal(t, "test/stats", recs[1].Path)
+	assert.Equal(t, []string{"/tmp/src", "b2:bucket"}, recs[1].Remotes)
+	assert.Equal(t, float64(42), recs[1].Stats["bytes"])
+	require.Len(t, recs[1].Transferred, historyMaxTransfers)
+	assert.Equal(t, "file10", recs[1].Transferred[0].Name)
+	assert.Equal(t, fmt.Sprintf("file%d", nTransfers-1), recs[1].Transferred[historyMaxTransfers-1].Name)
+	assert.Equal(t, 10, recs[1].Omitted)
+	assert.Empty(t, recs[0].Transferred)
+	assert.Equal(t, failJob.ID, recs[2].ID)
+	assert.Equal(t, "test/fail", recs[2].Path)
+	assert.False(t, recs[2].Success)
+	assert.Equal(t, "potato", recs[2].Error)
+
+	// Filters
+	recs = readHistory(t, rc.Params{"status": "failed"})
+	require.Len(t, recs, 1)
+	assert.Equal(t, failJob.ID, recs[0].ID)
+	recs = readHistory(t, rc.Params{"status": "success"})
+	assert.Len(t, recs, 2)
+	recs = readHistory(t, rc.Params{"remote": "s3:"})
+	assert.Len(t, recs, 2)
+	recs = readHistory(t, rc.Params{"remote": "s3:bucket"})
+	require.Len(t, recs, 1)
+	assert.Equal(t, failJob.ID, recs[0].ID)
+	recs = readHistory(t, rc.Params{"limit": 1})
+	require.Len(t, recs, 1)
+	assert.Equal(t, asyncJob.ID, recs[0].ID)
+	recs = readHistory(t, rc.Params{"status": "success", "limit": 1, "to": "0s"})
+	require.Len(t, recs, 1)
+	assert.Equal(t, asyncJob.ID, recs[0].ID)
+	recs = readHistory(t, rc.Params{"from": start.Add(-time.Minute).Format(time.RFC3339), "to": "1h"})
+	assert.Len(t, recs, 0)
+	recs = readHistory(t, rc.Params{"from": "1m"})
+	assert.Len(t, recs, 3)
+
+	_, err = call.Fn(ctx, rc.Params{"status": "potato"})
+	assert.Error(t, err)
+	_, err = call.Fn(ctx, rc.Params{"from": "potato"})
+	assert.Error(t, err)
+
+	// Old records are removed
+	history.mu.Lock()
+	history.maxAge = time.Nanosecond
+	history.prune()
+	history.mu.Unlock()
+	assert.Len(t, readHistory(t, rc.Params{}), 0)
+}
@@ -28,8 +28,15 @@ type Job struct {
 	Success   bool      `json:"success"`
 	Duration  float64   `json:"duration"`
 	Output    rc.Params `json:"output"`
+	Schedule  string    `json:"schedule,omitempty"` // ID of the schedule which started the job, if any
 	Stop      func()    `json:"-"`
 	listeners []*func()
+	path      string   // rc path of the command if known
+	async     bool     // set if the job was run with _async
+	remotes   []string // remotes the job was passed
+
+	// notifyTargets are where to send the events for the job
+	notifyTargets []*Notify
 
 	// realErr is the Error before printing it as a string, it's used to return
 	// the real error to the upper application layers while still printing the
@@ -72,6 +79,17 @@ func (job *Job) addListener(fn *func()) {
 	job.listeners = append(job.listeners, fn)
 }
 
+// onFinish calls fn in a new go routine when the job has finished
+func (job *Job) onFinish(fn func()) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.Finished {
+		go fn()
+	} else {
+		job.listeners = append(job.listeners, &fn)
+	}
+}
+
 func (job *Job) removeListener(fn *func()) {
 	job.mu.Lock()
 	defer job.mu.Unlock()
@@ -89,6 +107,15 @@ func (job *Job) run(ctx context.Context, fn rc.Func, in rc.Params) {
 		if r := recover(); r != nil {
 			job.finish(nil, fmt.Errorf("panic received: %v \n%s", r, string(debug.Stack())))
 		}
+		recordJob(job)
+		job.mu.Lock()
+		success := job.Success
+		job.mu.Unlock()
+		if success {
+			job.notify(EventFinish)
+		} else {
+			job.notify(EventError)
+		}
 	}()
 	job.finish(fn(ctx, in))
 }
@@ -202,6 +229,26 @@ func getAsync(ctx context.Context, in rc.Params) (context.Context, bool, error)
 	return ctx, isAsync, nil
 }
 
+// Read the rc path of the command from _path if set
+func getPath(in rc.Params) (string, error) {
+	path, err := in.GetString("_path")
+	if rc.NotErrParamNotFound(err) {
+		return "", err
+	}
+	delete(in, "_path")
+	return path, nil
+}
+
+// getRemotes returns the remotes in the parameters for the job
+func getRemotes(in rc.Params) (remotes []string) {
+	for _, key := range []string{"fs", "srcFs", "dstFs"} {
+		if remote, err := in.GetString(key); err == nil && remote != "" {
+			remotes = append(remotes, remote)
+		}
+	}
+	return remotes
+}
+
 // See if _config is set and if so adjust ctx to include it
 func getConfig(ctx context.Context, in rc.Params) (context.Context, error) {
 	if _, ok := in["_config"]; !ok {
@@ -262,6 +309,25 @@ func (jobs *Jobs) NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Jo
 		return nil, nil, err
 	}
 
+	path, err := getPath(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notify, err := getNotify(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	var notifyTargets []*Notify
+	if notify != nil {
+		notifyTargets = append(notifyTargets, notify)
+	}
+	if isAsync {
+		if global := globalNotify(jobs.opt); global != nil {
+			notifyTargets = append(notifyTargets, global)
+		}
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	stop := func() {
 		cancel()
@@ -273,10 +339,17 @@ func (jobs *Jobs) NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Jo
 		Group:     group,
 		StartTime: time.Now(),
 		Stop:      stop,
+		path:      path,
+		async:     isAsync,
+		remotes:   getRemotes(in),
+
+		notifyTargets: notifyTargets,
 	}
 	jobs.mu.Lock()
 	jobs.jobs[job.ID] = job
 	jobs.mu.Unlock()
+	history.startJob(group)
+	job.notify(EventStart)
 	if isAsync {
 		go job.run(ctx, fn, in)
 		out = make(rc.Params)
@@ -319,6 +392,7 @@ func init() {
 		Help: `Parameters:
 
 - jobid - id of the job (integer).
+- schedule - id of a schedule to read the status of its last job instead (string).
 
 Results:
 
@@ -332,16 +406,35 @@ Results:
 - success - boolean - true for success false otherwise
 - output - output of the job as would have been returned if called synchronously
 - progress - output of the progress related to the underlying job
+- schedule - id of the schedule which started the job, if any
+
+If schedule is passed then the status of the running job is returned
+if there is one, otherwise the result of the last run recorded by the
+scheduler, which is kept after the job itself has expired.
 `,
 	})
 }
 
 // Returns the status of a job
 func rcJobStatus(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	if _, found := in["jobid"]; !found {
+		if id, err := in.GetString("schedule"); err == nil {
+			s, err := getScheduler()
+			if err != nil {
+				return nil, err
+			}
+			return s.status(id)
+		}
+	}
 	jobID, err := in.GetInt64("jobid")
 	if err != nil {
 		return nil, err
 	}
+	return jobStatus(jobID)
+}
+
+// jobStatus returns the status of the job with jobID
+func jobStatus(jobID int64) (out rc.Params, err error) {
 	job := running.Get(jobID)
 	if job == nil {
 		return nil, errors.New("job not found")
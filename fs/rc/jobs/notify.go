@@ -0,0 +1,294 @@
+// Send notifications when jobs start and finish
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fshttp"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/atexit"
+)
+
+// Types of event sent when jobs change state
+const (
+	EventStart  = "start"  // the job has started
+	EventFinish = "finish" // the job has finished successfully
+	EventError  = "error"  // the job has finished with an error
+)
+
+// notifyTimeout is the maximum time to wait for a webhook or command
+const notifyTimeout = time.Minute
+
+// notifyRetries is the number of times to try a webhook
+const notifyRetries = 3
+
+// Event describes a change of state of a job which is sent to the
+// notification targets.
+type Event struct {
+	Event     string    `json:"event"` // one of EventStart, EventFinish or EventError
+	ID        int64     `json:"id"`
+	Group     string    `json:"group"`
+	Path      string    `json:"path"`
+	Remotes   []string  `json:"remotes"`
+	Schedule  string    `json:"schedule,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Duration  float64   `json:"duration"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error"`
+	Stats     rc.Params `json:"stats,omitempty"` // core/stats for the group of the job
+}
+
+// Notify describes where to send the events of a job.
+//
+// This is read from the _notify parameter of an rc call or made from
+// the --rc-notify-* flags. Command can only be set with the flags.
+type Notify struct {
+	URL     []string `json:"url"`     // webhooks to POST the event to as JSON
+	Command []string `json:"command"` // command and arguments to run with the event as JSON on stdin
+	Events  []string `json:"events"`  // events to send - all events if empty
+}
+
+// validate checks the notification targets and events are valid
+func (n *Notify) validate() error {
+	for _, u := range n.URL {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("bad notify url %q: %w", u, err)
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return fmt.Errorf("notify url %q must be http or https", u)
+		}
+	}
+	for _, event := range n.Events {
+		switch event {
+		case EventStart, EventFinish, EventError:
+		default:
+			return fmt.Errorf("unknown notify event %q - must be %s, %s or %s", event, EventStart, EventFinish, EventError)
+		}
+	}
+	return nil
+}
+
+// isEmpty returns true if there is nowhere to send the events
+func (n *Notify) isEmpty() bool {
+	return len(n.URL) == 0 && len(n.Command) == 0
+}
+
+// wants returns true if event should be sent
+func (n *Notify) wants(event string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+	for _, e := range n.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyWg tracks the notifications being sent
+var notifyWg sync.WaitGroup
+
+// notifyAtExit makes sure the notifications are waited for on exit
+var notifyAtExit sync.Once
+
+// waitNotify waits for the notifications being sent to finish
+func waitNotify() {
+	fs.Debugf(nil, "Waiting for job notifications to be sent")
+	notifyWg.Wait()
+}
+
+// send the event to the webhooks and command in the background
+func (n *Notify) send(ev *Event, data []byte) {
+	if !n.wants(ev.Event) {
+		return
+	}
+	notifyAtExit.Do(func() {
+		atexit.Register(waitNotify)
+	})
+	for _, u := range n.URL {
+		u := u
+		notifyWg.Add(1)
+		go func() {
+			defer notifyWg.Done()
+			if err := postWebhook(u, data); err != nil {
+				fs.Errorf(nil, "Job %d: failed to send %s notification: %v", ev.ID, ev.Event, err)
+			}
+		}()
+	}
+	if len(n.Command) != 0 {
+		notifyWg.Add(1)
+		go func() {
+			defer notifyWg.Done()
+			if err := runNotifyCommand(n.Command, ev, data); err != nil {
+				fs.Errorf(nil, "Job %d: failed to run %s notification command: %v", ev.ID, ev.Event, err)
+			}
+		}()
+	}
+}
+
+// postWebhook POSTs data to u retrying on failure
+func postWebhook(u string, data []byte) (err error) {
+	ctx := context.Background()
+	client := fshttp.NewClient(ctx)
+	for try := 1; try <= notifyRetries; try++ {
+		err = postWebhookOnce(ctx, client, u, data)
+		if err == nil {
+			return nil
+		}
+		if try < notifyRetries {
+			fs.Debugf(nil, "Notify webhook %q failed - retrying (%d/%d): %v", u, try, notifyRetries, err)
+			time.Sleep(time.Duration(try) * time.Second)
+		}
+	}
+	return err
+}
+
+// postWebhookOnce POSTs data to u
+func postWebhookOnce(ctx context.Context, client *http.Client, u string, data []byte) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(resp.Body, &err)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned HTTP status %s", resp.Status)
+	}
+	return nil
+}
+
+// runNotifyCommand runs command with data on stdin and details of the
+// event in the environment
+func runNotifyCommand(command []string, ev *Event, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(),
+		"RCLONE_EVENT="+ev.Event,
+		"RCLONE_JOB_ID="+strconv.FormatInt(ev.ID, 10),
+		"RCLONE_JOB_GROUP="+ev.Group,
+		"RCLONE_JOB_PATH="+ev.Path,
+		"RCLONE_JOB_SUCCESS="+strconv.FormatBool(ev.Success),
+		"RCLONE_JOB_ERROR="+ev.Error,
+	)
+	err := cmd.Run()
+	if err != nil {
+		if ers := strings.TrimSpace(stderr.String()); ers != "" {
+			return fmt.Errorf("%w: %s", err, ers)
+		}
+		return err
+	}
+	return nil
+}
+
+// getNotify reads the _notify parameter if set
+func getNotify(in rc.Params) (*Notify, error) {
+	if _, ok := in["_notify"]; !ok {
+		return nil, nil
+	}
+	n := new(Notify)
+	err := in.GetStruct("_notify", n)
+	if err != nil {
+		return nil, err
+	}
+	if len(n.Command) != 0 {
+		return nil, rc.NewErrParamInvalid(errors.New("_notify can't set a command - use --rc-notify-command instead"))
+	}
+	err = n.validate()
+	if err != nil {
+		return nil, rc.NewErrParamInvalid(err)
+	}
+	delete(in, "_notify") // remove the parameter
+	return n, nil
+}
+
+// CheckNotify checks the --rc-notify-* flags are valid
+func CheckNotify(opt *rc.Options) error {
+	n := globalNotify(opt)
+	if n == nil {
+		return nil
+	}
+	return n.validate()
+}
+
+// globalNotify returns the notifications set with the --rc-notify-*
+// flags or nil if there aren't any
+func globalNotify(opt *rc.Options) *Notify {
+	n := &Notify{
+		URL:     opt.NotifyURL,
+		Command: opt.NotifyCommand,
+		Events:  opt.NotifyEvents,
+	}
+	if n.isEmpty() {
+		return nil
+	}
+	return n
+}
+
+// notify sends the event for the job to the notification targets of
+// the job
+func (job *Job) notify(event string) {
+	job.mu.Lock()
+	ev := &Event{
+		Event:     event,
+		ID:        job.ID,
+		Group:     job.Group,
+		Path:      job.path,
+		Remotes:   job.remotes,
+		Schedule:  job.Schedule,
+		StartTime: job.StartTime,
+		EndTime:   job.EndTime,
+		Duration:  job.Duration,
+		Success:   job.Success,
+		Error:     job.Error,
+	}
+	targets := job.notifyTargets
+	job.mu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+	if event != EventStart {
+		if stats := accounting.LookupStatsGroup(ev.Group); stats != nil {
+			var err error
+			ev.Stats, err = stats.RemoteStats()
+			if err != nil {
+				fs.Errorf(nil, "Job %d: failed to read stats for notification: %v", ev.ID, err)
+			}
+		}
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		fs.Errorf(nil, "Job %d: failed to marshal notification: %v", ev.ID, err)
+		return
+	}
+	for _, n := range targets {
+		n.send(ev, data)
+	}
+}
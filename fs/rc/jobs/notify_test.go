@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyValidate(t *testing.T) {
+	for _, test := range []struct {
+		n       Notify
+		wantErr bool
+	}{
+		{Notify{}, false},
+		{Notify{URL: []string{"http://example.com/hook"}, Events: []string{"start", "finish", "error"}}, false},
+		{Notify{URL: []string{"ftp://example.com/hook"}}, true},
+		{Notify{URL: []string{":potato"}}, true},
+		{Notify{Events: []string{"potato"}}, true},
+	} {
+		err := test.n.validate()
+		assert.Equal(t, test.wantErr, err != nil, test.n)
+	}
+}
+
+func TestNotifyWants(t *testing.T) {
+	n := Notify{}
+	assert.True(t, n.wants(EventStart))
+	n.Events = []string{EventError}
+	assert.False(t, n.wants(EventStart))
+	assert.False(t, n.wants(EventFinish))
+	assert.True(t, n.wants(EventError))
+}
+
+func TestGetNotify(t *testing.T) {
+	n, err := getNotify(rc.Params{})
+	require.NoError(t, err)
+	assert.Nil(t, n)
+
+	in := rc.Params{"_notify": `{"url": ["https://example.com/hook"], "events": ["error"]}`}
+	n, err = getNotify(in)
+	require.NoError(t, err)
+	assert.Equal(t, &Notify{URL: []string{"https://example.com/hook"}, Events: []string{"error"}}, n)
+	assert.NotContains(t, in, "_notify")
+
+	_, err = getNotify(rc.Params{"_notify": rc.Params{"events": []string{"potato"}}})
+	assert.Error(t, err)
+
+	// Commands can only be set with --rc-notify-command
+	_, err = getNotify(rc.Params{"_notify": rc.Params{"command": []string{"sh", "-c", "echo potato"}}})
+	assert.Error(t, err)
+	assert.True(t, rc.IsErrParamInvalid(err))
+}
+
+func TestNotifyWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var events []Event
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var ev Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	failFn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		return nil, errors.New("potato")
+	}
+	jobs := newJobs()
+	job, _, err := jobs.NewJob(context.Background(), failFn, rc.Params{
+		"_path":   "test/fail",
+		"fs":      "s3:bucket",
+		"_notify": rc.Params{"url": []string{ts.URL}},
+	})
+	require.Error(t, err)
+	notifyWg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, events, 2)
+	// The notifications are sent in the background so may arrive in any order
+	if events[0].Event != EventStart {
+		events[0], events[1] = events[1], events[0]
+	}
+	assert.Equal(t, EventStart, events[0].Event)
+	assert.Equal(t, EventError, events[1].Event)
+	for _, ev := range events {
+		assert.Equal(t, job.ID, ev.ID)
+		assert.Equal(t, "test/fail", ev.Path)
+		assert.Equal(t, []string{"s3:bucket"}, ev.Remotes)
+	}
+	assert.Equal(t, "potato", events[1].Error)
+	assert.False(t, events[1].Success)
+}
+
+func TestNotifyGlobal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	jobs := newJobs()
+	opt := rc.DefaultOpt
+	opt.NotifyCommand = []string{"sh", "-c", `cat > "$0"; echo "$RCLONE_EVENT $RCLONE_JOB_PATH" >> "$0"`, out}
+	jobs.opt = &opt
+
+	// Synchronous jobs don't use the global notifications
+	_, _, err := jobs.NewJob(context.Background(), noopFn, rc.Params{"_path": "test/noop"})
+	require.NoError(t, err)
+	notifyWg.Wait()
+	_, err = os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+
+	// Async jobs do, but only for finish and error by default
+	job, _, err := jobs.NewJob(context.Background(), noopFn, rc.Params{"_path": "test/noop", "_async": true})
+	require.NoError(t, err)
+	var data []byte
+	for i := 0; i < 500; i++ {
+		data, _ = os.ReadFile(out)
+		if bytes.Contains(data, []byte("\n")) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var ev Event
+	require.NoError(t, dec.Decode(&ev))
+	assert.Equal(t, EventFinish, ev.Event)
+	assert.Equal(t, job.ID, ev.ID)
+	assert.True(t, ev.Success)
+	rest, err := io.ReadAll(dec.Buffered())
+	require.NoError(t, err)
+	assert.Equal(t, "finish test/noop\n", string(rest))
+}
@@ -0,0 +1,583 @@
+// Run rc commands on a schedule
+
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// ScheduleRun describes a run of a Schedule
+type ScheduleRun struct {
+	JobID     int64     `json:"id"`
+	Group     string    `json:"group"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Error     string    `json:"error"`
+	Finished  bool      `json:"finished"`
+	Success   bool      `json:"success"`
+	Duration  float64   `json:"duration"`
+	Output    rc.Params `json:"output"`
+}
+
+// Schedule describes an rc command run regularly by the scheduler
+type Schedule struct {
+	ID      string       `json:"id"`      // name of the schedule
+	Cron    string       `json:"cron"`    // when to run as a cron expression
+	Command string       `json:"command"` // rc command to run, e.g. "sync/sync"
+	Params  rc.Params    `json:"params"`  // parameters for the command
+	Created time.Time    `json:"created"` // when the schedule was added
+	NextRun time.Time    `json:"nextRun"` // when it will run next
+	LastRun *ScheduleRun `json:"lastRun"` // the last run to finish, if any
+	Skipped int64        `json:"skipped"` // runs skipped as the previous run was still going
+
+	cron  *cronSchedule // parsed Cron
+	jobID int64         // ID of the running job or 0
+}
+
+// scheduler runs the Schedules
+type scheduler struct {
+	mu        sync.Mutex
+	path      string               // file to save the schedules in
+	schedules map[string]*Schedule // schedules by ID
+	nextID    int                  // for making IDs
+	kick      chan struct{}        // send on here when the schedules change
+}
+
+// schedules is the scheduler, or nil if it isn't running
+var (
+	schedulesMu sync.Mutex
+	schedules   *scheduler
+)
+
+// StartScheduler starts running the schedules saved in path, which
+// is created if it doesn't exist.
+//
+// The schedule file is locked while the scheduler is running so only
+// one rclone can run its schedules.
+//
+// It can only be called once.
+func StartScheduler(ctx context.Context, path string) error {
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	if schedules != nil {
+		return errors.New("scheduler already running")
+	}
+	lock, err := lockScheduleFile(path)
+	if err != nil {
+		return err
+	}
+	s, err := newScheduler(path)
+	if err != nil {
+		_ = lock.Unlock()
+		return err
+	}
+	schedules = s
+	go func() {
+		s.run(ctx)
+		_ = lock.Unlock()
+	}()
+	return nil
+}
+
+// lockScheduleFile takes the lock file for the schedule file in path
+func lockScheduleFile(path string) (*flock.Flock, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make directory for schedules: %w", err)
+	}
+	lock := flock.New(path + ".lock")
+	locked, err := lock.TryLock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock schedule file: %w", err)
+	}
+	if !locked {
+		return nil, fmt.Errorf("schedule file %q is in use by another rclone", path)
+	}
+	return lock, nil
+}
+
+// getScheduler returns the running scheduler or an error
+func getScheduler() (*scheduler, error) {
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	if schedules == nil {
+		return nil, errors.New("scheduler isn't running - it is only run by rclone rcd")
+	}
+	return schedules, nil
+}
+
+// newScheduler makes a scheduler loading the schedules from path
+func newScheduler(path string) (*scheduler, error) {
+	s := &scheduler{
+		path:      path,
+		schedules: map[string]*Schedule{},
+		nextID:    1,
+		kick:      make(chan struct{}, 1),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read schedules: %w", err)
+	}
+	var saved []*Schedule
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schedules in %q: %w", path, err)
+	}
+	now := time.Now()
+	for _, sched := range saved {
+		sched.cron, err = parseCron(sched.Cron)
+		if err != nil {
+			fs.Errorf(nil, "Ignoring schedule %q: %v", sched.ID, err)
+			continue
+		}
+		// Don't try to catch up on runs missed while stopped
+		if sched.NextRun.Before(now) {
+			sched.NextRun = sched.cron.next(now)
+		}
+		s.schedules[sched.ID] = sched
+		s.updateNextID(sched.ID)
+	}
+	fs.Debugf(nil, "Loaded %d schedules from %q", len(s.schedules), path)
+	return s, nil
+}
+
+// updateNextID makes sure generated IDs won't clash with id
+func (s *scheduler) updateNextID(id string) {
+	if n, err := strconv.Atoi(id); err == nil && n >= s.nextID {
+		s.nextID = n + 1
+	}
+}
+
+// list returns the schedules sorted by ID - call with lock held
+func (s *scheduler) list() []*Schedule {
+	out := make([]*Schedule, 0, len(s.schedules))
+	for _, sched := range s.schedules {
+		out = append(out, sched)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// save the schedules to disk - call with lock held
+func (s *scheduler) save() error {
+	data, err := json.MarshalIndent(s.list(), "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedules: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(s.path), 0700)
+	if err != nil {
+		return fmt.Errorf("failed to make directory for schedules: %w", err)
+	}
+	// Write to a temporary file and rename so the file is always valid
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write schedules: %w", err)
+	}
+	err = os.Rename(tmp, s.path)
+	if err != nil {
+		return fmt.Errorf("failed to write schedules: %w", err)
+	}
+	return nil
+}
+
+// saveAndLog saves the schedules logging any error - call with lock held
+func (s *scheduler) saveAndLog() {
+	if err := s.save(); err != nil {
+		fs.Errorf(nil, "Scheduler: %v", err)
+	}
+}
+
+// kickScheduler wakes the scheduler up to look at the schedules again
+func (s *scheduler) kickScheduler() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// add a schedule returning its ID
+func (s *scheduler) add(id, cronExpr, command string, params rc.Params) (*Schedule, error) {
+	c, err := parseCron(cronExpr)
+	if err != nil {
+		return nil, err
+	}
+	call := rc.Calls.Get(command)
+	if call == nil {
+		return nil, fmt.Errorf("couldn't find command %q", command)
+	}
+	if call.NeedsRequest || call.NeedsResponse {
+		return nil, fmt.Errorf("command %q can't be scheduled", command)
+	}
+	if params == nil {
+		params = rc.Params{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == "" {
+		id = strconv.Itoa(s.nextID)
+	}
+	if _, found := s.schedules[id]; found {
+		return nil, fmt.Errorf("schedule %q already exists", id)
+	}
+	now := time.Now()
+	nextRun := c.next(now)
+	if nextRun.IsZero() {
+		return nil, fmt.Errorf("cron expression %q never matches", cronExpr)
+	}
+	s.updateNextID(id)
+	sched := &Schedule{
+		ID:      id,
+		Cron:    cronExpr,
+		Command: command,
+		Params:  params,
+		Created: now,
+		NextRun: nextRun,
+		cron:    c,
+	}
+	s.schedules[id] = sched
+	err = s.save()
+	if err != nil {
+		delete(s.schedules, id)
+		return nil, err
+	}
+	s.kickScheduler()
+	return sched, nil
+}
+
+// remove the schedule with id
+func (s *scheduler) remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.schedules[id]; !found {
+		return fmt.Errorf("schedule %q not found", id)
+	}
+	delete(s.schedules, id)
+	s.kickScheduler()
+	return s.save()
+}
+
+// run the schedules until ctx is cancelled
+func (s *scheduler) run(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		now := time.Now()
+		s.mu.Lock()
+		next := now.Add(time.Hour)
+		for _, sched := range s.list() {
+			if !sched.NextRun.After(now) {
+				s.start(sched, now)
+			}
+			if sched.NextRun.Before(next) {
+				next = sched.NextRun
+			}
+		}
+		s.mu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(time.Until(next))
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.kick:
+		case <-timer.C:
+		}
+	}
+}
+
+// start a run of sched - call with lock held
+func (s *scheduler) start(sched *Schedule, now time.Time) {
+	sched.NextRun = sched.cron.next(now)
+	if sched.NextRun.IsZero() {
+		// Should never happen as checked when added
+		sched.NextRun = now.Add(24 * time.Hour)
+	}
+	if sched.jobID != 0 {
+		sched.Skipped++
+		fs.Logf(nil, "Scheduler: skipping run of schedule %q as job %d is still running", sched.ID, sched.jobID)
+		s.saveAndLog()
+		return
+	}
+	call := rc.Calls.Get(sched.Command)
+	if call == nil {
+		s.finished(sched, &ScheduleRun{
+			StartTime: now,
+			EndTime:   now,
+			Finished:  true,
+			Error:     fmt.Sprintf("couldn't find command %q", sched.Command),
+		})
+		return
+	}
+	in := sched.Params.Copy()
+	in["_async"] = true
+	if _, found := in["_group"]; !found {
+		in["_group"] = "schedule/" + sched.ID
+	}
+	in["_path"] = sched.Command
+	job, _, err := running.NewJob(context.Background(), call.Fn, in)
+	if err != nil {
+		s.finished(sched, &ScheduleRun{
+			StartTime: now,
+			EndTime:   now,
+			Finished:  true,
+			Error:     err.Error(),
+		})
+		return
+	}
+	fs.Infof(nil, "Scheduler: started job %d for schedule %q", job.ID, sched.ID)
+	sched.jobID = job.ID
+	job.mu.Lock()
+	job.Schedule = sched.ID
+	job.mu.Unlock()
+	job.onFinish(func() {
+		job.mu.Lock()
+		run := &ScheduleRun{
+			JobID:     job.ID,
+			Group:     job.Group,
+			StartTime: job.StartTime,
+			EndTime:   job.EndTime,
+			Error:     job.Error,
+			Finished:  job.Finished,
+			Success:   job.Success,
+			Duration:  job.Duration,
+			Output:    job.Output,
+		}
+		job.mu.Unlock()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		sched.jobID = 0
+		s.finished(sched, run)
+	})
+}
+
+// finished records the result of a run of sched - call with lock held
+func (s *scheduler) finished(sched *Schedule, run *ScheduleRun) {
+	if run.Success {
+		fs.Infof(nil, "Scheduler: job %d for schedule %q succeeded", run.JobID, sched.ID)
+	} else {
+		fs.Errorf(nil, "Scheduler: job %d for schedule %q failed: %s", run.JobID, sched.ID, run.Error)
+	}
+	sched.LastRun = run
+	// Only save if the schedule hasn't been removed
+	if s.schedules[sched.ID] == sched {
+		s.saveAndLog()
+	}
+}
+
+// status returns the job/status of the last run of the schedule with id
+func (s *scheduler) status(id string) (out rc.Params, err error) {
+	s.mu.Lock()
+	sched := s.schedules[id]
+	var jobID int64
+	var run *ScheduleRun
+	if sched != nil {
+		jobID = sched.jobID
+		run = sched.LastRun
+	}
+	s.mu.Unlock()
+	if sched == nil {
+		return nil, fmt.Errorf("schedule %q not found", id)
+	}
+	// If the job is running then return its status
+	if jobID != 0 {
+		return jobStatus(jobID)
+	}
+	if run == nil {
+		return nil, fmt.Errorf("schedule %q hasn't run yet", id)
+	}
+	out = make(rc.Params)
+	err = rc.Reshape(&out, run)
+	if err != nil {
+		return nil, fmt.Errorf("reshape failed in job status: %w", err)
+	}
+	out["schedule"] = id
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "schedule/add",
+		AuthRequired: true,
+		Fn:           rcScheduleAdd,
+		Title:        "Add a schedule to run an rc command regularly",
+		Help: `This adds a schedule to run an rc command regularly as a job.
+This needs the scheduler which is only run by rclone rcd.
+
+Parameters:
+
+- cron - when to run the command as a cron expression (string)
+- command - the rc command to run, e.g. "sync/sync" (string)
+- params - the parameters for the command (object, optional)
+- id - name for the schedule (string, optional)
+
+The cron expression is either the standard 5 fields "minute hour
+day-of-month month day-of-week" in local time, one of "@yearly",
+"@monthly", "@weekly", "@daily" or "@hourly" or "@every DURATION",
+e.g. "@every 1h30m".
+
+The params may contain the _config, _filter and _group parameters
+like any other job. If _group isn't set then the jobs are put in the
+group "schedule/ID".
+
+The schedules are saved in --rc-schedule-file so they are run again
+when rclone rcd is restarted.
+
+If the job started by the schedule is still running when the schedule
+is next due then that run is skipped.
+
+Eg
+
+    rclone rc schedule/add id=photos cron="0 2 * * *" command=sync/sync params='{"srcFs": "/home/photos", "dstFs": "s3:photos"}'
+
+Results:
+
+- schedule - the schedule as returned by schedule/list
+`,
+	})
+}
+
+// Adds a schedule
+func rcScheduleAdd(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	s, err := getScheduler()
+	if err != nil {
+		return nil, err
+	}
+	cronExpr, err := in.GetString("cron")
+	if err != nil {
+		return nil, err
+	}
+	command, err := in.GetString("command")
+	if err != nil {
+		return nil, err
+	}
+	id, err := in.GetString("id")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	var params rc.Params
+	err = in.GetStructMissingOK("params", &params)
+	if err != nil {
+		return nil, err
+	}
+	sched, err := s.add(id, cronExpr, command, params)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var item rc.Params
+	err = rc.Reshape(&item, sched)
+	if err != nil {
+		return nil, fmt.Errorf("reshape failed in schedule add: %w", err)
+	}
+	item["running"] = sched.jobID
+	out = rc.Params{
+		"schedule": item,
+	}
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "schedule/list",
+		Fn:    rcScheduleList,
+		Title: "List the schedules",
+		Help: `Parameters: None.
+
+Results:
+
+- schedules - array of the schedules, each with
+    - id - name of the schedule
+    - cron - when to run as a cron expression
+    - command - the rc command to run
+    - params - the parameters for the command
+    - created - time the schedule was added
+    - nextRun - time the schedule will next run
+    - running - the ID of the job if running or 0
+    - skipped - number of runs skipped as the previous run was still going
+    - lastRun - the status of the last run which finished, or null,
+      in the same form as job/status
+`,
+	})
+}
+
+// Lists the schedules
+func rcScheduleList(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	s, err := getScheduler()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []rc.Params{}
+	for _, sched := range s.list() {
+		var item rc.Params
+		err = rc.Reshape(&item, sched)
+		if err != nil {
+			return nil, fmt.Errorf("reshape failed in schedule list: %w", err)
+		}
+		item["running"] = sched.jobID
+		list = append(list, item)
+	}
+	out = rc.Params{
+		"schedules": list,
+	}
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "schedule/remove",
+		AuthRequired: true,
+		Fn:           rcScheduleRemove,
+		Title:        "Remove a schedule",
+		Help: `This removes a schedule so it won't be run again. If a job
+started by the schedule is running it isn't stopped - use job/stop for
+that.
+
+Parameters:
+
+- id - name of the schedule (string)
+`,
+	})
+}
+
+// Removes a schedule
+func rcScheduleRemove(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	s, err := getScheduler()
+	if err != nil {
+		return nil, err
+	}
+	id, err := in.GetString("id")
+	if err != nil {
+		return nil, err
+	}
+	err = s.remove(id)
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{}, nil
+}
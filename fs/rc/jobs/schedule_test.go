@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wait for the schedule with id to finish a run
+func waitForRun(t *testing.T, s *scheduler, id string) *ScheduleRun {
+	for i := 0; i < 500; i++ {
+		s.mu.Lock()
+		sched := s.schedules[id]
+		run, jobID := sched.LastRun, sched.jobID
+		s.mu.Unlock()
+		if run != nil && jobID == 0 {
+			return run
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("schedule %q didn't run", id)
+	return nil
+}
+
+func TestSchedulerAddRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	s, err := newScheduler(path)
+	require.NoError(t, err)
+
+	// Errors
+	_, err = s.add("", "potato", "rc/noop", nil)
+	assert.Error(t, err)
+	_, err = s.add("", "@daily", "not/found", nil)
+	assert.Error(t, err)
+	_, err = s.add("", "0 0 30 feb *", "rc/noop", nil)
+	assert.Error(t, err)
+
+	before := time.Now()
+	sched, err := s.add("", "@hourly", "rc/noop", rc.Params{"potato": 1})
+	require.NoError(t, err)
+	assert.Equal(t, "1", sched.ID)
+	assert.True(t, sched.NextRun.After(before))
+	assert.True(t, sched.NextRun.Before(before.Add(time.Hour+time.Minute)))
+	sched, err = s.add("daily", "@daily", "rc/noop", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "daily", sched.ID)
+	_, err = s.add("daily", "@daily", "rc/noop", nil)
+	assert.Error(t, err)
+
+	// The schedules are loaded again
+	s2, err := newScheduler(path)
+	require.NoError(t, err)
+	require.Len(t, s2.schedules, 2)
+	assert.Equal(t, "@hourly", s2.schedules["1"].Cron)
+	assert.Equal(t, "rc/noop", s2.schedules["1"].Command)
+	assert.Equal(t, rc.Params{"potato": float64(1)}, s2.schedules["1"].Params)
+	assert.NotNil(t, s2.schedules["1"].cron)
+	sched, err = s2.add("", "@hourly", "rc/noop", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2", sched.ID)
+
+	require.NoError(t, s.remove("1"))
+	assert.Error(t, s.remove("1"))
+	s2, err = newScheduler(path)
+	require.NoError(t, err)
+	require.Len(t, s2.schedules, 1)
+
+	// A corrupted file is an error
+	require.NoError(t, os.WriteFile(path, []byte("potato"), 0600))
+	_, err = newScheduler(path)
+	assert.Error(t, err)
+}
+
+func TestLockScheduleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rc", "schedules.json")
+	lock, err := lockScheduleFile(path)
+	require.NoError(t, err)
+
+	// Only one scheduler can use the file
+	_, err = lockScheduleFile(path)
+	assert.ErrorContains(t, err, "in use")
+
+	require.NoError(t, lock.Unlock())
+	lock, err = lockScheduleFile(path)
+	require.NoError(t, err)
+	require.NoError(t, lock.Unlock())
+}
+
+func TestSchedulerRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	s, err := newScheduler(path)
+	require.NoError(t, err)
+
+	block := make(chan struct{})
+	rc.Add(rc.Call{
+		Path: "test/schedule-block",
+		Fn: func(ctx context.Context, in rc.Params) (rc.Params, error) {
+			<-block
+			return in, nil
+		},
+	})
+	_, err = s.add("block", "@every 1h", "test/schedule-block", rc.Params{"potato": "jersey royal"})
+	require.NoError(t, err)
+
+	// Start the job and try to start it again while it is running
+	now := time.Now()
+	s.mu.Lock()
+	sched := s.schedules["block"]
+	s.start(sched, now)
+	jobID := sched.jobID
+	s.start(sched, now)
+	assert.Equal(t, jobID, sched.jobID)
+	assert.Equal(t, int64(1), sched.Skipped)
+	assert.Equal(t, now.Add(time.Hour), sched.NextRun)
+	s.mu.Unlock()
+	require.NotEqual(t, int64(0), jobID)
+
+	// The status is the running job
+	out, err := s.status("block")
+	require.NoError(t, err)
+	assert.Equal(t, false, out["finished"])
+	assert.Equal(t, "block", out["schedule"])
+	assert.Equal(t, "schedule/block", out["group"])
+
+	close(block)
+	run := waitForRun(t, s, "block")
+	assert.Equal(t, jobID, run.JobID)
+	assert.True(t, run.Success)
+	assert.Equal(t, rc.Params{"potato": "jersey royal"}, run.Output)
+
+	// The status is kept after the job has expired
+	running.mu.Lock()
+	delete(running.jobs, jobID)
+	running.mu.Unlock()
+	out, err = s.status("block")
+	require.NoError(t, err)
+	assert.Equal(t, true, out["finished"])
+	assert.Equal(t, true, out["success"])
+	assert.Equal(t, "block", out["schedule"])
+
+	// The last run is saved
+	s2, err := newScheduler(path)
+	require.NoError(t, err)
+	require.NotNil(t, s2.schedules["block"].LastRun)
+	assert.Equal(t, jobID, s2.schedules["block"].LastRun.JobID)
+	assert.Equal(t, int64(1), s2.schedules["block"].Skipped)
+
+	_, err = s.status("not-found")
+	assert.Error(t, err)
+}
+
+func TestSchedulerLoop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	s, err := newScheduler(path)
+	require.NoError(t, err)
+	_, err = s.add("noop", "@every 1h", "rc/noop", nil)
+	require.NoError(t, err)
+
+	// Make the schedule due and start the scheduler
+	s.mu.Lock()
+	s.schedules["noop"].NextRun = time.Now()
+	s.mu.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.run(ctx)
+
+	run := waitForRun(t, s, "noop")
+	assert.True(t, run.Success)
+	assert.Equal(t, "schedule/noop", run.Group)
+}
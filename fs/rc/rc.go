@@ -13,6 +13,7 @@ import (
 	_ "net/http/pprof" // install the pprof http handlers
 	"time"
 
+	"github.com/rclone/rclone/fs"
 	libhttp "github.com/rclone/rclone/lib/http"
 )
 
@@ -34,6 +35,12 @@ type Options struct {
 	EnableMetrics            bool   // set to disable prometheus metrics on /metrics
 	JobExpireDuration        time.Duration
 	JobExpireInterval        time.Duration
+	ScheduleFile             string          // file to save the schedules in
+	JobHistory               bool            // set to keep a persistent history of the jobs
+	JobHistoryMaxAge         time.Duration   // remove jobs older than this from the history
+	NotifyURL                []string        // webhooks to POST job events to
+	NotifyCommand            fs.SpaceSepList // command to run with job events
+	NotifyEvents             fs.CommaSepList // job events to notify
 }
 
 // DefaultOpt is the default values used for Options
@@ -44,6 +51,8 @@ var DefaultOpt = Options{
 	Enabled:           false,
 	JobExpireDuration: 60 * time.Second,
 	JobExpireInterval: 10 * time.Second,
+	JobHistoryMaxAge:  30 * 24 * time.Hour,
+	NotifyEvents:      fs.CommaSepList{"finish", "error"},
 }
 
 func init() {
@@ -31,6 +31,12 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &Opt.EnableMetrics, "rc-enable-metrics", "", false, "Enable prometheus metrics on /metrics")
 	flags.DurationVarP(flagSet, &Opt.JobExpireDuration, "rc-job-expire-duration", "", Opt.JobExpireDuration, "Expire finished async jobs older than this value")
 	flags.DurationVarP(flagSet, &Opt.JobExpireInterval, "rc-job-expire-interval", "", Opt.JobExpireInterval, "Interval to check for expired async jobs")
+	flags.StringVarP(flagSet, &Opt.ScheduleFile, "rc-schedule-file", "", "", "File to save the schedules run by rcd in (default rc/schedules.json in the cache dir)")
+	flags.BoolVarP(flagSet, &Opt.JobHistory, "rc-job-history", "", false, "Keep a persistent history of the finished jobs")
+	flags.DurationVarP(flagSet, &Opt.JobHistoryMaxAge, "rc-job-history-max-age", "", Opt.JobHistoryMaxAge, "Remove jobs older than this from the job history (0 to keep forever)")
+	flags.StringArrayVarP(flagSet, &Opt.NotifyURL, "rc-notify-url", "", nil, "Webhook URL to POST async job events to as JSON (can be repeated)")
+	flags.FVarP(flagSet, &Opt.NotifyCommand, "rc-notify-command", "", "Command to run with async job events as JSON on stdin")
+	flags.FVarP(flagSet, &Opt.NotifyEvents, "rc-notify-events", "", "Comma separated list of job events to notify: start, finish, error")
 	Opt.HTTP.AddFlagsPrefix(flagSet, FlagPrefix)
 	Opt.Auth.AddFlagsPrefix(flagSet, FlagPrefix)
 	Opt.Template.AddFlagsPrefix(flagSet, FlagPrefix)
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -59,11 +60,21 @@ func init() {
 func Start(ctx context.Context, opt *rc.Options) (*Server, error) {
 	jobs.SetOpt(opt) // set the defaults for jobs
 	if opt.Enabled {
+		err := jobs.CheckNotify(opt)
+		if err != nil {
+			return nil, err
+		}
 		// Serve on the DefaultServeMux so can have global registrations appear
 		s, err := newServer(ctx, opt, http.DefaultServeMux)
 		if err != nil {
 			return nil, err
 		}
+		if opt.JobHistory {
+			err = jobs.StartHistory(ctx, opt.JobHistoryMaxAge)
+			if err != nil {
+				return nil, fmt.Errorf("failed to start job history: %w", err)
+			}
+		}
 		return s, s.Serve()
 	}
 	return nil, nil
@@ -293,6 +304,12 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 		return
 	}
 
+	// Notifications make rclone contact other servers so need authorisation too
+	if _, found := in["_notify"]; found && !s.opt.NoAuth && !s.server.UsingAuth() {
+		writeError(path, in, w, errors.New("authentication must be set up on the rc server to use _notify or the --rc-no-auth flag must be in use"), http.StatusForbidden)
+		return
+	}
+
 	inOrig := in.Copy()
 
 	if call.NeedsRequest {
@@ -305,6 +322,7 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 	}
 
 	fs.Debugf(nil, "rc: %q: with parameters %+v", path, in)
+	in["_path"] = path
 	job, out, err := jobs.NewJob(ctx, call.Fn, in)
 	if job != nil {
 		w.Header().Add("x-rclone-jobid", fmt.Sprintf("%d", job.ID))
@@ -737,6 +737,43 @@ func TestNoAuth(t *testing.T) {
 	testServer(t, tests, &opt)
 }
 
+func TestNotifyAuthRequired(t *testing.T) {
+	tests := []testRun{{
+		Name:        "notify",
+		URL:         "rc/noop",
+		Method:      "POST",
+		Body:        `{"_notify":{"url":["http://127.0.0.1:9/"]}}`,
+		ContentType: "application/json",
+		Status:      http.StatusForbidden,
+		Expected: `{
+	"error": "authentication must be set up on the rc server to use _notify or the --rc-no-auth flag must be in use",
+	"input": {
+		"_notify": {
+			"url": [
+				"http://127.0.0.1:9/"
+			]
+		}
+	},
+	"path": "rc/noop",
+	"status": 403
+}
+`,
+	}, {
+		Name:        "notify-command",
+		URL:         "rc/noop",
+		Method:      "POST",
+		Body:        `{"_notify":{"command":["false"]}}`,
+		ContentType: "application/json",
+		Status:      http.StatusForbidden,
+		Contains:    regexp.MustCompile(`use _notify`),
+	}}
+	opt := newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	opt.NoAuth = false
+	testServer(t, tests, &opt)
+}
+
 func TestWithUserPass(t *testing.T) {
 	tests := []testRun{{
 		Name:        "authMissing",
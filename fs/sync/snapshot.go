@@ -0,0 +1,233 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// SnapshotTimeFormat is the format of the snapshot directory names.
+//
+// It sorts in time order and has no characters which are a problem
+// on any of the backends.
+const SnapshotTimeFormat = "2006-01-02T150405Z"
+
+// SnapshotPartialSuffix is added to the name of a snapshot directory
+// while it is being made.
+//
+// It is renamed without the suffix once the sync has completed so the
+// incomplete snapshots aren't listed or pruned.
+const SnapshotPartialSuffix = ".partial"
+
+// Snapshot is a directory made by SnapshotSync
+type Snapshot struct {
+	Name string    // name of the snapshot directory
+	Time time.Time // time the snapshot was taken
+}
+
+// KeepOpt describes which snapshots to keep when pruning.
+//
+// The newest snapshot is always kept.
+type KeepOpt struct {
+	Daily  int // keep the newest snapshot for this many days
+	Weekly int // keep the newest snapshot for this many weeks
+}
+
+// IsZero returns true if no retention is set, so all the snapshots
+// are kept
+func (k KeepOpt) IsZero() bool {
+	return k.Daily <= 0 && k.Weekly <= 0
+}
+
+// ListSnapshots returns the snapshots in f, oldest first.
+//
+// Directories in f whose names aren't in SnapshotTimeFormat are
+// ignored, as are the incomplete snapshots.
+func ListSnapshots(ctx context.Context, f fs.Fs) (snapshots []Snapshot, err error) {
+	snapshots, _, err = listSnapshots(ctx, f)
+	return snapshots, err
+}
+
+// listSnapshots returns the complete and the incomplete snapshots in
+// f, oldest first
+func listSnapshots(ctx context.Context, f fs.Fs) (snapshots, partial []Snapshot, err error) {
+	entries, err := f.List(ctx, "")
+	if errors.Is(err, fs.ErrorDirNotFound) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	entries.ForDir(func(dir fs.Directory) {
+		name := strings.TrimSuffix(dir.Remote(), SnapshotPartialSuffix)
+		t, err := time.Parse(SnapshotTimeFormat, name)
+		if err != nil {
+			fs.Debugf(dir, "Ignoring directory which isn't a snapshot")
+			return
+		}
+		if name != dir.Remote() {
+			partial = append(partial, Snapshot{Name: name, Time: t})
+		} else {
+			snapshots = append(snapshots, Snapshot{Name: name, Time: t})
+		}
+	})
+	for _, list := range [][]Snapshot{snapshots, partial} {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Time.Before(list[j].Time)
+		})
+	}
+	return snapshots, partial, nil
+}
+
+// snapshotFs returns an Fs for the snapshot called name in fdst
+func snapshotFs(ctx context.Context, fdst fs.Fs, name string) (fs.Fs, error) {
+	return cache.Get(ctx, fspath.JoinRootPath(fs.ConfigString(fdst), name))
+}
+
+// SnapshotSync copies fsrc into a new snapshot directory in fdst
+// named after the current time.
+//
+// The snapshot is made in a directory with SnapshotPartialSuffix
+// which is renamed once the sync has succeeded. If the newest
+// incomplete snapshot is newer than all the complete ones, for
+// example when the sync is retried, then it is completed instead of
+// starting a new one.
+//
+// Files which are unchanged since the previous snapshot are server-side
+// copied from it with --copy-dest if fdst supports it, so only the
+// changed files are transferred. The old snapshots are then pruned
+// with keep.
+//
+// It returns the new snapshot.
+func SnapshotSync(ctx context.Context, fdst, fsrc fs.Fs, createEmptySrcDirs bool, keep KeepOpt) (snapshot Snapshot, err error) {
+	ci := fs.GetConfig(ctx)
+	if len(ci.CopyDest) > 0 || len(ci.CompareDest) > 0 {
+		return snapshot, errors.New("can't use --copy-dest or --compare-dest with snapshots as the previous snapshot is used")
+	}
+	snapshots, partial, err := listSnapshots(ctx, fdst)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	var prev *Snapshot
+	if len(snapshots) > 0 {
+		prev = &snapshots[len(snapshots)-1]
+	}
+	if len(partial) > 0 && (prev == nil || prev.Time.Before(partial[len(partial)-1].Time)) {
+		snapshot = partial[len(partial)-1]
+		partial = partial[:len(partial)-1]
+		fs.Infof(fdst, "Continuing incomplete snapshot %q", snapshot.Name)
+	} else {
+		now := time.Now().UTC()
+		snapshot = Snapshot{Name: now.Format(SnapshotTimeFormat), Time: now.Truncate(time.Second)}
+		if prev != nil && !prev.Time.Before(snapshot.Time) {
+			return snapshot, fmt.Errorf("snapshot %q is newer than the current time", prev.Name)
+		}
+	}
+	// Remove the incomplete snapshots which won't be completed
+	for _, old := range partial {
+		fs.Infof(fdst, "Removing incomplete snapshot %q", old.Name)
+		err = operations.Purge(ctx, fdst, old.Name+SnapshotPartialSuffix)
+		if err != nil {
+			return snapshot, fmt.Errorf("failed to remove incomplete snapshot %q: %w", old.Name, err)
+		}
+	}
+	partialName := snapshot.Name + SnapshotPartialSuffix
+	newFs, err := snapshotFs(ctx, fdst, partialName)
+	if err != nil {
+		return snapshot, err
+	}
+	if prev != nil {
+		if fdst.Features().Copy != nil {
+			ctx, ci = fs.AddConfig(ctx)
+			ci.CopyDest = []string{fspath.JoinRootPath(fs.ConfigString(fdst), prev.Name)}
+			fs.Infof(fdst, "Making snapshot %q copying unchanged files from %q", snapshot.Name, prev.Name)
+		} else {
+			fs.Logf(fdst, "Making snapshot %q copying all files as server-side copy isn't supported", snapshot.Name)
+		}
+	} else {
+		fs.Infof(fdst, "Making first snapshot %q", snapshot.Name)
+	}
+	err = Sync(ctx, newFs, fsrc, createEmptySrcDirs)
+	if err != nil {
+		return snapshot, fmt.Errorf("snapshot %q is incomplete: %w", snapshot.Name, err)
+	}
+	err = operations.DirMove(ctx, fdst, partialName, snapshot.Name)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to rename completed snapshot %q: %w", snapshot.Name, err)
+	}
+	if !keep.IsZero() {
+		_, err = PruneSnapshots(ctx, fdst, keep)
+	}
+	return snapshot, err
+}
+
+// SelectSnapshots works out which of snapshots, sorted oldest first,
+// should be kept according to keep and which should be removed.
+//
+// For each of the newest keep.Daily days with a snapshot, the newest
+// snapshot of the day is kept, and likewise for keep.Weekly ISO weeks.
+func SelectSnapshots(snapshots []Snapshot, keep KeepOpt) (kept, removed []Snapshot) {
+	if keep.IsZero() || len(snapshots) == 0 {
+		return snapshots, nil
+	}
+	keepIt := make([]bool, len(snapshots))
+	keepIt[len(snapshots)-1] = true
+	period := func(n int, key func(t time.Time) string) {
+		last := ""
+		for i := len(snapshots) - 1; i >= 0 && n > 0; i-- {
+			k := key(snapshots[i].Time.UTC())
+			if k == last {
+				continue
+			}
+			last = k
+			keepIt[i] = true
+			n--
+		}
+	}
+	period(keep.Daily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	period(keep.Weekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%02d", year, week)
+	})
+	for i, snapshot := range snapshots {
+		if keepIt[i] {
+			kept = append(kept, snapshot)
+		} else {
+			removed = append(removed, snapshot)
+		}
+	}
+	return kept, removed
+}
+
+// PruneSnapshots removes the snapshots in f which aren't selected by
+// keep, returning the ones removed.
+//
+// If keep is zero nothing is removed.
+func PruneSnapshots(ctx context.Context, f fs.Fs, keep KeepOpt) (removed []Snapshot, err error) {
+	if keep.IsZero() {
+		return nil, nil
+	}
+	snapshots, err := ListSnapshots(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	_, toRemove := SelectSnapshots(snapshots, keep)
+	for _, snapshot := range toRemove {
+		fs.Infof(f, "Removing snapshot %q", snapshot.Name)
+		err = operations.Purge(ctx, f, snapshot.Name)
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove snapshot %q: %w", snapshot.Name, err)
+		}
+		removed = append(removed, snapshot)
+	}
+	return removed, nil
+}
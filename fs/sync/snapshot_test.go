@@ -0,0 +1,142 @@
+// Test snapshots
+
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectSnapshots(t *testing.T) {
+	var snapshots []Snapshot
+	for _, name := range []string{
+		"2023-05-01T100000Z", // Mon week 18
+		"2023-05-07T100000Z", // Sun week 18
+		"2023-05-08T100000Z", // Mon week 19
+		"2023-05-10T090000Z", // Wed week 19
+		"2023-05-10T100000Z", // Wed week 19
+		"2023-05-11T100000Z", // Thu week 19
+		"2023-05-11T110000Z", // Thu week 19
+	} {
+		tm, err := time.Parse(SnapshotTimeFormat, name)
+		require.NoError(t, err)
+		snapshots = append(snapshots, Snapshot{Name: name, Time: tm})
+	}
+	names := func(snapshots []Snapshot) (names []string) {
+		for _, snapshot := range snapshots {
+			names = append(names, snapshot.Name)
+		}
+		return names
+	}
+	for _, test := range []struct {
+		keep KeepOpt
+		want []string
+	}{
+		{KeepOpt{}, names(snapshots)},
+		{KeepOpt{Daily: 1}, []string{"2023-05-11T110000Z"}},
+		{KeepOpt{Daily: 2}, []string{"2023-05-10T100000Z", "2023-05-11T110000Z"}},
+		{KeepOpt{Weekly: 2}, []string{"2023-05-07T100000Z", "2023-05-11T110000Z"}},
+		{KeepOpt{Daily: 2, Weekly: 2}, []string{"2023-05-07T100000Z", "2023-05-10T100000Z", "2023-05-11T110000Z"}},
+		{KeepOpt{Daily: 100}, []string{"2023-05-01T100000Z", "2023-05-07T100000Z", "2023-05-08T100000Z", "2023-05-10T100000Z", "2023-05-11T110000Z"}},
+	} {
+		kept, removed := SelectSnapshots(snapshots, test.keep)
+		assert.Equal(t, test.want, names(kept), test.keep)
+		assert.Equal(t, len(snapshots), len(kept)+len(removed), test.keep)
+	}
+	kept, removed := SelectSnapshots(nil, KeepOpt{Daily: 1})
+	assert.Empty(t, kept)
+	assert.Empty(t, removed)
+}
+
+func TestSnapshotSync(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("file1", "unchanged", t1)
+	file2 := r.WriteFile("sub dir/file2", "changed", t2)
+	old := "2001-02-03T040506Z"
+	r.WriteObject(ctx, old+"/file1", "unchanged", t1)
+	r.WriteObject(ctx, old+"/sub dir/file2", "old", t1)
+	r.WriteObject(ctx, "not a snapshot/file3", "ignored", t1)
+
+	snapshot, err := SnapshotSync(ctx, r.Fremote, r.Flocal, false, KeepOpt{})
+	require.NoError(t, err)
+
+	snapshots, err := ListSnapshots(ctx, r.Fremote)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, old, snapshots[0].Name)
+	assert.Equal(t, snapshot, snapshots[1])
+
+	newFs, err := snapshotFs(ctx, r.Fremote, snapshot.Name)
+	require.NoError(t, err)
+	fstest.CheckItems(t, newFs, file1, file2)
+
+	// Prune leaves the newest snapshot and the other directory
+	removed, err := PruneSnapshots(ctx, r.Fremote, KeepOpt{Daily: 1})
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	assert.Equal(t, old, removed[0].Name)
+	snapshots, err = ListSnapshots(ctx, r.Fremote)
+	require.NoError(t, err)
+	assert.Equal(t, []Snapshot{snapshot}, snapshots)
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{
+		fstest.NewItem("not a snapshot/file3", "ignored", t1),
+		fstest.NewItem(snapshot.Name+"/file1", "unchanged", t1),
+		fstest.NewItem(snapshot.Name+"/sub dir/file2", "changed", t2),
+	}, nil, fs.GetModifyWindow(ctx, r.Fremote))
+}
+
+func TestSnapshotSyncIncomplete(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	file1 := r.WriteFile("file1", "unchanged", t1)
+	file2 := r.WriteFile("sub dir/file2", "changed", t2)
+	old := "2001-02-03T040506Z"
+	stale := "2001-02-02T040506Z"
+	partial := "2001-02-04T040506Z"
+	r.WriteObject(ctx, old+"/file1", "unchanged", t1)
+	r.WriteObject(ctx, stale+SnapshotPartialSuffix+"/file1", "stale", t1)
+	r.WriteObject(ctx, partial+SnapshotPartialSuffix+"/file1", "unchanged", t1)
+	r.WriteObject(ctx, partial+SnapshotPartialSuffix+"/deleted", "deleted", t1)
+
+	// Incomplete snapshots aren't listed or pruned
+	snapshots, err := ListSnapshots(ctx, r.Fremote)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, old, snapshots[0].Name)
+	removed, err := PruneSnapshots(ctx, r.Fremote, KeepOpt{Daily: 1})
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+
+	// The newest incomplete snapshot is completed and the stale one removed
+	snapshot, err := SnapshotSync(ctx, r.Fremote, r.Flocal, false, KeepOpt{})
+	require.NoError(t, err)
+	assert.Equal(t, partial, snapshot.Name)
+	snapshots, err = ListSnapshots(ctx, r.Fremote)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, snapshot, snapshots[1])
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{
+		fstest.NewItem(old+"/file1", "unchanged", t1),
+		fstest.NewItem(partial+"/file1", "unchanged", t1),
+		fstest.NewItem(partial+"/sub dir/file2", "changed", t2),
+	}, nil, fs.GetModifyWindow(ctx, r.Fremote))
+	newFs, err := snapshotFs(ctx, r.Fremote, snapshot.Name)
+	require.NoError(t, err)
+	fstest.CheckItems(t, newFs, file1, file2)
+}
+
+func TestSnapshotSyncCopyDest(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	ctx, ci := fs.AddConfig(ctx)
+	ci.CopyDest = []string{r.FremoteName + "/other"}
+	_, err := SnapshotSync(ctx, r.Fremote, r.Flocal, false, KeepOpt{})
+	assert.ErrorContains(t, err, "--copy-dest")
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/march"
 	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/lib/pacer"
 )
 
 type syncCopyMove struct {
@@ -56,6 +57,9 @@ type syncCopyMove struct {
 	checkerWg              sync.WaitGroup         // wait for checkers
 	toBeChecked            *pipe                  // checkers channel
 	transfersWg            sync.WaitGroup         // wait for transfers
+	adaptive               *pacer.Adaptive        // controls the number of transfers if set
+	adaptiveWg             sync.WaitGroup         // wait for the adaptive controller
+	adaptiveDone           chan struct{}          // close to stop the adaptive controller
 	toBeUploaded           *pipe                  // copiers channel
 	errorMu                sync.Mutex             // Mutex covering the errors variables
 	err                    error                  // normal error from copy process
@@ -426,6 +430,9 @@ func (s *syncCopyMove) pairCopyOrMove(ctx context.Context, in *pipe, fdst fs.Fs,
 		}
 		src := pair.Src
 		dst := pair.Dst
+		if s.adaptive != nil {
+			s.adaptive.Get()
+		}
 		if s.DoMove {
 			if src != dst {
 				_, err = operations.Move(ctx, fdst, dst, src.Remote(), src)
@@ -436,6 +443,9 @@ func (s *syncCopyMove) pairCopyOrMove(ctx context.Context, in *pipe, fdst fs.Fs,
 		} else {
 			_, err = operations.Copy(ctx, fdst, dst, src.Remote(), src)
 		}
+		if s.adaptive != nil {
+			s.adaptive.Put()
+		}
 		s.processError(err)
 	}
 }
@@ -458,9 +468,16 @@ func (s *syncCopyMove) stopCheckers() {
 
 // This starts the background transfers
 func (s *syncCopyMove) startTransfers() {
-	s.transfersWg.Add(s.ci.Transfers)
-	for i := 0; i < s.ci.Transfers; i++ {
-		fraction := (100 * i) / s.ci.Transfers
+	transfers := s.ci.Transfers
+	if s.ci.AdaptiveTransfers {
+		s.startAdaptive()
+		// Start the maximum number of transfers and let the
+		// controller limit how many are running
+		transfers = s.adaptive.Stats().Max
+	}
+	s.transfersWg.Add(transfers)
+	for i := 0; i < transfers; i++ {
+		fraction := (100 * i) / transfers
 		go s.pairCopyOrMove(s.ctx, s.toBeUploaded, s.fdst, fraction, &s.transfersWg)
 	}
 }
@@ -470,6 +487,71 @@ func (s *syncCopyMove) stopTransfers() {
 	s.toBeUploaded.Close()
 	fs.Debugf(s.fdst, "Waiting for transfers to finish")
 	s.transfersWg.Wait()
+	s.stopAdaptive()
+}
+
+// adaptiveInterval is how often the adaptive controller looks at the
+// throughput
+const adaptiveInterval = 5 * time.Second
+
+// startAdaptive starts the controller which varies the number of
+// transfers with --adaptive-transfers
+func (s *syncCopyMove) startAdaptive() {
+	s.adaptive = pacer.NewAdaptive(s.ci.Transfers, s.ci.AdaptiveTransfersMin, s.ci.AdaptiveTransfersMax, adaptiveInterval)
+	s.adaptive.Register(remoteNames(s.fsrc, s.fdst)...)
+	stats := accounting.Stats(s.ctx)
+	stats.SetAdaptive(s.adaptive)
+	s.adaptiveDone = make(chan struct{})
+	s.adaptiveWg.Add(1)
+	go func() {
+		defer s.adaptiveWg.Done()
+		ticker := time.NewTicker(adaptiveInterval)
+		defer ticker.Stop()
+		lastBytes := stats.GetBytes()
+		lastTime := time.Now()
+		limit := s.adaptive.Limit()
+		for {
+			select {
+			case <-s.adaptiveDone:
+				return
+			case now := <-ticker.C:
+				bytes := stats.GetBytes()
+				speed := float64(bytes-lastBytes) / now.Sub(lastTime).Seconds()
+				lastBytes, lastTime = bytes, now
+				s.adaptive.Update(speed)
+				if newLimit := s.adaptive.Limit(); newLimit != limit {
+					fs.Infof(s.fdst, "Adaptive transfers: changed from %d to %d at %v/s", limit, newLimit, fs.SizeSuffix(int64(speed)))
+					limit = newLimit
+				}
+			}
+		}
+	}()
+}
+
+// remoteNames returns the names of the remotes the Fses use, including
+// the ones they wrap, for the Adaptive controller to listen to.
+func remoteNames(fses ...fs.Fs) (names []string) {
+	for _, f := range fses {
+		for f != nil {
+			names = append(names, f.Name())
+			unWrap := f.Features().UnWrap
+			if unWrap == nil {
+				break
+			}
+			f = unWrap()
+		}
+	}
+	return names
+}
+
+// stopAdaptive stops the controller started by startAdaptive if any
+func (s *syncCopyMove) stopAdaptive() {
+	if s.adaptive == nil {
+		return
+	}
+	close(s.adaptiveDone)
+	s.adaptiveWg.Wait()
+	s.adaptive.Unregister()
 }
 
 // This starts the background renamers.
@@ -19,6 +19,7 @@ import (
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/pacer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/text/unicode/norm"
@@ -80,6 +81,60 @@ func TestCopyMissingDirectory(t *testing.T) {
 	require.Error(t, err)
 }
 
+// Now with --adaptive-transfers
+func TestCopyAdaptiveTransfers(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+
+	ci.AdaptiveTransfers = true
+	ci.AdaptiveTransfersMin = 1
+	ci.AdaptiveTransfersMax = 3
+
+	file1 := r.WriteFile("sub dir/hello world", "hello world", t1)
+	file2 := r.WriteFile("potato", "hello potato", t2)
+	file3 := r.WriteFile("sub dir/potato2", "hello potato2", t1)
+
+	accounting.GlobalStats().ResetCounters()
+	err := CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	r.CheckLocalItems(t, file1, file2, file3)
+	r.CheckRemoteItems(t, file1, file2, file3)
+
+	out, err := accounting.GlobalStats().RemoteStats()
+	require.NoError(t, err)
+	stats, ok := out["adaptiveTransfers"].(pacer.AdaptiveStats)
+	require.True(t, ok)
+	assert.Equal(t, 3, stats.Max)
+	assert.Equal(t, 0, stats.Active)
+}
+
+// Now with --adaptive-transfers on an up to date tree with more files
+// than the transfers allowed
+func TestSyncAdaptiveTransfersUpToDate(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+
+	ci.AdaptiveTransfers = true
+	ci.AdaptiveTransfersMin = 1
+	ci.AdaptiveTransfersMax = 2
+
+	var items []fstest.Item
+	for i := 0; i < 8; i++ {
+		items = append(items, r.WriteBoth(ctx, fmt.Sprintf("file%d", i), "hello", t1))
+	}
+	file := r.WriteFile("new", "hello new", t2)
+
+	accounting.GlobalStats().ResetCounters()
+	err := Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	r.CheckRemoteItems(t, append(items, file)...)
+	assert.Equal(t, int64(1), accounting.GlobalStats().GetTransfers())
+}
+
 // Now with --no-traverse
 func TestCopyNoTraverse(t *testing.T) {
 	ctx := context.Background()
@@ -896,6 +951,33 @@ func TestSyncWithExclude(t *testing.T) {
 	r.CheckLocalItems(t, file2, file1, file3)
 }
 
+// Test with --ignore-file where the ignore files in the source and
+// destination differ
+func TestSyncWithIgnoreFile(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	srcIgnore := r.WriteFile(".gitignore", "*.log\n", t2)
+	r.WriteFile("a.log", "log", t1)
+	file1 := r.WriteFile("b.txt", "text", t1)
+	file2 := r.WriteFile("sub/c.txt", "text", t1)
+	r.WriteObject(ctx, ".gitignore", "*.txt\nsub/\n", t1)
+	file3 := r.WriteObject(ctx, "d.log", "log", t1)
+	r.WriteObject(ctx, "e.txt", "text", t1)
+
+	opt := filter.DefaultOpt
+	opt.IgnoreFile = []string{".gitignore"}
+	fi, err := filter.NewFilter(&opt)
+	require.NoError(t, err)
+	ctx = filter.ReplaceConfig(ctx, fi)
+
+	// The rules are only read from the source so b.txt and sub/c.txt
+	// are copied, d.log is kept and e.txt is deleted
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	r.CheckRemoteItems(t, srcIgnore, file1, file2, file3)
+}
+
 // Test with exclude and delete excluded
 func TestSyncWithExcludeAndDeleteExcluded(t *testing.T) {
 	ctx := context.Background()
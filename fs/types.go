@@ -281,3 +281,38 @@ type WriterAtCloser interface {
 	io.WriterAt
 	io.Closer
 }
+
+// ChunkWriterInfo describes how the chunks passed to a ChunkWriter
+// should be made
+type ChunkWriterInfo struct {
+	ChunkSize         int64 // size of each chunk, except the last which may be smaller
+	Concurrency       int   // number of chunks the backend would like to upload at once, used unless --multi-thread-streams is set
+	LeavePartsOnError bool  // if set don't call Abort on an error
+}
+
+// ChunkWriter uploads an object as a series of chunks
+//
+// WriteChunk may be called concurrently. When all the chunks have
+// been written Close must be called, or Abort if the upload failed.
+type ChunkWriter interface {
+	// WriteChunk uploads chunk number chunkNumber (starting from 0)
+	// read from reader. The reader may be seeked back to the
+	// start to retry.
+	WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (bytesWritten int64, err error)
+
+	// Close completes the upload
+	Close(ctx context.Context) error
+
+	// Abort cancels the upload and removes any chunks written
+	Abort(ctx context.Context) error
+}
+
+// ChunkWriterResumer is an optional interface for ChunkWriter
+//
+// It allows an upload to be carried on after the process restarts
+// by passing the state to Features.ResumeChunkWriter.
+type ChunkWriterResumer interface {
+	// ResumeState returns an opaque description of the upload so
+	// far. It may be called concurrently with WriteChunk.
+	ResumeState() (state []byte, err error)
+}
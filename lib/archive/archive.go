@@ -0,0 +1,102 @@
+// Package archive provides the archive formats, and helpers for
+// reading and writing them, shared by the archive backend and the
+// archive command.
+package archive
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Format is the type of an archive
+type Format int
+
+// Archive formats
+const (
+	FormatNone Format = iota
+	FormatZip
+	FormatTar
+	FormatTarGz
+	FormatTarBz2
+)
+
+// formats describes the archive formats and how to recognise them
+var formats = []struct {
+	name     string
+	format   Format
+	suffixes []string
+}{
+	{"zip", FormatZip, []string{".zip"}},
+	{"tar", FormatTar, []string{".tar"}},
+	{"tar.gz", FormatTarGz, []string{".tar.gz", ".tgz"}},
+	{"tar.bz2", FormatTarBz2, []string{".tar.bz2", ".tbz2"}},
+}
+
+// String returns the name of the format
+func (fm Format) String() string {
+	for _, f := range formats {
+		if f.format == fm {
+			return f.name
+		}
+	}
+	return fmt.Sprintf("format(%d)", int(fm))
+}
+
+// FormatOf returns the archive format implied by the suffix of the
+// file name given or FormatNone if it isn't an archive.
+func FormatOf(fileName string) Format {
+	lower := strings.ToLower(fileName)
+	for _, f := range formats {
+		for _, suffix := range f.suffixes {
+			if strings.HasSuffix(lower, suffix) && len(lower) > len(suffix) {
+				return f.format
+			}
+		}
+	}
+	return FormatNone
+}
+
+// ParseFormat returns the format called name or if name is empty the
+// format implied by the suffix of fileName.
+func ParseFormat(name, fileName string) (Format, error) {
+	if name == "" {
+		if fm := FormatOf(fileName); fm != FormatNone {
+			return fm, nil
+		}
+		return FormatNone, fmt.Errorf("can't work out archive format from %q - use --format", fileName)
+	}
+	for _, f := range formats {
+		if f.name == name {
+			return f.format, nil
+		}
+	}
+	return FormatNone, fmt.Errorf("unknown archive format %q", name)
+}
+
+// Decompress returns a reader for the tar stream in the archive in
+//
+// For formats which aren't compressed in is returned.
+func (fm Format) Decompress(in io.Reader) (io.Reader, error) {
+	switch fm {
+	case FormatTarGz:
+		return gzip.NewReader(in)
+	case FormatTarBz2:
+		return bzip2.NewReader(in), nil
+	}
+	return in, nil
+}
+
+// CleanName makes the path of an entry in an archive relative with no
+// trailing /, returning false if it should be ignored.
+//
+// Leading / and .. are removed so entries can't escape the archive or
+// be written outside the destination.
+func CleanName(name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = path.Clean("/" + name)[1:]
+	return name, name != ""
+}
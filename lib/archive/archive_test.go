@@ -0,0 +1,113 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatOf(t *testing.T) {
+	for _, test := range []struct {
+		fileName string
+		want     Format
+	}{
+		{"file.zip", FormatZip},
+		{"file.ZIP", FormatZip},
+		{"file.tar", FormatTar},
+		{"file.tar.gz", FormatTarGz},
+		{"file.tgz", FormatTarGz},
+		{"file.tar.bz2", FormatTarBz2},
+		{"file.tbz2", FormatTarBz2},
+		{"file.txt", FormatNone},
+		{".zip", FormatNone},
+	} {
+		assert.Equal(t, test.want, FormatOf(test.fileName), test.fileName)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		fileName string
+		want     Format
+		wantErr  bool
+	}{
+		{"", "file.zip", FormatZip, false},
+		{"", "file.tar.gz", FormatTarGz, false},
+		{"", "file.txt", FormatNone, true},
+		{"zip", "file.txt", FormatZip, false},
+		{"tar.gz", "file.zip", FormatTarGz, false},
+		{"rar", "file.zip", FormatNone, true},
+	} {
+		got, err := ParseFormat(test.name, test.fileName)
+		if test.wantErr {
+			assert.Error(t, err, test.fileName)
+		} else {
+			assert.NoError(t, err, test.fileName)
+		}
+		assert.Equal(t, test.want, got, test.fileName)
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"file.txt", "file.txt", true},
+		{"dir/", "dir", true},
+		{"/abs/file.txt", "abs/file.txt", true},
+		{"../../evil.txt", "evil.txt", true},
+		{`dir\file.txt`, "dir/file.txt", true},
+		{"./", "", false},
+	} {
+		got, ok := CleanName(test.in)
+		assert.Equal(t, test.want, got, test.in)
+		assert.Equal(t, test.ok, ok, test.in)
+	}
+}
+
+// writeZip makes a zip with the headers given and returns the files in it
+func writeZip(t *testing.T, headers ...*zip.FileHeader) []*zip.File {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, fh := range headers {
+		_, err := zw.CreateHeader(fh)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return zr.File
+}
+
+func TestZipMetadata(t *testing.T) {
+	modTime := time.Date(2017, 2, 3, 4, 5, 6, 123456789, time.UTC)
+	meta := fs.Metadata{"mode": "100640", "uid": "1000"}
+
+	// Metadata stored by rclone is read back with the full
+	// modification time
+	fh := &zip.FileHeader{Name: "file.txt", Modified: modTime}
+	SetZipMetadata(fh, meta)
+	other := &zip.FileHeader{Name: "other.txt", Modified: modTime}
+	other.SetMode(0600)
+	files := writeZip(t, fh, other)
+
+	got := GetZipMetadata(files[0])
+	assert.Equal(t, "100640", got["mode"])
+	assert.Equal(t, "1000", got["uid"])
+	assert.Equal(t, modTime, ModTime(got, time.Time{}))
+	assert.Equal(t, "-rw-r-----", files[0].Mode().String())
+
+	// Otherwise it is made from the standard fields
+	got = GetZipMetadata(files[1])
+	assert.Equal(t, "100600", got["mode"])
+	assert.Equal(t, modTime.Truncate(time.Second), ModTime(got, time.Time{}).UTC())
+	assert.Equal(t, modTime, ModTime(fs.Metadata{}, modTime))
+}
@@ -0,0 +1,152 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// metaPrefix is the prefix of the PAX records used to store rclone
+// metadata in tar archives
+const metaPrefix = "RCLONE."
+
+// zipMetaID is the ID of the zip extra field used to store rclone
+// metadata as JSON. Tools which don't know it ignore it.
+const zipMetaID = 0x6372
+
+// zipMaxExtra is the maximum size of the data in a zip extra field
+const zipMaxExtra = 0xFFFF
+
+// SetTarMetadata sets the header fields and PAX records for the
+// metadata
+func SetTarMetadata(hdr *tar.Header, meta fs.Metadata) {
+	if len(meta) == 0 {
+		return
+	}
+	if hdr.PAXRecords == nil {
+		hdr.PAXRecords = make(map[string]string, len(meta))
+	}
+	for k, v := range meta {
+		hdr.PAXRecords[metaPrefix+k] = v
+	}
+	// Set the standard fields too so other tools can read them
+	if mode, err := strconv.ParseUint(meta["mode"], 8, 32); err == nil {
+		hdr.Mode = int64(mode & 07777)
+	}
+	if uid, err := strconv.Atoi(meta["uid"]); err == nil {
+		hdr.Uid = uid
+	}
+	if gid, err := strconv.Atoi(meta["gid"]); err == nil {
+		hdr.Gid = gid
+	}
+	if atime, err := time.Parse(time.RFC3339Nano, meta["atime"]); err == nil {
+		hdr.AccessTime = atime
+	}
+}
+
+// GetTarMetadata reads the metadata stored by SetTarMetadata from hdr.
+//
+// If the archive wasn't made by rclone then the metadata is made from
+// the standard fields.
+func GetTarMetadata(hdr *tar.Header) fs.Metadata {
+	meta := make(fs.Metadata)
+	for k, v := range hdr.PAXRecords {
+		if strings.HasPrefix(k, metaPrefix) {
+			meta[k[len(metaPrefix):]] = v
+		}
+	}
+	if len(meta) > 0 {
+		return meta
+	}
+	typeBits := int64(0100000) // S_IFREG
+	if hdr.Typeflag == tar.TypeDir {
+		typeBits = 0040000 // S_IFDIR
+	}
+	meta["mode"] = strconv.FormatInt(typeBits|hdr.Mode&07777, 8)
+	meta["uid"] = strconv.Itoa(hdr.Uid)
+	meta["gid"] = strconv.Itoa(hdr.Gid)
+	meta["mtime"] = hdr.ModTime.Format(time.RFC3339Nano)
+	if !hdr.AccessTime.IsZero() {
+		meta["atime"] = hdr.AccessTime.Format(time.RFC3339Nano)
+	}
+	return meta
+}
+
+// SetZipMetadata stores the metadata in an extra field of fh.
+//
+// The modification time is always stored as zip only stores it to the
+// second otherwise. The permissions are set in the standard field too
+// so other tools can read them.
+func SetZipMetadata(fh *zip.FileHeader, meta fs.Metadata) {
+	stored := make(fs.Metadata, len(meta)+1)
+	for k, v := range meta {
+		stored[k] = v
+	}
+	if _, ok := stored["mtime"]; !ok && !fh.Modified.IsZero() {
+		stored["mtime"] = fh.Modified.Format(time.RFC3339Nano)
+	}
+	if mode, err := strconv.ParseUint(meta["mode"], 8, 32); err == nil {
+		fh.SetMode(os.FileMode(mode & 0777))
+	}
+	data, err := json.Marshal(stored)
+	// leave room for the header and the fields archive/zip adds
+	if err != nil || len(data) > zipMaxExtra-len(fh.Extra)-64 {
+		fs.Errorf(fh.Name, "Metadata too large to store in zip archive")
+		return
+	}
+	extra := make([]byte, 4, 4+len(data))
+	binary.LittleEndian.PutUint16(extra[0:], zipMetaID)
+	binary.LittleEndian.PutUint16(extra[2:], uint16(len(data)))
+	fh.Extra = append(fh.Extra, append(extra, data...)...)
+}
+
+// GetZipMetadata reads the metadata stored by SetZipMetadata from
+// file.
+//
+// If the archive wasn't made by rclone then the metadata is made from
+// the standard fields.
+func GetZipMetadata(file *zip.File) fs.Metadata {
+	for extra := file.Extra; len(extra) >= 4; {
+		id := binary.LittleEndian.Uint16(extra[0:])
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if size > len(extra)-4 {
+			break
+		}
+		if id == zipMetaID {
+			var meta fs.Metadata
+			if err := json.Unmarshal(extra[4:4+size], &meta); err == nil && len(meta) > 0 {
+				return meta
+			}
+			break
+		}
+		extra = extra[4+size:]
+	}
+	meta := make(fs.Metadata)
+	if file.CreatorVersion>>8 == 3 { // made on Unix so has a mode
+		typeBits := uint32(0100000) // S_IFREG
+		if file.Mode().IsDir() {
+			typeBits = 0040000 // S_IFDIR
+		}
+		meta["mode"] = strconv.FormatUint(uint64(typeBits|uint32(file.Mode().Perm())), 8)
+	}
+	if !file.Modified.IsZero() {
+		meta["mtime"] = file.Modified.Format(time.RFC3339Nano)
+	}
+	return meta
+}
+
+// ModTime returns the modification time stored in meta or def if it
+// isn't set.
+func ModTime(meta fs.Metadata, def time.Time) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, meta["mtime"]); err == nil {
+		return t
+	}
+	return def
+}
@@ -0,0 +1,67 @@
+package archive
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/chunkedreader"
+)
+
+// Sizes of the range requests used to read archives
+const (
+	InitialChunkSize = 1024 * 1024 // first range request size
+	MaxChunkSize     = -1          // range requests double in size without limit
+)
+
+// ReaderAt reads an object at random offsets with range requests for
+// archive/zip.
+//
+// Reads which follow on from the last one use the same stream so
+// reading the files in a zip in order doesn't need a new request for
+// each read.
+//
+// It must be used within the Use method which closes any open stream
+// afterwards.
+type ReaderAt struct {
+	mu  sync.Mutex
+	ctx context.Context
+	o   fs.Object
+	cr  *chunkedreader.ChunkedReader
+	pos int64 // offset the next read from cr will be at
+}
+
+// NewReaderAt makes a ReaderAt for o
+func NewReaderAt(o fs.Object) *ReaderAt {
+	return &ReaderAt{o: o}
+}
+
+// Use calls fn with the ReaderAt ready for use
+//
+// Only one Use may be in progress at once.
+func (r *ReaderAt) Use(ctx context.Context, fn func() error) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ctx = ctx
+	r.cr = chunkedreader.New(ctx, r.o, InitialChunkSize, MaxChunkSize)
+	r.pos = -1
+	defer fs.CheckClose(r.cr, &err)
+	return fn()
+}
+
+// ReadAt reads len(p) bytes at offset off
+func (r *ReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off != r.pos {
+		_, err = r.cr.RangeSeek(r.ctx, off, io.SeekStart, -1)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err = io.ReadFull(r.cr, p)
+	r.pos = off + int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -2,17 +2,21 @@ package serve
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"html/template"
+	"mime"
 	"net/http"
 	"net/url"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/lib/rest"
 )
 
@@ -37,6 +41,11 @@ type Directory struct {
 	Breadcrumb   []Crumb
 	Sort         string
 	Order        string
+	Search       string // search pattern if the entries are search results
+	CanUpload    bool   // whether files may be uploaded to the directory
+	CanMkdir     bool   // whether directories may be made in the directory
+	CanDelete    bool   // whether entries may be deleted
+	CanRename    bool   // whether entries may be renamed
 }
 
 // Crumb is a breadcrumb entry
@@ -90,6 +99,19 @@ func (d *Directory) AddHTMLEntry(remote string, isDir bool, size int64, modTime
 	if leaf == "." {
 		leaf = ""
 	}
+	d.addHTMLEntry(remote, leaf, isDir, size, modTime)
+}
+
+// AddSearchEntry adds an entry found by searching below the directory.
+//
+// The entry is shown and linked with its path relative to the
+// directory rather than its leaf.
+func (d *Directory) AddSearchEntry(remote string, isDir bool, size int64, modTime time.Time) {
+	d.addHTMLEntry(remote, d.relativePath(remote), isDir, size, modTime)
+}
+
+// addHTMLEntry adds an entry shown as leaf to that directory
+func (d *Directory) addHTMLEntry(remote string, leaf string, isDir bool, size int64, modTime time.Time) {
 	urlRemote := leaf
 	if isDir {
 		leaf += "/"
@@ -123,6 +145,14 @@ func (d *Directory) AddEntry(remote string, isDir bool) {
 	})
 }
 
+// relativePath returns the path of remote relative to the directory
+func (d *Directory) relativePath(remote string) string {
+	if d.DirRemote == "" {
+		return remote
+	}
+	return strings.TrimPrefix(remote, strings.TrimSuffix(d.DirRemote, "/")+"/")
+}
+
 // Error logs the error and if a ResponseWriter is given it writes an http.StatusInternalServerError
 func Error(what interface{}, w http.ResponseWriter, text string, err error) {
 	err = fs.CountError(err)
@@ -162,6 +192,36 @@ func (d *Directory) ProcessQueryParams(sortParm string, orderParm string) *Direc
 
 }
 
+// ProcessPageParams limits the entries to a page based on the request
+// offset/limit parameters. The default is all the entries.
+//
+// This should be called after ProcessQueryParams so the pages are in
+// a consistent order.
+func (d *Directory) ProcessPageParams(offsetParm string, limitParm string) error {
+	offset, limit := 0, -1
+	var err error
+	if offsetParm != "" {
+		offset, err = strconv.Atoi(offsetParm)
+		if err != nil || offset < 0 {
+			return fmt.Errorf("invalid offset %q", offsetParm)
+		}
+	}
+	if limitParm != "" {
+		limit, err = strconv.Atoi(limitParm)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid limit %q", limitParm)
+		}
+	}
+	if offset > len(d.Entries) {
+		offset = len(d.Entries)
+	}
+	d.Entries = d.Entries[offset:]
+	if limit >= 0 && limit < len(d.Entries) {
+		d.Entries = d.Entries[:limit]
+	}
+	return nil
+}
+
 type byName Directory
 type byNameDirFirst Directory
 type bySize Directory
@@ -221,6 +281,73 @@ const (
 	sortByTime         = "time"
 )
 
+// timeFormat is the format of the ModTime in JSON listings which is
+// the same as used by lsjson for remotes with nanosecond precision
+const timeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// wantJSON returns true if the request asked for a JSON listing with
+// ?format=json or by preferring application/json in the Accept header
+func wantJSON(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "json":
+		return true
+	case "html":
+		return false
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(accept)
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return true
+		case "text/html":
+			return false
+		}
+	}
+	return false
+}
+
+// JSONEntries returns the entries in the format used by lsjson.
+//
+// The Path is relative to the directory.
+func (d *Directory) JSONEntries() []operations.ListJSONItem {
+	items := make([]operations.ListJSONItem, 0, len(d.Entries))
+	for _, entry := range d.Entries {
+		item := operations.ListJSONItem{
+			Path:    d.relativePath(entry.remote),
+			Name:    path.Base(entry.remote),
+			Size:    entry.Size,
+			ModTime: operations.Timestamp{When: entry.ModTime, Format: timeFormat},
+			IsDir:   entry.IsDir,
+		}
+		if entry.IsDir {
+			item.MimeType = "inode/directory"
+			item.Size = -1
+		} else {
+			item.MimeType = fs.MimeTypeFromName(entry.remote)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// serveJSON serves the entries in the format used by lsjson
+func (d *Directory) serveJSON(w http.ResponseWriter) {
+	buf := &bytes.Buffer{}
+	err := json.NewEncoder(buf).Encode(d.JSONEntries())
+	if err != nil {
+		Error(d.DirRemote, w, "Failed to encode JSON", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = buf.WriteTo(w)
+	if err != nil {
+		Error(d.DirRemote, nil, "Failed to drain JSON buffer", err)
+	}
+}
+
 // Serve serves a directory
 func (d *Directory) Serve(w http.ResponseWriter, r *http.Request) {
 	// Account the transfer
@@ -229,6 +356,13 @@ func (d *Directory) Serve(w http.ResponseWriter, r *http.Request) {
 
 	fs.Infof(d.DirRemote, "%s: Serving directory", r.RemoteAddr)
 
+	// The listing depends on the Accept header
+	w.Header().Add("Vary", "Accept")
+	if wantJSON(r) {
+		d.serveJSON(w)
+		return
+	}
+
 	buf := &bytes.Buffer{}
 	err := d.HTMLTemplate.Execute(buf, d)
 	if err != nil {
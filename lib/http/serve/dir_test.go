@@ -123,3 +123,90 @@ func TestServe(t *testing.T) {
 </html>
 `, string(body))
 }
+
+func TestAddSearchEntry(t *testing.T) {
+	var modtime = time.Now()
+	var d = NewDirectory("z", GetTemplate(t))
+	d.AddSearchEntry("z/a/b.txt", false, 64, modtime)
+	d.AddSearchEntry("z/a/c", true, 0, modtime)
+	assert.Equal(t, []DirEntry{
+		{remote: "z/a/b.txt", URL: "a/b.txt", Leaf: "a/b.txt", IsDir: false, Size: 64, ModTime: modtime},
+		{remote: "z/a/c", URL: "a/c/", Leaf: "a/c/", IsDir: true, Size: 0, ModTime: modtime},
+	}, d.Entries)
+}
+
+func TestProcessPageParams(t *testing.T) {
+	for _, test := range []struct {
+		offset  string
+		limit   string
+		want    []string
+		wantErr bool
+	}{
+		{"", "", []string{"a", "b", "c", "d"}, false},
+		{"1", "", []string{"b", "c", "d"}, false},
+		{"", "2", []string{"a", "b"}, false},
+		{"1", "2", []string{"b", "c"}, false},
+		{"3", "2", []string{"d"}, false},
+		{"10", "", []string{}, false},
+		{"", "0", []string{}, false},
+		{"-1", "", nil, true},
+		{"", "potato", nil, true},
+	} {
+		d := NewDirectory("", GetTemplate(t))
+		for _, name := range []string{"a", "b", "c", "d"} {
+			d.AddEntry(name, false)
+		}
+		err := d.ProcessPageParams(test.offset, test.limit)
+		what := test.offset + "/" + test.limit
+		if test.wantErr {
+			assert.Error(t, err, what)
+			continue
+		}
+		require.NoError(t, err, what)
+		got := []string{}
+		for _, entry := range d.Entries {
+			got = append(got, entry.Leaf)
+		}
+		assert.Equal(t, test.want, got, what)
+	}
+}
+
+func TestWantJSON(t *testing.T) {
+	for _, test := range []struct {
+		url    string
+		accept string
+		want   bool
+	}{
+		{"/", "", false},
+		{"/?format=json", "", true},
+		{"/?format=html", "application/json", false},
+		{"/", "application/json", true},
+		{"/", "application/json;q=0.9, */*", true},
+		{"/", "text/html,application/xhtml+xml,application/json;q=0.9,*/*;q=0.8", false},
+		{"/", "*/*", false},
+	} {
+		r := httptest.NewRequest("GET", "http://example.com"+test.url, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		assert.Equal(t, test.want, wantJSON(r), test.url+" "+test.accept)
+	}
+}
+
+func TestServeJSON(t *testing.T) {
+	var modtime = time.Date(2001, 2, 3, 4, 5, 6, 7, time.UTC)
+	d := NewDirectory("aDirectory", GetTemplate(t))
+	d.AddHTMLEntry("aDirectory/file.txt", false, 64, modtime)
+	d.AddHTMLEntry("aDirectory/dir", true, 0, modtime)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com/aDirectory/", nil)
+	r.Header.Set("Accept", "application/json")
+	d.Serve(w, r)
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `[{"Path":"file.txt","Name":"file.txt","Size":64,"MimeType":"text/plain; charset=utf-8","ModTime":"2001-02-03T04:05:06.000000007Z","IsDir":false},{"Path":"dir","Name":"dir","Size":-1,"MimeType":"inode/directory","ModTime":"2001-02-03T04:05:06.000000007Z","IsDir":true}]
+`, string(body))
+}
@@ -31,15 +31,20 @@ to be used within the template to server pages:
 | .Order      | The current ordering used.  This is changeable via ?order= parameter |
 |             | Order Options: asc,desc (default asc) |
 | .Query      | Currently unused. |
+| .Search     | The search pattern if .Entries are search results.  This is set via ?search= parameter |
 | .Breadcrumb | Allows for creating a relative navigation |
 |-- .Link     | The relative to the root link of the Text. |
 |-- .Text     | The Name of the directory. |
 | .Entries    | Information about a specific file/directory. |
 |-- .URL      | The 'url' of an entry.  |
-|-- .Leaf     | Currently same as 'URL' but intended to be 'just' the name. |
+|-- .Leaf     | Currently same as 'URL' but intended to be 'just' the name. For search results the path relative to .Name. |
 |-- .IsDir    | Boolean for if an entry is a directory or not. |
 |-- .Size     | Size in Bytes of the entry. |
 |-- .ModTime  | The UTC timestamp of an entry. |
+| .CanUpload  | Boolean for if files may be uploaded to the directory. |
+| .CanMkdir   | Boolean for if directories may be made in the directory. |
+| .CanDelete  | Boolean for if entries may be deleted. |
+| .CanRename  | Boolean for if entries may be renamed. |
 `
 
 	tmpl, err := template.New("template help").Parse(help)
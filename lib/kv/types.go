@@ -29,6 +29,8 @@ type Bucket interface {
 // Cursor decouples bbolt.Cursor from key-val operations
 type Cursor interface {
 	First() ([]byte, []byte)
+	Last() ([]byte, []byte)
 	Next() ([]byte, []byte)
+	Prev() ([]byte, []byte)
 	Seek([]byte) ([]byte, []byte)
 }
@@ -0,0 +1,222 @@
+// Adaptive concurrency control
+
+package pacer
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Adaptive limits the number of concurrent operations, adjusting the
+// limit with additive increase, multiplicative decrease (AIMD) like
+// TCP congestion control does.
+//
+// The limit is halved, at most once per interval, when one of the
+// remotes it is registered for is rate limiting (eg 429 Too Many
+// Requests or 503 Service Unavailable). Otherwise Update
+// increases the limit by one if all the operations allowed were in
+// use and takes the increase back if it made the throughput worse.
+type Adaptive struct {
+	mu           sync.Mutex
+	cond         *sync.Cond
+	min          int           // minimum limit
+	max          int           // maximum limit
+	limit        int           // current limit
+	active       int           // number of operations in progress
+	interval     time.Duration // minimum time between decreases
+	lastDecrease time.Time     // time of the last decrease
+	rateLimited  bool          // set if rate limited since the last Update
+	busy         bool          // set if active reached limit since the last Update
+	increased    bool          // set if the last Update increased the limit
+	lastSpeed    float64       // throughput passed to the last Update
+	increases    int           // number of times the limit was increased
+	decreases    int           // number of times the limit was decreased
+	names        []string      // remotes registered for - use adaptiveMu
+}
+
+// AdaptiveStats describes the state of an Adaptive
+type AdaptiveStats struct {
+	Limit     int `json:"limit"`     // current limit
+	Min       int `json:"min"`       // minimum limit
+	Max       int `json:"max"`       // maximum limit
+	Active    int `json:"active"`    // operations in progress
+	Increases int `json:"increases"` // times the limit was increased
+	Decreases int `json:"decreases"` // times the limit was decreased
+}
+
+// NewAdaptive makes an Adaptive which starts with a limit of start
+// and varies it between min and max.
+//
+// It won't decrease the limit more often than interval which should
+// be the same as the interval Update is called at.
+func NewAdaptive(start, min, max int, interval time.Duration) *Adaptive {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	if start < min {
+		start = min
+	} else if start > max {
+		start = max
+	}
+	a := &Adaptive{
+		min:      min,
+		max:      max,
+		limit:    start,
+		interval: interval,
+	}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+// Get waits until another operation is allowed. Each Get must be
+// paired with a Put when the operation has finished.
+func (a *Adaptive) Get() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for a.active >= a.limit {
+		a.busy = true
+		a.cond.Wait()
+	}
+	a.active++
+	if a.active >= a.limit {
+		a.busy = true
+	}
+}
+
+// Put marks an operation started with Get as finished
+func (a *Adaptive) Put() {
+	a.mu.Lock()
+	a.active--
+	a.mu.Unlock()
+	a.cond.Signal()
+}
+
+// RateLimited halves the limit, unless it was decreased less than
+// interval ago.
+func (a *Adaptive) RateLimited() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rateLimited = true
+	now := time.Now()
+	if now.Sub(a.lastDecrease) < a.interval {
+		return
+	}
+	a.lastDecrease = now
+	a.decrease(a.limit / 2)
+}
+
+// decrease the limit to newLimit - call with lock held
+func (a *Adaptive) decrease(newLimit int) {
+	if newLimit < a.min {
+		newLimit = a.min
+	}
+	if newLimit < a.limit {
+		a.limit = newLimit
+		a.decreases++
+	}
+}
+
+// Update the limit given the current throughput in bytes/s.
+//
+// This should be called regularly, every interval.
+func (a *Adaptive) Update(speed float64) {
+	a.mu.Lock()
+	increased := false
+	switch {
+	case a.rateLimited:
+		// The limit was decreased already
+	case a.increased && speed < a.lastSpeed*0.9:
+		// The last increase made things worse so take it back
+		a.decrease(a.limit - 1)
+	case a.busy && a.limit < a.max:
+		a.limit++
+		a.increases++
+		increased = true
+	}
+	a.increased = increased
+	a.rateLimited = false
+	a.busy = a.active >= a.limit
+	a.lastSpeed = speed
+	a.mu.Unlock()
+	if increased {
+		a.cond.Broadcast()
+	}
+}
+
+// Limit returns the current limit
+func (a *Adaptive) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit
+}
+
+// Stats returns the current state
+func (a *Adaptive) Stats() AdaptiveStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return AdaptiveStats{
+		Limit:     a.limit,
+		Min:       a.min,
+		Max:       a.max,
+		Active:    a.active,
+		Increases: a.increases,
+		Decreases: a.decreases,
+	}
+}
+
+// The Adaptive controllers which are told when a remote rate limits
+var (
+	adaptiveMu    sync.Mutex
+	adaptives     = map[string]map[*Adaptive]struct{}{}
+	adaptiveCount int32 // len(adaptives) for reading without the lock
+)
+
+// Register makes a receive the rate limiting from the remotes called
+// names until Unregister is called.
+func (a *Adaptive) Register(names ...string) {
+	adaptiveMu.Lock()
+	defer adaptiveMu.Unlock()
+	for _, name := range names {
+		if adaptives[name] == nil {
+			adaptives[name] = map[*Adaptive]struct{}{}
+		}
+		adaptives[name][a] = struct{}{}
+	}
+	a.names = append(a.names, names...)
+	atomic.StoreInt32(&adaptiveCount, int32(len(adaptives)))
+}
+
+// Unregister stops a receiving the rate limiting from the remotes
+func (a *Adaptive) Unregister() {
+	adaptiveMu.Lock()
+	defer adaptiveMu.Unlock()
+	for _, name := range a.names {
+		delete(adaptives[name], a)
+		if len(adaptives[name]) == 0 {
+			delete(adaptives, name)
+		}
+	}
+	a.names = nil
+	atomic.StoreInt32(&adaptiveCount, int32(len(adaptives)))
+}
+
+// RateLimited tells the Adaptive controllers registered for the remote
+// called name that it is rate limiting.
+//
+// This is called by fs.Pacer when a call is retried because of a rate
+// limiting error such as HTTP 429 Too Many Requests or 503 Service
+// Unavailable.
+func RateLimited(name string) {
+	if atomic.LoadInt32(&adaptiveCount) == 0 {
+		return
+	}
+	adaptiveMu.Lock()
+	defer adaptiveMu.Unlock()
+	for a := range adaptives[name] {
+		a.RateLimited()
+	}
+}
@@ -0,0 +1,125 @@
+package pacer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAdaptive(t *testing.T) {
+	for _, test := range []struct {
+		start, min, max int
+		want            AdaptiveStats
+	}{
+		{4, 1, 8, AdaptiveStats{Limit: 4, Min: 1, Max: 8}},
+		{4, 0, 8, AdaptiveStats{Limit: 4, Min: 1, Max: 8}},
+		{0, 2, 8, AdaptiveStats{Limit: 2, Min: 2, Max: 8}},
+		{10, 2, 8, AdaptiveStats{Limit: 8, Min: 2, Max: 8}},
+		{4, 5, 3, AdaptiveStats{Limit: 5, Min: 5, Max: 5}},
+	} {
+		a := NewAdaptive(test.start, test.min, test.max, time.Second)
+		assert.Equal(t, test.want, a.Stats())
+	}
+}
+
+func TestAdaptiveGetPut(t *testing.T) {
+	a := NewAdaptive(2, 1, 4, time.Second)
+	a.Get()
+	a.Get()
+	assert.Equal(t, 2, a.Stats().Active)
+
+	// A third Get waits until a Put
+	got := make(chan struct{})
+	go func() {
+		a.Get()
+		close(got)
+	}()
+	select {
+	case <-got:
+		t.Fatal("Get didn't wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	a.Put()
+	<-got
+	assert.Equal(t, 2, a.Stats().Active)
+
+	// An increase in the limit lets a waiting Get through
+	got = make(chan struct{})
+	go func() {
+		a.Get()
+		close(got)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	a.Update(100)
+	<-got
+	assert.Equal(t, AdaptiveStats{Limit: 3, Min: 1, Max: 4, Active: 3, Increases: 1}, a.Stats())
+	a.Put()
+	a.Put()
+	a.Put()
+	assert.Equal(t, 0, a.Stats().Active)
+}
+
+func TestAdaptiveAIMD(t *testing.T) {
+	a := NewAdaptive(2, 1, 4, time.Hour)
+
+	// Not busy so no increase
+	a.Update(100)
+	assert.Equal(t, 2, a.Limit())
+
+	// Busy so increase up to max
+	a.Get()
+	a.Get()
+	a.Update(100)
+	assert.Equal(t, 3, a.Limit())
+	a.Get()
+	a.Update(200)
+	assert.Equal(t, 4, a.Limit())
+	a.Get()
+	a.Update(300)
+	assert.Equal(t, 4, a.Limit())
+
+	// Rate limiting halves the limit once per interval
+	a.RateLimited()
+	assert.Equal(t, 2, a.Limit())
+	a.RateLimited()
+	assert.Equal(t, 2, a.Limit())
+	a.Update(300)
+	assert.Equal(t, 2, a.Limit())
+	for i := 0; i < 4; i++ {
+		a.Put()
+	}
+
+	// An increase which makes the throughput worse is taken back
+	a.Get()
+	a.Get()
+	a.Update(300)
+	assert.Equal(t, 3, a.Limit())
+	a.Get()
+	a.Update(200)
+	assert.Equal(t, 2, a.Limit())
+	assert.Equal(t, AdaptiveStats{Limit: 2, Min: 1, Max: 4, Active: 3, Increases: 3, Decreases: 2}, a.Stats())
+}
+
+func TestAdaptiveRegister(t *testing.T) {
+	a := NewAdaptive(8, 1, 8, time.Hour)
+
+	// Not registered so not told about rate limiting
+	RateLimited("remote")
+	assert.Equal(t, 8, a.Limit())
+
+	a.Register("remote", "other")
+	RateLimited("unrelated")
+	assert.Equal(t, 8, a.Limit())
+	RateLimited("remote")
+	assert.Equal(t, 4, a.Limit())
+	a.lastDecrease = time.Time{}
+	RateLimited("other")
+	assert.Equal(t, 2, a.Limit())
+
+	a.Unregister()
+	a.lastDecrease = time.Time{}
+	RateLimited("remote")
+	assert.Equal(t, 2, a.Limit())
+	assert.Len(t, adaptives, 0)
+}